@@ -0,0 +1,34 @@
+// Command fake-gateway is a local payment gateway for trying out topups. It opens charges
+// in memory and, when one is paid or failed, sends a signed callback to the app. Callbacks
+// can be dropped to exercise the reconciliation job.
+//
+//	go run ./cmd/fake-gateway -secret sandbox-secret
+//	curl -X POST localhost:9091/charges/<reference>/pay
+package main
+
+import (
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"tahap2/internal/gateway"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9091", "address to listen on")
+	secret := flag.String("secret", "", "secret shared with the app, PAYMENT_GATEWAY_SECRET")
+	callbackURL := flag.String("callback", "http://localhost:8080/api/v1/gateway/sandbox/callback", "where payment callbacks are sent")
+	publicURL := flag.String("public-url", "http://localhost:9091", "base of the card payment urls handed out")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long a charge can be paid")
+	dropCallbacks := flag.Bool("drop-callbacks", false, "settle charges without calling back, leaving it to reconciliation")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("-secret is required")
+	}
+
+	g := gateway.NewFakeGateway(*secret, *callbackURL, *publicURL, *ttl, *dropCallbacks, slog.Default())
+	log.Printf("Fake gateway listening on %s, calling back %s", *addr, *callbackURL)
+	log.Fatal(http.ListenAndServe(*addr, g.Handler()))
+}
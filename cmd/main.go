@@ -1,45 +1,452 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"tahap2/internal/config"
+	"tahap2/internal/domain"
+	"tahap2/internal/gateway"
 	"tahap2/internal/handlers"
+	"tahap2/internal/health"
+	"tahap2/internal/logging"
+	"tahap2/internal/metrics"
 	"tahap2/internal/middlewares"
+	"tahap2/internal/openapi"
 	"tahap2/internal/repositories"
 	"tahap2/internal/services"
+	"tahap2/internal/storage"
+	"tahap2/internal/tracing"
+	"tahap2/internal/validation"
 	"tahap2/internal/workers"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+const (
+	// maxTransferBacklog is how many transfers may wait for the worker before the app stops
+	// reporting ready.
+	maxTransferBacklog = 1000
+	shutdownTimeout    = 30 * time.Second
 )
 
 func main() {
+	logger := logging.New(os.Stdout, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"))
+	// the standard log package, still used while starting up, writes through it too.
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), os.Getenv("TRACE_EXPORTER"), os.Stdout)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+
 	db := config.InitDB()
 	e := echo.New()
+	e.Validator = validation.New()
 	eventBus := workers.NewEventBus()
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("failed to get database pool: %v", err)
+	}
+	metrics.RegisterDBStats(sqlDB, "postgres")
+	for _, eventType := range []string{workers.EventTypeTransfer, workers.EventTypeWithdrawal} {
+		metrics.RegisterQueueDepth(eventType, func() int { return eventBus.Depth(eventType) })
+	}
+
+	transactor := repositories.NewTransactor(db)
 	userRepo := repositories.NewUserRepository(db)
 	transRepo := repositories.NewTransactionRepo(db)
-	authService := services.NewAuthService(userRepo)
+	metrics.RegisterPendingTransfers(transRepo.GetPendingTransferStats)
+	systemAccountRepo := repositories.NewSystemAccountRepo(db)
+	for code, name := range map[string]string{
+		domain.SystemAccountFeeRevenue:    "Fee revenue",
+		domain.SystemAccountClosurePayout: "Account closure payouts",
+		domain.SystemAccountDisbursements: "Bank disbursements",
+	} {
+		if _, err := systemAccountRepo.EnsureSystemAccount(context.Background(), code, name); err != nil {
+			log.Fatalf("failed to set up system account %s: %v", code, err)
+		}
+	}
+	auditService := services.NewAuditService(repositories.NewAuditRepo(db), logger)
+	auditHandler := handlers.NewAuditHandler(auditService)
+
+	authMiddleware := middlewares.NewAuthMiddleware(userRepo)
+	authService := services.NewAuthService(transactor, userRepo, auditService)
 	authHandler := handlers.NewAuthHandler(authService)
 
-	transService := services.NewTransactionService(userRepo, transRepo, eventBus)
+	feeService := services.NewFeeService(transactor, repositories.NewFeeRuleRepo(db), auditService)
+	limitService := services.NewLimitService(repositories.NewTransactionLimitRepo(db), userRepo, transRepo)
+	limitHandler := handlers.NewLimitHandler(limitService)
+	webhookRepo := repositories.NewWebhookRepo(db)
+	webhookService := services.NewWebhookService(webhookRepo, logger)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+
+	merchantRepo := repositories.NewMerchantRepo(db)
+	merchantService := services.NewMerchantService(transactor, merchantRepo, userRepo, transRepo, auditService, webhookService)
+	merchantHandler := handlers.NewMerchantHandler(merchantService)
+	merchantAuthMiddleware := middlewares.NewMerchantAuthMiddleware(merchantService)
+
+	transService := services.NewTransactionService(transactor, userRepo, transRepo, systemAccountRepo, merchantRepo, feeService, limitService, auditService, webhookService, eventBus,
+		os.Getenv("REQUIRE_VERIFIED_RECIPIENT") == "true")
 	transHandler := handlers.NewTransactionHandler(transService)
+	paymentGateway := gateway.NewSandboxGateway("sandbox", paymentGatewayURL(), secretEnv("PAYMENT_GATEWAY_SECRET"))
+	intentService := services.NewPaymentIntentService(transactor, repositories.NewPaymentIntentRepo(db), userRepo, limitService, transService, auditService, logger, paymentGateway)
+	topUpHandler := handlers.NewTopUpHandler(intentService)
+	disbursementProvider := newDisbursementProvider(logger)
+	withdrawalService := services.NewWithdrawalService(transactor, repositories.NewWithdrawalRepo(db), userRepo, transRepo, systemAccountRepo,
+		feeService, limitService, auditService, disbursementProvider, eventBus, logger)
+	withdrawalHandler := handlers.NewWithdrawalHandler(withdrawalService)
+	reconciliationService := services.NewReconciliationService(repositories.NewReconciliationRepo(db), auditService)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	qrHandler := handlers.NewQRHandler(services.NewQRService(transactor, repositories.NewQRRepo(db), merchantService, transService, logger))
+
+	scheduleRepo := repositories.NewScheduledTransferRepo(db)
+	scheduleService := services.NewScheduledTransferService(scheduleRepo, transService)
+	scheduleHandler := handlers.NewScheduledTransferHandler(scheduleService)
+
+	kycStore, err := storage.NewLocalBlobStore(kycStorageDir())
+	if err != nil {
+		log.Fatalf("failed to set up kyc document storage: %v", err)
+	}
+	statementStore, err := storage.NewLocalBlobStore(statementStorageDir())
+	if err != nil {
+		log.Fatalf("failed to set up statement storage: %v", err)
+	}
+	statementService := services.NewStatementService(repositories.NewStatementRepo(db), userRepo, statementStore, logger)
+	statementHandler := handlers.NewStatementHandler(statementService, logger)
+	kycCipher, err := repositories.NewFieldCipher(encryptionKeyEnv("KYC_ENCRYPTION_KEY"))
+	if err != nil {
+		log.Fatalf("invalid KYC_ENCRYPTION_KEY: %v", err)
+	}
+	kycRepo := repositories.NewKYCRepo(db, kycCipher)
+	if encrypted, err := kycRepo.EncryptIDNumbers(context.Background()); err != nil {
+		log.Fatalf("failed to encrypt kyc id numbers: %v", err)
+	} else if encrypted > 0 {
+		logger.Info("encrypted kyc id numbers stored in clear", "count", encrypted)
+	}
+	kycService := services.NewKYCService(transactor, kycRepo, userRepo, kycStore, auditService)
+	kycHandler := handlers.NewKYCHandler(kycService)
+
+	adminService := services.NewAdminService(transactor, userRepo, transRepo, systemAccountRepo, limitService, auditService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	if phoneNumber := os.Getenv("ADMIN_PHONE_NUMBER"); phoneNumber != "" {
+		if err = adminService.EnsureAdmin(context.Background(), phoneNumber); err != nil {
+			logger.Error("failed to promote admin", "phone_number", phoneNumber, "error", err)
+		}
+	}
+
+	// workers run until the server has shut down, so requests in flight can still publish.
+	// The scheduler publishes too, it runs on a context of its own to be stopped first.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workersDone sync.WaitGroup
+	startWorker := func(ctx context.Context, start func(ctx context.Context)) {
+		workersDone.Add(1)
+		go func() {
+			defer workersDone.Done()
+			start(ctx)
+		}()
+	}
+	schedulerCtx, stopScheduler := context.WithCancel(workerCtx)
+	var schedulerDone sync.WaitGroup
 
-	transferWorkers := workers.NewTransactionWorker(eventBus, userRepo, transRepo)
-	go transferWorkers.StartWorker()
+	transferWorkers := workers.NewTransactionWorker(eventBus, transactor, userRepo, transRepo, systemAccountRepo, limitService, auditService, webhookService, logger)
+	startWorker(workerCtx, transferWorkers.StartWorker)
 
+	webhookDispatcher := workers.NewWebhookDispatcher(webhookRepo, logger)
+	startWorker(workerCtx, webhookDispatcher.StartDispatcher)
+
+	transferScheduler := workers.NewTransferScheduler(scheduleRepo, transService, logger)
+	schedulerDone.Add(1)
+	go func() {
+		defer schedulerDone.Done()
+		transferScheduler.StartScheduler(schedulerCtx)
+	}()
+
+	topUpReconciler := workers.NewTopUpReconciler(intentService, logger)
+	startWorker(workerCtx, topUpReconciler.StartReconciler)
+
+	withdrawalWorker := workers.NewWithdrawalWorker(eventBus, withdrawalService, logger)
+	startWorker(workerCtx, withdrawalWorker.StartWorker)
+
+	statementArchiver := workers.NewStatementArchiver(statementService, logger)
+	startWorker(workerCtx, statementArchiver.StartArchiver)
+
+	ledgerReconciler := workers.NewLedgerReconciler(reconciliationService, logger)
+	startWorker(workerCtx, ledgerReconciler.StartReconciler)
+
+	checker := health.NewChecker()
+	checker.Register("database", sqlDB.PingContext)
+	checker.Register("migrations", func(ctx context.Context) error { return config.CheckMigrations(ctx, db) })
+	checker.Register("transfer_worker", transferWorkers.Check())
+	checker.Register("event_bus", func(context.Context) error {
+		if depth := eventBus.Depth(workers.EventTypeTransfer); depth > maxTransferBacklog {
+			return fmt.Errorf("%d transfers waiting for the worker", depth)
+		}
+		return nil
+	})
+	healthHandler := handlers.NewHealthHandler(checker, logger)
+
+	apiDocument := handlers.APIDocument()
+	apiSpec, err := apiDocument.Handler()
+	if err != nil {
+		log.Fatalf("failed to set up the openapi document: %v", err)
+	}
+	apiDocs := openapi.UIHandler("/docs", apiDocument.Info.Title, "/openapi.json")
+
+	var rateLimitStore domain.RateLimitStore = storage.NewMemoryRateLimitStore()
+	if os.Getenv("RATE_LIMIT_STORE") == domain.RateLimitStorePostgres {
+		rateLimitRepo := repositories.NewRateLimitRepo(db)
+		rateLimitStore = rateLimitRepo
+		startWorker(workerCtx, workers.NewRateLimitSweeper(rateLimitRepo, logger).StartSweeper)
+	}
+	// logins and registrations are counted per client and endpoint, so guessing pins is slow.
+	// They are refused while the store is failing, pins could be guessed without a limit.
+	authRateLimit := middlewares.NewRateLimitMiddleware(rateLimitStore, middlewares.RateLimitPolicy{
+		Name:       "auth",
+		Limit:      rateLimitEnv("RATE_LIMIT_AUTH", domain.RateLimit{Limit: 10, Period: time.Minute}),
+		Keys:       []middlewares.RateLimitKey{middlewares.KeyByIP, middlewares.KeyByRoute},
+		FailClosed: true,
+	}, logger)
+	moneyRateLimit := middlewares.NewRateLimitMiddleware(rateLimitStore, middlewares.RateLimitPolicy{
+		Name:  "money",
+		Limit: rateLimitEnv("RATE_LIMIT_MONEY", domain.RateLimit{Limit: 30, Period: time.Minute}),
+		Keys:  []middlewares.RateLimitKey{middlewares.KeyByUser, middlewares.KeyByRoute},
+	}, logger)
+
+	e.HideBanner = true
+	// X-Forwarded-For can be made up by anyone, it is only believed behind a proxy setting it.
+	e.IPExtractor = echo.ExtractIPDirect()
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	}
+	e.Use(otelecho.Middleware(tracing.ServiceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/ping", "/healthz", "/readyz", "/openapi.json", "/docs", "/docs/*":
+			return true
+		}
+		return false
+	})))
+	e.Use(middlewares.RequestIDMiddleware, middlewares.RequestMetaMiddleware, middlewares.NewRequestLoggerMiddleware(logger),
+		middlewares.MetricsMiddleware, middlewares.LanguageMiddleware)
+	// statements are streamed while they are rendered, big ones take longer than any other request.
+	e.Use(middlewares.NewTimeoutMiddleware(durationEnv("REQUEST_TIMEOUT", 15*time.Second), map[string]time.Duration{
+		"/api/v1/statements":                 durationEnv("STATEMENT_REQUEST_TIMEOUT", 2*time.Minute),
+		"/api/v1/admin/users/:id/statements": durationEnv("STATEMENT_REQUEST_TIMEOUT", 2*time.Minute),
+	}))
 	e.GET("/ping", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, echo.Map{"message": "pong"})
 	})
-	apiV1 := e.Group("/api/v1")
-	apiV1.POST("/register", authHandler.Register)
-	apiV1.POST("/login", authHandler.Login)
-	apiV1.PUT("/profile", authHandler.UpdateProfile, middlewares.AuthMiddleware)
+	e.GET("/healthz", healthHandler.Live)
+	e.GET("/readyz", healthHandler.Ready)
+	e.GET("/openapi.json", echo.WrapHandler(apiSpec))
+	e.GET("/docs", echo.WrapHandler(apiDocs))
+	e.GET("/docs/*", echo.WrapHandler(apiDocs))
+	handlers.RegisterRoutes(e.Group(handlers.APIPrefix), handlers.API{
+		Auth:                   authHandler,
+		TopUp:                  topUpHandler,
+		Transaction:            transHandler,
+		QR:                     qrHandler,
+		Limit:                  limitHandler,
+		Statement:              statementHandler,
+		Withdrawal:             withdrawalHandler,
+		KYC:                    kycHandler,
+		Schedule:               scheduleHandler,
+		Merchant:               merchantHandler,
+		Webhook:                webhookHandler,
+		Admin:                  adminHandler,
+		Audit:                  auditHandler,
+		Reconciliation:         reconciliationHandler,
+		Fee:                    handlers.NewFeeHandler(feeService),
+		AuthMiddleware:         authMiddleware,
+		MerchantAuthMiddleware: merchantAuthMiddleware,
+		AuthRateLimit:          authRateLimit,
+		MoneyRateLimit:         moneyRateLimit,
+	})
+
+	// a route added, moved or removed without its documentation stops the app from starting.
+	if err = apiDocument.CheckRoutes(e.Routes(), handlers.APIPrefix); err != nil {
+		log.Fatalf("openapi document and routes drifted apart:\n%v", err)
+	}
 
-	apiV1.POST("/topup", transHandler.TopupHandler, middlewares.AuthMiddleware)
-	apiV1.POST("/pay", transHandler.PaymentHandler, middlewares.AuthMiddleware)
-	apiV1.POST("/transfer", transHandler.TransferHandler, middlewares.AuthMiddleware)
-	apiV1.GET("/transactions", transHandler.GetAllTransactions, middlewares.AuthMiddleware)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serverErr := make(chan error, 2)
+	go func() {
+		logger.Info("listening", "addr", ":8080")
+		serverErr <- e.Start(":8080")
+	}()
+	// the metrics tell more than a client should know, they are served on a port of their own
+	// that is only reachable from inside the deployment.
+	metricsServer := &http.Server{Addr: metricsAddr(), Handler: metrics.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		logger.Info("serving metrics", "addr", metricsServer.Addr)
+		if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	exitCode := 0
+	select {
+	case err = <-serverErr:
+		logger.Error("server stopped", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		// reports not ready first, so load balancers stop sending requests before the
+		// server stops accepting them.
+		checker.ShutDown()
+		drainDelay := shutdownDrainDelay()
+		logger.Info("shutting down", "drain_delay", drainDelay)
+		time.Sleep(drainDelay)
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err = e.Shutdown(shutdownCtx); err != nil {
+			logger.Error("failed to shut down server", "error", err)
+			exitCode = 1
+		}
+		cancel()
+	}
+
+	// the transfers and withdrawals already published are left to the workers before they
+	// stop, they would otherwise stay pending until reconciliation reports them.
+	stopScheduler()
+	schedulerDone.Wait()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err = eventBus.Drain(drainCtx, workers.EventTypeTransfer, workers.EventTypeWithdrawal); err != nil {
+		logger.Error("failed to drain the event bus", "error", err)
+	}
+	cancelDrain()
+	stopWorkers()
+	workersDone.Wait()
+	metricsCtx, cancelMetrics := context.WithTimeout(context.Background(), shutdownTimeout)
+	if err = metricsServer.Shutdown(metricsCtx); err != nil {
+		logger.Error("failed to shut down metrics server", "error", err)
+	}
+	cancelMetrics()
+	// flushes the spans still batched.
+	if err = shutdownTracing(context.Background()); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
+	os.Exit(exitCode)
+}
+
+// metricsAddr is where the metrics are served, apart from the API so they are not public.
+func metricsAddr() string {
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		return addr
+	}
+	return ":9090"
+}
+
+// rateLimitEnv reads a rate limit like "10/1m" from the environment variable key, fallback
+// when it is unset or invalid.
+func rateLimitEnv(key string, fallback domain.RateLimit) domain.RateLimit {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	limit, err := domain.ParseRateLimit(value)
+	if err != nil {
+		slog.Warn("invalid rate limit, using the default", "key", key, "value", value, "error", err)
+		return fallback
+	}
+	return limit
+}
+
+// shutdownDrainDelay is how long the app keeps serving while reporting not ready, long
+// enough for load balancers to notice.
+func shutdownDrainDelay() time.Duration {
+	return durationEnv("SHUTDOWN_DRAIN_DELAY", 5*time.Second)
+}
+
+// secretEnv reads a secret callbacks are verified with. An empty one would let anyone forge
+// them, so the app refuses to start without it.
+func secretEnv(key string) string {
+	secret := os.Getenv(key)
+	if secret == "" {
+		log.Fatalf("%s must be set", key)
+	}
+	return secret
+}
+
+// encryptionKeyEnv reads a base64 encoded encryption key, the app refuses to start without a
+// valid one.
+func encryptionKeyEnv(key string) []byte {
+	encoded := secretEnv(key)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		log.Fatalf("%s must be base64 encoded: %v", key, err)
+	}
+	return decoded
+}
+
+// durationEnv reads a duration like "30s" from the environment variable key, fallback when it
+// is unset or invalid.
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return duration
+}
+
+func kycStorageDir() string {
+	if dir := os.Getenv("KYC_STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "./data/kyc"
+}
+
+func statementStorageDir() string {
+	if dir := os.Getenv("STATEMENT_STORAGE_DIR"); dir != "" {
+		return dir
+	}
+	return "./data/statements"
+}
+
+func paymentGatewayURL() string {
+	if url := os.Getenv("PAYMENT_GATEWAY_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:9091"
+}
+
+// newDisbursementProvider sets up the provider named by DISBURSEMENT_PROVIDER. The fake one
+// pays nothing out, it is only allowed when ALLOW_FAKE_PROVIDERS is set for development.
+func newDisbursementProvider(logger *slog.Logger) domain.DisbursementProvider {
+	switch name := os.Getenv("DISBURSEMENT_PROVIDER"); name {
+	case "fakebank":
+		if os.Getenv("ALLOW_FAKE_PROVIDERS") != "true" {
+			log.Fatalf("the fakebank disbursement provider is for development only, set ALLOW_FAKE_PROVIDERS=true to use it")
+		}
+		return gateway.NewFakeDisbursementProvider(name, secretEnv("DISBURSEMENT_SECRET"), disbursementCallbackURL(), 5*time.Second, logger)
+	case "":
+		log.Fatalf("DISBURSEMENT_PROVIDER must be set")
+	default:
+		log.Fatalf("unknown disbursement provider %q", name)
+	}
+	return nil
+}
 
-	e.Logger.Fatal(e.Start(":8080"))
+// disbursementCallbackURL is where the fake disbursement provider reports back, the app itself.
+func disbursementCallbackURL() string {
+	if url := os.Getenv("DISBURSEMENT_CALLBACK_URL"); url != "" {
+		return url
+	}
+	return "http://localhost:8080/api/v1/disbursement/fakebank/callback"
 }
@@ -0,0 +1,68 @@
+// Command reconcile runs one ledger reconciliation against DATABASE_URL and prints the
+// report. It exits with status 1 when it found drift or stuck transfers, so it can gate
+// scripts and cron jobs.
+//
+//	DATABASE_URL=... go run ./cmd/reconcile -stuck-after 1h -open-tasks
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"tahap2/internal/config"
+	"tahap2/internal/domain"
+	"tahap2/internal/repositories"
+	"tahap2/internal/services"
+	"time"
+)
+
+func main() {
+	stuckAfter := flag.Duration("stuck-after", 30*time.Minute, "report transfers pending for longer than this")
+	openTasks := flag.Bool("open-tasks", false, "open repair tasks for the findings")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	db := config.InitDB()
+	reconciliationService := services.NewReconciliationService(repositories.NewReconciliationRepo(db),
+		services.NewAuditService(repositories.NewAuditRepo(db), slog.Default()))
+
+	run, err := reconciliationService.Run(context.Background(), domain.ReconciliationOptions{StuckAfter: *stuckAfter, OpenRepairTasks: *openTasks})
+	if err != nil {
+		log.Fatalf("reconciliation failed: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(run); err != nil {
+			log.Fatalf("failed to write report: %v", err)
+		}
+	} else {
+		printReport(run)
+	}
+	if !run.IsClean() {
+		os.Exit(1)
+	}
+}
+
+func printReport(run domain.ReconciliationRun) {
+	fmt.Printf("Run %s, %d wallets checked in %s\n", run.ID, run.WalletsChecked, run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+
+	fmt.Printf("\nBalance mismatches: %d\n", len(run.Mismatches))
+	for _, m := range run.Mismatches {
+		fmt.Printf("  %-8s %s  balance %d  ledger %d  drift %+d\n", m.WalletType, m.WalletID, m.Balance, m.LedgerBalance, m.Drift)
+	}
+
+	fmt.Printf("\nTransfers pending for more than %s: %d\n", run.StuckAfter, len(run.StuckTransfers))
+	for _, t := range run.StuckTransfers {
+		fmt.Printf("  %s  user %s  amount %d  since %s\n", t.TransactionID, t.UserID, t.Amount, t.CreatedAt.Format(time.DateTime))
+	}
+
+	if run.RepairTasksOpened > 0 {
+		fmt.Printf("\nOpened %d repair tasks\n", run.RepairTasksOpened)
+	}
+}
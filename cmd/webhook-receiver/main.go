@@ -0,0 +1,55 @@
+// Command webhook-receiver is a local endpoint for trying out webhooks. It verifies the
+// signature of every request and prints the event, and can be told to fail the first
+// requests to exercise the retry schedule.
+//
+//	go run ./cmd/webhook-receiver -secret whsec_... -fail 2
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"tahap2/internal/domain"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9090", "address to listen on")
+	secret := flag.String("secret", "", "endpoint secret returned when the webhook was registered")
+	fail := flag.Int64("fail", 0, "answer the first n requests with 500")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "maximum age of the signed timestamp")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("-secret is required")
+	}
+
+	var received atomic.Int64
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		n := received.Add(1)
+		if !domain.VerifyWebhook(*secret, r.Header.Get(domain.WebhookHeaderSignature), r.Header.Get(domain.WebhookHeaderTimestamp), body, *tolerance) {
+			log.Printf("#%d rejected: invalid signature", n)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		if n <= *fail {
+			log.Printf("#%d failing on purpose: %s %s", n, r.Header.Get(domain.WebhookHeaderEvent), r.Header.Get(domain.WebhookHeaderID))
+			http.Error(w, "failing on purpose", http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("#%d %s %s %s", n, r.Header.Get(domain.WebhookHeaderEvent), r.Header.Get(domain.WebhookHeaderID), body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	log.Printf("Listening for webhooks on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"os"
 	"tahap2/internal/domain"
@@ -8,8 +10,113 @@ import (
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
+// models are the tables AutoMigrate keeps up to date.
+var models = []interface{}{
+	&domain.User{},
+	&domain.Transaction{},
+	&domain.ScheduledTransfer{},
+	&domain.ScheduledTransferRun{},
+	&domain.FeeRule{},
+	&domain.SystemAccount{},
+	&domain.TransactionLimit{},
+	&domain.KYCSubmission{},
+	&domain.AuditLog{},
+	&domain.Merchant{},
+	&domain.MerchantAPIKey{},
+	&domain.QRCode{},
+	&domain.WebhookEndpoint{},
+	&domain.WebhookDelivery{},
+	&domain.WebhookAttempt{},
+	&domain.PaymentIntent{},
+	&domain.BankAccount{},
+	&domain.Withdrawal{},
+	&domain.ReconciliationRun{},
+	&domain.RepairTask{},
+	&domain.StatementArchive{},
+	&domain.RateLimitBucket{},
+}
+
+// migration changes what AutoMigrate can't, such as existing indexes or rows. Each runs once,
+// in order, and is recorded in schema_migrations.
+type migration struct {
+	name string
+	sql  string
+}
+
+var migrations = []migration{
+	{
+		// phone numbers are looked up in E.164, numbers stored in a local form such as 08… are
+		// rewritten to it. Numbers that would then collide with another account's are left for
+		// support to merge.
+		name: "phone_numbers_e164",
+		sql: `WITH normalized AS (
+	SELECT id, CASE
+		WHEN p LIKE '0%' THEN '+62' || substr(p, 2)
+		WHEN p LIKE '62%' THEN '+' || p
+		ELSE p
+	END AS phone_number
+	FROM (SELECT id, regexp_replace(phone_number, '[ -]', '', 'g') AS p FROM users) AS stripped
+	WHERE p ~ '^\+?[0-9]+$'
+), distinct_numbers AS (
+	SELECT n.phone_number FROM normalized n
+	WHERE NOT EXISTS (SELECT 1 FROM users o WHERE o.phone_number = n.phone_number AND o.id <> n.id)
+	GROUP BY n.phone_number HAVING COUNT(*) = 1
+)
+UPDATE users u SET phone_number = n.phone_number
+FROM normalized n JOIN distinct_numbers USING (phone_number)
+WHERE u.id = n.id AND u.phone_number <> n.phone_number;
+UPDATE scheduled_transfers s SET target_phone_number = n.phone_number
+FROM (
+	SELECT id, CASE
+		WHEN p LIKE '0%' THEN '+62' || substr(p, 2)
+		WHEN p LIKE '62%' THEN '+' || p
+		ELSE p
+	END AS phone_number
+	FROM (SELECT id, regexp_replace(target_phone_number, '[ -]', '', 'g') AS p FROM scheduled_transfers) AS stripped
+	WHERE p ~ '^\+?[0-9]+$'
+) AS n
+WHERE s.id = n.id AND s.target_phone_number <> n.phone_number;`,
+	},
+}
+
+// runMigrations applies the migrations that haven't run yet, each in a transaction of its own.
+func runMigrations(db *gorm.DB) error {
+	err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	name TEXT PRIMARY KEY,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`).Error
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// the lock keeps instances starting together from running the same migration.
+			if err := tx.Exec("LOCK TABLE schema_migrations IN EXCLUSIVE MODE").Error; err != nil {
+				return err
+			}
+			var applied int64
+			if err := tx.Raw("SELECT COUNT(*) FROM schema_migrations WHERE name = ?", m.name).Scan(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				return nil
+			}
+			if err := tx.Exec(m.sql).Error; err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO schema_migrations (name) VALUES (?)", m.name).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
 func InitDB() *gorm.DB {
 	dsn := os.Getenv("DATABASE_URL")
 	connDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
@@ -17,6 +124,12 @@ func InitDB() *gorm.DB {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// every query gets a span, without its arguments since those hold pins and phone numbers.
+	err = connDB.Use(tracing.NewPlugin(tracing.WithoutQueryVariables(), tracing.WithoutMetrics()))
+	if err != nil {
+		log.Fatalf("failed to set up query tracing: %v", err)
+	}
+
 	db, err := connDB.DB()
 	if err != nil {
 		log.Panicf("failed to get database: %v", err)
@@ -31,10 +144,49 @@ func InitDB() *gorm.DB {
 	}
 
 	// auto migrate models
-	err = connDB.AutoMigrate(&domain.User{}, &domain.Transaction{})
+	err = connDB.AutoMigrate(models...)
 	if err != nil {
 		log.Fatalf("failed to migrate database: %v", err)
 	}
+	if err = runMigrations(connDB); err != nil {
+		log.Fatalf("failed to migrate database: %v", err)
+	}
+
+	// the audit log is append only, the database refuses to change or remove entries.
+	err = connDB.Exec(`
+CREATE OR REPLACE FUNCTION reject_audit_log_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_logs is append only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_logs
+	FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_log_change();
+`).Error
+	if err != nil {
+		log.Fatalf("failed to protect audit log: %v", err)
+	}
 
 	return connDB
 }
+
+// CheckMigrations fails when a table of the models or the audit log protection is missing, as
+// when the database was restored from a backup taken before a migration.
+func CheckMigrations(ctx context.Context, db *gorm.DB) error {
+	migrator := db.WithContext(ctx).Migrator()
+	for _, model := range models {
+		if !migrator.HasTable(model) {
+			return fmt.Errorf("table of %T is missing", model)
+		}
+	}
+
+	var triggers int64
+	err := db.WithContext(ctx).Raw("SELECT COUNT(*) FROM pg_trigger WHERE tgname = ?", "audit_logs_append_only").Scan(&triggers).Error
+	if err != nil {
+		return err
+	}
+	if triggers == 0 {
+		return fmt.Errorf("audit log protection is missing")
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// BalanceAdjustment is a manual correction of a user's balance by back-office staff.
+// A positive amount credits the wallet, a negative amount debits it.
+type BalanceAdjustment struct {
+	UserID  uuid.UUID
+	ActorID uuid.UUID
+	Amount  int64
+	Reason  string
+	// OverrideLimit credits the user even past the maximum balance of its tier.
+	OverrideLimit bool
+}
+
+type AdminService interface {
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]*User, error)
+	GetUser(ctx context.Context, userID uuid.UUID) (*User, error)
+	FreezeUser(ctx context.Context, actorID, userID uuid.UUID) (User, error)
+	UnfreezeUser(ctx context.Context, actorID, userID uuid.UUID) (User, error)
+	// CloseUser closes the account. A remaining balance is paid out to the closure payout
+	// account when payout is set, otherwise the balance must be zero.
+	CloseUser(ctx context.Context, actorID, userID uuid.UUID, reason string, payout bool) (User, error)
+	SetUserRole(ctx context.Context, actorID, userID uuid.UUID, role string) (User, error)
+	GetTransaction(ctx context.Context, transactionID uuid.UUID) (*Transaction, error)
+	GetUserTransactions(ctx context.Context, userID uuid.UUID) ([]*Transaction, error)
+	AdjustBalance(ctx context.Context, adjustment BalanceAdjustment) (Transaction, error)
+	// EnsureAdmin promotes the user registered with phoneNumber to admin, it is used to
+	// bootstrap the first back-office account.
+	EnsureAdmin(ctx context.Context, phoneNumber string) error
+}
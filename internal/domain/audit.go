@@ -0,0 +1,199 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditLogin              = "auth.login"
+	AuditLoginFailed        = "auth.login_failed"
+	AuditProfileUpdated     = "user.profile_updated"
+	AuditPinChanged         = "user.pin_changed"
+	AuditAccountClosed      = "user.account_closed"
+	AuditUserFrozen         = "admin.user_frozen"
+	AuditUserUnfrozen       = "admin.user_unfrozen"
+	AuditUserClosed         = "admin.user_closed"
+	AuditRoleChanged        = "admin.role_changed"
+	AuditBalanceAdjusted    = "admin.balance_adjusted"
+	AuditKYCApproved        = "admin.kyc_approved"
+	AuditKYCRejected        = "admin.kyc_rejected"
+	AuditTopUp              = "money.topup"
+	AuditPayment            = "money.payment"
+	AuditTransferCreated    = "money.transfer_created"
+	AuditTransferSettled    = "money.transfer_settled"
+	AuditTransferRejected   = "money.transfer_rejected"
+	AuditRefund             = "money.refund"
+	AuditWithdrawalHeld     = "money.withdrawal_held"
+	AuditWithdrawalCaptured = "money.withdrawal_captured"
+	AuditWithdrawalReleased = "money.withdrawal_released"
+
+	AuditMerchantCreated       = "admin.merchant_created"
+	AuditMerchantKeyRotated    = "admin.merchant_key_rotated"
+	AuditMerchantStatusChanged = "admin.merchant_status_changed"
+	AuditRepairTaskResolved    = "admin.repair_task_resolved"
+	AuditFeeRuleCreated        = "admin.fee_rule_created"
+	AuditFeeRuleUpdated        = "admin.fee_rule_updated"
+)
+
+// AuditLog is one entry of the append-only audit trail. Every entry carries the hash of
+// the previous one, so editing or removing a row breaks the chain from that point on.
+type AuditLog struct {
+	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Sequence    int64          `gorm:"uniqueIndex;not null" json:"sequence"`
+	EventType   string         `gorm:"not null;index" json:"event_type"`
+	ActorID     *uuid.UUID     `gorm:"type:uuid;index" json:"actor_id,omitempty"`
+	SubjectType string         `json:"subject_type,omitempty"`
+	SubjectID   string         `gorm:"index" json:"subject_id,omitempty"`
+	IPAddress   string         `json:"ip_address,omitempty"`
+	UserAgent   string         `json:"user_agent,omitempty"`
+	RequestID   string         `gorm:"index" json:"request_id,omitempty"`
+	Before      map[string]any `gorm:"type:jsonb;serializer:json" json:"before,omitempty"`
+	After       map[string]any `gorm:"type:jsonb;serializer:json" json:"after,omitempty"`
+	PrevHash    string         `gorm:"not null" json:"prev_hash"`
+	Hash        string         `gorm:"not null;uniqueIndex" json:"hash"`
+	CreatedAt   time.Time      `gorm:"not null;index" json:"created_at"`
+}
+
+// ComputeHash returns the hash of the entry chained to PrevHash. CreatedAt must already be
+// truncated to the precision of the database for the hash to be reproducible.
+func (a *AuditLog) ComputeHash() string {
+	actor := ""
+	if a.ActorID != nil {
+		actor = a.ActorID.String()
+	}
+	// json.Marshal sorts map keys, which keeps the diff encoding stable.
+	before, _ := json.Marshal(a.Before)
+	after, _ := json.Marshal(a.After)
+
+	h := sha256.New()
+	for _, part := range []string{
+		a.PrevHash,
+		strconv.FormatInt(a.Sequence, 10),
+		a.EventType,
+		actor,
+		a.SubjectType,
+		a.SubjectID,
+		a.IPAddress,
+		a.UserAgent,
+		a.RequestID,
+		string(before),
+		string(after),
+		a.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditState describes a money movement for the audit log.
+func (t Transaction) AuditState() map[string]any {
+	state := map[string]any{
+		"transaction_id": t.ID.String(),
+		"user_id":        t.UserID.String(),
+		"kind":           t.Kind,
+		"type":           t.TransactionType,
+		"status":         t.Status,
+		"amount":         t.Amount,
+		"fee":            t.Fee,
+		"balance_before": t.BalanceBefore,
+		"balance_after":  t.BalanceAfter,
+	}
+	if t.MerchantID != nil {
+		state["merchant_id"] = t.MerchantID.String()
+	}
+	return state
+}
+
+// AuditState describes a fee rule for the audit log.
+func (r FeeRule) AuditState() map[string]any {
+	state := map[string]any{
+		"transaction_kind": r.TransactionKind,
+		"user_tier":        r.UserTier,
+		"fee_type":         r.FeeType,
+		"flat_amount":      r.FlatAmount,
+		"percentage_bps":   r.PercentageBps,
+		"min_fee":          r.MinFee,
+		"max_fee":          r.MaxFee,
+		"active":           r.Active,
+	}
+	if len(r.Tiers) > 0 {
+		tiers := make([]map[string]any, len(r.Tiers))
+		for i, tier := range r.Tiers {
+			tiers[i] = map[string]any{"up_to": tier.UpTo, "flat_amount": tier.FlatAmount, "percentage_bps": tier.PercentageBps}
+		}
+		state["tiers"] = tiers
+	}
+	return state
+}
+
+// AuditEntry is what a service reports, request metadata is filled in from the context.
+type AuditEntry struct {
+	EventType   string
+	ActorID     uuid.UUID
+	SubjectType string
+	SubjectID   string
+	Before      map[string]any
+	After       map[string]any
+}
+
+type AuditFilter struct {
+	EventType string
+	ActorID   *uuid.UUID
+	SubjectID string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// AuditChainReport is the result of re-verifying the hash chain.
+type AuditChainReport struct {
+	Valid          bool   `json:"valid"`
+	EntriesChecked int64  `json:"entries_checked"`
+	BrokenAt       *int64 `json:"broken_at,omitempty"`
+}
+
+// RequestMeta describes the HTTP request an action originates from.
+type RequestMeta struct {
+	IPAddress string
+	UserAgent string
+	RequestID string
+}
+
+type requestMetaKey struct{}
+
+// WithRequestMeta returns a copy of ctx carrying meta.
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// RequestMetaFrom returns the request metadata stored in ctx, if any.
+func RequestMetaFrom(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+type AuditRepository interface {
+	// AppendAuditLog assigns the next sequence number and previous hash to entry, computes
+	// its hash and stores it. Appends are serialized so the chain never forks.
+	AppendAuditLog(ctx context.Context, entry *AuditLog) error
+	GetAuditLogs(ctx context.Context, filter AuditFilter) ([]*AuditLog, error)
+	// WalkAuditLogs calls fn for every entry in sequence order.
+	WalkAuditLogs(ctx context.Context, fn func(entry *AuditLog) error) error
+}
+
+type AuditService interface {
+	// Record appends an entry to the audit log. Failures are logged rather than returned,
+	// an audit outage must not undo the action it describes.
+	Record(ctx context.Context, entry AuditEntry)
+	GetAuditLogs(ctx context.Context, filter AuditFilter) ([]*AuditLog, error)
+	VerifyChain(ctx context.Context) (AuditChainReport, error)
+}
@@ -0,0 +1,118 @@
+package domain
+
+// Error is an error clients are told about. Its code identifies it in the message catalog,
+// so it can be answered in the client's language, the message is the English text.
+type Error struct {
+	code    string
+	message string
+}
+
+func NewError(code, message string) *Error {
+	return &Error{code: code, message: message}
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Code() string {
+	return e.code
+}
+
+var (
+	ErrPhoneNumberRegistered = NewError("phone_number_registered", "phone number already registered")
+	ErrPhoneNumberNotFound   = NewError("phone_number_not_found", "phone number not found")
+	ErrPinMismatch           = NewError("pin_mismatch", "Phone number and PIN doesn't match")
+
+	ErrUserNotFound        = NewError("user_not_found", "user not found")
+	ErrTargetUserNotFound  = NewError("target_user_not_found", "user target not found")
+	ErrInsufficientBalance = NewError("insufficient_balance", "insufficient balance")
+	ErrSelfTransfer        = NewError("self_transfer", "cannot transfer to your own account")
+	ErrRecipientUnverified = NewError("recipient_unverified", "recipient account is not verified")
+	ErrFeeExceedsAmount    = NewError("fee_exceeds_amount", "amount is too small to cover the fee")
+	ErrLimitExceeded       = NewError("limit_exceeded", "transaction limit exceeded")
+	ErrAmountOverflow      = NewError("amount_overflow", "amount is out of range")
+
+	ErrAccountFrozen     = NewError("account_frozen", "account is frozen")
+	ErrAccountClosed     = NewError("account_closed", "account is closed")
+	ErrRecipientClosed   = NewError("recipient_closed", "recipient account is closed")
+	ErrBalanceNotZero    = NewError("balance_not_zero", "balance must be zero to close the account")
+	ErrInvalidPin        = NewError("invalid_pin", "invalid PIN")
+	ErrInvalidPinFormat  = NewError("invalid_pin_format", "pin must be exactly 6 numeric digits")
+	ErrFeatureNotAllowed = NewError("feature_not_allowed", "this feature is not available for your account tier, please complete verification")
+
+	ErrKYCNotFound         = NewError("kyc_not_found", "kyc submission not found")
+	ErrKYCAlreadyPending   = NewError("kyc_already_pending", "a kyc submission is already waiting for review")
+	ErrKYCAlreadyReviewed  = NewError("kyc_already_reviewed", "kyc submission has already been reviewed")
+	ErrInvalidKYCTier      = NewError("invalid_kyc_tier", "requested_tier must be basic or full")
+	ErrInvalidIDNumber     = NewError("invalid_id_number", "id_number must be 16 digits")
+	ErrInvalidDateOfBirth  = NewError("invalid_date_of_birth", "date_of_birth must be a past date and the user at least 17 years old")
+	ErrKYCDocumentRequired = NewError("kyc_document_required", "a document image is required for the full tier")
+	ErrKYCRejectReason     = NewError("kyc_reject_reason", "a reason is required to reject a submission")
+	ErrUnsupportedDocument = NewError("unsupported_document", "document must be a JPEG, PNG or PDF file")
+
+	ErrTransactionNotFound = NewError("transaction_not_found", "transaction not found")
+	ErrReasonRequired      = NewError("reason_required", "a reason is required")
+	ErrInvalidAdjustment   = NewError("invalid_adjustment", "adjustment amount must not be zero")
+	ErrInvalidRole         = NewError("invalid_role", "role must be one of user, support, admin or auditor")
+	ErrSelfRoleChange      = NewError("self_role_change", "you cannot change your own role")
+
+	ErrMerchantNotFound     = NewError("merchant_not_found", "merchant not found")
+	ErrMerchantInactive     = NewError("merchant_inactive", "merchant is not accepting payments")
+	ErrMerchantNameRequired = NewError("merchant_name_required", "merchant name is required")
+	ErrInvalidMerchantState = NewError("invalid_merchant_state", "status must be active or suspended")
+	ErrInvalidAPIKey        = NewError("invalid_api_key", "invalid api key")
+	ErrNotRefundable        = NewError("not_refundable", "only successful payments can be refunded")
+	ErrRefundExceedsPayment = NewError("refund_exceeds_payment", "refund exceeds the refundable amount of the payment")
+	ErrInvalidAmount        = NewError("invalid_amount", "amount must be greater than zero")
+
+	ErrInvalidQR        = NewError("invalid_qr", "invalid qr code")
+	ErrQRNotFound       = NewError("qr_not_found", "qr code not found")
+	ErrQRUnavailable    = NewError("qr_unavailable", "qr code has already been paid or has expired")
+	ErrQRAmountMismatch = NewError("qr_amount_mismatch", "amount does not match the qr code")
+	ErrInvalidQRTTL     = NewError("invalid_qr_ttl", "expires_in must be between 1 minute and 24 hours")
+
+	ErrWebhookNotFound      = NewError("webhook_not_found", "webhook endpoint not found")
+	ErrDeliveryNotFound     = NewError("delivery_not_found", "webhook delivery not found")
+	ErrInvalidWebhookURL    = NewError("invalid_webhook_url", "url must be an absolute http or https url")
+	ErrWebhookURLNotPublic  = NewError("webhook_url_not_public", "url must resolve to a public address")
+	ErrInvalidWebhookEvents = NewError("invalid_webhook_events", "events must be one or more of transaction.succeeded, transaction.failed or refund.created")
+
+	ErrIntentNotFound        = NewError("intent_not_found", "payment intent not found")
+	ErrInvalidPaymentMethod  = NewError("invalid_payment_method", "method must be va or card")
+	ErrUnknownGateway        = NewError("unknown_gateway", "unknown payment gateway")
+	ErrInvalidCallback       = NewError("invalid_callback", "invalid gateway callback signature")
+	ErrGatewayAmountMismatch = NewError("gateway_amount_mismatch", "amount paid does not match the payment intent")
+
+	ErrBankAccountNotFound         = NewError("bank_account_not_found", "bank account not found")
+	ErrBankAccountExists           = NewError("bank_account_exists", "bank account is already saved")
+	ErrBankAccountRequired         = NewError("bank_account_required", "bank_code and account_number are required")
+	ErrBankAccountUnknown          = NewError("bank_account_unknown", "the bank does not know this account")
+	ErrWithdrawalNotFound          = NewError("withdrawal_not_found", "withdrawal not found")
+	ErrUnknownDisbursementProvider = NewError("unknown_disbursement_provider", "unknown disbursement provider")
+	ErrInvalidDisbursementCallback = NewError("invalid_disbursement_callback", "invalid disbursement callback signature")
+
+	ErrInvalidStatementMonth  = NewError("invalid_statement_month", "month must be YYYY-MM and not in the future")
+	ErrInvalidStatementFormat = NewError("invalid_statement_format", "format must be csv or pdf")
+	ErrMonthNotEnded          = NewError("month_not_ended", "month has not ended yet")
+
+	ErrFeeRuleNotFound    = NewError("fee_rule_not_found", "fee rule not found")
+	ErrFeeRuleConflict    = NewError("fee_rule_conflict", "another fee rule is already active for this transaction kind and tier")
+	ErrInvalidFeeKind     = NewError("invalid_fee_kind", "transaction_kind must be one of topup, payment, transfer or withdrawal")
+	ErrInvalidFeeTier     = NewError("invalid_fee_tier", "user_tier must be empty or one of unverified, basic or full")
+	ErrInvalidFeeType     = NewError("invalid_fee_type", "fee_type must be one of flat, percentage or tiered")
+	ErrInvalidFeeAmount   = NewError("invalid_fee_amount", "fee amounts must not be negative and percentages at most 10000 basis points")
+	ErrInvalidFeeCap      = NewError("invalid_fee_cap", "max_fee must be 0 or at least min_fee")
+	ErrInvalidFeeBrackets = NewError("invalid_fee_brackets", "tiered rules need tiers with distinct up_to values, other rules none")
+
+	ErrRunNotFound         = NewError("run_not_found", "reconciliation run not found")
+	ErrRepairTaskNotFound  = NewError("repair_task_not_found", "repair task not found")
+	ErrRepairTaskResolved  = NewError("repair_task_resolved", "repair task is already resolved")
+	ErrInvalidRepairStatus = NewError("invalid_repair_status", "status must be open or resolved")
+
+	ErrScheduleNotFound    = NewError("schedule_not_found", "scheduled transfer not found")
+	ErrInvalidFrequency    = NewError("invalid_frequency", "frequency must be one of once, daily, weekly or monthly")
+	ErrScheduleInPast      = NewError("schedule_in_past", "start_at must be in the future")
+	ErrInvalidScheduleEnd  = NewError("invalid_schedule_end", "end_at must be after start_at")
+	ErrScheduleNotEditable = NewError("schedule_not_editable", "scheduled transfer is no longer active")
+)
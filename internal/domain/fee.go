@@ -0,0 +1,200 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FeeTypeFlat       = "flat"
+	FeeTypePercentage = "percentage"
+	FeeTypeTiered     = "tiered"
+)
+
+// FeeRule describes how the fee of one transaction kind is computed. Rules with an empty
+// UserTier apply to every tier, a rule for the user's own tier takes precedence.
+// Percentages are expressed in basis points (1/100 of a percent) to keep money integral.
+// A kind has a single active rule per tier, so the fee doesn't depend on which of two rules
+// the database returns first.
+type FeeRule struct {
+	ID              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	TransactionKind string    `gorm:"not null;index;uniqueIndex:idx_fee_rules_active,where:active" json:"transaction_kind"`
+	UserTier        string    `gorm:"not null;default:'';uniqueIndex:idx_fee_rules_active" json:"user_tier"`
+	FeeType         string    `gorm:"not null" json:"fee_type"`
+	FlatAmount      int64     `gorm:"default:0;not null" json:"flat_amount"`
+	PercentageBps   int64     `gorm:"default:0;not null" json:"percentage_bps"`
+	Tiers           []FeeTier `gorm:"type:jsonb;serializer:json" json:"tiers,omitempty"`
+	MinFee          int64     `gorm:"default:0;not null" json:"min_fee"`
+	MaxFee          int64     `gorm:"default:0;not null" json:"max_fee"` // 0 means uncapped
+	Active          bool      `gorm:"default:true;not null" json:"active"`
+	CreatedAt       time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// FeeTier is one bracket of a tiered rule, it applies to amounts up to and including
+// UpTo. The bracket with UpTo 0 catches every amount above the other brackets.
+type FeeTier struct {
+	UpTo          int64 `json:"up_to"`
+	FlatAmount    int64 `json:"flat_amount"`
+	PercentageBps int64 `json:"percentage_bps"`
+}
+
+// MaxPercentageBps is the highest percentage a rule may charge, 100%.
+const MaxPercentageBps = 10000
+
+// Validate reports why the rule can't be used to charge fees, if anything.
+func (r *FeeRule) Validate() error {
+	switch r.TransactionKind {
+	case TransactionKindTopUp, TransactionKindPayment, TransactionKindTransfer, TransactionKindWithdrawal:
+	default:
+		return ErrInvalidFeeKind
+	}
+	if r.UserTier != "" && !IsUserTier(r.UserTier) {
+		return ErrInvalidFeeTier
+	}
+	if r.FlatAmount < 0 || r.MinFee < 0 || r.MaxFee < 0 || r.PercentageBps < 0 || r.PercentageBps > MaxPercentageBps {
+		return ErrInvalidFeeAmount
+	}
+	if r.MaxFee > 0 && r.MaxFee < r.MinFee {
+		return ErrInvalidFeeCap
+	}
+
+	switch r.FeeType {
+	case FeeTypeFlat, FeeTypePercentage:
+		if len(r.Tiers) > 0 {
+			return ErrInvalidFeeBrackets
+		}
+	case FeeTypeTiered:
+		if len(r.Tiers) == 0 {
+			return ErrInvalidFeeBrackets
+		}
+		seen := make(map[int64]bool, len(r.Tiers))
+		for _, tier := range r.Tiers {
+			if tier.UpTo < 0 || seen[tier.UpTo] {
+				return ErrInvalidFeeBrackets
+			}
+			seen[tier.UpTo] = true
+			if tier.FlatAmount < 0 || tier.PercentageBps < 0 || tier.PercentageBps > MaxPercentageBps {
+				return ErrInvalidFeeAmount
+			}
+		}
+	default:
+		return ErrInvalidFeeType
+	}
+	return nil
+}
+
+// Calculate returns the fee charged for amount under this rule.
+func (r *FeeRule) Calculate(amount int64) int64 {
+	var fee int64
+	switch r.FeeType {
+	case FeeTypeFlat:
+		fee = r.FlatAmount
+	case FeeTypePercentage:
+		fee = r.FlatAmount + percentOf(amount, r.PercentageBps)
+	case FeeTypeTiered:
+		tiers := make([]FeeTier, len(r.Tiers))
+		copy(tiers, r.Tiers)
+		sort.Slice(tiers, func(i, j int) bool {
+			if tiers[i].UpTo == 0 || tiers[j].UpTo == 0 {
+				return tiers[j].UpTo == 0 && tiers[i].UpTo != 0
+			}
+			return tiers[i].UpTo < tiers[j].UpTo
+		})
+		for _, tier := range tiers {
+			if tier.UpTo == 0 || amount <= tier.UpTo {
+				fee = tier.FlatAmount + percentOf(amount, tier.PercentageBps)
+				break
+			}
+		}
+	}
+
+	if fee < r.MinFee {
+		fee = r.MinFee
+	}
+	if r.MaxFee > 0 && fee > r.MaxFee {
+		fee = r.MaxFee
+	}
+	return fee
+}
+
+// percentOf returns bps basis points of amount, rounded up to the next unit. The amount
+// is split before multiplying so large amounts don't overflow.
+func percentOf(amount, bps int64) int64 {
+	whole := amount / 10000 * bps
+	rest := amount % 10000 * bps
+	result := whole + rest/10000
+	if rest%10000 != 0 {
+		result++
+	}
+	return result
+}
+
+const (
+	SystemAccountFeeRevenue    = "fee_revenue"
+	SystemAccountClosurePayout = "closure_payout"
+	SystemAccountDisbursements = "disbursements"
+)
+
+// SystemAccount is an internal wallet owned by the company, such as the account that
+// collects fee revenue. Its ledger lines are stored as transactions with its ID as UserID.
+type SystemAccount struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Code      string    `gorm:"unique;not null" json:"code"`
+	Name      string    `gorm:"not null" json:"name"`
+	Balance   int64     `gorm:"default:0;not null" json:"balance"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// NewFeeLine builds the revenue account's ledger line for the fee charged on parent.
+// revenue must already hold the balance after the fee was credited.
+func NewFeeLine(revenue *SystemAccount, parent Transaction) Transaction {
+	return Transaction{
+		Status:          TransactionStatusSuccess,
+		UserID:          revenue.ID,
+		TransactionType: TransactionTypeDebit,
+		Kind:            TransactionKindFee,
+		Amount:          parent.Fee,
+		Remark:          "fee for " + parent.Kind + " " + parent.ID.String(),
+		BalanceBefore:   revenue.Balance - parent.Fee,
+		BalanceAfter:    revenue.Balance,
+		ReferenceID:     &parent.ID,
+	}
+}
+
+type FeeRuleRepository interface {
+	GetActiveFeeRules(ctx context.Context, kind string) ([]*FeeRule, error)
+	// GetFeeRules returns the rules of kind, active or not, or of every kind when kind is empty.
+	GetFeeRules(ctx context.Context, kind string) ([]*FeeRule, error)
+	// GetFeeRuleByIDForUpdate loads the rule and locks its row until the surrounding
+	// transaction ends.
+	GetFeeRuleByIDForUpdate(ctx context.Context, id uuid.UUID) (*FeeRule, error)
+	// CreateFeeRule and UpdateFeeRule return ErrFeeRuleConflict when another rule is active
+	// for the same kind and tier.
+	CreateFeeRule(ctx context.Context, rule *FeeRule) error
+	UpdateFeeRule(ctx context.Context, rule *FeeRule) error
+}
+
+type SystemAccountRepository interface {
+	// EnsureSystemAccount creates the account identified by code when it doesn't exist yet.
+	EnsureSystemAccount(ctx context.Context, code, name string) (*SystemAccount, error)
+	// CreditSystemAccount locks the account, adds amount to its balance and returns it
+	// with the new balance.
+	CreditSystemAccount(ctx context.Context, code string, amount int64) (*SystemAccount, error)
+}
+
+type FeeCalculator interface {
+	CalculateFee(ctx context.Context, kind string, user *User, amount int64) (int64, error)
+}
+
+// FeeRuleService lets the back office see and change the fee rules.
+type FeeRuleService interface {
+	GetFeeRules(ctx context.Context, kind string) ([]*FeeRule, error)
+	CreateFeeRule(ctx context.Context, actorID uuid.UUID, rule FeeRule) (FeeRule, error)
+	// UpdateFeeRule replaces the rule with the ID of rule.
+	UpdateFeeRule(ctx context.Context, actorID uuid.UUID, rule FeeRule) (FeeRule, error)
+}
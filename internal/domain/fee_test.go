@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFeeRuleValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		rule FeeRule
+		want error
+	}{
+		{"flat", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypeFlat, FlatAmount: 2500}, nil},
+		{"percentage for a tier", FeeRule{TransactionKind: TransactionKindTopUp, UserTier: UserTierBasic, FeeType: FeeTypePercentage, PercentageBps: 150, MaxFee: 5000}, nil},
+		{"tiered", FeeRule{TransactionKind: TransactionKindWithdrawal, FeeType: FeeTypeTiered, Tiers: []FeeTier{{UpTo: 1_000_000, FlatAmount: 1000}, {FlatAmount: 2500}}}, nil},
+		{"fee kind", FeeRule{TransactionKind: TransactionKindFee, FeeType: FeeTypeFlat}, ErrInvalidFeeKind},
+		{"unknown tier", FeeRule{TransactionKind: TransactionKindTransfer, UserTier: "gold", FeeType: FeeTypeFlat}, ErrInvalidFeeTier},
+		{"unknown type", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: "free"}, ErrInvalidFeeType},
+		{"negative amount", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypeFlat, FlatAmount: -1}, ErrInvalidFeeAmount},
+		{"over 100%", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypePercentage, PercentageBps: 10001}, ErrInvalidFeeAmount},
+		{"cap below minimum", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypeFlat, MinFee: 2000, MaxFee: 1000}, ErrInvalidFeeCap},
+		{"tiered without tiers", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypeTiered}, ErrInvalidFeeBrackets},
+		{"two catch-all tiers", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypeTiered, Tiers: []FeeTier{{FlatAmount: 1}, {FlatAmount: 2}}}, ErrInvalidFeeBrackets},
+		{"flat with tiers", FeeRule{TransactionKind: TransactionKindTransfer, FeeType: FeeTypeFlat, Tiers: []FeeTier{{FlatAmount: 1}}}, ErrInvalidFeeBrackets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
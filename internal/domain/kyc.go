@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	KYCStatusPending  = "pending"
+	KYCStatusApproved = "approved"
+	KYCStatusRejected = "rejected"
+
+	FeatureTopUp    = "topup"
+	FeaturePayment  = "payment"
+	FeatureTransfer = "transfer"
+	FeatureWithdraw = "withdraw"
+)
+
+// tierFeatures lists what each account tier is allowed to do.
+var tierFeatures = map[string][]string{
+	UserTierUnverified: {FeatureTopUp, FeaturePayment},
+	UserTierBasic:      {FeatureTopUp, FeaturePayment, FeatureTransfer, FeatureWithdraw},
+	UserTierFull:       {FeatureTopUp, FeaturePayment, FeatureTransfer, FeatureWithdraw},
+}
+
+// TierAllows reports whether users of tier may use feature.
+func TierAllows(tier, feature string) bool {
+	if tier == "" {
+		tier = UserTierUnverified
+	}
+	for _, f := range tierFeatures[tier] {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// KYCSubmission is a request to be verified for a tier. A user has a single submission
+// waiting for review at a time.
+type KYCSubmission struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_kyc_submissions_pending_user,where:status = 'pending'" json:"user_id"`
+	RequestedTier string     `gorm:"not null" json:"requested_tier"`
+	IDNumber      string     `gorm:"not null" json:"id_number"`
+	DateOfBirth   time.Time  `gorm:"type:date;not null" json:"date_of_birth"`
+	DocumentKey   string     `json:"-"`
+	DocumentType  string     `json:"document_type,omitempty"`
+	Status        string     `gorm:"not null;index" json:"status"`
+	RejectReason  string     `json:"reject_reason,omitempty"`
+	ReviewedBy    *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// KYCDocument is an uploaded identity document.
+type KYCDocument struct {
+	ContentType string
+	Content     io.Reader
+}
+
+// BlobStore stores binary objects such as identity documents under a key.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type KYCRepository interface {
+	CreateSubmission(ctx context.Context, submission *KYCSubmission) error
+	GetSubmissionByID(ctx context.Context, id uuid.UUID) (*KYCSubmission, error)
+	GetLatestSubmissionByUserID(ctx context.Context, userID uuid.UUID) (*KYCSubmission, error)
+	GetSubmissionsByStatus(ctx context.Context, status string, limit, offset int) ([]*KYCSubmission, error)
+	UpdateSubmission(ctx context.Context, submission *KYCSubmission) error
+}
+
+type KYCService interface {
+	Submit(ctx context.Context, submission KYCSubmission, document *KYCDocument) (KYCSubmission, error)
+	GetLatestSubmission(ctx context.Context, userID uuid.UUID) (*KYCSubmission, error)
+	GetReviewQueue(ctx context.Context, status string, limit, offset int) ([]*KYCSubmission, error)
+	GetDocument(ctx context.Context, submissionID uuid.UUID) (io.ReadCloser, string, error)
+	Approve(ctx context.Context, reviewerID, submissionID uuid.UUID) (KYCSubmission, error)
+	Reject(ctx context.Context, reviewerID, submissionID uuid.UUID, reason string) (KYCSubmission, error)
+}
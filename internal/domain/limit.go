@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransactionLimit holds the limits of one user tier: how much may leave the wallet, how
+// much may be topped up and how much the wallet may hold. A zero value disables that
+// particular limit. The balance and topup caps default to those of unverified users, so
+// a tier configured before they existed isn't left without them.
+type TransactionLimit struct {
+	Tier              string    `gorm:"primaryKey" json:"tier"`
+	MaxPerTransaction int64     `gorm:"default:0;not null" json:"max_per_transaction"`
+	DailyOutgoing     int64     `gorm:"default:0;not null" json:"daily_outgoing"`
+	MonthlyOutgoing   int64     `gorm:"default:0;not null" json:"monthly_outgoing"`
+	MaxCountPerWindow int64     `gorm:"default:0;not null" json:"max_count_per_window"`
+	WindowSeconds     int64     `gorm:"default:0;not null" json:"window_seconds"`
+	MaxBalance        int64     `gorm:"default:2000000;not null" json:"max_balance"`
+	MonthlyTopUp      int64     `gorm:"default:5000000;not null" json:"monthly_topup"`
+	CreatedAt         time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// DefaultTransactionLimits are used for tiers that have no row in the database. No tier may
+// send out more in a day than its wallet can hold.
+var DefaultTransactionLimits = map[string]TransactionLimit{
+	UserTierUnverified: {
+		Tier:              UserTierUnverified,
+		MaxBalance:        2_000_000,
+		MonthlyTopUp:      5_000_000,
+		MaxPerTransaction: 1_000_000,
+		DailyOutgoing:     2_000_000,
+		MonthlyOutgoing:   5_000_000,
+		MaxCountPerWindow: 10,
+		WindowSeconds:     60,
+	},
+	UserTierBasic: {
+		Tier:              UserTierBasic,
+		MaxBalance:        10_000_000,
+		MonthlyTopUp:      20_000_000,
+		MaxPerTransaction: 5_000_000,
+		DailyOutgoing:     10_000_000,
+		MonthlyOutgoing:   50_000_000,
+		MaxCountPerWindow: 20,
+		WindowSeconds:     60,
+	},
+	UserTierFull: {
+		Tier:              UserTierFull,
+		MaxBalance:        20_000_000,
+		MonthlyTopUp:      100_000_000,
+		MaxPerTransaction: 20_000_000,
+		DailyOutgoing:     20_000_000,
+		MonthlyOutgoing:   100_000_000,
+		MaxCountPerWindow: 30,
+		WindowSeconds:     60,
+	},
+}
+
+// TransactionStats sums a user's transactions of some kinds since a point in time.
+type TransactionStats struct {
+	Total int64
+	Count int64
+}
+
+// RemainingLimits is what the user can still send out in the current day and month.
+type RemainingLimits struct {
+	Tier                  string `json:"tier"`
+	MaxPerTransaction     int64  `json:"max_per_transaction"`
+	DailyLimit            int64  `json:"daily_limit"`
+	DailyRemaining        int64  `json:"daily_remaining"`
+	MonthlyLimit          int64  `json:"monthly_limit"`
+	MonthlyRemaining      int64  `json:"monthly_remaining"`
+	WindowLimit           int64  `json:"window_limit"`
+	WindowRemaining       int64  `json:"window_remaining"`
+	WindowSeconds         int64  `json:"window_seconds"`
+	MaxBalance            int64  `json:"max_balance"`
+	BalanceRemaining      int64  `json:"balance_remaining"`
+	MonthlyTopUpLimit     int64  `json:"monthly_topup_limit"`
+	MonthlyTopUpRemaining int64  `json:"monthly_topup_remaining"`
+}
+
+// The limits a LimitExceededError can name.
+const (
+	LimitPerTransaction   = "per transaction"
+	LimitDaily            = "daily"
+	LimitMonthly          = "monthly"
+	LimitTransactionCount = "transaction count"
+	LimitMaxBalance       = "maximum balance"
+	LimitMonthlyTopUp     = "monthly topup"
+)
+
+// LimitExceededError tells which limit a transaction would break. Max and Remaining are
+// amounts, except for the transaction count limit where they count transactions made
+// within Window.
+type LimitExceededError struct {
+	Limit     string
+	Max       int64
+	Remaining int64
+	Window    time.Duration
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit exceeded: limit %d, remaining %d", e.Limit, e.Max, e.Remaining)
+}
+
+func (e *LimitExceededError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type TransactionLimitRepository interface {
+	GetLimitByTier(ctx context.Context, tier string) (*TransactionLimit, error)
+}
+
+type LimitService interface {
+	// CheckOutgoing returns a *LimitExceededError when user can't send amount out.
+	// It must run in the same transaction that locks the user's row.
+	CheckOutgoing(ctx context.Context, user *User, amount int64) error
+	// CheckIncoming returns a *LimitExceededError when crediting amount would take the
+	// user's balance over the tier's maximum balance. It must run in a transaction that
+	// locks the user's row.
+	CheckIncoming(ctx context.Context, user *User, amount int64) error
+	// CheckTopUp applies the monthly topup limit on top of CheckIncoming.
+	CheckTopUp(ctx context.Context, user *User, amount int64) error
+	GetRemainingLimits(ctx context.Context, userID uuid.UUID) (RemainingLimits, error)
+}
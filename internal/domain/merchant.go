@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	MerchantStatusActive    = "active"
+	MerchantStatusSuspended = "suspended"
+)
+
+// Merchant is a business that accepts wallet payments. Its balance is the settlement wallet
+// payments are credited to, with its own ledger lines like a system account.
+type Merchant struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Balance   int64     `gorm:"not null;default:0" json:"balance"`
+	Status    string    `gorm:"not null;default:active" json:"status"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (m *Merchant) IsActive() bool {
+	return m.Status == MerchantStatusActive
+}
+
+// MerchantAPIKey authenticates a merchant's server. Only the SHA-256 of the key is stored,
+// the key itself is shown once when it is issued.
+type MerchantAPIKey struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MerchantID uuid.UUID  `gorm:"type:uuid;not null;index" json:"merchant_id"`
+	Prefix     string     `gorm:"not null" json:"prefix"`
+	KeyHash    string     `gorm:"not null;uniqueIndex" json:"-"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// IssuedAPIKey is returned once when a key is created, Key is not retrievable afterwards.
+type IssuedAPIKey struct {
+	MerchantAPIKey
+	Key string `json:"key"`
+}
+
+// Refund asks for amount of a merchant payment to be returned to the payer.
+type Refund struct {
+	MerchantID uuid.UUID
+	PaymentID  uuid.UUID
+	Amount     int64
+	Reason     string
+}
+
+type MerchantRepository interface {
+	CreateMerchant(ctx context.Context, merchant *Merchant) error
+	GetMerchantByID(ctx context.Context, id uuid.UUID) (*Merchant, error)
+	GetMerchantByIDForUpdate(ctx context.Context, id uuid.UUID) (*Merchant, error)
+	UpdateMerchant(ctx context.Context, merchant *Merchant) error
+	CreateAPIKey(ctx context.Context, key *MerchantAPIKey) error
+	// GetActiveAPIKeyByHash returns the non revoked key with the given hash.
+	GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*MerchantAPIKey, error)
+	// RevokeAPIKeys revokes every active key of the merchant.
+	RevokeAPIKeys(ctx context.Context, merchantID uuid.UUID) error
+}
+
+type MerchantService interface {
+	// CreateMerchant creates the merchant along with its first API key.
+	CreateMerchant(ctx context.Context, actorID uuid.UUID, name string) (Merchant, IssuedAPIKey, error)
+	GetMerchant(ctx context.Context, merchantID uuid.UUID) (*Merchant, error)
+	// RotateAPIKey revokes the merchant's keys and issues a new one.
+	RotateAPIKey(ctx context.Context, actorID, merchantID uuid.UUID) (IssuedAPIKey, error)
+	SetMerchantStatus(ctx context.Context, actorID, merchantID uuid.UUID, status string) (Merchant, error)
+	// Authenticate resolves an API key to its active merchant.
+	Authenticate(ctx context.Context, apiKey string) (*Merchant, error)
+	// GetPayments lists the merchant's settlement ledger, payments and refunds, newest first.
+	GetPayments(ctx context.Context, merchantID uuid.UUID, limit, offset int) ([]*Transaction, error)
+	Refund(ctx context.Context, refund Refund) (Transaction, error)
+}
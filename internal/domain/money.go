@@ -0,0 +1,23 @@
+package domain
+
+import "math"
+
+// MaxAmount is the most a single request can move. It is far above the limit of any tier,
+// larger amounts are mistakes.
+const MaxAmount int64 = 1_000_000_000
+
+// AddAmounts adds a and b, returning ErrAmountOverflow instead of wrapping around.
+func AddAmounts(a, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, ErrAmountOverflow
+	}
+	return a + b, nil
+}
+
+// SubAmounts subtracts b from a, returning ErrAmountOverflow instead of wrapping around.
+func SubAmounts(a, b int64) (int64, error) {
+	if (b < 0 && a > math.MaxInt64+b) || (b > 0 && a < math.MinInt64+b) {
+		return 0, ErrAmountOverflow
+	}
+	return a - b, nil
+}
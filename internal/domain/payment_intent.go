@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	PaymentMethodVA   = "va"
+	PaymentMethodCard = "card"
+
+	IntentStatusPending = "pending"
+	IntentStatusPaid    = "paid"
+	IntentStatusFailed  = "failed"
+	IntentStatusExpired = "expired"
+
+	// GatewayHeaderSignature carries the signature of a gateway callback body.
+	GatewayHeaderSignature = "X-Gateway-Signature"
+)
+
+// PaymentIntent is a topup waiting to be paid through a payment gateway. The wallet is only
+// credited once the gateway confirms the payment.
+type PaymentIntent struct {
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Amount   int64     `gorm:"not null" json:"amount"`
+	Method   string    `gorm:"not null" json:"method"`
+	Provider string    `gorm:"not null" json:"provider"`
+	// ProviderRef is empty until the gateway has opened the charge.
+	ProviderRef   string     `gorm:"uniqueIndex:idx_payment_intents_provider_ref,where:provider_ref <> ''" json:"provider_ref"`
+	Status        string     `gorm:"not null;index" json:"status"`
+	VANumber      string     `json:"va_number,omitempty"`
+	PaymentURL    string     `json:"payment_url,omitempty"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	TransactionID *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (i *PaymentIntent) IsPending() bool {
+	return i.Status == IntentStatusPending
+}
+
+// GatewayCharge is what a gateway returns when a charge is opened.
+type GatewayCharge struct {
+	Reference  string
+	VANumber   string
+	PaymentURL string
+	ExpiresAt  time.Time
+}
+
+// GatewayChargeStatus is the state of a charge as reported by the gateway, either in a
+// callback or when queried. Status is one of the IntentStatus values, OrderID the id of the
+// intent the charge was opened for.
+type GatewayChargeStatus struct {
+	Reference string
+	OrderID   string
+	Status    string
+	Amount    int64
+}
+
+// PaymentGateway is a payment provider topups can be paid through.
+type PaymentGateway interface {
+	Name() string
+	CreateCharge(ctx context.Context, intent *PaymentIntent) (GatewayCharge, error)
+	GetChargeStatus(ctx context.Context, reference string) (GatewayChargeStatus, error)
+	// ParseCallback verifies the signature of a callback body and decodes it.
+	ParseCallback(signature string, body []byte) (GatewayChargeStatus, error)
+}
+
+type PaymentIntentRepository interface {
+	CreateIntent(ctx context.Context, intent *PaymentIntent) error
+	UpdateIntent(ctx context.Context, intent *PaymentIntent) error
+	GetIntentByID(ctx context.Context, id uuid.UUID) (*PaymentIntent, error)
+	// GetIntentByIDForUpdate loads the intent and locks its row until the surrounding
+	// transaction ends.
+	GetIntentByIDForUpdate(ctx context.Context, id uuid.UUID) (*PaymentIntent, error)
+	// GetIntentByProviderRefForUpdate loads the intent and locks its row until the
+	// surrounding transaction ends.
+	GetIntentByProviderRefForUpdate(ctx context.Context, provider, reference string) (*PaymentIntent, error)
+	// GetStalePendingIntents returns pending intents with an open charge not updated since
+	// olderThan, least recently updated first.
+	GetStalePendingIntents(ctx context.Context, olderThan time.Time, limit int) ([]*PaymentIntent, error)
+	// TouchPendingIntent bumps updated_at of an intent that is still pending, which moves it
+	// to the back of the stale queue.
+	TouchPendingIntent(ctx context.Context, id uuid.UUID) error
+}
+
+type PaymentIntentService interface {
+	// CreateTopUpIntent opens a charge with the gateway for method and stores it as pending.
+	CreateTopUpIntent(ctx context.Context, userID uuid.UUID, amount int64, method string) (PaymentIntent, error)
+	GetIntent(ctx context.Context, userID, intentID uuid.UUID) (*PaymentIntent, error)
+	// HandleCallback verifies and applies a gateway callback.
+	HandleCallback(ctx context.Context, provider, signature string, body []byte) error
+	// Reconcile asks the gateway about intents pending for longer than staleAfter and
+	// applies their current status. It returns how many intents changed.
+	Reconcile(ctx context.Context, staleAfter time.Duration, limit int) (int, error)
+}
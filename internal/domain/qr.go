@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	QRStatusActive = "active"
+	QRStatusUsed   = "used"
+
+	// QRAppID identifies this wallet inside the merchant account template of a payload.
+	QRAppID = "ID.TAHAP2.WALLET"
+
+	qrPointStatic  = "11"
+	qrPointDynamic = "12"
+	qrCurrencyIDR  = "360"
+	qrCountry      = "ID"
+	qrCity         = "JAKARTA"
+	qrCategoryCode = "5999"
+	qrMaxNameLen   = 25 // bytes
+)
+
+// EMV merchant presented QR data object ids.
+const (
+	qrTagFormat        = "00"
+	qrTagPoint         = "01"
+	qrTagMerchant      = "26"
+	qrTagCategory      = "52"
+	qrTagCurrency      = "53"
+	qrTagAmount        = "54"
+	qrTagCountry       = "58"
+	qrTagName          = "59"
+	qrTagCity          = "60"
+	qrTagAdditional    = "62"
+	qrTagCRC           = "63"
+	qrSubTagAppID      = "00"
+	qrSubTagMerchantID = "01"
+	qrSubTagReference  = "05"
+)
+
+// QRCode is a dynamic QR code, payable once for a fixed amount until it expires. Static
+// codes are derived from the merchant alone and are not stored.
+type QRCode struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MerchantID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"merchant_id"`
+	Amount        int64      `gorm:"not null" json:"amount"`
+	Status        string     `gorm:"not null;default:active" json:"status"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	PaidBy        *uuid.UUID `gorm:"type:uuid" json:"paid_by,omitempty"`
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// QRPayload is the content of a merchant QR code.
+type QRPayload struct {
+	Dynamic      bool
+	MerchantID   uuid.UUID
+	MerchantName string
+	Amount       int64
+	// ReferenceID is the id of the QRCode behind a dynamic payload.
+	ReferenceID uuid.UUID
+}
+
+// Encode renders the payload in the EMV merchant presented format, ending with its CRC.
+func (p QRPayload) Encode() string {
+	point := qrPointStatic
+	if p.Dynamic {
+		point = qrPointDynamic
+	}
+	// lengths are written in bytes, a name is cut on the last whole rune that fits.
+	name := p.MerchantName
+	for len(name) > qrMaxNameLen {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+
+	var b strings.Builder
+	writeTLV(&b, qrTagFormat, "01")
+	writeTLV(&b, qrTagPoint, point)
+	writeTLV(&b, qrTagMerchant, tlv(qrSubTagAppID, QRAppID)+tlv(qrSubTagMerchantID, p.MerchantID.String()))
+	writeTLV(&b, qrTagCategory, qrCategoryCode)
+	writeTLV(&b, qrTagCurrency, qrCurrencyIDR)
+	if p.Dynamic {
+		writeTLV(&b, qrTagAmount, strconv.FormatInt(p.Amount, 10))
+	}
+	writeTLV(&b, qrTagCountry, qrCountry)
+	writeTLV(&b, qrTagName, name)
+	writeTLV(&b, qrTagCity, qrCity)
+	if p.Dynamic {
+		writeTLV(&b, qrTagAdditional, tlv(qrSubTagReference, p.ReferenceID.String()))
+	}
+	// the CRC covers everything up to and including its own tag and length.
+	b.WriteString(qrTagCRC + "04")
+	b.WriteString(fmt.Sprintf("%04X", crc16CCITT([]byte(b.String()))))
+	return b.String()
+}
+
+// ParseQRPayload validates the CRC and structure of payload and extracts the fields this
+// wallet needs to pay it.
+func ParseQRPayload(payload string) (QRPayload, error) {
+	if len(payload) < 8 || payload[len(payload)-8:len(payload)-4] != qrTagCRC+"04" {
+		return QRPayload{}, ErrInvalidQR
+	}
+	crc := fmt.Sprintf("%04X", crc16CCITT([]byte(payload[:len(payload)-4])))
+	if !strings.EqualFold(crc, payload[len(payload)-4:]) {
+		return QRPayload{}, ErrInvalidQR
+	}
+
+	fields, err := parseTLV(payload[:len(payload)-8])
+	if err != nil || fields[qrTagFormat] != "01" || fields[qrTagCurrency] != qrCurrencyIDR {
+		return QRPayload{}, ErrInvalidQR
+	}
+
+	merchantInfo, err := parseTLV(fields[qrTagMerchant])
+	if err != nil || merchantInfo[qrSubTagAppID] != QRAppID {
+		return QRPayload{}, ErrInvalidQR
+	}
+	merchantID, err := uuid.Parse(merchantInfo[qrSubTagMerchantID])
+	if err != nil {
+		return QRPayload{}, ErrInvalidQR
+	}
+
+	p := QRPayload{MerchantID: merchantID, MerchantName: fields[qrTagName]}
+	switch fields[qrTagPoint] {
+	case qrPointStatic:
+	case qrPointDynamic:
+		p.Dynamic = true
+		p.Amount, err = strconv.ParseInt(fields[qrTagAmount], 10, 64)
+		if err != nil || p.Amount <= 0 {
+			return QRPayload{}, ErrInvalidQR
+		}
+		additional, err := parseTLV(fields[qrTagAdditional])
+		if err != nil {
+			return QRPayload{}, ErrInvalidQR
+		}
+		p.ReferenceID, err = uuid.Parse(additional[qrSubTagReference])
+		if err != nil {
+			return QRPayload{}, ErrInvalidQR
+		}
+	default:
+		return QRPayload{}, ErrInvalidQR
+	}
+	return p, nil
+}
+
+func tlv(tag, value string) string {
+	return fmt.Sprintf("%s%02d%s", tag, len(value), value)
+}
+
+func writeTLV(b *strings.Builder, tag, value string) {
+	b.WriteString(tlv(tag, value))
+}
+
+// parseTLV splits data into its top level data objects.
+func parseTLV(data string) (map[string]string, error) {
+	fields := map[string]string{}
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, ErrInvalidQR
+		}
+		length, err := strconv.Atoi(data[2:4])
+		if err != nil || len(data) < 4+length {
+			return nil, ErrInvalidQR
+		}
+		fields[data[:2]] = data[4 : 4+length]
+		data = data[4+length:]
+	}
+	return fields, nil
+}
+
+// crc16CCITT is the CRC-16/CCITT-FALSE checksum (polynomial 0x1021, initial value 0xFFFF)
+// EMV QR codes use.
+func crc16CCITT(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// QRPayment asks to pay a scanned payload. Amount is required for static codes and must
+// match the payload, if given, for dynamic ones.
+type QRPayment struct {
+	UserID  uuid.UUID
+	Payload string
+	Amount  int64
+	Remarks string
+}
+
+type QRRepository interface {
+	CreateQRCode(ctx context.Context, qr *QRCode) error
+	GetQRCodeByID(ctx context.Context, id uuid.UUID) (*QRCode, error)
+	// ClaimQRCode marks an active, unexpired code as used by userID. It reports false when
+	// the code was already used or has expired.
+	ClaimQRCode(ctx context.Context, id, userID uuid.UUID, now time.Time) (bool, error)
+	SetQRCodeTransaction(ctx context.Context, id, transactionID uuid.UUID) error
+}
+
+type QRService interface {
+	GetStaticQR(ctx context.Context, merchantID uuid.UUID) (string, error)
+	CreateDynamicQR(ctx context.Context, merchantID uuid.UUID, amount int64, ttl time.Duration) (QRCode, string, error)
+	// GetDynamicQR returns the code with its payload, only to the merchant that created it.
+	GetDynamicQR(ctx context.Context, merchantID, qrID uuid.UUID) (QRCode, string, error)
+	PayQR(ctx context.Context, payment QRPayment) (Transaction, error)
+}
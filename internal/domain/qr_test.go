@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// emvcoSample is the merchant presented payload of the EMVCo QR specification's example,
+// with its CRC of A13A. It isn't addressed to this wallet.
+const emvcoSample = "00020101021229300012D156000000000510A93FO3230Q31280012D15600000001030812345678520441115802CN" +
+	"5914BEST TRANSPORT6007BEIJING64200002ZH0104最佳运输0202北京540523.7253031565502016233030412340603***" +
+	"0708A60086670902ME91320016A0112233449988770708123456786304A13A"
+
+var (
+	testMerchantID  = uuid.MustParse("6f1c2a3b-4d5e-4f60-8a7b-9c0d1e2f3a4b")
+	testReferenceID = uuid.MustParse("0b9e8d7c-6b5a-4c3d-9e2f-1a0b9c8d7e6f")
+)
+
+func TestCRC16CCITT(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want uint16
+	}{
+		{"check value", "123456789", 0x29B1},
+		{"emvco sample", emvcoSample[:len(emvcoSample)-4], 0xA13A},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crc16CCITT([]byte(tt.data)); got != tt.want {
+				t.Errorf("crc16CCITT = %04X, want %04X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQRPayloadEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload QRPayload
+		want    string
+	}{
+		{
+			name:    "static",
+			payload: QRPayload{MerchantID: testMerchantID, MerchantName: "Warung Bu Sri"},
+			want: "000201010211" + "26600016ID.TAHAP2.WALLET01366f1c2a3b-4d5e-4f60-8a7b-9c0d1e2f3a4b" +
+				"52045999" + "5303360" + "5802ID" + "5913Warung Bu Sri" + "6007JAKARTA" + "6304FB42",
+		},
+		{
+			name:    "dynamic",
+			payload: QRPayload{Dynamic: true, MerchantID: testMerchantID, MerchantName: "Warung Bu Sri", Amount: 25000, ReferenceID: testReferenceID},
+			want: "000201010212" + "26600016ID.TAHAP2.WALLET01366f1c2a3b-4d5e-4f60-8a7b-9c0d1e2f3a4b" +
+				"52045999" + "5303360" + "540525000" + "5802ID" + "5913Warung Bu Sri" + "6007JAKARTA" +
+				"624005360b9e8d7c-6b5a-4c3d-9e2f-1a0b9c8d7e6f" + "6304DCBA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payload.Encode(); got != tt.want {
+				t.Errorf("Encode() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQRPayloadMerchantNameCut(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Warung Makan Sederhana Bu Sri", "Warung Makan Sederhana Bu"},
+		// 8 three byte runes fit in 25 bytes, the 9th would split.
+		{strings.Repeat("最", 40), strings.Repeat("最", 8)},
+		{"Kopi Kenangan Café Senayan", "Kopi Kenangan Café Senay"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := QRPayload{MerchantID: testMerchantID, MerchantName: tt.name}.Encode()
+			parsed, err := ParseQRPayload(encoded)
+			if err != nil {
+				t.Fatalf("ParseQRPayload: %v", err)
+			}
+			if parsed.MerchantName != tt.want || !utf8.ValidString(parsed.MerchantName) {
+				t.Errorf("name = %q, want %q", parsed.MerchantName, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQRPayload(t *testing.T) {
+	dynamic := QRPayload{Dynamic: true, MerchantID: testMerchantID, MerchantName: "Warung Bu Sri", Amount: 25000, ReferenceID: testReferenceID}
+	static := QRPayload{MerchantID: testMerchantID, MerchantName: "Warung Bu Sri"}
+
+	for _, want := range []QRPayload{static, dynamic} {
+		got, err := ParseQRPayload(want.Encode())
+		if err != nil {
+			t.Fatalf("ParseQRPayload(%+v): %v", want, err)
+		}
+		if got != want {
+			t.Errorf("ParseQRPayload = %+v, want %+v", got, want)
+		}
+	}
+
+	encoded := dynamic.Encode()
+	invalid := map[string]string{
+		"emvco sample":   emvcoSample,
+		"bad crc":        encoded[:len(encoded)-4] + "0000",
+		"amount changed": strings.Replace(encoded, "540525000", "540599000", 1),
+		"truncated":      encoded[:40],
+		"empty":          "",
+	}
+	for name, payload := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseQRPayload(payload); !errors.Is(err, ErrInvalidQR) {
+				t.Errorf("err = %v, want ErrInvalidQR", err)
+			}
+		})
+	}
+}
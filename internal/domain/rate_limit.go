@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+)
+
+// RateLimit is a token bucket holding Limit tokens, refilled evenly over Period. Every
+// request takes a token, so bursts of up to Limit requests pass.
+type RateLimit struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRateLimit reads a limit written as "<limit>/<period>", e.g. "10/1m".
+func ParseRateLimit(spec string) (RateLimit, error) {
+	limit, period, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected <limit>/<period>", spec)
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, the limit must be a positive number", spec)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, the period must be a positive duration", spec)
+	}
+	return RateLimit{Limit: n, Period: d}, nil
+}
+
+// RateLimitResult is what a request was told by the bucket.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+	// RetryAfter is how long until the next token, zero when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// Take refills a bucket that held tokens at last and takes a token from it if there is one.
+// It returns what the bucket holds afterwards, stores keep that with now as the new last.
+func (l RateLimit) Take(tokens float64, last, now time.Time) (float64, RateLimitResult) {
+	perToken := l.Period.Seconds() / float64(l.Limit)
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(l.Limit), tokens+elapsed/perToken)
+	}
+
+	result := RateLimitResult{Limit: l.Limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) * perToken)
+	}
+	result.Remaining = int(tokens)
+	result.ResetAfter = seconds((float64(l.Limit) - tokens) * perToken)
+	return tokens, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimitBucket is a token bucket shared by the replicas through the database. FullAt is
+// when it is full again, from then on it behaves the same as a new one and can be deleted.
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey"`
+	Tokens    float64   `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+	FullAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP;index"`
+}
+
+// RateLimitStore keeps the buckets. Take must be atomic per key, concurrent requests with the
+// same key can't take the same token.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// RateLimitBucketRepository removes the shared buckets nobody has taken from since they
+// filled up again.
+type RateLimitBucketRepository interface {
+	DeleteFullBuckets(ctx context.Context, now time.Time) (int64, error)
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitBurst(t *testing.T) {
+	limit := RateLimit{Limit: 5, Period: 10 * time.Second}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tokens := float64(limit.Limit)
+
+	var result RateLimitResult
+	for i := range limit.Limit {
+		tokens, result = limit.Take(tokens, now, now)
+		if !result.Allowed {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+		if want := limit.Limit - i - 1; result.Remaining != want {
+			t.Errorf("request %d: %d remaining, want %d", i+1, result.Remaining, want)
+		}
+	}
+	tokens, result = limit.Take(tokens, now, now)
+	if result.Allowed {
+		t.Fatal("request past the burst allowed")
+	}
+	// a token comes back every 2s, the bucket is full 10s after it was emptied.
+	if result.RetryAfter != 2*time.Second || result.ResetAfter != 10*time.Second {
+		t.Errorf("retry after %s, reset after %s, want 2s and 10s", result.RetryAfter, result.ResetAfter)
+	}
+	if tokens != 0 {
+		t.Errorf("a refused request took a token, %v left", tokens)
+	}
+}
+
+func TestRateLimitRefill(t *testing.T) {
+	limit := RateLimit{Limit: 5, Period: 10 * time.Second}
+	last := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		allowed bool
+		// left is what the bucket holds after the request.
+		left float64
+	}{
+		{"empty", 0, 0, false, 0},
+		{"before the next token", 0, 1500 * time.Millisecond, false, 0.75},
+		{"at the next token", 0, 2 * time.Second, true, 0},
+		{"two tokens later", 0, 4 * time.Second, true, 1},
+		{"refilled past the limit", 0, time.Hour, true, 4},
+		{"clock went back", 3, -time.Second, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, result := limit.Take(tt.tokens, last, last.Add(tt.elapsed))
+			if result.Allowed != tt.allowed || tokens != tt.left {
+				t.Errorf("allowed %v with %v left, want %v with %v", result.Allowed, tokens, tt.allowed, tt.left)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	limit, err := ParseRateLimit("10/1m")
+	if err != nil || limit != (RateLimit{Limit: 10, Period: time.Minute}) {
+		t.Errorf("ParseRateLimit(10/1m) = %+v, %v", limit, err)
+	}
+	for _, spec := range []string{"", "10", "0/1m", "-1/1m", "ten/1m", "10/0s", "10/-1m", "10/minute"} {
+		if _, err := ParseRateLimit(spec); err == nil {
+			t.Errorf("ParseRateLimit(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	WalletTypeUser     = "user"
+	WalletTypeMerchant = "merchant"
+	WalletTypeSystem   = "system"
+
+	RepairTaskBalanceDrift   = "balance_drift"
+	RepairTaskStuckTransfer  = "stuck_transfer"
+	RepairTaskStatusOpen     = "open"
+	RepairTaskStatusResolved = "resolved"
+)
+
+// BalanceMismatch is a wallet whose stored balance differs from the balance rebuilt from
+// its ledger lines. Drift is Balance minus LedgerBalance.
+type BalanceMismatch struct {
+	WalletID      uuid.UUID `json:"wallet_id"`
+	WalletType    string    `json:"wallet_type"`
+	Balance       int64     `json:"balance"`
+	LedgerBalance int64     `json:"ledger_balance"`
+	Drift         int64     `json:"drift"`
+}
+
+// StuckTransfer is a transfer that is still pending long after it was requested, its
+// worker event was most likely lost.
+type StuckTransfer struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	Amount        int64     `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ReconciliationRun is the report of one reconciliation pass.
+type ReconciliationRun struct {
+	ID                uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	StartedAt         time.Time         `gorm:"not null;index" json:"started_at"`
+	FinishedAt        time.Time         `gorm:"not null" json:"finished_at"`
+	WalletsChecked    int64             `gorm:"not null" json:"wallets_checked"`
+	StuckAfter        string            `gorm:"not null" json:"stuck_after"`
+	Mismatches        []BalanceMismatch `gorm:"type:jsonb;serializer:json" json:"mismatches"`
+	StuckTransfers    []StuckTransfer   `gorm:"type:jsonb;serializer:json" json:"stuck_transfers"`
+	RepairTasksOpened int               `gorm:"not null" json:"repair_tasks_opened"`
+}
+
+// IsClean reports whether the run found nothing to repair.
+func (r *ReconciliationRun) IsClean() bool {
+	return len(r.Mismatches) == 0 && len(r.StuckTransfers) == 0
+}
+
+// RepairTask is a finding of a reconciliation run waiting for back-office staff to fix
+// it, e.g. with a balance adjustment. At most one task is open per subject and kind.
+type RepairTask struct {
+	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	RunID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"run_id"`
+	Kind        string         `gorm:"not null;index:idx_repair_tasks_subject" json:"kind"`
+	SubjectType string         `gorm:"not null" json:"subject_type"`
+	SubjectID   uuid.UUID      `gorm:"type:uuid;not null;index:idx_repair_tasks_subject" json:"subject_id"`
+	Details     map[string]any `gorm:"type:jsonb;serializer:json" json:"details"`
+	Status      string         `gorm:"not null;index" json:"status"`
+	Resolution  string         `json:"resolution,omitempty"`
+	ResolvedBy  *uuid.UUID     `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time     `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+type ReconciliationOptions struct {
+	// StuckAfter is how long a transfer may stay pending before it is reported.
+	StuckAfter time.Duration
+	// OpenRepairTasks opens a repair task for every finding that has no open task yet.
+	OpenRepairTasks bool
+}
+
+type ReconciliationRepository interface {
+	// CountWallets returns how many user, merchant and system wallets exist.
+	CountWallets(ctx context.Context) (int64, error)
+	// GetBalanceMismatches rebuilds every wallet from its successful ledger lines, plus the
+	// pending withdrawal holds that already left the balance, and returns the ones that
+	// don't match. The comparison runs as one statement so it sees a consistent snapshot.
+	GetBalanceMismatches(ctx context.Context) ([]BalanceMismatch, error)
+	GetStuckTransfers(ctx context.Context, createdBefore time.Time) ([]StuckTransfer, error)
+
+	CreateRun(ctx context.Context, run *ReconciliationRun) error
+	GetRuns(ctx context.Context, limit, offset int) ([]*ReconciliationRun, error)
+	GetRunByID(ctx context.Context, id uuid.UUID) (*ReconciliationRun, error)
+
+	// HasOpenRepairTask reports whether subjectID already has an open task of kind.
+	HasOpenRepairTask(ctx context.Context, kind string, subjectID uuid.UUID) (bool, error)
+	CreateRepairTask(ctx context.Context, task *RepairTask) error
+	UpdateRepairTask(ctx context.Context, task *RepairTask) error
+	GetRepairTaskByID(ctx context.Context, id uuid.UUID) (*RepairTask, error)
+	GetRepairTasks(ctx context.Context, status string, limit, offset int) ([]*RepairTask, error)
+}
+
+type ReconciliationService interface {
+	Run(ctx context.Context, opts ReconciliationOptions) (ReconciliationRun, error)
+	GetRuns(ctx context.Context, limit, offset int) ([]*ReconciliationRun, error)
+	GetRun(ctx context.Context, runID uuid.UUID) (*ReconciliationRun, error)
+	GetRepairTasks(ctx context.Context, status string, limit, offset int) ([]*RepairTask, error)
+	ResolveRepairTask(ctx context.Context, actorID, taskID uuid.UUID, resolution string) (RepairTask, error)
+}
@@ -0,0 +1,69 @@
+package domain
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+
+	PermissionUsersRead        = "users:read"
+	PermissionUsersFreeze      = "users:freeze"
+	PermissionUsersClose       = "users:close"
+	PermissionUsersManageRoles = "users:manage_roles"
+	PermissionTransactionsRead = "transactions:read"
+	PermissionBalanceAdjust    = "balance:adjust"
+	PermissionKYCReview        = "kyc:review"
+	PermissionAuditRead        = "audit:read"
+	PermissionMerchantsRead    = "merchants:read"
+	PermissionMerchantsManage  = "merchants:manage"
+	PermissionWebhooksManage   = "webhooks:manage"
+	PermissionLedgerReconcile  = "ledger:reconcile"
+	PermissionFeesManage       = "fees:manage"
+)
+
+// rolePermissions lists what each back-office role may do. Plain users have no
+// back-office permissions.
+var rolePermissions = map[string][]string{
+	RoleSupport: {
+		PermissionUsersRead,
+		PermissionTransactionsRead,
+		PermissionKYCReview,
+		PermissionMerchantsRead,
+	},
+	RoleAuditor: {
+		PermissionUsersRead,
+		PermissionTransactionsRead,
+		PermissionAuditRead,
+		PermissionMerchantsRead,
+	},
+	RoleAdmin: {
+		PermissionUsersRead,
+		PermissionUsersFreeze,
+		PermissionUsersClose,
+		PermissionUsersManageRoles,
+		PermissionTransactionsRead,
+		PermissionBalanceAdjust,
+		PermissionKYCReview,
+		PermissionAuditRead,
+		PermissionMerchantsRead,
+		PermissionMerchantsManage,
+		PermissionWebhooksManage,
+		PermissionLedgerReconcile,
+		PermissionFeesManage,
+	},
+}
+
+// RoleHasPermission reports whether role grants permission.
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidRole reports whether role is one of the known roles.
+func IsValidRole(role string) bool {
+	return role == RoleUser || role == RoleSupport || role == RoleAdmin || role == RoleAuditor
+}
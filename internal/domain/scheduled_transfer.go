@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScheduleFrequencyOnce    = "once"
+	ScheduleFrequencyDaily   = "daily"
+	ScheduleFrequencyWeekly  = "weekly"
+	ScheduleFrequencyMonthly = "monthly"
+
+	ScheduleStatusActive    = "active"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusCancelled = "cancelled"
+
+	ScheduleRunStatusRunning = "running"
+	ScheduleRunStatusSuccess = "success"
+	ScheduleRunStatusFailed  = "failed"
+)
+
+type ScheduledTransfer struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TargetPhoneNumber string     `gorm:"not null" json:"target_phone_number"`
+	Amount            int64      `gorm:"not null" json:"amount"`
+	Remark            string     `gorm:"not null" json:"remark"`
+	Frequency         string     `gorm:"not null" json:"frequency"`
+	StartAt           time.Time  `gorm:"not null" json:"start_at"`
+	EndAt             *time.Time `json:"end_at"`
+	NextRunAt         time.Time  `gorm:"not null;index" json:"next_run_at"`
+	LastRunAt         *time.Time `json:"last_run_at"`
+	Status            string     `gorm:"not null;index" json:"status"`
+	CreatedAt         time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// ScheduledTransferRun records a single execution of a schedule. The unique index on
+// (schedule_id, scheduled_for) guarantees an occurrence is executed at most once even
+// when several replicas run the scheduler.
+type ScheduledTransferRun struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ScheduleID    uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_schedule_occurrence" json:"schedule_id"`
+	ScheduledFor  time.Time  `gorm:"not null;uniqueIndex:idx_schedule_occurrence" json:"scheduled_for"`
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id"` // given before the transfer is made, cleared when it fails
+	Status        string     `gorm:"not null" json:"status"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	Schedule *ScheduledTransfer `gorm:"foreignKey:ScheduleID" json:"-"`
+}
+
+// IsRecurring reports whether the schedule repeats after its first run.
+func (s *ScheduledTransfer) IsRecurring() bool {
+	return s.Frequency != ScheduleFrequencyOnce
+}
+
+// Advance moves NextRunAt to the first occurrence after now, so occurrences missed while no
+// scheduler was running are skipped instead of being run late one after another. The schedule
+// is completed when there is no occurrence left before EndAt.
+func (s *ScheduledTransfer) Advance(now time.Time) {
+	ranAt := s.NextRunAt
+	s.LastRunAt = &ranAt
+
+	next := s.NextRunAt
+	for {
+		switch s.Frequency {
+		case ScheduleFrequencyDaily:
+			next = next.AddDate(0, 0, 1)
+		case ScheduleFrequencyWeekly:
+			next = next.AddDate(0, 0, 7)
+		case ScheduleFrequencyMonthly:
+			next = addMonthClamped(next, s.StartAt.Day())
+		default:
+			s.Status = ScheduleStatusCompleted
+			return
+		}
+
+		if s.EndAt != nil && next.After(*s.EndAt) {
+			s.Status = ScheduleStatusCompleted
+			return
+		}
+		if next.After(now) {
+			break
+		}
+	}
+	s.NextRunAt = next
+}
+
+// addMonthClamped returns the same wall-clock time in the following month on anchorDay,
+// clamped to the last day of that month, so a schedule started on the 31st runs on the
+// 30th of April instead of spilling over into May.
+func addMonthClamped(t time.Time, anchorDay int) time.Time {
+	firstOfNext := time.Date(t.Year(), t.Month()+1, 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := firstOfNext.AddDate(0, 1, -1).Day()
+	day := anchorDay
+	if day > lastDay {
+		day = lastDay
+	}
+	return firstOfNext.AddDate(0, 0, day-1)
+}
+
+type ScheduledTransferRepository interface {
+	CreateSchedule(ctx context.Context, schedule *ScheduledTransfer) error
+	GetScheduleByID(ctx context.Context, id uuid.UUID) (*ScheduledTransfer, error)
+	GetSchedulesByUserID(ctx context.Context, userID uuid.UUID) ([]*ScheduledTransfer, error)
+	UpdateSchedule(ctx context.Context, schedule *ScheduledTransfer) error
+	// ClaimDueRuns locks active schedules due at or before now, records a run for each of
+	// them and advances them to their next occurrence. Schedules locked by another
+	// replica are skipped.
+	ClaimDueRuns(ctx context.Context, now time.Time, limit int) ([]*ScheduledTransferRun, error)
+	// ClaimStaleRuns locks runs still running since before staleBefore, left behind by a
+	// replica that stopped while executing them. Runs whose transfer was stored are recorded
+	// as succeeded, runs of cancelled schedules as failed, and the others are returned with
+	// their schedule to be executed again.
+	ClaimStaleRuns(ctx context.Context, staleBefore, now time.Time, limit int) ([]*ScheduledTransferRun, error)
+	UpdateRun(ctx context.Context, run *ScheduledTransferRun) error
+	GetRunsByScheduleID(ctx context.Context, scheduleID uuid.UUID) ([]*ScheduledTransferRun, error)
+}
+
+type ScheduledTransferService interface {
+	CreateSchedule(ctx context.Context, schedule ScheduledTransfer) (ScheduledTransfer, error)
+	GetSchedules(ctx context.Context, userID uuid.UUID) ([]*ScheduledTransfer, error)
+	GetSchedule(ctx context.Context, userID, scheduleID uuid.UUID) (*ScheduledTransfer, error)
+	UpdateSchedule(ctx context.Context, userID uuid.UUID, schedule ScheduledTransfer) (ScheduledTransfer, error)
+	CancelSchedule(ctx context.Context, userID, scheduleID uuid.UUID) error
+	GetScheduleRuns(ctx context.Context, userID, scheduleID uuid.UUID) ([]*ScheduledTransferRun, error)
+}
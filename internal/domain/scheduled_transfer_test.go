@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledTransferAdvance(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2026, month, d, 9, 0, 0, 0, time.UTC)
+	}
+	endAt := day(time.June, 1)
+
+	tests := []struct {
+		name       string
+		schedule   ScheduledTransfer
+		now        time.Time
+		wantNext   time.Time
+		wantStatus string
+	}{
+		{
+			name:       "next occurrence",
+			schedule:   ScheduledTransfer{Frequency: ScheduleFrequencyDaily, StartAt: day(time.March, 1), NextRunAt: day(time.March, 1)},
+			now:        day(time.March, 1),
+			wantNext:   day(time.March, 2),
+			wantStatus: ScheduleStatusActive,
+		},
+		{
+			name:       "missed occurrences are skipped",
+			schedule:   ScheduledTransfer{Frequency: ScheduleFrequencyWeekly, StartAt: day(time.March, 1), NextRunAt: day(time.March, 1)},
+			now:        day(time.March, 20),
+			wantNext:   day(time.March, 22),
+			wantStatus: ScheduleStatusActive,
+		},
+		{
+			name:       "monthly keeps its day",
+			schedule:   ScheduledTransfer{Frequency: ScheduleFrequencyMonthly, StartAt: day(time.January, 31), NextRunAt: day(time.January, 31)},
+			now:        day(time.March, 15),
+			wantNext:   day(time.March, 31),
+			wantStatus: ScheduleStatusActive,
+		},
+		{
+			name:       "once completes",
+			schedule:   ScheduledTransfer{Frequency: ScheduleFrequencyOnce, StartAt: day(time.March, 1), NextRunAt: day(time.March, 1)},
+			now:        day(time.March, 1),
+			wantNext:   day(time.March, 1),
+			wantStatus: ScheduleStatusCompleted,
+		},
+		{
+			name:       "completes past its end",
+			schedule:   ScheduledTransfer{Frequency: ScheduleFrequencyDaily, StartAt: day(time.March, 1), NextRunAt: day(time.March, 1), EndAt: &endAt},
+			now:        day(time.July, 1),
+			wantNext:   day(time.March, 1),
+			wantStatus: ScheduleStatusCompleted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := tt.schedule
+			schedule.Status = ScheduleStatusActive
+			schedule.Advance(tt.now)
+
+			if !schedule.NextRunAt.Equal(tt.wantNext) {
+				t.Errorf("NextRunAt = %v, want %v", schedule.NextRunAt, tt.wantNext)
+			}
+			if schedule.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", schedule.Status, tt.wantStatus)
+			}
+			if schedule.LastRunAt == nil || !schedule.LastRunAt.Equal(tt.schedule.NextRunAt) {
+				t.Errorf("LastRunAt = %v, want %v", schedule.LastRunAt, tt.schedule.NextRunAt)
+			}
+		})
+	}
+}
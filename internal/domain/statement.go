@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+const (
+	StatementFormatCSV = "csv"
+	StatementFormatPDF = "pdf"
+
+	// StatementMonthLayout is how statement months are written, e.g. 2026-09.
+	StatementMonthLayout = "2006-01"
+)
+
+// StatementArchive records that the statements of a closed month were generated and stored,
+// they are served from the blob store from then on.
+type StatementArchive struct {
+	ID             uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_statement_archives_month" json:"user_id"`
+	Month          string    `gorm:"not null;uniqueIndex:idx_statement_archives_month" json:"month"`
+	ClosingBalance int64     `gorm:"not null" json:"closing_balance"`
+	CreatedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// StatementTotal adds up the lines of one kind, In and Out are what they added to and took
+// from the balance including fees.
+type StatementTotal struct {
+	Kind  string
+	Count int
+	In    int64
+	Out   int64
+}
+
+// ParseStatementMonth parses a month in StatementMonthLayout and returns its first instant
+// in loc. Months that haven't started yet are rejected.
+func ParseStatementMonth(month string, loc *time.Location) (time.Time, error) {
+	start, err := time.ParseInLocation(StatementMonthLayout, month, loc)
+	if err != nil || start.After(time.Now()) {
+		return time.Time{}, ErrInvalidStatementMonth
+	}
+	return start, nil
+}
+
+// IsValidStatementFormat reports whether format is one of the StatementFormat values.
+func IsValidStatementFormat(format string) bool {
+	return format == StatementFormatCSV || format == StatementFormatPDF
+}
+
+type StatementRepository interface {
+	// GetBalanceAt rebuilds the balance of the wallet from its ledger lines created before t.
+	GetBalanceAt(ctx context.Context, userID uuid.UUID, t time.Time) (int64, error)
+	// StreamLines calls fn with each ledger line of the wallet created in [from, to), oldest
+	// first, without loading them all at once.
+	StreamLines(ctx context.Context, userID uuid.UUID, from, to time.Time, fn func(*Transaction) error) error
+	// GetUnarchivedUserIDs returns users with ledger lines in [from, to) whose statement for
+	// month hasn't been archived yet.
+	GetUnarchivedUserIDs(ctx context.Context, month string, from, to time.Time, limit int) ([]uuid.UUID, error)
+	GetArchive(ctx context.Context, userID uuid.UUID, month string) (*StatementArchive, error)
+	CreateArchive(ctx context.Context, archive *StatementArchive) error
+}
+
+type StatementService interface {
+	// WriteStatement writes the statement of the user for the month starting at month to w.
+	WriteStatement(ctx context.Context, userID uuid.UUID, month time.Time, format string, w io.Writer) error
+	// ArchiveMonth generates and stores the statements of up to limit users for the month
+	// starting at month, which must have ended. It returns how many were archived.
+	ArchiveMonth(ctx context.Context, month time.Time, limit int) (int, error)
+}
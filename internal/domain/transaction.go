@@ -6,28 +6,94 @@ import (
 	"time"
 )
 
+const (
+	TransactionStatusPending = "pending"
+	TransactionStatusSuccess = "success"
+	TransactionStatusFailed  = "failed"
+
+	// TransactionTypeDebit adds money to the account's balance, TransactionTypeCredit takes it out.
+	TransactionTypeDebit  = "DEBIT"
+	TransactionTypeCredit = "CREDIT"
+
+	TransactionKindTopUp      = "topup"
+	TransactionKindPayment    = "payment"
+	TransactionKindTransfer   = "transfer"
+	TransactionKindFee        = "fee"
+	TransactionKindAdjustment = "adjustment"
+	TransactionKindPayout     = "payout"
+	TransactionKindRefund     = "refund"
+	TransactionKindWithdrawal = "withdrawal"
+
+	// MaxRemarkLength is how many characters the remark of a transaction may have.
+	MaxRemarkLength = 140
+)
+
 type Transaction struct {
-	ID              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	Status          string    `gorm:"not null" json:"status"`
-	UserID          uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	TransactionType string    `gorm:"not null" json:"transaction_type"`
-	Amount          int64     `gorm:"not null" json:"amount"`
-	Remark          string    `gorm:"not null" json:"remark"`
-	BalanceBefore   int64     `gorm:"not null" json:"balance_before"`
-	BalanceAfter    int64     `gorm:"not null" json:"balance_after"`
-	CreatedAt       time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	ID              uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Status          string     `gorm:"not null" json:"status"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	TransactionType string     `gorm:"not null" json:"transaction_type"`
+	Kind            string     `gorm:"index" json:"kind"`
+	Amount          int64      `gorm:"not null" json:"amount"`
+	Fee             int64      `gorm:"default:0;not null" json:"fee"`
+	Remark          string     `gorm:"not null" json:"remark"`
+	BalanceBefore   int64      `gorm:"not null" json:"balance_before"`
+	BalanceAfter    int64      `gorm:"not null" json:"balance_after"`
+	ReferenceID     *uuid.UUID `gorm:"type:uuid;index" json:"reference_id,omitempty"` // parent of a fee, merchant or refund line
+	MerchantID      *uuid.UUID `gorm:"type:uuid;index" json:"merchant_id,omitempty"`
+	CreatedAt       time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// BalanceEffect is how much the line changed the balance of its wallet. Fees are taken out
+// of a DEBIT and added on top of a CREDIT.
+func (t *Transaction) BalanceEffect() int64 {
+	if t.TransactionType == TransactionTypeDebit {
+		return t.Amount - t.Fee
+	}
+	return -(t.Amount + t.Fee)
+}
+
+// TransferInquiry is the preview shown to the sender before a transfer is confirmed.
+type TransferInquiry struct {
+	RecipientName        string `json:"recipient_name"`
+	RecipientPhoneNumber string `json:"recipient_phone_number"`
+	Amount               int64  `json:"amount"`
+	Fee                  int64  `json:"fee"`
+	Total                int64  `json:"total"`
 }
 
 type TransactionService interface {
-	ProcessTopUp(userID uuid.UUID, amount int64) (Transaction, error)
-	ProcessPayment(userID uuid.UUID, amount int64, remarks string) (Transaction, error)
-	ProcessTransfer(userID, target uuid.UUID, amount int64, remarks string) (Transaction, error)
-	GetAllTransactions(userID uuid.UUID) ([]*Transaction, error)
+	// CreditTopUp credits a gateway-confirmed topup inside the transaction carried by ctx.
+	CreditTopUp(ctx context.Context, userID uuid.UUID, amount int64, remark string) (Transaction, error)
+	// ProcessPayment pays amount to the merchant, the fee is charged to the user on top.
+	ProcessPayment(ctx context.Context, userID, merchantID uuid.UUID, amount int64, remarks string) (Transaction, error)
+	// PayMerchant pays like ProcessPayment inside the transaction carried by ctx, the caller
+	// passes the payment to PaymentCommitted once that transaction has committed.
+	PayMerchant(ctx context.Context, userID, merchantID uuid.UUID, amount int64, remarks string) (Transaction, error)
+	PaymentCommitted(ctx context.Context, payment Transaction)
+	// ProcessTransfer stores the pending transfer under transactionID, or an id of the
+	// database's choosing when it is uuid.Nil. A caller retrying after a crash passes the id
+	// it chose before, to look up whether the transfer was made.
+	ProcessTransfer(ctx context.Context, userID uuid.UUID, targetPhoneNumber string, amount int64, remarks string, transactionID uuid.UUID) (Transaction, error)
+	InquiryTransfer(ctx context.Context, userID uuid.UUID, targetPhoneNumber string, amount int64) (TransferInquiry, error)
+	GetAllTransactions(ctx context.Context, userID uuid.UUID) ([]*Transaction, error)
 }
 
 type TransactionRepository interface {
 	GetTransactionByID(ctx context.Context, id uuid.UUID) (*Transaction, error)
+	// GetTransactionByIDForUpdate loads the transaction and locks its row until the
+	// surrounding transaction ends.
+	GetTransactionByIDForUpdate(ctx context.Context, id uuid.UUID) (*Transaction, error)
 	CreateTransaction(ctx context.Context, transaction *Transaction) error
 	GetTransactionsByUserID(ctx context.Context, userID uuid.UUID) ([]*Transaction, error)
 	UpdateTransaction(ctx context.Context, transaction *Transaction) error
+	// SumTransactions sums the user's pending and successful outgoing or incoming transactions
+	// of the given kinds created at or after since.
+	SumTransactions(ctx context.Context, userID uuid.UUID, transactionType string, kinds []string, since time.Time) (TransactionStats, error)
+	// SumChildTransactions sums the successful transactions of kind referencing referenceID.
+	SumChildTransactions(ctx context.Context, referenceID uuid.UUID, kind string) (int64, error)
+	GetTransactionsByUserIDPaged(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Transaction, error)
+	// GetPendingTransferStats counts the transfers waiting for the worker and returns when the
+	// oldest was created, the zero time when there is none.
+	GetPendingTransferStats(ctx context.Context) (int64, time.Time, error)
 }
@@ -0,0 +1,8 @@
+package domain
+
+import "context"
+
+// Transactor groups repository calls into a single database transaction.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
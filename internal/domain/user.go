@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,21 +16,105 @@ type User struct {
 	Address     string    `gorm:"not null"`
 	Pin         string    `gorm:"not null"`
 	Balance     int64     `gorm:"default:0;not null"`
+	Tier        string    `gorm:"default:unverified;not null"`
+	Role        string    `gorm:"default:user;not null"`
+	Status      string    `gorm:"default:active;not null;index"`
 	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	// ClosedAt is set when the account is closed. Closed accounts are never deleted so
+	// their transaction history stays available.
+	ClosedAt *time.Time `json:"closed_at"`
+	// Language is the language the user wants to be answered in, empty to follow the
+	// Accept-Language of each request.
+	Language string `gorm:"default:'';not null"`
+}
+
+const (
+	UserTierUnverified = "unverified"
+	UserTierBasic      = "basic"
+	UserTierFull       = "full"
+)
+
+// NormalizePhoneNumber turns the local forms of an Indonesian number, 08… and 628…, into
+// E.164 (+628…), without spaces or dashes. Anything that isn't a number is returned as is.
+func NormalizePhoneNumber(phoneNumber string) string {
+	number := strings.NewReplacer(" ", "", "-", "").Replace(phoneNumber)
+	digits := strings.TrimPrefix(number, "+")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return phoneNumber
+	}
+	switch {
+	case strings.HasPrefix(number, "0"):
+		return "+62" + number[1:]
+	case strings.HasPrefix(number, "62"):
+		return "+" + number
+	}
+	return number
+}
+
+// tierRanks orders the tiers from the least to the most verified, an empty tier is unverified.
+var tierRanks = map[string]int{UserTierUnverified: 0, UserTierBasic: 1, UserTierFull: 2}
+
+// TierAbove reports whether tier is more verified than other.
+func TierAbove(tier, other string) bool {
+	return tierRanks[tier] > tierRanks[other]
+}
+
+// IsUserTier reports whether tier is one of the user tiers.
+func IsUserTier(tier string) bool {
+	_, ok := tierRanks[tier]
+	return ok
+}
+
+// Frozen accounts can still log in and receive money but can't send any out, closed
+// accounts can't log in at all.
+const (
+	UserStatusActive = "active"
+	UserStatusFrozen = "frozen"
+	UserStatusClosed = "closed"
+)
+
+// IsVerified reports whether the user has passed at least the basic verification.
+func (u *User) IsVerified() bool {
+	return u.Tier != "" && u.Tier != UserTierUnverified
+}
+
+// CanSendMoney returns the reason the account may not move money out, if any.
+func (u *User) CanSendMoney() error {
+	switch u.Status {
+	case UserStatusFrozen:
+		return ErrAccountFrozen
+	case UserStatusClosed:
+		return ErrAccountClosed
+	default:
+		return nil
+	}
+}
+
+// IsClosed reports whether the account has been closed.
+func (u *User) IsClosed() bool {
+	return u.Status == UserStatusClosed
 }
 
 // UserRepository defines the methods for database operations
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *User) error
-	GetUserByPhoneNumber(phoneNumber string) (*User, error)
+	GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*User, error)
 	UpdateUser(ctx context.Context, user *User) error
-	GetUserByID(userID uuid.UUID) (*User, error)
+	GetUserByID(ctx context.Context, userID uuid.UUID) (*User, error)
+	GetUserByIDForUpdate(ctx context.Context, userID uuid.UUID) (*User, error)
+	// SearchUsers matches query against the phone number prefix and the first and last name.
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]*User, error)
 }
 
 // UserService defines the methods for business logic
 type UserService interface {
 	Register(ctx context.Context, user User) (User, error)
-	Login(ctx context.Context, phoneNumber, pin string) (string, error)
+	Login(ctx context.Context, phoneNumber, pin string) (User, error)
 	UpdateProfile(ctx context.Context, userID uuid.UUID, firstname, lastname, address string) (User, error)
+	// SetLanguage stores the language the user wants to be answered in, empty to follow Accept-Language.
+	SetLanguage(ctx context.Context, userID uuid.UUID, language string) (User, error)
+	ChangePin(ctx context.Context, userID uuid.UUID, oldPin, newPin string) error
+	// CloseAccount closes the user's own account after confirming the PIN. The balance must be zero.
+	CloseAccount(ctx context.Context, userID uuid.UUID, pin string) error
 }
@@ -0,0 +1,206 @@
+package domain
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WebhookEventTransactionSucceeded = "transaction.succeeded"
+	WebhookEventTransactionFailed    = "transaction.failed"
+	WebhookEventRefundCreated        = "refund.created"
+
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+
+	WebhookHeaderID        = "X-Webhook-Id"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+
+	// WebhookMaxAttempts is how often a delivery is tried before it is marked failed.
+	WebhookMaxAttempts = 8
+)
+
+// WebhookEventTypes are the events endpoints can subscribe to.
+var WebhookEventTypes = []string{
+	WebhookEventTransactionSucceeded,
+	WebhookEventTransactionFailed,
+	WebhookEventRefundCreated,
+}
+
+// WebhookEndpoint receives the events it subscribes to. Endpoints of a merchant only get
+// events about that merchant, partner endpoints (no merchant) get every event.
+type WebhookEndpoint struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	MerchantID *uuid.UUID `gorm:"type:uuid;index" json:"merchant_id,omitempty"`
+	URL        string     `gorm:"not null" json:"url"`
+	Secret     string     `gorm:"not null" json:"-"`
+	Events     []string   `gorm:"type:jsonb;serializer:json;not null" json:"events"`
+	Active     bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, event := range e.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event queued for one endpoint.
+type WebhookDelivery struct {
+	ID            uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	EndpointID    uuid.UUID         `gorm:"type:uuid;not null;index" json:"endpoint_id"`
+	EventID       uuid.UUID         `gorm:"type:uuid;not null;index" json:"event_id"`
+	EventType     string            `gorm:"not null" json:"event_type"`
+	Payload       string            `gorm:"type:text;not null" json:"payload"`
+	Status        string            `gorm:"not null;index" json:"status"`
+	Attempts      int               `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time         `gorm:"not null;index" json:"next_attempt_at"`
+	RedeliveryOf  *uuid.UUID        `gorm:"type:uuid" json:"redelivery_of,omitempty"`
+	DeliveredAt   *time.Time        `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time         `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt     time.Time         `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	AttemptLog    []*WebhookAttempt `gorm:"foreignKey:DeliveryID" json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt records a single HTTP call of a delivery.
+type WebhookAttempt struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	DeliveryID uuid.UUID `gorm:"type:uuid;not null;index" json:"delivery_id"`
+	Attempt    int       `gorm:"not null" json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// WebhookBackoff returns how long to wait before the attempt following attempt n:
+// 30 seconds, doubling every attempt up to 6 hours.
+func WebhookBackoff(n int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < n && backoff < 6*time.Hour; i++ {
+		backoff *= 2
+	}
+	return min(backoff, 6*time.Hour)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which some clouds serve metadata from.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// WebhookAddressAllowed reports whether webhooks may be sent to addr. Loopback, private and
+// link-local addresses lead into our own network instead of to a receiver on the internet,
+// so they are refused along with addresses nothing can be sent to.
+func WebhookAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// SignWebhook returns the signature header value for body sent at timestamp, an HMAC-SHA256
+// over "<timestamp>.<body>" keyed with the endpoint secret.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks a received signature and rejects timestamps further than tolerance
+// from now, which keeps captured requests from being replayed later.
+func VerifyWebhook(secret, signature, timestamp string, body []byte, tolerance time.Duration) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	expected := SignWebhook(secret, ts, body)
+	return hmac.Equal([]byte(expected), []byte(strings.TrimSpace(signature)))
+}
+
+// WebhookEvent is something that happened that endpoints may be told about.
+type WebhookEvent struct {
+	Type       string
+	MerchantID *uuid.UUID
+	Data       any
+}
+
+// WebhookTransaction is how a transaction is described to webhook receivers. Balances are
+// left out, they belong to the account holder only.
+type WebhookTransaction struct {
+	TransactionID string `json:"transaction_id"`
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Amount        int64  `json:"amount"`
+	Fee           int64  `json:"fee"`
+	Remarks       string `json:"remarks"`
+	MerchantID    string `json:"merchant_id,omitempty"`
+	ReferenceID   string `json:"reference_id,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
+
+func NewWebhookTransaction(t Transaction) WebhookTransaction {
+	data := WebhookTransaction{
+		TransactionID: t.ID.String(),
+		Kind:          t.Kind,
+		Status:        t.Status,
+		Amount:        t.Amount,
+		Fee:           t.Fee,
+		Remarks:       t.Remark,
+		CreatedAt:     t.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if t.MerchantID != nil {
+		data.MerchantID = t.MerchantID.String()
+	}
+	if t.ReferenceID != nil {
+		data.ReferenceID = t.ReferenceID.String()
+	}
+	return data
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	GetEndpointByID(ctx context.Context, id uuid.UUID) (*WebhookEndpoint, error)
+	// GetEndpoints lists the merchant's endpoints, or every endpoint when merchantID is nil.
+	GetEndpoints(ctx context.Context, merchantID *uuid.UUID) ([]*WebhookEndpoint, error)
+	// GetEndpointsForEvent returns the active endpoints of the merchant and all active
+	// partner endpoints.
+	GetEndpointsForEvent(ctx context.Context, merchantID *uuid.UUID) ([]*WebhookEndpoint, error)
+	UpdateEndpoint(ctx context.Context, endpoint *WebhookEndpoint) error
+	CreateDeliveries(ctx context.Context, deliveries []*WebhookDelivery) error
+	GetDeliveryByID(ctx context.Context, id uuid.UUID) (*WebhookDelivery, error)
+	GetDeliveriesByEndpoint(ctx context.Context, endpointID uuid.UUID, limit, offset int) ([]*WebhookDelivery, error)
+	// ClaimDueDeliveries picks pending deliveries whose attempt is due and pushes their next
+	// attempt back by lease, so other replicas skip them while they are being sent.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error
+	CreateAttempt(ctx context.Context, attempt *WebhookAttempt) error
+}
+
+// WebhookService manages endpoints on behalf of a merchant, or of the back office when
+// merchantID is nil. A merchant only sees its own endpoints and deliveries.
+type WebhookService interface {
+	// CreateEndpoint registers url for events. The returned secret is only shown once.
+	CreateEndpoint(ctx context.Context, merchantID *uuid.UUID, url string, events []string) (WebhookEndpoint, string, error)
+	GetEndpoints(ctx context.Context, merchantID *uuid.UUID) ([]*WebhookEndpoint, error)
+	DisableEndpoint(ctx context.Context, merchantID *uuid.UUID, endpointID uuid.UUID) error
+	GetDeliveries(ctx context.Context, merchantID *uuid.UUID, endpointID uuid.UUID, limit, offset int) ([]*WebhookDelivery, error)
+	// Redeliver queues a fresh delivery of the same event to the same endpoint.
+	Redeliver(ctx context.Context, merchantID *uuid.UUID, deliveryID uuid.UUID) (WebhookDelivery, error)
+	// Publish queues event for every subscribed endpoint. Failures are logged, not returned.
+	Publish(ctx context.Context, event WebhookEvent)
+}
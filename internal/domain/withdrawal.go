@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	WithdrawalStatusPending    = "pending"
+	WithdrawalStatusProcessing = "processing"
+	WithdrawalStatusSucceeded  = "succeeded"
+	WithdrawalStatusFailed     = "failed"
+
+	DisbursementStatusSucceeded = "succeeded"
+	DisbursementStatusFailed    = "failed"
+	DisbursementStatusTimeout   = "timeout"
+
+	// DisbursementHeaderSignature carries the signature of a disbursement callback body.
+	DisbursementHeaderSignature = "X-Disbursement-Signature"
+)
+
+// BankAccount is a bank account the user withdraws to. AccountName is what the bank
+// returned for the account when it was saved.
+type BankAccount struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bank_accounts_owner" json:"user_id"`
+	BankCode      string    `gorm:"not null;uniqueIndex:idx_bank_accounts_owner" json:"bank_code"`
+	AccountNumber string    `gorm:"not null;uniqueIndex:idx_bank_accounts_owner" json:"account_number"`
+	AccountName   string    `gorm:"not null" json:"account_name"`
+	CreatedAt     time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// Withdrawal moves money from the wallet to a bank account. The amount and fee are held,
+// taken off the balance and recorded as a pending transaction, when it is requested. The
+// hold is captured when the provider reports the disbursement succeeded and released back
+// to the wallet when it failed or timed out.
+type Withdrawal struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	BankAccountID uuid.UUID  `gorm:"type:uuid;not null" json:"bank_account_id"`
+	BankCode      string     `gorm:"not null" json:"bank_code"`
+	AccountNumber string     `gorm:"not null" json:"account_number"`
+	AccountName   string     `gorm:"not null" json:"account_name"`
+	Amount        int64      `gorm:"not null" json:"amount"`
+	Fee           int64      `gorm:"default:0;not null" json:"fee"`
+	Status        string     `gorm:"not null;index" json:"status"`
+	Provider      string     `gorm:"not null" json:"provider"`
+	ProviderRef   string     `json:"provider_ref,omitempty"`
+	FailureReason string     `json:"failure_reason,omitempty"`
+	TransactionID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"transaction_id"` // the hold
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP;index" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// IsSubmittable reports whether the withdrawal still has to be handed to the provider: it is
+// pending, or its submission was claimed before staleBefore and never got a reference, as
+// when the process died while submitting it.
+func (w *Withdrawal) IsSubmittable(staleBefore time.Time) bool {
+	switch w.Status {
+	case WithdrawalStatusPending:
+		return true
+	case WithdrawalStatusProcessing:
+		return w.ProviderRef == "" && w.UpdatedAt.Before(staleBefore)
+	default:
+		return false
+	}
+}
+
+// IsSettled reports whether the hold has been captured or released.
+func (w *Withdrawal) IsSettled() bool {
+	return w.Status == WithdrawalStatusSucceeded || w.Status == WithdrawalStatusFailed
+}
+
+// Disbursement is a payout handed to a provider. ExternalID is the withdrawal id, providers
+// use it to recognise retried requests and echo it back in callbacks.
+type Disbursement struct {
+	ExternalID    string
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+	Amount        int64
+}
+
+// DisbursementResult is the state of a disbursement as reported by a provider. Status is one
+// of the DisbursementStatus values, or empty while the provider is still working on it.
+type DisbursementResult struct {
+	ExternalID string
+	Reference  string
+	Status     string
+	Reason     string
+}
+
+// DisbursementProvider pays money out to bank accounts.
+type DisbursementProvider interface {
+	Name() string
+	// InquireAccount returns the name of the holder of a bank account.
+	InquireAccount(ctx context.Context, bankCode, accountNumber string) (string, error)
+	Disburse(ctx context.Context, disbursement Disbursement) (DisbursementResult, error)
+	// ParseCallback verifies the signature of a callback body and decodes it.
+	ParseCallback(signature string, body []byte) (DisbursementResult, error)
+}
+
+type WithdrawalRepository interface {
+	CreateBankAccount(ctx context.Context, account *BankAccount) error
+	GetBankAccountByID(ctx context.Context, id uuid.UUID) (*BankAccount, error)
+	GetBankAccountsByUserID(ctx context.Context, userID uuid.UUID) ([]*BankAccount, error)
+	DeleteBankAccount(ctx context.Context, id uuid.UUID) error
+
+	CreateWithdrawal(ctx context.Context, withdrawal *Withdrawal) error
+	UpdateWithdrawal(ctx context.Context, withdrawal *Withdrawal) error
+	GetWithdrawalByID(ctx context.Context, id uuid.UUID) (*Withdrawal, error)
+	// GetWithdrawalByIDForUpdate loads the withdrawal and locks its row until the
+	// surrounding transaction ends.
+	GetWithdrawalByIDForUpdate(ctx context.Context, id uuid.UUID) (*Withdrawal, error)
+	GetWithdrawalsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Withdrawal, error)
+	// GetPendingWithdrawals returns withdrawals that were never handed to the provider, and
+	// those that were claimed before staleBefore without getting a reference, oldest first.
+	GetPendingWithdrawals(ctx context.Context, staleBefore time.Time, limit int) ([]*Withdrawal, error)
+}
+
+type WithdrawalService interface {
+	InquireBankAccount(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (string, error)
+	AddBankAccount(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (BankAccount, error)
+	GetBankAccounts(ctx context.Context, userID uuid.UUID) ([]*BankAccount, error)
+	RemoveBankAccount(ctx context.Context, userID, bankAccountID uuid.UUID) error
+
+	// RequestWithdrawal places the hold and queues the withdrawal for the worker.
+	RequestWithdrawal(ctx context.Context, userID, bankAccountID uuid.UUID, amount int64) (Withdrawal, error)
+	GetWithdrawal(ctx context.Context, userID, withdrawalID uuid.UUID) (*Withdrawal, error)
+	GetWithdrawals(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Withdrawal, error)
+	// Submit hands a pending withdrawal to the provider, it is called by the worker.
+	Submit(ctx context.Context, withdrawalID uuid.UUID) error
+	// SubmitPending submits withdrawals that are still pending, e.g. because the process
+	// stopped before the worker got to them or the provider couldn't be reached, and those
+	// whose submission was interrupted.
+	SubmitPending(ctx context.Context) error
+	// HandleCallback verifies a provider callback and captures or releases the hold.
+	HandleCallback(ctx context.Context, provider, signature string, body []byte) error
+}
@@ -0,0 +1,146 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"tahap2/internal/domain"
+	"time"
+)
+
+// FakeDisbursementProvider is an in-process disbursement provider for local testing. It
+// accepts every disbursement and reports the outcome through a signed callback after delay,
+// decided by the last digit of the account number:
+//
+//	...1  the bank rejects the transfer (failed)
+//	...2  the bank never answers (timeout)
+//	else  the money arrives (succeeded)
+//
+// Account numbers ending in 000 don't exist at the bank.
+type FakeDisbursementProvider struct {
+	name        string
+	secret      string
+	callbackURL string
+	delay       time.Duration
+	client      *http.Client
+	logger      *slog.Logger
+
+	mu   sync.Mutex
+	seen map[string]domain.DisbursementResult
+}
+
+// fakeDisbursementCallback is the callback body the fake provider sends.
+type fakeDisbursementCallback struct {
+	ExternalID string `json:"external_id"`
+	Reference  string `json:"reference"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+func NewFakeDisbursementProvider(name, secret, callbackURL string, delay time.Duration, logger *slog.Logger) *FakeDisbursementProvider {
+	return &FakeDisbursementProvider{
+		name:        name,
+		secret:      secret,
+		callbackURL: callbackURL,
+		delay:       delay,
+		client:      &http.Client{Timeout: sandboxTimeout},
+		logger:      logger,
+		seen:        map[string]domain.DisbursementResult{},
+	}
+}
+
+func (p *FakeDisbursementProvider) Name() string {
+	return p.name
+}
+
+func (p *FakeDisbursementProvider) InquireAccount(ctx context.Context, bankCode, accountNumber string) (string, error) {
+	if strings.HasSuffix(accountNumber, "000") {
+		return "", domain.ErrBankAccountUnknown
+	}
+	return "FAKE HOLDER " + strings.ToUpper(bankCode) + " " + accountNumber[max(len(accountNumber)-4, 0):], nil
+}
+
+func (p *FakeDisbursementProvider) Disburse(ctx context.Context, disbursement domain.Disbursement) (domain.DisbursementResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// a repeated external id gets the disbursement that was already accepted.
+	if result, ok := p.seen[disbursement.ExternalID]; ok {
+		return result, nil
+	}
+	result := domain.DisbursementResult{
+		ExternalID: disbursement.ExternalID,
+		Reference:  "fd_" + randomHex(8),
+	}
+	p.seen[disbursement.ExternalID] = result
+
+	outcome := fakeDisbursementCallback{
+		ExternalID: result.ExternalID,
+		Reference:  result.Reference,
+		Status:     domain.DisbursementStatusSucceeded,
+	}
+	switch {
+	case strings.HasSuffix(disbursement.AccountNumber, "1"):
+		outcome.Status, outcome.Reason = domain.DisbursementStatusFailed, "rejected by the receiving bank"
+	case strings.HasSuffix(disbursement.AccountNumber, "2"):
+		outcome.Status, outcome.Reason = domain.DisbursementStatusTimeout, "no answer from the receiving bank"
+	}
+	time.AfterFunc(p.delay, func() { p.callback(outcome) })
+
+	return result, nil
+}
+
+func (p *FakeDisbursementProvider) ParseCallback(signature string, body []byte) (domain.DisbursementResult, error) {
+	if !VerifySignature(p.secret, signature, body) {
+		return domain.DisbursementResult{}, domain.ErrInvalidDisbursementCallback
+	}
+
+	var callback fakeDisbursementCallback
+	if err := json.Unmarshal(body, &callback); err != nil || callback.ExternalID == "" {
+		return domain.DisbursementResult{}, domain.ErrInvalidDisbursementCallback
+	}
+	return domain.DisbursementResult{
+		ExternalID: callback.ExternalID,
+		Reference:  callback.Reference,
+		Status:     callback.Status,
+		Reason:     callback.Reason,
+	}, nil
+}
+
+// callback sends the outcome once, the withdrawal stays processing when it doesn't arrive.
+func (p *FakeDisbursementProvider) callback(outcome fakeDisbursementCallback) {
+	body, err := json.Marshal(outcome)
+	if err != nil {
+		p.logger.Error("fake disbursement callback failed", "reference", outcome.Reference, "error", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, p.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		p.logger.Error("fake disbursement callback failed", "reference", outcome.Reference, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(domain.DisbursementHeaderSignature, Sign(p.secret, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.logger.Error("fake disbursement callback failed", "reference", outcome.Reference, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		p.logger.Warn("fake disbursement callback rejected", "reference", outcome.Reference, "status", resp.StatusCode)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
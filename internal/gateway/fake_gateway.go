@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"tahap2/internal/domain"
+	"time"
+)
+
+// FakeGateway serves the sandbox gateway protocol from memory, for trying out topups locally
+// and for tests. It opens charges and, when one is paid or failed, sends a signed callback
+// to callbackURL. Callbacks can be dropped to exercise reconciliation.
+type FakeGateway struct {
+	secret        string
+	callbackURL   string
+	publicURL     string
+	ttl           time.Duration
+	dropCallbacks bool
+	client        *http.Client
+	logger        *slog.Logger
+
+	mu      sync.Mutex
+	charges map[string]*Charge
+}
+
+// NewFakeGateway returns a gateway whose charges can be paid for ttl. publicURL is the base of
+// the card payment urls it hands out.
+func NewFakeGateway(secret, callbackURL, publicURL string, ttl time.Duration, dropCallbacks bool, logger *slog.Logger) *FakeGateway {
+	return &FakeGateway{
+		secret:        secret,
+		callbackURL:   callbackURL,
+		publicURL:     strings.TrimRight(publicURL, "/"),
+		ttl:           ttl,
+		dropCallbacks: dropCallbacks,
+		client:        &http.Client{Timeout: sandboxTimeout},
+		logger:        logger,
+		charges:       map[string]*Charge{},
+	}
+}
+
+// Handler serves the gateway API, along with the pay and fail routes the customer would use.
+func (g *FakeGateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /charges", g.authorized(g.createCharge))
+	mux.HandleFunc("GET /charges/{reference}", g.authorized(g.getCharge))
+	// paying and failing stand in for the customer, they need no credentials.
+	mux.HandleFunc("POST /charges/{reference}/pay", g.settle(domain.IntentStatusPaid))
+	mux.HandleFunc("POST /charges/{reference}/fail", g.settle(domain.IntentStatusFailed))
+	return mux
+}
+
+func (g *FakeGateway) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+g.secret {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (g *FakeGateway) createCharge(w http.ResponseWriter, r *http.Request) {
+	var req ChargeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Amount <= 0 || req.OrderID == "" {
+		http.Error(w, "invalid charge", http.StatusBadRequest)
+		return
+	}
+
+	charge := &Charge{
+		Reference: "ch_" + randomHex(8),
+		OrderID:   req.OrderID,
+		Amount:    req.Amount,
+		Method:    req.Method,
+		Status:    domain.IntentStatusPending,
+		ExpiresAt: time.Now().Add(g.ttl),
+	}
+	switch req.Method {
+	case domain.PaymentMethodVA:
+		charge.VANumber = "8808" + randomDigits(12)
+	case domain.PaymentMethodCard:
+		charge.PaymentURL = g.publicURL + "/charges/" + charge.Reference + "/pay"
+	default:
+		http.Error(w, "unsupported method", http.StatusBadRequest)
+		return
+	}
+
+	g.mu.Lock()
+	g.charges[charge.Reference] = charge
+	g.mu.Unlock()
+
+	g.logger.Info("opened charge", "method", charge.Method, "reference", charge.Reference, "order_id", charge.OrderID, "amount", charge.Amount)
+	writeJSON(w, http.StatusCreated, charge)
+}
+
+func (g *FakeGateway) getCharge(w http.ResponseWriter, r *http.Request) {
+	charge, ok := g.charge(r.PathValue("reference"))
+	if !ok {
+		http.Error(w, "charge not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, charge)
+}
+
+func (g *FakeGateway) settle(status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reference := r.PathValue("reference")
+		g.mu.Lock()
+		charge, ok := g.charges[reference]
+		if ok {
+			expire(charge)
+		}
+		settled := ok && charge.Status == domain.IntentStatusPending
+		if settled {
+			charge.Status = status
+		}
+		var snapshot Charge
+		if ok {
+			snapshot = *charge
+		}
+		g.mu.Unlock()
+
+		switch {
+		case !ok:
+			http.Error(w, "charge not found", http.StatusNotFound)
+			return
+		case !settled:
+			http.Error(w, "charge is "+snapshot.Status, http.StatusConflict)
+			return
+		}
+
+		g.logger.Info("charge settled", "reference", reference, "status", status)
+		if g.dropCallbacks {
+			g.logger.Info("dropping callback", "reference", reference)
+		} else if err := g.callback(snapshot); err != nil {
+			g.logger.Warn("callback failed", "reference", reference, "error", err)
+		}
+		writeJSON(w, http.StatusOK, snapshot)
+	}
+}
+
+// charge returns a copy of the charge, expiring it first when its time is up.
+func (g *FakeGateway) charge(reference string) (Charge, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[reference]
+	if !ok {
+		return Charge{}, false
+	}
+	expire(charge)
+	return *charge, true
+}
+
+func expire(charge *Charge) {
+	if charge.Status == domain.IntentStatusPending && time.Now().After(charge.ExpiresAt) {
+		charge.Status = domain.IntentStatusExpired
+	}
+}
+
+// callback sends the charge once, a real gateway would keep retrying until it gets a 2xx.
+func (g *FakeGateway) callback(charge Charge) error {
+	body, err := json.Marshal(charge)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, g.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(domain.GatewayHeaderSignature, Sign(g.secret, body))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomDigits(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	for i := range b {
+		b[i] = '0' + b[i]%10
+	}
+	return string(b)
+}
@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const (
+	sandboxTimeout = 10 * time.Second
+	// sandboxBodyLimit caps how much of a gateway response is read.
+	sandboxBodyLimit = 1 << 20
+)
+
+// ChargeRequest opens a charge, OrderID is the id of the payment intent.
+type ChargeRequest struct {
+	OrderID string `json:"order_id"`
+	Amount  int64  `json:"amount"`
+	Method  string `json:"method"`
+}
+
+// Charge is how the sandbox gateway describes a charge, both in API responses and in
+// the callbacks it sends. Status uses the payment intent statuses.
+type Charge struct {
+	Reference  string    `json:"reference"`
+	OrderID    string    `json:"order_id"`
+	Amount     int64     `json:"amount"`
+	Method     string    `json:"method"`
+	Status     string    `json:"status"`
+	VANumber   string    `json:"va_number,omitempty"`
+	PaymentURL string    `json:"payment_url,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SandboxGateway talks to the sandbox gateway protocol served by cmd/fake-gateway. API
+// calls carry the secret as a bearer token and callbacks are signed with it.
+type SandboxGateway struct {
+	name    string
+	baseURL string
+	secret  string
+	client  *http.Client
+}
+
+func NewSandboxGateway(name, baseURL, secret string) *SandboxGateway {
+	return &SandboxGateway{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  secret,
+		client:  &http.Client{Timeout: sandboxTimeout},
+	}
+}
+
+func (g *SandboxGateway) Name() string {
+	return g.name
+}
+
+func (g *SandboxGateway) CreateCharge(ctx context.Context, intent *domain.PaymentIntent) (domain.GatewayCharge, error) {
+	body, err := json.Marshal(ChargeRequest{
+		OrderID: intent.ID.String(),
+		Amount:  intent.Amount,
+		Method:  intent.Method,
+	})
+	if err != nil {
+		return domain.GatewayCharge{}, err
+	}
+
+	var charge Charge
+	if err = g.do(ctx, http.MethodPost, "/charges", body, &charge); err != nil {
+		return domain.GatewayCharge{}, err
+	}
+
+	return domain.GatewayCharge{
+		Reference:  charge.Reference,
+		VANumber:   charge.VANumber,
+		PaymentURL: charge.PaymentURL,
+		ExpiresAt:  charge.ExpiresAt,
+	}, nil
+}
+
+func (g *SandboxGateway) GetChargeStatus(ctx context.Context, reference string) (domain.GatewayChargeStatus, error) {
+	var charge Charge
+	if err := g.do(ctx, http.MethodGet, "/charges/"+url.PathEscape(reference), nil, &charge); err != nil {
+		return domain.GatewayChargeStatus{}, err
+	}
+	return chargeStatus(charge), nil
+}
+
+func (g *SandboxGateway) ParseCallback(signature string, body []byte) (domain.GatewayChargeStatus, error) {
+	if !VerifySignature(g.secret, signature, body) {
+		return domain.GatewayChargeStatus{}, domain.ErrInvalidCallback
+	}
+
+	var charge Charge
+	if err := json.Unmarshal(body, &charge); err != nil || charge.Reference == "" {
+		return domain.GatewayChargeStatus{}, domain.ErrInvalidCallback
+	}
+	return chargeStatus(charge), nil
+}
+
+func (g *SandboxGateway) do(ctx context.Context, method, path string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.secret)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, sandboxBodyLimit))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s answered %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+func chargeStatus(charge Charge) domain.GatewayChargeStatus {
+	return domain.GatewayChargeStatus{
+		Reference: charge.Reference,
+		OrderID:   charge.OrderID,
+		Status:    charge.Status,
+		Amount:    charge.Amount,
+	}
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body, the value of the callback signature header.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature is the one of body. Without a secret anyone could
+// sign, so nothing verifies.
+func VerifySignature(secret, signature string, body []byte) bool {
+	if secret == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(strings.TrimSpace(signature)))
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+type AdminHandler struct {
+	adminService domain.AdminService
+}
+
+func NewAdminHandler(adminService domain.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+func (h *AdminHandler) SearchUsers(c echo.Context) error {
+	limit, offset := pagination(c)
+	users, err := h.adminService.SearchUsers(c.Request().Context(), c.QueryParam("q"), limit, offset)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "search_users_failed", err)
+	}
+
+	result := make([]AdminUserResponse, len(users))
+	for i, user := range users {
+		result[i] = toAdminUserResponse(user)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": result,
+	})
+}
+
+func (h *AdminHandler) GetUser(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	user, err := h.adminService.GetUser(c.Request().Context(), userID)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "get_user_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toAdminUserResponse(user),
+	})
+}
+
+func (h *AdminHandler) FreezeUser(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	user, err := h.adminService.FreezeUser(c.Request().Context(), actorID, userID)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "freeze_user_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toAdminUserResponse(&user),
+	})
+}
+
+func (h *AdminHandler) UnfreezeUser(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	user, err := h.adminService.UnfreezeUser(c.Request().Context(), actorID, userID)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "unfreeze_user_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toAdminUserResponse(&user),
+	})
+}
+
+func (h *AdminHandler) CloseUser(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req CloseUserParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.adminService.CloseUser(c.Request().Context(), actorID, userID, req.Reason, req.Payout)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "close_user_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toAdminUserResponse(&user),
+	})
+}
+
+func (h *AdminHandler) SetUserRole(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req SetUserRoleParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.adminService.SetUserRole(c.Request().Context(), actorID, userID, req.Role)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "set_user_role_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toAdminUserResponse(&user),
+	})
+}
+
+func (h *AdminHandler) GetUserTransactions(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	transactions, err := h.adminService.GetUserTransactions(c.Request().Context(), userID)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "get_transactions_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toTransactionsDetailResponse(transactions),
+	})
+}
+
+func (h *AdminHandler) GetTransaction(c echo.Context) error {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	trans, err := h.adminService.GetTransaction(c.Request().Context(), transactionID)
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "get_transaction_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toTransactionsDetailResponse([]*domain.Transaction{trans})[0],
+	})
+}
+
+func (h *AdminHandler) AdjustBalance(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req AdjustBalanceParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	transInfo, err := h.adminService.AdjustBalance(c.Request().Context(), domain.BalanceAdjustment{
+		UserID:        userID,
+		ActorID:       actorID,
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		OverrideLimit: req.OverrideLimit,
+	})
+	if err != nil {
+		return localizedError(c, adminErrorStatus(err), "balance_adjustment_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toTransactionResponse(transInfo),
+	})
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound),
+		errors.Is(err, domain.ErrTransactionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrReasonRequired),
+		errors.Is(err, domain.ErrInvalidAdjustment),
+		errors.Is(err, domain.ErrInvalidRole):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrSelfRoleChange):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrAccountClosed),
+		errors.Is(err, domain.ErrBalanceNotZero):
+		return http.StatusConflict
+	default:
+		return transactionErrorStatus(err)
+	}
+}
+
+func toAdminUserResponse(user *domain.User) AdminUserResponse {
+	resp := AdminUserResponse{
+		ID:          user.ID,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		PhoneNumber: user.PhoneNumber,
+		Address:     user.Address,
+		Balance:     user.Balance,
+		Tier:        user.Tier,
+		Role:        user.Role,
+		Status:      user.Status,
+		CreatedAt:   user.CreatedAt.Format(time.DateTime),
+		UpdatedAt:   user.UpdatedAt.Format(time.DateTime),
+	}
+	if user.ClosedAt != nil {
+		resp.ClosedAt = user.ClosedAt.Format(time.DateTime)
+	}
+	return resp
+}
+
+type CloseUserParam struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+	Payout bool   `json:"payout"`
+}
+
+type SetUserRoleParam struct {
+	Role string `json:"role" validate:"required,oneof=user support admin auditor"`
+}
+
+// AdjustBalanceParam credits the user with a positive Amount and debits a negative one.
+// OverrideLimit allows a credit past the maximum balance of the user's tier.
+type AdjustBalanceParam struct {
+	Amount        int64  `json:"amount" validate:"adjustment"`
+	Reason        string `json:"reason" validate:"required,max=255"`
+	OverrideLimit bool   `json:"override_limit"`
+}
+
+type AdminUserResponse struct {
+	ID          uuid.UUID `json:"user_id"`
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	PhoneNumber string    `json:"phone_number"`
+	Address     string    `json:"address"`
+	Balance     int64     `json:"balance"`
+	Tier        string    `json:"tier"`
+	Role        string    `json:"role"`
+	Status      string    `json:"status"`
+	CreatedAt   string    `json:"created_at"`
+	UpdatedAt   string    `json:"updated_at"`
+	ClosedAt    string    `json:"closed_at,omitempty"`
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type AuditHandler struct {
+	auditService domain.AuditService
+}
+
+func NewAuditHandler(auditService domain.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// GetAuditLogs filters the audit log by event_type, actor_id, subject_id and a from/to
+// range of RFC 3339 timestamps, newest first.
+func (h *AuditHandler) GetAuditLogs(c echo.Context) error {
+	limit, offset := pagination(c)
+	filter := domain.AuditFilter{
+		EventType: c.QueryParam("event_type"),
+		SubjectID: c.QueryParam("subject_id"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if actor := c.QueryParam("actor_id"); actor != "" {
+		actorID, err := uuid.Parse(actor)
+		if err != nil {
+			return invalidParameter(c, "actor_id", "uuid", "")
+		}
+		filter.ActorID = &actorID
+	}
+	for param, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return invalidParameter(c, param, "datetime", time.RFC3339)
+		}
+		*target = &t
+	}
+
+	logs, err := h.auditService.GetAuditLogs(c.Request().Context(), filter)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_audit_logs_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": logs,
+	})
+}
+
+func (h *AuditHandler) VerifyChain(c echo.Context) error {
+	report, err := h.auditService.VerifyChain(c.Request().Context())
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "verify_audit_chain_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": report,
+	})
+}
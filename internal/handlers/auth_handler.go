@@ -1,13 +1,11 @@
 package handlers
 
 import (
-	"context"
 	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"net/http"
-	"regexp"
 	"tahap2/internal/domain"
 	"tahap2/internal/middlewares"
 	"time"
@@ -29,15 +27,11 @@ func NewAuthHandler(authService domain.UserService) *AuthHandler {
 
 func (h *AuthHandler) Register(c echo.Context) error {
 	var req RegisterParam
-	if err := c.Bind(&req); err != nil {
+	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
 
-	err := req.validate()
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid body request"})
-	}
-	newUser, err := h.authService.Register(context.Background(), domain.User{
+	newUser, err := h.authService.Register(c.Request().Context(), domain.User{
 		FirstName:   req.FirstName,
 		LastName:    req.LastName,
 		PhoneNumber: req.PhoneNumber,
@@ -45,7 +39,7 @@ func (h *AuthHandler) Register(c echo.Context) error {
 		Pin:         req.Pin,
 	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": err.Error()})
+		return localizedError(c, http.StatusInternalServerError, "", err)
 	}
 
 	return c.JSON(http.StatusCreated, echo.Map{
@@ -56,21 +50,27 @@ func (h *AuthHandler) Register(c echo.Context) error {
 
 func (h *AuthHandler) Login(c echo.Context) error {
 	var req LoginParam
-	if err := c.Bind(&req); err != nil {
+	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
-	userID, err := h.authService.Login(context.Background(), req.PhoneNumber, req.PIN)
+	user, err := h.authService.Login(c.Request().Context(), req.PhoneNumber, req.PIN)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, domain.ErrPhoneNumberNotFound), errors.Is(err, domain.ErrPinMismatch),
+			errors.Is(err, domain.ErrAccountClosed):
+			status = http.StatusUnauthorized
+		}
+		return localizedError(c, status, "", err)
 	}
 
-	accessToken, err := generateToken(userID, middlewares.AccessTokenSecret, accessTokenTTL)
+	accessToken, err := generateToken(user.ID.String(), user.Role, middlewares.AccessTokenSecret, accessTokenTTL)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to generate token"})
+		return refuse(c, http.StatusInternalServerError, "token_failed")
 	}
-	refreshToken, err := generateToken(userID, refreshTokenSecret, refreshTokenTTL)
+	refreshToken, err := generateToken(user.ID.String(), user.Role, refreshTokenSecret, refreshTokenTTL)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "failed to generate token"})
+		return refuse(c, http.StatusInternalServerError, "token_failed")
 	}
 
 	result := LoginResponse{
@@ -86,17 +86,13 @@ func (h *AuthHandler) Login(c echo.Context) error {
 
 func (h *AuthHandler) UpdateProfile(c echo.Context) error {
 	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
-	var req struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Address   string `json:"address"`
-	}
-	if err := c.Bind(&req); err != nil {
+	var req UpdateProfileParam
+	if err := bindAndValidate(c, &req); err != nil {
 		return err
 	}
-	user, err := h.authService.UpdateProfile(context.Background(), userID, req.FirstName, req.LastName, req.Address)
+	user, err := h.authService.UpdateProfile(c.Request().Context(), userID, req.FirstName, req.LastName, req.Address)
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": err.Error()})
+		return localizedError(c, http.StatusUnauthorized, "", err)
 	}
 
 	result := UserResponse{
@@ -104,6 +100,7 @@ func (h *AuthHandler) UpdateProfile(c echo.Context) error {
 		FirstName: user.FirstName,
 		LastName:  user.LastName,
 		Address:   user.Address,
+		Language:  user.Language,
 		UpdatedAt: user.UpdatedAt.Format(time.DateTime),
 	}
 
@@ -113,52 +110,88 @@ func (h *AuthHandler) UpdateProfile(c echo.Context) error {
 	})
 }
 
-// Generate JWT token
-func generateToken(userID string, secret []byte, expiry time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(expiry).Unix(),
+// SetLanguage stores the language the user is answered in from now on, an empty one goes
+// back to following Accept-Language.
+func (h *AuthHandler) SetLanguage(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	var req LanguageParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	user, err := h.authService.SetLanguage(c.Request().Context(), userID, req.Language)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrAccountClosed) {
+			status = http.StatusUnprocessableEntity
+		}
+		return localizedError(c, status, "", err)
+	}
+	if user.Language != "" {
+		middlewares.SetLanguage(c, user.Language)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(secret)
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toUserResponse(user),
+	})
 }
 
-func (r RegisterParam) validate() error {
-	// Check required fields
-	if r.FirstName == "" {
-		return errors.New("first_name is required")
-	}
-	if r.LastName == "" {
-		return errors.New("last_name is required")
-	}
-	if r.PhoneNumber == "" {
-		return errors.New("phone_number is required")
-	}
-	if r.Address == "" {
-		return errors.New("address is required")
-	}
-	if r.Pin == "" {
-		return errors.New("pin is required")
+func (h *AuthHandler) ChangePin(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	var req ChangePinParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	// Validate phone number length (10-15 characters)
-	if len(r.PhoneNumber) < 10 || len(r.PhoneNumber) > 15 {
-		return errors.New("phone_number must be between 10 and 15 characters")
+	err := h.authService.ChangePin(c.Request().Context(), userID, req.OldPin, req.NewPin)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, domain.ErrInvalidPin):
+			status = http.StatusUnauthorized
+		case errors.Is(err, domain.ErrInvalidPinFormat):
+			status = http.StatusBadRequest
+		case errors.Is(err, domain.ErrAccountClosed):
+			status = http.StatusUnprocessableEntity
+		}
+		return localizedError(c, status, "", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+func (h *AuthHandler) CloseAccount(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	var req CloseAccountParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	// Validate address length (min 5 characters)
-	if len(r.Address) < 5 {
-		return errors.New("address must be at least 5 characters")
+	err := h.authService.CloseAccount(c.Request().Context(), userID, req.Pin)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, domain.ErrInvalidPin):
+			status = http.StatusUnauthorized
+		case errors.Is(err, domain.ErrBalanceNotZero), errors.Is(err, domain.ErrAccountClosed):
+			status = http.StatusUnprocessableEntity
+		}
+		return localizedError(c, status, "", err)
 	}
 
-	// Validate PIN (exactly 6 digits)
-	pinRegex := regexp.MustCompile(`^\d{6}$`)
-	if !pinRegex.MatchString(r.Pin) {
-		return errors.New("pin must be exactly 6 numeric digits")
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+// Generate JWT token
+func generateToken(userID, role string, secret []byte, expiry time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"exp":     time.Now().Add(expiry).Unix(),
 	}
 
-	return nil
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secret)
 }
 
 func toUserResponse(user domain.User) UserResponse {
@@ -168,13 +201,14 @@ func toUserResponse(user domain.User) UserResponse {
 		LastName:    user.LastName,
 		PhoneNumber: user.PhoneNumber,
 		Address:     user.Address,
+		Language:    user.Language,
 		CreatedAt:   user.CreatedAt.Format(time.DateTime),
 	}
 }
 
 type LoginParam struct {
-	PhoneNumber string `json:"phone_number"`
-	PIN         string `json:"pin"`
+	PhoneNumber string `json:"phone_number" validate:"required"`
+	PIN         string `json:"pin" validate:"required"`
 }
 
 type LoginResponse struct {
@@ -183,11 +217,30 @@ type LoginResponse struct {
 }
 
 type RegisterParam struct {
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	PhoneNumber string `json:"phone_number"`
-	Address     string `json:"address"`
-	Pin         string `json:"pin"`
+	FirstName   string `json:"first_name" validate:"required,max=50"`
+	LastName    string `json:"last_name" validate:"required,max=50"`
+	PhoneNumber string `json:"phone_number" validate:"required,phone"`
+	Address     string `json:"address" validate:"required,min=5,max=255"`
+	Pin         string `json:"pin" validate:"required,pin"`
+}
+
+type UpdateProfileParam struct {
+	FirstName string `json:"first_name" validate:"required,max=50"`
+	LastName  string `json:"last_name" validate:"required,max=50"`
+	Address   string `json:"address" validate:"required,min=5,max=255"`
+}
+
+type LanguageParam struct {
+	Language string `json:"language" validate:"omitempty,oneof=en id"`
+}
+
+type ChangePinParam struct {
+	OldPin string `json:"old_pin" validate:"required"`
+	NewPin string `json:"new_pin" validate:"required,pin"`
+}
+
+type CloseAccountParam struct {
+	Pin string `json:"pin" validate:"required"`
 }
 
 type UserResponse struct {
@@ -196,6 +249,7 @@ type UserResponse struct {
 	LastName    string    `json:"last_name"`
 	PhoneNumber string    `json:"phone_number,omitempty"`
 	Address     string    `json:"address"`
+	Language    string    `json:"language,omitempty"`
 	CreatedAt   string    `json:"created_at,omitempty"`
 	UpdatedAt   string    `json:"updated_at,omitempty"`
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"tahap2/internal/i18n"
+	"tahap2/internal/middlewares"
+	"tahap2/internal/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// codeInvalidParameters refuses a request for its path or query parameters.
+const codeInvalidParameters = "invalid_parameters"
+
+// localizedError answers err in the language of the request. When operation is set, it is
+// the code of a message telling which operation failed, with err as its reason. Errors
+// clients aren't told about are answered with a generic message and left for the request log.
+func localizedError(c echo.Context, status int, operation string, err error) error {
+	lang := middlewares.Language(c)
+	message := i18n.Error(lang, err)
+	if operation != "" {
+		message = i18n.T(lang, operation, i18n.Args{"reason": message})
+	}
+	code := i18n.Code(err)
+	if code == "" {
+		c.Set(middlewares.ErrorKey, err)
+	}
+	return c.JSON(status, middlewares.ErrorResponse{Message: message, Code: code})
+}
+
+// refuse answers status with the message of code in the language of the request, and why each
+// of fieldErrs was refused.
+func refuse(c echo.Context, status int, code string, fieldErrs ...validation.FieldError) error {
+	lang := middlewares.Language(c)
+	resp := middlewares.ErrorResponse{Message: i18n.T(lang, code, nil), Code: code}
+	if len(fieldErrs) > 0 {
+		resp.Errors = validation.Errors(fieldErrs).Localize(lang)
+	}
+	return c.JSON(status, resp)
+}
+
+// invalidParameter refuses the request because its path or query parameter field broke the
+// rule, param is what the rule was given.
+func invalidParameter(c echo.Context, field, rule, param string) error {
+	return refuse(c, http.StatusBadRequest, codeInvalidParameters, validation.NewFieldError(field, rule, param, reflect.String))
+}
+
+// invalidField refuses the request because the field of its body broke the rule.
+func invalidField(c echo.Context, status int, field, rule, param string) error {
+	return refuse(c, status, codeInvalidRequest, validation.NewFieldError(field, rule, param, reflect.String))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"tahap2/internal/domain"
+	"tahap2/internal/i18n"
+	"tahap2/internal/middlewares"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func answer(t *testing.T, lang string, err error) (middlewares.ErrorResponse, echo.Context) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	c.Set(middlewares.LanguageKey, lang)
+	if err := localizedError(c, http.StatusInternalServerError, "topup_failed", err); err != nil {
+		t.Fatal(err)
+	}
+	var resp middlewares.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp, c
+}
+
+func TestLocalizedErrorHidesInternalErrors(t *testing.T) {
+	internal := errors.New(`pq: relation "payment_intents" does not exist`)
+	resp, c := answer(t, i18n.Indonesian, fmt.Errorf("failed to save intent: %w", internal))
+	if want := "isi saldo gagal. : terjadi kesalahan, silakan coba lagi nanti"; resp.Message != want || resp.Code != "" {
+		t.Fatalf("response = %+v, want message %q", resp, want)
+	}
+	if logged, _ := c.Get(middlewares.ErrorKey).(error); !errors.Is(logged, internal) {
+		t.Fatalf("the request log gets %v", logged)
+	}
+}
+
+func TestLocalizedErrorTranslatesDomainErrors(t *testing.T) {
+	resp, c := answer(t, i18n.English, fmt.Errorf("charge: %w", domain.ErrInvalidAmount))
+	if want := "topup failed. : " + domain.ErrInvalidAmount.Error(); resp.Message != want || resp.Code != domain.ErrInvalidAmount.Code() {
+		t.Fatalf("response = %+v, want message %q", resp, want)
+	}
+	if c.Get(middlewares.ErrorKey) != nil {
+		t.Fatal("a domain error was left for the request log")
+	}
+}
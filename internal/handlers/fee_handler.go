@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+)
+
+type FeeHandler struct {
+	feeService domain.FeeRuleService
+}
+
+func NewFeeHandler(feeService domain.FeeRuleService) *FeeHandler {
+	return &FeeHandler{feeService: feeService}
+}
+
+// GetFeeRules lists the fee rules, active or not, of the kind query parameter when given.
+func (h *FeeHandler) GetFeeRules(c echo.Context) error {
+	rules, err := h.feeService.GetFeeRules(c.Request().Context(), c.QueryParam("kind"))
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_fee_rules_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": rules,
+	})
+}
+
+func (h *FeeHandler) CreateFeeRule(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	var req FeeRuleParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	rule, err := h.feeService.CreateFeeRule(c.Request().Context(), actorID, req.toFeeRule())
+	if err != nil {
+		return localizedError(c, feeErrorStatus(err), "create_fee_rule_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": rule,
+	})
+}
+
+// UpdateFeeRule replaces the rule, a rule is retired by updating it with active false.
+func (h *FeeHandler) UpdateFeeRule(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	ruleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req FeeRuleParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	rule := req.toFeeRule()
+	rule.ID = ruleID
+	rule, err = h.feeService.UpdateFeeRule(c.Request().Context(), actorID, rule)
+	if err != nil {
+		return localizedError(c, feeErrorStatus(err), "update_fee_rule_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": rule,
+	})
+}
+
+func feeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrFeeRuleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidFeeKind),
+		errors.Is(err, domain.ErrInvalidFeeTier),
+		errors.Is(err, domain.ErrInvalidFeeType),
+		errors.Is(err, domain.ErrInvalidFeeAmount),
+		errors.Is(err, domain.ErrInvalidFeeCap),
+		errors.Is(err, domain.ErrInvalidFeeBrackets):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrFeeRuleConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FeeRuleParam describes a fee rule, percentages are in basis points. Rules are active unless
+// active is false.
+type FeeRuleParam struct {
+	TransactionKind string         `json:"transaction_kind" validate:"required,oneof=topup payment transfer withdrawal"`
+	UserTier        string         `json:"user_tier" validate:"omitempty,oneof=unverified basic full"`
+	FeeType         string         `json:"fee_type" validate:"required,oneof=flat percentage tiered"`
+	FlatAmount      int64          `json:"flat_amount" validate:"min=0"`
+	PercentageBps   int64          `json:"percentage_bps" validate:"min=0,max=10000"`
+	Tiers           []FeeTierParam `json:"tiers" validate:"max=20,dive"`
+	MinFee          int64          `json:"min_fee" validate:"min=0"`
+	MaxFee          int64          `json:"max_fee" validate:"min=0"`
+	Active          *bool          `json:"active,omitempty"`
+}
+
+// FeeTierParam is a bracket of a tiered rule, up_to 0 catches the amounts above the others.
+type FeeTierParam struct {
+	UpTo          int64 `json:"up_to" validate:"min=0"`
+	FlatAmount    int64 `json:"flat_amount" validate:"min=0"`
+	PercentageBps int64 `json:"percentage_bps" validate:"min=0,max=10000"`
+}
+
+func (p FeeRuleParam) toFeeRule() domain.FeeRule {
+	rule := domain.FeeRule{
+		TransactionKind: p.TransactionKind,
+		UserTier:        p.UserTier,
+		FeeType:         p.FeeType,
+		FlatAmount:      p.FlatAmount,
+		PercentageBps:   p.PercentageBps,
+		MinFee:          p.MinFee,
+		MaxFee:          p.MaxFee,
+		Active:          p.Active == nil || *p.Active,
+	}
+	for _, tier := range p.Tiers {
+		rule.Tiers = append(rule.Tiers, domain.FeeTier{UpTo: tier.UpTo, FlatAmount: tier.FlatAmount, PercentageBps: tier.PercentageBps})
+	}
+	return rule
+}
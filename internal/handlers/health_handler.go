@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"tahap2/internal/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+	logger  *slog.Logger
+}
+
+func NewHealthHandler(checker *health.Checker, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{checker: checker, logger: logger}
+}
+
+// Live answers as long as the process can serve requests at all. Dependencies are left to
+// Ready, a database outage must not get the app restarted.
+func (h *HealthHandler) Live(c echo.Context) error {
+	return c.JSON(http.StatusOK, echo.Map{"status": "ok"})
+}
+
+// Ready reports every component and answers 503 when one of them is down or the app is
+// shutting down. Why a component is down is only logged.
+func (h *HealthHandler) Ready(c echo.Context) error {
+	ctx := c.Request().Context()
+	report := h.checker.Check(ctx)
+	if !report.Ready {
+		for name, component := range report.Components {
+			if component.Status == health.StatusDown {
+				h.logger.WarnContext(ctx, "component not ready", "component", name, "error", component.Error)
+			}
+		}
+		return c.JSON(http.StatusServiceUnavailable, echo.Map{"status": "not_ready", "result": report})
+	}
+	return c.JSON(http.StatusOK, echo.Map{"status": "ready", "result": report})
+}
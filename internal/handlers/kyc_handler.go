@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"strconv"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+const maxKYCDocumentSize = 5 << 20 // 5 MB
+
+type KYCHandler struct {
+	kycService domain.KYCService
+}
+
+func NewKYCHandler(kycService domain.KYCService) *KYCHandler {
+	return &KYCHandler{kycService: kycService}
+}
+
+// Submit accepts a multipart form with id_number, date_of_birth (YYYY-MM-DD),
+// requested_tier and an optional document file.
+func (h *KYCHandler) Submit(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req KYCSubmitParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	dateOfBirth, err := time.Parse(time.DateOnly, req.DateOfBirth)
+	if err != nil {
+		return invalidField(c, http.StatusBadRequest, "date_of_birth", "datetime", time.DateOnly)
+	}
+
+	var document *domain.KYCDocument
+	fileHeader, err := c.FormFile("document")
+	if err == nil {
+		if fileHeader.Size > maxKYCDocumentSize {
+			return invalidField(c, http.StatusRequestEntityTooLarge, "document", "filesize", "5 MB")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return invalidField(c, http.StatusBadRequest, "document", "invalid", "")
+		}
+		defer file.Close()
+
+		// trust the content itself rather than the client supplied header.
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(file, head)
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return invalidField(c, http.StatusBadRequest, "document", "invalid", "")
+		}
+		document = &domain.KYCDocument{
+			ContentType: http.DetectContentType(head[:n]),
+			Content:     file,
+		}
+	} else if !errors.Is(err, http.ErrMissingFile) {
+		return invalidField(c, http.StatusBadRequest, "document", "invalid", "")
+	}
+
+	submission, err := h.kycService.Submit(c.Request().Context(), domain.KYCSubmission{
+		UserID:        userID,
+		RequestedTier: req.RequestedTier,
+		IDNumber:      req.IDNumber,
+		DateOfBirth:   dateOfBirth,
+	}, document)
+	if err != nil {
+		return localizedError(c, kycErrorStatus(err), "kyc_submission_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": toKYCResponse(&submission),
+	})
+}
+
+func (h *KYCHandler) GetStatus(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	submission, err := h.kycService.GetLatestSubmission(c.Request().Context(), userID)
+	if err != nil {
+		return localizedError(c, kycErrorStatus(err), "get_kyc_status_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toKYCResponse(submission),
+	})
+}
+
+func (h *KYCHandler) GetReviewQueue(c echo.Context) error {
+	limit, offset := pagination(c)
+	submissions, err := h.kycService.GetReviewQueue(c.Request().Context(), c.QueryParam("status"), limit, offset)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_kyc_submissions_failed", err)
+	}
+
+	result := make([]KYCResponse, len(submissions))
+	for i, submission := range submissions {
+		result[i] = toKYCResponse(submission)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": result,
+	})
+}
+
+func (h *KYCHandler) GetDocument(c echo.Context) error {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	content, contentType, err := h.kycService.GetDocument(c.Request().Context(), submissionID)
+	if err != nil {
+		return localizedError(c, kycErrorStatus(err), "get_kyc_document_failed", err)
+	}
+	defer content.Close()
+
+	return c.Stream(http.StatusOK, contentType, content)
+}
+
+func (h *KYCHandler) Approve(c echo.Context) error {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	submission, err := h.kycService.Approve(c.Request().Context(), reviewerID(c), submissionID)
+	if err != nil {
+		return localizedError(c, kycErrorStatus(err), "approve_kyc_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toKYCResponse(&submission),
+	})
+}
+
+func (h *KYCHandler) Reject(c echo.Context) error {
+	submissionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req RejectKYCParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	submission, err := h.kycService.Reject(c.Request().Context(), reviewerID(c), submissionID, req.Reason)
+	if err != nil {
+		return localizedError(c, kycErrorStatus(err), "reject_kyc_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toKYCResponse(&submission),
+	})
+}
+
+// reviewerID returns the authenticated back-office user, if the route carries one.
+func reviewerID(c echo.Context) uuid.UUID {
+	id, _ := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	return id
+}
+
+// pagination reads the limit and offset query parameters, limit defaults to 20 and is capped at 100.
+func pagination(c echo.Context) (int, int) {
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	offset, err := strconv.Atoi(c.QueryParam("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+func kycErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrKYCNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrKYCAlreadyPending),
+		errors.Is(err, domain.ErrKYCAlreadyReviewed):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrInvalidKYCTier),
+		errors.Is(err, domain.ErrInvalidIDNumber),
+		errors.Is(err, domain.ErrInvalidDateOfBirth),
+		errors.Is(err, domain.ErrKYCDocumentRequired),
+		errors.Is(err, domain.ErrKYCRejectReason),
+		errors.Is(err, domain.ErrUnsupportedDocument):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func toKYCResponse(submission *domain.KYCSubmission) KYCResponse {
+	resp := KYCResponse{
+		SubmissionID:  submission.ID.String(),
+		UserID:        submission.UserID.String(),
+		RequestedTier: submission.RequestedTier,
+		IDNumber:      maskIDNumber(submission.IDNumber),
+		DateOfBirth:   submission.DateOfBirth.Format(time.DateOnly),
+		HasDocument:   submission.DocumentKey != "",
+		Status:        submission.Status,
+		RejectReason:  submission.RejectReason,
+		CreatedAt:     submission.CreatedAt.Format(time.DateTime),
+	}
+	if submission.ReviewedAt != nil {
+		resp.ReviewedAt = submission.ReviewedAt.Format(time.DateTime)
+	}
+	return resp
+}
+
+// maskIDNumber only keeps the last four digits of an identity number.
+func maskIDNumber(idNumber string) string {
+	if len(idNumber) <= 4 {
+		return idNumber
+	}
+	masked := []byte(idNumber)
+	for i := 0; i < len(masked)-4; i++ {
+		masked[i] = '*'
+	}
+	return string(masked)
+}
+
+// KYCSubmitParam holds the fields of the submission form, the document is read separately.
+type KYCSubmitParam struct {
+	IDNumber      string `form:"id_number" validate:"required,number,len=16"`
+	DateOfBirth   string `form:"date_of_birth" validate:"required,datetime=2006-01-02"`
+	RequestedTier string `form:"requested_tier" validate:"required,oneof=basic full"`
+}
+
+type RejectKYCParam struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+type KYCResponse struct {
+	SubmissionID  string `json:"submission_id"`
+	UserID        string `json:"user_id"`
+	RequestedTier string `json:"requested_tier"`
+	IDNumber      string `json:"id_number"`
+	DateOfBirth   string `json:"date_of_birth"`
+	HasDocument   bool   `json:"has_document"`
+	Status        string `json:"status"`
+	RejectReason  string `json:"reject_reason,omitempty"`
+	ReviewedAt    string `json:"reviewed_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+)
+
+type LimitHandler struct {
+	limitService domain.LimitService
+}
+
+func NewLimitHandler(limitService domain.LimitService) *LimitHandler {
+	return &LimitHandler{limitService: limitService}
+}
+
+func (h *LimitHandler) GetRemainingLimits(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	limits, err := h.limitService.GetRemainingLimits(c.Request().Context(), userID)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_limits_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": limits,
+	})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+type MerchantHandler struct {
+	merchantService domain.MerchantService
+}
+
+func NewMerchantHandler(merchantService domain.MerchantService) *MerchantHandler {
+	return &MerchantHandler{merchantService: merchantService}
+}
+
+// GetProfile returns the authenticated merchant with its settlement balance.
+func (h *MerchantHandler) GetProfile(c echo.Context) error {
+	merchantID := c.Get(middlewares.MerchantIDKey).(uuid.UUID)
+
+	merchant, err := h.merchantService.GetMerchant(c.Request().Context(), merchantID)
+	if err != nil {
+		return localizedError(c, merchantErrorStatus(err), "get_merchant_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toMerchantResponse(merchant),
+	})
+}
+
+func (h *MerchantHandler) GetPayments(c echo.Context) error {
+	merchantID := c.Get(middlewares.MerchantIDKey).(uuid.UUID)
+	limit, offset := pagination(c)
+
+	transactions, err := h.merchantService.GetPayments(c.Request().Context(), merchantID, limit, offset)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_payments_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toTransactionsDetailResponse(transactions),
+	})
+}
+
+func (h *MerchantHandler) Refund(c echo.Context) error {
+	merchantID := c.Get(middlewares.MerchantIDKey).(uuid.UUID)
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req RefundParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	transInfo, err := h.merchantService.Refund(c.Request().Context(), domain.Refund{
+		MerchantID: merchantID,
+		PaymentID:  paymentID,
+		Amount:     req.Amount,
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		return localizedError(c, merchantErrorStatus(err), "refund_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toTransactionResponse(transInfo),
+	})
+}
+
+func (h *MerchantHandler) CreateMerchant(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	var req CreateMerchantParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	merchant, key, err := h.merchantService.CreateMerchant(c.Request().Context(), actorID, req.Name)
+	if err != nil {
+		return localizedError(c, merchantErrorStatus(err), "create_merchant_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": CreateMerchantResponse{
+			Merchant: toMerchantResponse(&merchant),
+			APIKey:   toAPIKeyResponse(key),
+		},
+	})
+}
+
+func (h *MerchantHandler) GetMerchant(c echo.Context) error {
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	merchant, err := h.merchantService.GetMerchant(c.Request().Context(), merchantID)
+	if err != nil {
+		return localizedError(c, merchantErrorStatus(err), "get_merchant_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toMerchantResponse(merchant),
+	})
+}
+
+func (h *MerchantHandler) RotateAPIKey(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	key, err := h.merchantService.RotateAPIKey(c.Request().Context(), actorID, merchantID)
+	if err != nil {
+		return localizedError(c, merchantErrorStatus(err), "rotate_api_key_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": toAPIKeyResponse(key),
+	})
+}
+
+func (h *MerchantHandler) SetMerchantStatus(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	merchantID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req MerchantStatusParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	merchant, err := h.merchantService.SetMerchantStatus(c.Request().Context(), actorID, merchantID, req.Status)
+	if err != nil {
+		return localizedError(c, merchantErrorStatus(err), "set_merchant_status_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toMerchantResponse(&merchant),
+	})
+}
+
+func merchantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrMerchantNotFound),
+		errors.Is(err, domain.ErrTransactionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrMerchantNameRequired),
+		errors.Is(err, domain.ErrInvalidMerchantState),
+		errors.Is(err, domain.ErrInvalidAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrNotRefundable),
+		errors.Is(err, domain.ErrRefundExceedsPayment):
+		return http.StatusUnprocessableEntity
+	default:
+		return transactionErrorStatus(err)
+	}
+}
+
+func toMerchantResponse(merchant *domain.Merchant) MerchantResponse {
+	return MerchantResponse{
+		MerchantID: merchant.ID,
+		Name:       merchant.Name,
+		Balance:    merchant.Balance,
+		Status:     merchant.Status,
+		CreatedAt:  merchant.CreatedAt.Format(time.DateTime),
+	}
+}
+
+func toAPIKeyResponse(key domain.IssuedAPIKey) APIKeyResponse {
+	return APIKeyResponse{
+		KeyID:     key.ID,
+		Key:       key.Key,
+		Prefix:    key.Prefix,
+		CreatedAt: key.CreatedAt.Format(time.DateTime),
+	}
+}
+
+type RefundParam struct {
+	Amount int64  `json:"amount" validate:"amount"`
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+type CreateMerchantParam struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type MerchantStatusParam struct {
+	Status string `json:"status" validate:"required,oneof=active suspended"`
+}
+
+type CreateMerchantResponse struct {
+	Merchant MerchantResponse `json:"merchant"`
+	APIKey   APIKeyResponse   `json:"api_key"`
+}
+
+type MerchantResponse struct {
+	MerchantID uuid.UUID `json:"merchant_id"`
+	Name       string    `json:"name"`
+	Balance    int64     `json:"balance"`
+	Status     string    `json:"status"`
+	CreatedAt  string    `json:"created_at"`
+}
+
+type APIKeyResponse struct {
+	KeyID     uuid.UUID `json:"key_id"`
+	Key       string    `json:"key"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt string    `json:"created_at"`
+}
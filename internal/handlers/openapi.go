@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"tahap2/internal/openapi"
+	"tahap2/internal/validation"
+)
+
+const (
+	// APIPrefix is where every documented route is served below.
+	APIPrefix = "/api/v1"
+
+	securityBearer   = "bearerAuth"
+	securityMerchant = "merchantApiKey"
+)
+
+var (
+	idParam          = openapi.Param{Name: "id", In: "path", Schema: &openapi.Schema{Type: "string", Format: "uuid"}}
+	paginationParams = []openapi.Param{
+		{Name: "limit", In: "query", Description: "Defaults to 20, at most 100.", Schema: &openapi.Schema{Type: "integer"}},
+		{Name: "offset", In: "query", Schema: &openapi.Schema{Type: "integer"}},
+	}
+	qrFormatParam   = openapi.Param{Name: "format", In: "query", Description: "png answers with the image of the code.", Schema: &openapi.Schema{Type: "string", Enum: []string{"png"}}}
+	statementParams = []openapi.Param{
+		{Name: "month", In: "query", Required: true, Description: "YYYY-MM", Schema: &openapi.Schema{Type: "string"}},
+		{Name: "format", In: "query", Description: "Defaults to pdf.", Schema: &openapi.Schema{Type: "string", Enum: []string{domain.StatementFormatPDF, domain.StatementFormatCSV}}},
+	}
+	statementFiles = []string{statementContentTypes[domain.StatementFormatPDF], statementContentTypes[domain.StatementFormatCSV]}
+	callbackBody   = &openapi.Schema{Type: "object", Description: "The notification as the provider sends it."}
+
+	// the statuses the error mappers falling back to transactionErrorStatus answer with, with
+	// and without conflicts, and those of the top-up and withdrawal mappers, which also turn
+	// away forged callbacks. TestDocumentedErrors checks every route lists what its handler
+	// can answer.
+	transactionErrors         = []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity}
+	transactionConflictErrors = []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity}
+	topUpErrors               = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity}
+	withdrawalErrors          = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity}
+)
+
+// APIDocument describes every route below APIPrefix. main refuses to start when it and the
+// routes registered drift apart.
+func APIDocument() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:       "Wallet API",
+		Description: "Successful responses carry the outcome in result, failed ones a message.",
+		Version:     "1.0.0",
+	})
+	doc.RequiredField = validation.Required
+	doc.AddSecurityScheme(securityBearer, openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+	doc.AddSecurityScheme(securityMerchant, openapi.SecurityScheme{Type: "apiKey", In: "header", Name: middlewares.MerchantKeyHead})
+	for _, route := range apiRoutes() {
+		route.Path = APIPrefix + route.Path
+		doc.AddRoute(route)
+	}
+	return doc
+}
+
+func apiRoutes() []openapi.Route {
+	var routes []openapi.Route
+	add := func(group []openapi.Route, tag, security string, forbidden bool) {
+		for _, route := range group {
+			route.Tag, route.Security, route.Forbidden = tag, security, route.Forbidden || forbidden
+			routes = append(routes, route)
+		}
+	}
+
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/register", Summary: "Register a user", RateLimited: true, RateLimitFailsClosed: true,
+			Request: RegisterParam{}, Status: http.StatusCreated, Response: UserResponse{}, Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodPost, Path: "/login", Summary: "Log in with phone number and pin", RateLimited: true, RateLimitFailsClosed: true,
+			Request: LoginParam{}, Response: LoginResponse{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+	}, "Auth", "", false)
+	add([]openapi.Route{
+		{Method: http.MethodPut, Path: "/profile", Summary: "Update the profile",
+			Request: UpdateProfileParam{}, Response: UserResponse{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized}},
+		{Method: http.MethodPut, Path: "/profile/language", Summary: "Set the language messages are answered in, empty to follow Accept-Language",
+			Request: LanguageParam{}, Response: UserResponse{}, Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		{Method: http.MethodPut, Path: "/pin", Summary: "Change the pin",
+			Request: ChangePinParam{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity}},
+		{Method: http.MethodPost, Path: "/account/close", Summary: "Close the account, the balance must be zero",
+			Request: CloseAccountParam{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusUnprocessableEntity}},
+	}, "Auth", securityBearer, false)
+
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/topup", Summary: "Open a top-up, the wallet is credited once the gateway confirms it", RateLimited: true,
+			Request: TopUpParam{}, Status: http.StatusCreated, Response: PaymentIntentResponse{}, Errors: topUpErrors},
+		{Method: http.MethodGet, Path: "/topup/:id", Summary: "Get a top-up", Params: []openapi.Param{idParam},
+			Response: PaymentIntentResponse{}, Errors: topUpErrors},
+	}, "Top-ups", securityBearer, false)
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/gateway/:provider/callback", Summary: "Payment notification of a gateway",
+			Params:  []openapi.Param{{Name: domain.GatewayHeaderSignature, In: "header", Required: true}},
+			Request: callbackBody, Errors: topUpErrors},
+	}, "Callbacks", "", false)
+
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/pay", Summary: "Pay a merchant", RateLimited: true,
+			Request: PaymentParam{}, Response: TransactionResponse{}, Errors: transactionErrors},
+		{Method: http.MethodPost, Path: "/pay/qr", Summary: "Pay a merchant by a scanned QR code", RateLimited: true,
+			Request: PayQRParam{}, Response: TransactionResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPost, Path: "/transfer", Summary: "Transfer to another user", RateLimited: true,
+			Request: TransferParam{}, Response: TransactionResponse{}, Errors: transactionErrors},
+		{Method: http.MethodPost, Path: "/transfer/inquiry", Summary: "Check the recipient and fee of a transfer", RateLimited: true,
+			Request: TransferParam{}, Response: domain.TransferInquiry{}, Errors: transactionErrors},
+		{Method: http.MethodGet, Path: "/transactions", Summary: "List the transactions",
+			Response: []TransactionDetailsResponse{}},
+		{Method: http.MethodGet, Path: "/limits", Summary: "Get the remaining limits",
+			Response: domain.RemainingLimits{}},
+		{Method: http.MethodGet, Path: "/statements", Summary: "Download the statement of a month", Params: statementParams,
+			Files: statementFiles, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	}, "Transactions", securityBearer, false)
+
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/bank-accounts/inquiry", Summary: "Look up the holder of a bank account", RateLimited: true,
+			Request: BankAccountParam{}, Response: BankAccountInquiryResponse{}, Errors: withdrawalErrors},
+		{Method: http.MethodPost, Path: "/bank-accounts", Summary: "Save a bank account",
+			Request: BankAccountParam{}, Status: http.StatusCreated, Response: BankAccountResponse{}, Errors: withdrawalErrors},
+		{Method: http.MethodGet, Path: "/bank-accounts", Summary: "List the saved bank accounts",
+			Response: []BankAccountResponse{}},
+		{Method: http.MethodDelete, Path: "/bank-accounts/:id", Summary: "Remove a bank account", Params: []openapi.Param{idParam},
+			Errors: withdrawalErrors},
+		{Method: http.MethodPost, Path: "/withdrawals", Summary: "Withdraw to a saved bank account", RateLimited: true,
+			Request: WithdrawalParam{}, Status: http.StatusAccepted, Response: WithdrawalResponse{}, Errors: withdrawalErrors},
+		{Method: http.MethodGet, Path: "/withdrawals", Summary: "List the withdrawals", Params: paginationParams,
+			Response: []WithdrawalResponse{}},
+		{Method: http.MethodGet, Path: "/withdrawals/:id", Summary: "Get a withdrawal", Params: []openapi.Param{idParam},
+			Response: WithdrawalResponse{}, Errors: withdrawalErrors},
+	}, "Withdrawals", securityBearer, false)
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/disbursement/:provider/callback", Summary: "Outcome of a disbursement",
+			Params:  []openapi.Param{{Name: domain.DisbursementHeaderSignature, In: "header", Required: true}},
+			Request: callbackBody, Errors: withdrawalErrors},
+	}, "Callbacks", "", false)
+
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/kyc", Summary: "Submit identity details for a higher tier",
+			Request: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"id_number":      {Type: "string"},
+					"date_of_birth":  {Type: "string", Format: "date"},
+					"requested_tier": {Type: "string", Enum: []string{domain.UserTierBasic, domain.UserTierFull}},
+					"document":       {Type: "string", Format: "binary", Description: "At most 5 MB, required for the full tier."},
+				},
+				Required: []string{"id_number", "date_of_birth", "requested_tier"},
+			},
+			RequestContentType: openapi.ContentTypeMultipart, Status: http.StatusCreated, Response: KYCResponse{},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusRequestEntityTooLarge}},
+		{Method: http.MethodGet, Path: "/kyc", Summary: "Get the latest submission",
+			Response: KYCResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	}, "KYC", securityBearer, false)
+
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/scheduled-transfers", Summary: "Schedule a transfer",
+			Request: ScheduledTransferParam{}, Status: http.StatusCreated, Response: ScheduledTransferResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodGet, Path: "/scheduled-transfers", Summary: "List the scheduled transfers",
+			Response: []ScheduledTransferResponse{}},
+		{Method: http.MethodGet, Path: "/scheduled-transfers/:id", Summary: "Get a scheduled transfer", Params: []openapi.Param{idParam},
+			Response: ScheduledTransferResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPut, Path: "/scheduled-transfers/:id", Summary: "Change a scheduled transfer", Params: []openapi.Param{idParam},
+			Request: ScheduledTransferParam{}, Response: ScheduledTransferResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodDelete, Path: "/scheduled-transfers/:id", Summary: "Cancel a scheduled transfer", Params: []openapi.Param{idParam},
+			Errors: transactionConflictErrors},
+		{Method: http.MethodGet, Path: "/scheduled-transfers/:id/runs", Summary: "List the runs of a scheduled transfer", Params: []openapi.Param{idParam},
+			Response: []domain.ScheduledTransferRun{}, Errors: transactionConflictErrors},
+	}, "Scheduled transfers", securityBearer, false)
+
+	// the key of a suspended merchant is refused with a 403.
+	add([]openapi.Route{
+		{Method: http.MethodGet, Path: "/merchant", Summary: "Get the merchant and its settlement balance",
+			Response: MerchantResponse{}, Errors: transactionErrors},
+		{Method: http.MethodGet, Path: "/merchant/payments", Summary: "List the payments received", Params: paginationParams,
+			Response: []TransactionDetailsResponse{}},
+		{Method: http.MethodPost, Path: "/merchant/payments/:id/refunds", Summary: "Refund a payment, fully or partially", Params: []openapi.Param{idParam},
+			Request: RefundParam{}, Response: TransactionResponse{}, Errors: transactionErrors},
+		{Method: http.MethodGet, Path: "/merchant/qr", Summary: "Get the static QR code", Params: []openapi.Param{qrFormatParam},
+			Response: QRResponse{}, Files: []string{"image/png"}, Errors: transactionConflictErrors},
+		{Method: http.MethodPost, Path: "/merchant/qr", Summary: "Create a dynamic QR code for an amount",
+			Request: DynamicQRParam{}, Status: http.StatusCreated, Response: QRResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodGet, Path: "/merchant/qr/:id", Summary: "Get a dynamic QR code", Params: []openapi.Param{idParam, qrFormatParam},
+			Response: QRResponse{}, Files: []string{"image/png"}, Errors: transactionConflictErrors},
+	}, "Merchant", securityMerchant, true)
+	add(webhookRoutes("/merchant"), "Merchant webhooks", securityMerchant, true)
+
+	// the admin routes each need a permission of the role.
+	add([]openapi.Route{
+		{Method: http.MethodGet, Path: "/admin/users", Summary: "Search users",
+			Params:   append([]openapi.Param{{Name: "q", In: "query", Description: "Matches names and phone numbers."}}, paginationParams...),
+			Response: []AdminUserResponse{}},
+		{Method: http.MethodGet, Path: "/admin/users/:id", Summary: "Get a user", Params: []openapi.Param{idParam},
+			Response: AdminUserResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodGet, Path: "/admin/users/:id/transactions", Summary: "List the transactions of a user", Params: []openapi.Param{idParam},
+			Response: []TransactionDetailsResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPost, Path: "/admin/users/:id/freeze", Summary: "Freeze a user", Params: []openapi.Param{idParam},
+			Response: AdminUserResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPost, Path: "/admin/users/:id/unfreeze", Summary: "Unfreeze a user", Params: []openapi.Param{idParam},
+			Response: AdminUserResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPost, Path: "/admin/users/:id/close", Summary: "Close the account of a user", Params: []openapi.Param{idParam},
+			Request: CloseUserParam{}, Response: AdminUserResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPut, Path: "/admin/users/:id/role", Summary: "Set the role of a user", Params: []openapi.Param{idParam},
+			Request: SetUserRoleParam{}, Response: AdminUserResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodPost, Path: "/admin/users/:id/adjustments", Summary: "Adjust the balance of a user", Params: []openapi.Param{idParam},
+			Request: AdjustBalanceParam{}, Response: TransactionResponse{}, Errors: transactionConflictErrors},
+		{Method: http.MethodGet, Path: "/admin/users/:id/statements", Summary: "Download the statement of a user", Params: append([]openapi.Param{idParam}, statementParams...),
+			Files: statementFiles, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: "/admin/transactions/:id", Summary: "Get a transaction", Params: []openapi.Param{idParam},
+			Response: TransactionDetailsResponse{}, Errors: transactionConflictErrors},
+	}, "Admin users", securityBearer, true)
+	add([]openapi.Route{
+		{Method: http.MethodGet, Path: "/admin/kyc", Summary: "List submissions to review",
+			Params:   append([]openapi.Param{{Name: "status", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{domain.KYCStatusPending, domain.KYCStatusApproved, domain.KYCStatusRejected}}}}, paginationParams...),
+			Response: []KYCResponse{}},
+		{Method: http.MethodGet, Path: "/admin/kyc/:id/document", Summary: "Download the document of a submission", Params: []openapi.Param{idParam},
+			Files: []string{"application/pdf", "image/jpeg", "image/png"}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/kyc/:id/approve", Summary: "Approve a submission", Params: []openapi.Param{idParam},
+			Response: KYCResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/kyc/:id/reject", Summary: "Reject a submission", Params: []openapi.Param{idParam},
+			Request: RejectKYCParam{}, Response: KYCResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	}, "Admin KYC", securityBearer, true)
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/admin/merchants", Summary: "Create a merchant and its first API key",
+			Request: CreateMerchantParam{}, Status: http.StatusCreated, Response: CreateMerchantResponse{}, Errors: transactionErrors},
+		{Method: http.MethodGet, Path: "/admin/merchants/:id", Summary: "Get a merchant", Params: []openapi.Param{idParam},
+			Response: MerchantResponse{}, Errors: transactionErrors},
+		{Method: http.MethodPut, Path: "/admin/merchants/:id/status", Summary: "Activate or suspend a merchant", Params: []openapi.Param{idParam},
+			Request: MerchantStatusParam{}, Response: MerchantResponse{}, Errors: transactionErrors},
+		{Method: http.MethodPost, Path: "/admin/merchants/:id/api-keys", Summary: "Rotate the API key of a merchant", Params: []openapi.Param{idParam},
+			Status: http.StatusCreated, Response: APIKeyResponse{}, Errors: transactionErrors},
+	}, "Admin merchants", securityBearer, true)
+	add(webhookRoutes("/admin"), "Admin webhooks", securityBearer, true)
+	add([]openapi.Route{
+		{Method: http.MethodGet, Path: "/admin/audit-logs", Summary: "Search the audit log, newest first",
+			Params: append([]openapi.Param{
+				{Name: "event_type", In: "query"},
+				{Name: "actor_id", In: "query", Schema: &openapi.Schema{Type: "string", Format: "uuid"}},
+				{Name: "subject_id", In: "query"},
+				{Name: "from", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+				{Name: "to", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+			}, paginationParams...),
+			Response: []domain.AuditLog{}, Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodGet, Path: "/admin/audit-logs/verify", Summary: "Verify the hash chain of the audit log",
+			Response: domain.AuditChainReport{}},
+	}, "Admin audit", securityBearer, true)
+	add([]openapi.Route{
+		{Method: http.MethodPost, Path: "/admin/reconciliation/runs", Summary: "Reconcile the ledger now",
+			Request: StartRunParam{}, Status: http.StatusCreated, Response: domain.ReconciliationRun{}, Errors: []int{http.StatusBadRequest}},
+		{Method: http.MethodGet, Path: "/admin/reconciliation/runs", Summary: "List the reconciliation runs", Params: paginationParams,
+			Response: []domain.ReconciliationRun{}},
+		{Method: http.MethodGet, Path: "/admin/reconciliation/runs/:id", Summary: "Get a reconciliation run", Params: []openapi.Param{idParam},
+			Response: domain.ReconciliationRun{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodGet, Path: "/admin/repair-tasks", Summary: "List the repair tasks",
+			Params:   append([]openapi.Param{{Name: "status", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"open", "resolved"}}}}, paginationParams...),
+			Response: []domain.RepairTask{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/repair-tasks/:id/resolve", Summary: "Resolve a repair task", Params: []openapi.Param{idParam},
+			Request: ResolveRepairTaskParam{}, Response: domain.RepairTask{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	}, "Admin reconciliation", securityBearer, true)
+	add([]openapi.Route{
+		{Method: http.MethodGet, Path: "/admin/fee-rules", Summary: "List the fee rules, active or not",
+			Params:   []openapi.Param{{Name: "kind", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []string{"topup", "payment", "transfer", "withdrawal"}}}},
+			Response: []domain.FeeRule{}},
+		{Method: http.MethodPost, Path: "/admin/fee-rules", Summary: "Create a fee rule",
+			Request: FeeRuleParam{}, Status: http.StatusCreated, Response: domain.FeeRule{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+		{Method: http.MethodPut, Path: "/admin/fee-rules/:id", Summary: "Replace a fee rule, or retire it with active false", Params: []openapi.Param{idParam},
+			Request: FeeRuleParam{}, Response: domain.FeeRule{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	}, "Admin fees", securityBearer, true)
+	return routes
+}
+
+// webhookRoutes are served to merchants for their own endpoints and to admins for any.
+func webhookRoutes(prefix string) []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodPost, Path: prefix + "/webhooks", Summary: "Register a webhook endpoint, the secret is only shown once",
+			Request: WebhookEndpointParam{}, Status: http.StatusCreated, Response: WebhookEndpointResponse{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: prefix + "/webhooks", Summary: "List the webhook endpoints",
+			Response: []WebhookEndpointResponse{}},
+		{Method: http.MethodDelete, Path: prefix + "/webhooks/:id", Summary: "Disable a webhook endpoint", Params: []openapi.Param{idParam},
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodGet, Path: prefix + "/webhooks/:id/deliveries", Summary: "List the deliveries to an endpoint",
+			Params:   append([]openapi.Param{idParam}, paginationParams...),
+			Response: []domain.WebhookDelivery{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		{Method: http.MethodPost, Path: prefix + "/webhook-deliveries/:id/redeliver", Summary: "Send a delivery again", Params: []openapi.Param{idParam},
+			Status: http.StatusAccepted, Response: domain.WebhookDelivery{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/skip2/go-qrcode"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+const qrImageSize = 256
+
+type QRHandler struct {
+	qrService domain.QRService
+}
+
+func NewQRHandler(qrService domain.QRService) *QRHandler {
+	return &QRHandler{qrService: qrService}
+}
+
+// GetStaticQR returns the merchant's static payload, or its PNG image with ?format=png.
+func (h *QRHandler) GetStaticQR(c echo.Context) error {
+	merchantID := c.Get(middlewares.MerchantIDKey).(uuid.UUID)
+
+	payload, err := h.qrService.GetStaticQR(c.Request().Context(), merchantID)
+	if err != nil {
+		return localizedError(c, qrErrorStatus(err), "get_qr_failed", err)
+	}
+	if c.QueryParam("format") == "png" {
+		return renderQR(c, payload)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": QRResponse{Payload: payload},
+	})
+}
+
+func (h *QRHandler) CreateDynamicQR(c echo.Context) error {
+	merchantID := c.Get(middlewares.MerchantIDKey).(uuid.UUID)
+
+	var req DynamicQRParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	ttl := 15 * time.Minute
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+
+	qr, payload, err := h.qrService.CreateDynamicQR(c.Request().Context(), merchantID, req.Amount, ttl)
+	if err != nil {
+		return localizedError(c, qrErrorStatus(err), "create_qr_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": toQRResponse(&qr, payload),
+	})
+}
+
+// GetDynamicQR returns a dynamic code, or its PNG image with ?format=png.
+func (h *QRHandler) GetDynamicQR(c echo.Context) error {
+	merchantID := c.Get(middlewares.MerchantIDKey).(uuid.UUID)
+	qrID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	qr, payload, err := h.qrService.GetDynamicQR(c.Request().Context(), merchantID, qrID)
+	if err != nil {
+		return localizedError(c, qrErrorStatus(err), "get_qr_failed", err)
+	}
+	if c.QueryParam("format") == "png" {
+		return renderQR(c, payload)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toQRResponse(&qr, payload),
+	})
+}
+
+func (h *QRHandler) PayQR(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req PayQRParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	transInfo, err := h.qrService.PayQR(c.Request().Context(), domain.QRPayment{
+		UserID:  userID,
+		Payload: req.Payload,
+		Amount:  req.Amount,
+		Remarks: req.Remarks,
+	})
+	if err != nil {
+		return localizedError(c, qrErrorStatus(err), "qr_payment_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toTransactionResponse(transInfo),
+	})
+}
+
+func renderQR(c echo.Context, payload string) error {
+	png, err := qrcode.Encode(payload, qrcode.Medium, qrImageSize)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "render_qr_failed", err)
+	}
+	return c.Blob(http.StatusOK, "image/png", png)
+}
+
+func qrErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrQRNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidQR),
+		errors.Is(err, domain.ErrInvalidQRTTL),
+		errors.Is(err, domain.ErrQRAmountMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrQRUnavailable):
+		return http.StatusConflict
+	default:
+		return merchantErrorStatus(err)
+	}
+}
+
+func toQRResponse(qr *domain.QRCode, payload string) QRResponse {
+	return QRResponse{
+		QRID:      qr.ID.String(),
+		Payload:   payload,
+		Amount:    qr.Amount,
+		Status:    qr.Status,
+		ExpiresAt: qr.ExpiresAt.Format(time.DateTime),
+	}
+}
+
+type DynamicQRParam struct {
+	Amount    int64 `json:"amount" validate:"amount"`
+	ExpiresIn int64 `json:"expires_in" validate:"omitempty,min=60,max=86400"` // seconds, defaults to 15 minutes
+}
+
+// PayQRParam carries the scanned payload, Amount is only read for static codes.
+type PayQRParam struct {
+	Payload string `json:"payload" validate:"required,max=512"`
+	Amount  int64  `json:"amount" validate:"omitempty,amount"`
+	Remarks string `json:"remarks" validate:"remark"`
+}
+
+type QRResponse struct {
+	QRID      string `json:"qr_id,omitempty"`
+	Payload   string `json:"payload"`
+	Amount    int64  `json:"amount,omitempty"`
+	Status    string `json:"status,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
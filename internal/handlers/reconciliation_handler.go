@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+// defaultStuckAfter is how long a transfer may stay pending before a run started over the
+// API reports it, when the request doesn't say.
+const defaultStuckAfter = 30 * time.Minute
+
+type ReconciliationHandler struct {
+	reconciliationService domain.ReconciliationService
+}
+
+func NewReconciliationHandler(reconciliationService domain.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{reconciliationService: reconciliationService}
+}
+
+// StartRun runs a reconciliation now. stuck_after is a Go duration such as "30m".
+func (h *ReconciliationHandler) StartRun(c echo.Context) error {
+	var req StartRunParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	opts := domain.ReconciliationOptions{StuckAfter: defaultStuckAfter, OpenRepairTasks: req.OpenRepairTasks}
+	if req.StuckAfter != "" {
+		stuckAfter, err := time.ParseDuration(req.StuckAfter)
+		if err != nil || stuckAfter <= 0 {
+			return invalidField(c, http.StatusBadRequest, "stuck_after", "duration", "")
+		}
+		opts.StuckAfter = stuckAfter
+	}
+
+	run, err := h.reconciliationService.Run(c.Request().Context(), opts)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "reconciliation_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": run,
+	})
+}
+
+func (h *ReconciliationHandler) GetRuns(c echo.Context) error {
+	limit, offset := pagination(c)
+	runs, err := h.reconciliationService.GetRuns(c.Request().Context(), limit, offset)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_reconciliation_runs_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": runs,
+	})
+}
+
+func (h *ReconciliationHandler) GetRun(c echo.Context) error {
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	run, err := h.reconciliationService.GetRun(c.Request().Context(), runID)
+	if err != nil {
+		return localizedError(c, reconciliationErrorStatus(err), "get_reconciliation_run_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": run,
+	})
+}
+
+// GetRepairTasks lists repair tasks, filtered by the status query parameter when given.
+func (h *ReconciliationHandler) GetRepairTasks(c echo.Context) error {
+	limit, offset := pagination(c)
+	tasks, err := h.reconciliationService.GetRepairTasks(c.Request().Context(), c.QueryParam("status"), limit, offset)
+	if err != nil {
+		return localizedError(c, reconciliationErrorStatus(err), "get_repair_tasks_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": tasks,
+	})
+}
+
+func (h *ReconciliationHandler) ResolveRepairTask(c echo.Context) error {
+	actorID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	taskID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req ResolveRepairTaskParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	task, err := h.reconciliationService.ResolveRepairTask(c.Request().Context(), actorID, taskID, req.Resolution)
+	if err != nil {
+		return localizedError(c, reconciliationErrorStatus(err), "resolve_repair_task_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": task,
+	})
+}
+
+func reconciliationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrRunNotFound),
+		errors.Is(err, domain.ErrRepairTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrReasonRequired),
+		errors.Is(err, domain.ErrInvalidRepairStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrRepairTaskResolved):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type StartRunParam struct {
+	StuckAfter      string `json:"stuck_after"`
+	OpenRepairTasks bool   `json:"open_repair_tasks"`
+}
+
+type ResolveRepairTaskParam struct {
+	Resolution string `json:"resolution" validate:"required,max=255"`
+}
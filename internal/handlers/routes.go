@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+
+	"github.com/labstack/echo/v4"
+)
+
+// API holds what the routes below APIPrefix are served by.
+type API struct {
+	Auth           *AuthHandler
+	TopUp          *TopUpHandler
+	Transaction    *TransactionHandler
+	QR             *QRHandler
+	Limit          *LimitHandler
+	Statement      *StatementHandler
+	Withdrawal     *WithdrawalHandler
+	KYC            *KYCHandler
+	Schedule       *ScheduledTransferHandler
+	Merchant       *MerchantHandler
+	Webhook        *WebhookHandler
+	Admin          *AdminHandler
+	Audit          *AuditHandler
+	Reconciliation *ReconciliationHandler
+	Fee            *FeeHandler
+
+	// AuthMiddleware lets users in, MerchantAuthMiddleware merchants by their API key.
+	AuthMiddleware         echo.MiddlewareFunc
+	MerchantAuthMiddleware echo.MiddlewareFunc
+	// AuthRateLimit guards logging in and registering, MoneyRateLimit the routes moving money.
+	AuthRateLimit  echo.MiddlewareFunc
+	MoneyRateLimit echo.MiddlewareFunc
+}
+
+// RegisterRoutes serves the API on apiV1, the group of APIPrefix. Every route it registers
+// must be documented by APIDocument.
+func RegisterRoutes(apiV1 *echo.Group, api API) {
+	authMiddleware, moneyRateLimit := api.AuthMiddleware, api.MoneyRateLimit
+
+	apiV1.POST("/register", api.Auth.Register, api.AuthRateLimit)
+	apiV1.POST("/login", api.Auth.Login, api.AuthRateLimit)
+	apiV1.PUT("/profile", api.Auth.UpdateProfile, authMiddleware)
+	apiV1.PUT("/profile/language", api.Auth.SetLanguage, authMiddleware)
+	apiV1.PUT("/pin", api.Auth.ChangePin, authMiddleware)
+	apiV1.POST("/account/close", api.Auth.CloseAccount, authMiddleware)
+
+	apiV1.POST("/topup", api.TopUp.CreateTopUp, authMiddleware, moneyRateLimit)
+	apiV1.GET("/topup/:id", api.TopUp.GetTopUp, authMiddleware)
+	apiV1.POST("/gateway/:provider/callback", api.TopUp.GatewayCallback)
+	apiV1.POST("/pay", api.Transaction.PaymentHandler, authMiddleware, moneyRateLimit)
+	apiV1.POST("/pay/qr", api.QR.PayQR, authMiddleware, moneyRateLimit)
+	apiV1.POST("/transfer", api.Transaction.TransferHandler, authMiddleware, moneyRateLimit)
+	apiV1.POST("/transfer/inquiry", api.Transaction.TransferInquiryHandler, authMiddleware, moneyRateLimit)
+	apiV1.GET("/transactions", api.Transaction.GetAllTransactions, authMiddleware)
+	apiV1.GET("/limits", api.Limit.GetRemainingLimits, authMiddleware)
+	apiV1.GET("/statements", api.Statement.GetStatement, authMiddleware)
+
+	apiV1.POST("/bank-accounts/inquiry", api.Withdrawal.InquireBankAccount, authMiddleware, moneyRateLimit)
+	apiV1.POST("/bank-accounts", api.Withdrawal.AddBankAccount, authMiddleware)
+	apiV1.GET("/bank-accounts", api.Withdrawal.GetBankAccounts, authMiddleware)
+	apiV1.DELETE("/bank-accounts/:id", api.Withdrawal.RemoveBankAccount, authMiddleware)
+	apiV1.POST("/withdrawals", api.Withdrawal.CreateWithdrawal, authMiddleware, moneyRateLimit)
+	apiV1.GET("/withdrawals", api.Withdrawal.GetWithdrawals, authMiddleware)
+	apiV1.GET("/withdrawals/:id", api.Withdrawal.GetWithdrawal, authMiddleware)
+	apiV1.POST("/disbursement/:provider/callback", api.Withdrawal.DisbursementCallback)
+
+	apiV1.POST("/kyc", api.KYC.Submit, authMiddleware)
+	apiV1.GET("/kyc", api.KYC.GetStatus, authMiddleware)
+
+	schedules := apiV1.Group("/scheduled-transfers", authMiddleware)
+	schedules.POST("", api.Schedule.CreateSchedule)
+	schedules.GET("", api.Schedule.GetSchedules)
+	schedules.GET("/:id", api.Schedule.GetSchedule)
+	schedules.PUT("/:id", api.Schedule.UpdateSchedule)
+	schedules.DELETE("/:id", api.Schedule.CancelSchedule)
+	schedules.GET("/:id/runs", api.Schedule.GetScheduleRuns)
+
+	merchant := apiV1.Group("/merchant", api.MerchantAuthMiddleware)
+	merchant.GET("", api.Merchant.GetProfile)
+	merchant.GET("/payments", api.Merchant.GetPayments)
+	merchant.POST("/payments/:id/refunds", api.Merchant.Refund)
+	merchant.GET("/qr", api.QR.GetStaticQR)
+	merchant.POST("/qr", api.QR.CreateDynamicQR)
+	merchant.GET("/qr/:id", api.QR.GetDynamicQR)
+	merchant.POST("/webhooks", api.Webhook.CreateEndpoint)
+	merchant.GET("/webhooks", api.Webhook.GetEndpoints)
+	merchant.DELETE("/webhooks/:id", api.Webhook.DisableEndpoint)
+	merchant.GET("/webhooks/:id/deliveries", api.Webhook.GetDeliveries)
+	merchant.POST("/webhook-deliveries/:id/redeliver", api.Webhook.Redeliver)
+
+	admin := apiV1.Group("/admin", authMiddleware)
+	admin.GET("/users", api.Admin.SearchUsers, middlewares.RequirePermission(domain.PermissionUsersRead))
+	admin.GET("/users/:id", api.Admin.GetUser, middlewares.RequirePermission(domain.PermissionUsersRead))
+	admin.GET("/users/:id/transactions", api.Admin.GetUserTransactions, middlewares.RequirePermission(domain.PermissionTransactionsRead))
+	admin.POST("/users/:id/freeze", api.Admin.FreezeUser, middlewares.RequirePermission(domain.PermissionUsersFreeze))
+	admin.POST("/users/:id/unfreeze", api.Admin.UnfreezeUser, middlewares.RequirePermission(domain.PermissionUsersFreeze))
+	admin.POST("/users/:id/close", api.Admin.CloseUser, middlewares.RequirePermission(domain.PermissionUsersClose))
+	admin.PUT("/users/:id/role", api.Admin.SetUserRole, middlewares.RequirePermission(domain.PermissionUsersManageRoles))
+	admin.POST("/users/:id/adjustments", api.Admin.AdjustBalance, middlewares.RequirePermission(domain.PermissionBalanceAdjust))
+	admin.GET("/users/:id/statements", api.Statement.GetUserStatement, middlewares.RequirePermission(domain.PermissionTransactionsRead))
+	admin.GET("/transactions/:id", api.Admin.GetTransaction, middlewares.RequirePermission(domain.PermissionTransactionsRead))
+	admin.GET("/kyc", api.KYC.GetReviewQueue, middlewares.RequirePermission(domain.PermissionKYCReview))
+	admin.GET("/kyc/:id/document", api.KYC.GetDocument, middlewares.RequirePermission(domain.PermissionKYCReview))
+	admin.POST("/kyc/:id/approve", api.KYC.Approve, middlewares.RequirePermission(domain.PermissionKYCReview))
+	admin.POST("/kyc/:id/reject", api.KYC.Reject, middlewares.RequirePermission(domain.PermissionKYCReview))
+	admin.POST("/merchants", api.Merchant.CreateMerchant, middlewares.RequirePermission(domain.PermissionMerchantsManage))
+	admin.GET("/merchants/:id", api.Merchant.GetMerchant, middlewares.RequirePermission(domain.PermissionMerchantsRead))
+	admin.PUT("/merchants/:id/status", api.Merchant.SetMerchantStatus, middlewares.RequirePermission(domain.PermissionMerchantsManage))
+	admin.POST("/merchants/:id/api-keys", api.Merchant.RotateAPIKey, middlewares.RequirePermission(domain.PermissionMerchantsManage))
+	admin.POST("/webhooks", api.Webhook.CreateEndpoint, middlewares.RequirePermission(domain.PermissionWebhooksManage))
+	admin.GET("/webhooks", api.Webhook.GetEndpoints, middlewares.RequirePermission(domain.PermissionWebhooksManage))
+	admin.DELETE("/webhooks/:id", api.Webhook.DisableEndpoint, middlewares.RequirePermission(domain.PermissionWebhooksManage))
+	admin.GET("/webhooks/:id/deliveries", api.Webhook.GetDeliveries, middlewares.RequirePermission(domain.PermissionWebhooksManage))
+	admin.POST("/webhook-deliveries/:id/redeliver", api.Webhook.Redeliver, middlewares.RequirePermission(domain.PermissionWebhooksManage))
+	admin.GET("/audit-logs", api.Audit.GetAuditLogs, middlewares.RequirePermission(domain.PermissionAuditRead))
+	admin.GET("/audit-logs/verify", api.Audit.VerifyChain, middlewares.RequirePermission(domain.PermissionAuditRead))
+	admin.POST("/reconciliation/runs", api.Reconciliation.StartRun, middlewares.RequirePermission(domain.PermissionLedgerReconcile))
+	admin.GET("/reconciliation/runs", api.Reconciliation.GetRuns, middlewares.RequirePermission(domain.PermissionLedgerReconcile))
+	admin.GET("/reconciliation/runs/:id", api.Reconciliation.GetRun, middlewares.RequirePermission(domain.PermissionLedgerReconcile))
+	admin.GET("/repair-tasks", api.Reconciliation.GetRepairTasks, middlewares.RequirePermission(domain.PermissionLedgerReconcile))
+	admin.POST("/repair-tasks/:id/resolve", api.Reconciliation.ResolveRepairTask, middlewares.RequirePermission(domain.PermissionLedgerReconcile))
+	admin.GET("/fee-rules", api.Fee.GetFeeRules, middlewares.RequirePermission(domain.PermissionFeesManage))
+	admin.POST("/fee-rules", api.Fee.CreateFeeRule, middlewares.RequirePermission(domain.PermissionFeesManage))
+	admin.PUT("/fee-rules/:id", api.Fee.UpdateFeeRule, middlewares.RequirePermission(domain.PermissionFeesManage))
+}
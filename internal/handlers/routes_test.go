@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"slices"
+	"strings"
+	"tahap2/internal/openapi"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func servedRoutes(t *testing.T) []*echo.Route {
+	t.Helper()
+	pass := func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	e := echo.New()
+	RegisterRoutes(e.Group(APIPrefix), API{AuthMiddleware: pass, MerchantAuthMiddleware: pass, AuthRateLimit: pass, MoneyRateLimit: pass})
+	return e.Routes()
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	if err := APIDocument().CheckRoutes(servedRoutes(t), APIPrefix); err != nil {
+		t.Fatalf("openapi document and routes drifted apart:\n%v", err)
+	}
+}
+
+// TestDocumentedErrors checks the Errors of every route against the handler serving it: they
+// must list exactly the error statuses the handler's code, or any function of this package it
+// calls, can answer with. 500 is documented for every route.
+func TestDocumentedErrors(t *testing.T) {
+	statuses := answeredStatuses(t)
+	documented := make(map[string]openapi.Route)
+	for _, route := range apiRoutes() {
+		documented[route.Method+" "+APIPrefix+route.Path] = route
+	}
+
+	for _, served := range servedRoutes(t) {
+		route, ok := documented[served.Method+" "+served.Path]
+		if !ok {
+			continue
+		}
+		handler := handlerName(served.Name)
+		answered, ok := statuses[handler]
+		if !ok {
+			t.Errorf("%s %s: handler %s not found in the source", served.Method, served.Path, handler)
+			continue
+		}
+
+		var want []int
+		for _, status := range answered {
+			if status >= 400 && status != http.StatusInternalServerError && !slices.Contains(want, status) {
+				want = append(want, status)
+			}
+		}
+		slices.Sort(want)
+		got := slices.Sorted(slices.Values(route.Errors))
+		if !slices.Equal(got, want) {
+			t.Errorf("%s %s: documented errors %v, %s answers %v", served.Method, served.Path, got, handler, want)
+		}
+	}
+}
+
+// handlerName turns the name echo gives a route's handler, such as
+// tahap2/internal/handlers.(*AuthHandler).Login-fm, into AuthHandler.Login.
+func handlerName(name string) string {
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimPrefix(name, "handlers.")
+	name = strings.TrimSuffix(name, "-fm")
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
+
+// answeredStatuses reads the package's source and returns, by function name (Type.Method for
+// methods), the statuses the function names itself or through the functions it calls.
+func answeredStatuses(t *testing.T) map[string][]int {
+	t.Helper()
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	direct := make(map[string][]int)
+	calls := make(map[string][]string)
+	for name, file := range pkgs["handlers"].Files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			key, receiver := fn.Name.Name, ""
+			if fn.Recv != nil {
+				typ := fn.Recv.List[0].Type
+				if star, ok := typ.(*ast.StarExpr); ok {
+					typ = star.X
+				}
+				key = typ.(*ast.Ident).Name + "." + fn.Name.Name
+				if names := fn.Recv.List[0].Names; len(names) > 0 {
+					receiver = names[0].Name
+				}
+			}
+
+			ast.Inspect(fn.Body, func(node ast.Node) bool {
+				switch n := node.(type) {
+				case *ast.SelectorExpr:
+					if pkg, ok := n.X.(*ast.Ident); ok && pkg.Name == "http" && strings.HasPrefix(n.Sel.Name, "Status") {
+						status, ok := statusCodes[n.Sel.Name]
+						if !ok {
+							t.Fatalf("%s: add http.%s to statusCodes", key, n.Sel.Name)
+						}
+						direct[key] = append(direct[key], status)
+					}
+				case *ast.CallExpr:
+					switch callee := n.Fun.(type) {
+					case *ast.Ident:
+						calls[key] = append(calls[key], callee.Name)
+					case *ast.SelectorExpr:
+						if x, ok := callee.X.(*ast.Ident); ok && receiver != "" && x.Name == receiver {
+							calls[key] = append(calls[key], strings.Split(key, ".")[0]+"."+callee.Sel.Name)
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	all := make(map[string][]int)
+	var collect func(key string, seen map[string]bool) []int
+	collect = func(key string, seen map[string]bool) []int {
+		if seen[key] {
+			return nil
+		}
+		seen[key] = true
+		statuses := slices.Clone(direct[key])
+		for _, callee := range calls[key] {
+			statuses = append(statuses, collect(callee, seen)...)
+		}
+		return statuses
+	}
+	for key := range direct {
+		all[key] = collect(key, map[string]bool{})
+	}
+	for key := range calls {
+		if _, ok := all[key]; !ok {
+			all[key] = collect(key, map[string]bool{})
+		}
+	}
+	return all
+}
+
+var statusCodes = map[string]int{
+	"StatusOK":                    http.StatusOK,
+	"StatusCreated":               http.StatusCreated,
+	"StatusAccepted":              http.StatusAccepted,
+	"StatusNoContent":             http.StatusNoContent,
+	"StatusBadRequest":            http.StatusBadRequest,
+	"StatusUnauthorized":          http.StatusUnauthorized,
+	"StatusForbidden":             http.StatusForbidden,
+	"StatusNotFound":              http.StatusNotFound,
+	"StatusConflict":              http.StatusConflict,
+	"StatusGone":                  http.StatusGone,
+	"StatusRequestEntityTooLarge": http.StatusRequestEntityTooLarge,
+	"StatusUnsupportedMediaType":  http.StatusUnsupportedMediaType,
+	"StatusUnprocessableEntity":   http.StatusUnprocessableEntity,
+	"StatusTooManyRequests":       http.StatusTooManyRequests,
+	"StatusInternalServerError":   http.StatusInternalServerError,
+	"StatusBadGateway":            http.StatusBadGateway,
+	"StatusServiceUnavailable":    http.StatusServiceUnavailable,
+}
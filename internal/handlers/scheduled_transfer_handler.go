@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+type ScheduledTransferHandler struct {
+	scheduleService domain.ScheduledTransferService
+}
+
+func NewScheduledTransferHandler(scheduleService domain.ScheduledTransferService) *ScheduledTransferHandler {
+	return &ScheduledTransferHandler{scheduleService: scheduleService}
+}
+
+func (h *ScheduledTransferHandler) CreateSchedule(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req ScheduledTransferParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(c.Request().Context(), req.toDomain(userID))
+	if err != nil {
+		return localizedError(c, scheduleErrorStatus(err), "create_scheduled_transfer_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": toScheduledTransferResponse(&schedule),
+	})
+}
+
+func (h *ScheduledTransferHandler) GetSchedules(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	schedules, err := h.scheduleService.GetSchedules(c.Request().Context(), userID)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_scheduled_transfers_failed", err)
+	}
+
+	result := make([]ScheduledTransferResponse, len(schedules))
+	for i, schedule := range schedules {
+		result[i] = toScheduledTransferResponse(schedule)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": result,
+	})
+}
+
+func (h *ScheduledTransferHandler) GetSchedule(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(c.Request().Context(), userID, scheduleID)
+	if err != nil {
+		return localizedError(c, scheduleErrorStatus(err), "get_scheduled_transfer_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toScheduledTransferResponse(schedule),
+	})
+}
+
+func (h *ScheduledTransferHandler) UpdateSchedule(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	var req ScheduledTransferParam
+	if err = bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	update := req.toDomain(userID)
+	update.ID = scheduleID
+	schedule, err := h.scheduleService.UpdateSchedule(c.Request().Context(), userID, update)
+	if err != nil {
+		return localizedError(c, scheduleErrorStatus(err), "update_scheduled_transfer_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toScheduledTransferResponse(&schedule),
+	})
+}
+
+func (h *ScheduledTransferHandler) CancelSchedule(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	err = h.scheduleService.CancelSchedule(c.Request().Context(), userID, scheduleID)
+	if err != nil {
+		return localizedError(c, scheduleErrorStatus(err), "cancel_scheduled_transfer_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+func (h *ScheduledTransferHandler) GetScheduleRuns(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	runs, err := h.scheduleService.GetScheduleRuns(c.Request().Context(), userID, scheduleID)
+	if err != nil {
+		return localizedError(c, scheduleErrorStatus(err), "get_scheduled_transfer_runs_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": runs,
+	})
+}
+
+func scheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrScheduleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidFrequency),
+		errors.Is(err, domain.ErrScheduleInPast),
+		errors.Is(err, domain.ErrInvalidScheduleEnd):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrScheduleNotEditable):
+		return http.StatusConflict
+	default:
+		return transactionErrorStatus(err)
+	}
+}
+
+func (p ScheduledTransferParam) toDomain(userID uuid.UUID) domain.ScheduledTransfer {
+	return domain.ScheduledTransfer{
+		UserID:            userID,
+		TargetPhoneNumber: p.PhoneNumber,
+		Amount:            p.Amount,
+		Remark:            p.Remarks,
+		Frequency:         p.Frequency,
+		StartAt:           p.StartAt,
+		EndAt:             p.EndAt,
+	}
+}
+
+func toScheduledTransferResponse(schedule *domain.ScheduledTransfer) ScheduledTransferResponse {
+	resp := ScheduledTransferResponse{
+		ScheduleID:  schedule.ID.String(),
+		PhoneNumber: schedule.TargetPhoneNumber,
+		Amount:      schedule.Amount,
+		Remarks:     schedule.Remark,
+		Frequency:   schedule.Frequency,
+		StartAt:     schedule.StartAt.Format(time.DateTime),
+		NextRunAt:   schedule.NextRunAt.Format(time.DateTime),
+		Status:      schedule.Status,
+		CreatedAt:   schedule.CreatedAt.Format(time.DateTime),
+	}
+	if schedule.EndAt != nil {
+		resp.EndAt = schedule.EndAt.Format(time.DateTime)
+	}
+	if schedule.LastRunAt != nil {
+		resp.LastRunAt = schedule.LastRunAt.Format(time.DateTime)
+	}
+	return resp
+}
+
+type ScheduledTransferParam struct {
+	PhoneNumber string     `json:"phone_number" validate:"required,phone"`
+	Amount      int64      `json:"amount" validate:"amount"`
+	Remarks     string     `json:"remarks" validate:"remark"`
+	Frequency   string     `json:"frequency" validate:"required,oneof=once daily weekly monthly"`
+	StartAt     time.Time  `json:"start_at" validate:"required"`
+	EndAt       *time.Time `json:"end_at" validate:"omitempty,gtfield=StartAt"`
+}
+
+type ScheduledTransferResponse struct {
+	ScheduleID  string `json:"schedule_id"`
+	PhoneNumber string `json:"phone_number"`
+	Amount      int64  `json:"amount"`
+	Remarks     string `json:"remarks"`
+	Frequency   string `json:"frequency"`
+	StartAt     string `json:"start_at"`
+	EndAt       string `json:"end_at,omitempty"`
+	NextRunAt   string `json:"next_run_at"`
+	LastRunAt   string `json:"last_run_at,omitempty"`
+	Status      string `json:"status"`
+	CreatedAt   string `json:"created_at"`
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"log/slog"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+var statementContentTypes = map[string]string{
+	domain.StatementFormatCSV: "text/csv; charset=utf-8",
+	domain.StatementFormatPDF: "application/pdf",
+}
+
+type StatementHandler struct {
+	statementService domain.StatementService
+	logger           *slog.Logger
+}
+
+func NewStatementHandler(statementService domain.StatementService, logger *slog.Logger) *StatementHandler {
+	return &StatementHandler{statementService: statementService, logger: logger}
+}
+
+// GetStatement downloads the statement of the user for ?month=YYYY-MM as ?format=csv or pdf.
+func (h *StatementHandler) GetStatement(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	return h.writeStatement(c, userID)
+}
+
+// GetUserStatement is GetStatement for back-office staff, for the user in the path.
+func (h *StatementHandler) GetUserStatement(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+	return h.writeStatement(c, userID)
+}
+
+// writeStatement streams the statement as it is produced. Errors can only be reported while
+// nothing was written yet, later ones cut the download short.
+func (h *StatementHandler) writeStatement(c echo.Context, userID uuid.UUID) error {
+	month, err := domain.ParseStatementMonth(c.QueryParam("month"), time.Local)
+	if err != nil {
+		return localizedError(c, http.StatusBadRequest, "", err)
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = domain.StatementFormatPDF
+	}
+	if !domain.IsValidStatementFormat(format) {
+		return localizedError(c, http.StatusBadRequest, "", domain.ErrInvalidStatementFormat)
+	}
+
+	resp := c.Response()
+	resp.Header().Set(echo.HeaderContentType, statementContentTypes[format])
+	resp.Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="statement-%s.%s"`, month.Format(domain.StatementMonthLayout), format))
+
+	err = h.statementService.WriteStatement(c.Request().Context(), userID, month, format, resp)
+	if err != nil {
+		if !resp.Committed {
+			resp.Header().Del(echo.HeaderContentDisposition)
+			return localizedError(c, statementErrorStatus(err), "get_statement_failed", err)
+		}
+		h.logger.ErrorContext(c.Request().Context(), "statement cut short", "user_id", userID, "error", err)
+	}
+	return nil
+}
+
+func statementErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidStatementMonth),
+		errors.Is(err, domain.ErrInvalidStatementFormat):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
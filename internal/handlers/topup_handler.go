@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+// callbackBodyLimit caps the size of a gateway callback.
+const callbackBodyLimit = 64 << 10
+
+type TopUpHandler struct {
+	intentService domain.PaymentIntentService
+}
+
+func NewTopUpHandler(intentService domain.PaymentIntentService) *TopUpHandler {
+	return &TopUpHandler{intentService: intentService}
+}
+
+// CreateTopUp opens a payment intent, the wallet is credited once the gateway confirms it.
+func (h *TopUpHandler) CreateTopUp(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req TopUpParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	intent, err := h.intentService.CreateTopUpIntent(c.Request().Context(), userID, req.Amount, req.Method)
+	if err != nil {
+		return localizedError(c, topUpErrorStatus(err), "topup_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": toPaymentIntentResponse(&intent),
+	})
+}
+
+func (h *TopUpHandler) GetTopUp(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	intentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	intent, err := h.intentService.GetIntent(c.Request().Context(), userID, intentID)
+	if err != nil {
+		return localizedError(c, topUpErrorStatus(err), "get_topup_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toPaymentIntentResponse(intent),
+	})
+}
+
+// GatewayCallback receives payment notifications from a gateway. Gateways retry until they
+// get a 2xx, so repeated callbacks for an intent that is already settled are acknowledged.
+func (h *TopUpHandler) GatewayCallback(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, callbackBodyLimit))
+	if err != nil {
+		return refuse(c, http.StatusBadRequest, codeInvalidRequest)
+	}
+
+	err = h.intentService.HandleCallback(c.Request().Context(), c.Param("provider"), c.Request().Header.Get(domain.GatewayHeaderSignature), body)
+	if err != nil {
+		return localizedError(c, topUpErrorStatus(err), "callback_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+func topUpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrIntentNotFound),
+		errors.Is(err, domain.ErrUnknownGateway):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidAmount),
+		errors.Is(err, domain.ErrInvalidPaymentMethod):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrInvalidCallback):
+		return http.StatusUnauthorized
+	default:
+		return transactionErrorStatus(err)
+	}
+}
+
+func toPaymentIntentResponse(intent *domain.PaymentIntent) PaymentIntentResponse {
+	resp := PaymentIntentResponse{
+		IntentID:      intent.ID.String(),
+		Amount:        intent.Amount,
+		Method:        intent.Method,
+		Provider:      intent.Provider,
+		Status:        intent.Status,
+		VANumber:      intent.VANumber,
+		PaymentURL:    intent.PaymentURL,
+		FailureReason: intent.FailureReason,
+		TransactionID: optionalID(intent.TransactionID),
+		ExpiresAt:     intent.ExpiresAt.Format(time.DateTime),
+		CreatedAt:     intent.CreatedAt.Format(time.DateTime),
+	}
+	if intent.PaidAt != nil {
+		resp.PaidAt = intent.PaidAt.Format(time.DateTime)
+	}
+	return resp
+}
+
+type TopUpParam struct {
+	Amount int64  `json:"amount" validate:"amount"`
+	Method string `json:"method" validate:"required,oneof=va card"`
+}
+
+type PaymentIntentResponse struct {
+	IntentID      string `json:"intent_id"`
+	Amount        int64  `json:"amount"`
+	Method        string `json:"method"`
+	Provider      string `json:"provider"`
+	Status        string `json:"status"`
+	VANumber      string `json:"va_number,omitempty"`
+	PaymentURL    string `json:"payment_url,omitempty"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	ExpiresAt     string `json:"expires_at"`
+	PaidAt        string `json:"paid_at,omitempty"`
+	CreatedAt     string `json:"created_at"`
+}
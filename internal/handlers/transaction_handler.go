@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	"net/http"
@@ -18,20 +18,17 @@ func NewTransactionHandler(transService domain.TransactionService) *TransactionH
 	return &TransactionHandler{transService: transService}
 }
 
-func (h *TransactionHandler) TopupHandler(c echo.Context) error {
+func (h *TransactionHandler) PaymentHandler(c echo.Context) error {
 	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
 
-	var req struct {
-		Amount int64 `json:"amount"`
-	}
-
-	if err := c.Bind(&req); err != nil || req.Amount <= 0 {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid amount"})
+	var req PaymentParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	transInfo, err := h.transService.ProcessTopUp(userID, req.Amount)
+	transInfo, err := h.transService.ProcessPayment(c.Request().Context(), userID, req.MerchantID, req.Amount, req.Remarks)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": fmt.Sprintf("topup failed. : %s", err.Error())})
+		return localizedError(c, transactionErrorStatus(err), "payment_failed", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -40,21 +37,17 @@ func (h *TransactionHandler) TopupHandler(c echo.Context) error {
 	})
 }
 
-func (h *TransactionHandler) PaymentHandler(c echo.Context) error {
+func (h *TransactionHandler) TransferHandler(c echo.Context) error {
 	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
 
-	var req struct {
-		Amount  int64  `json:"amount"`
-		Remarks string `json:"remarks"`
+	var req TransferParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	if err := c.Bind(&req); err != nil || req.Amount <= 0 {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid amount"})
-	}
-
-	transInfo, err := h.transService.ProcessPayment(userID, req.Amount, req.Remarks)
+	transInfo, err := h.transService.ProcessTransfer(c.Request().Context(), userID, req.PhoneNumber, req.Amount, req.Remarks, uuid.Nil)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": fmt.Sprintf("payment failed. : %s", err.Error())})
+		return localizedError(c, transactionErrorStatus(err), "transfer_failed", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
@@ -63,36 +56,31 @@ func (h *TransactionHandler) PaymentHandler(c echo.Context) error {
 	})
 }
 
-func (h *TransactionHandler) TransferHandler(c echo.Context) error {
+func (h *TransactionHandler) TransferInquiryHandler(c echo.Context) error {
 	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
 
-	var req struct {
-		TargetUserID uuid.UUID `json:"target_user"`
-		Amount       int64     `json:"amount"`
-		Remarks      string    `json:"remarks"`
+	var req TransferParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
 	}
 
-	if err := c.Bind(&req); err != nil || req.Amount <= 0 {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "invalid amount"})
-	}
-
-	transInfo, err := h.transService.ProcessTransfer(userID, req.TargetUserID, req.Amount, req.Remarks)
+	inquiry, err := h.transService.InquiryTransfer(c.Request().Context(), userID, req.PhoneNumber, req.Amount)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": fmt.Sprintf("transfer failed. : %s", err.Error())})
+		return localizedError(c, transactionErrorStatus(err), "transfer_inquiry_failed", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{
 		"status": "success",
-		"result": toTransactionResponse(transInfo),
+		"result": inquiry,
 	})
 }
 
 func (h *TransactionHandler) GetAllTransactions(c echo.Context) error {
 	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
 
-	transactions, err := h.transService.GetAllTransactions(userID)
+	transactions, err := h.transService.GetAllTransactions(c.Request().Context(), userID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": fmt.Sprintf("get transactions failed. : %s", err.Error())})
+		return localizedError(c, http.StatusInternalServerError, "get_transactions_failed", err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{
 		"status": "success",
@@ -100,6 +88,30 @@ func (h *TransactionHandler) GetAllTransactions(c echo.Context) error {
 	})
 }
 
+// transactionErrorStatus maps transaction validation errors to client errors, everything else is a server error.
+func transactionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrTargetUserNotFound),
+		errors.Is(err, domain.ErrMerchantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrSelfTransfer),
+		errors.Is(err, domain.ErrRecipientUnverified),
+		errors.Is(err, domain.ErrInsufficientBalance),
+		errors.Is(err, domain.ErrFeeExceedsAmount),
+		errors.Is(err, domain.ErrAmountOverflow):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrLimitExceeded),
+		errors.Is(err, domain.ErrFeatureNotAllowed),
+		errors.Is(err, domain.ErrAccountFrozen),
+		errors.Is(err, domain.ErrAccountClosed),
+		errors.Is(err, domain.ErrRecipientClosed),
+		errors.Is(err, domain.ErrMerchantInactive):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 func toTransactionsDetailResponse(trans []*domain.Transaction) []*TransactionDetailsResponse {
 	result := make([]*TransactionDetailsResponse, len(trans))
 	for i, tran := range trans {
@@ -107,8 +119,12 @@ func toTransactionsDetailResponse(trans []*domain.Transaction) []*TransactionDet
 			TransactionID:   tran.ID.String(),
 			UserID:          tran.UserID.String(),
 			TransactionType: tran.TransactionType,
+			Kind:            tran.Kind,
 			Amount:          tran.Amount,
+			Fee:             tran.Fee,
 			Remarks:         tran.Remark,
+			ReferenceID:     optionalID(tran.ReferenceID),
+			MerchantID:      optionalID(tran.MerchantID),
 			BalanceBefore:   tran.BalanceBefore,
 			BalanceAfter:    tran.BalanceAfter,
 			Status:          tran.Status,
@@ -119,10 +135,18 @@ func toTransactionsDetailResponse(trans []*domain.Transaction) []*TransactionDet
 	return result
 }
 
+func optionalID(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
+
 func toTransactionResponse(src domain.Transaction) TransactionResponse {
 	return TransactionResponse{
 		TransactionID: src.ID.String(),
 		Amount:        src.Amount,
+		Fee:           src.Fee,
 		BalanceBefore: src.BalanceBefore,
 		BalanceAfter:  src.BalanceAfter,
 		Status:        src.Status,
@@ -130,12 +154,28 @@ func toTransactionResponse(src domain.Transaction) TransactionResponse {
 	}
 }
 
+type PaymentParam struct {
+	MerchantID uuid.UUID `json:"merchant_id" validate:"nonnil"`
+	Amount     int64     `json:"amount" validate:"amount"`
+	Remarks    string    `json:"remarks" validate:"remark"`
+}
+
+type TransferParam struct {
+	PhoneNumber string `json:"phone_number" validate:"required,phone"`
+	Amount      int64  `json:"amount" validate:"amount"`
+	Remarks     string `json:"remarks" validate:"remark"`
+}
+
 type TransactionDetailsResponse struct {
 	TransactionID   string `json:"transaction_id"`
 	UserID          string `json:"user_id"`
 	TransactionType string `json:"transaction_type"`
+	Kind            string `json:"kind"`
 	Amount          int64  `json:"amount"`
+	Fee             int64  `json:"fee"`
 	Remarks         string `json:"remarks"`
+	ReferenceID     string `json:"reference_id,omitempty"`
+	MerchantID      string `json:"merchant_id,omitempty"`
 	BalanceBefore   int64  `json:"balance_before"`
 	BalanceAfter    int64  `json:"balance_after"`
 	Status          string `json:"status"`
@@ -145,6 +185,7 @@ type TransactionDetailsResponse struct {
 type TransactionResponse struct {
 	TransactionID string `json:"transaction_id"`
 	Amount        int64  `json:"amount"`
+	Fee           int64  `json:"fee"`
 	BalanceBefore int64  `json:"balance_before"`
 	BalanceAfter  int64  `json:"balance_after"`
 	Status        string `json:"status"`
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"tahap2/internal/i18n"
+	"tahap2/internal/middlewares"
+	"tahap2/internal/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+const codeInvalidRequest = "invalid_request"
+
+// bindAndValidate binds the request into req and checks it against its validate tags. The
+// error is the answer for the client, a 400 listing the fields that were refused in the
+// language of the request, handlers return it as is.
+func bindAndValidate(c echo.Context, req any) error {
+	if err := c.Bind(req); err != nil {
+		lang := middlewares.Language(c)
+		resp := middlewares.ErrorResponse{Message: i18n.T(lang, codeInvalidRequest, nil), Code: codeInvalidRequest}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			resp.Errors = validation.Errors{
+				validation.NewFieldError(typeErr.Field, "type", jsonType(typeErr), typeErr.Type.Kind()),
+			}.Localize(lang)
+		}
+		// echo answers with an internal *echo.HTTPError rather than this one, so only its cause
+		// is kept.
+		var bindErr *echo.HTTPError
+		if errors.As(err, &bindErr) && bindErr.Internal != nil {
+			err = bindErr.Internal
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, resp).SetInternal(err)
+	}
+
+	if err := c.Validate(req); err != nil {
+		var fieldErrs validation.Errors
+		if errors.As(err, &fieldErrs) {
+			lang := middlewares.Language(c)
+			return echo.NewHTTPError(http.StatusBadRequest, middlewares.ErrorResponse{
+				Message: i18n.T(lang, codeInvalidRequest, nil),
+				Code:    codeInvalidRequest,
+				Errors:  fieldErrs.Localize(lang),
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+// jsonType names the JSON type a field expected, rather than the Go one.
+func jsonType(typeErr *json.UnmarshalTypeError) string {
+	switch typeErr.Type.String() {
+	case "uuid.UUID":
+		return "uuid"
+	case "time.Time":
+		return "timestamp"
+	}
+	switch typeErr.Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return typeErr.Type.Kind().String()
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+// WebhookHandler serves both the merchant API, where a merchant manages its own endpoints,
+// and the admin API, where partner endpoints are registered and any endpoint can be inspected.
+type WebhookHandler struct {
+	webhookService domain.WebhookService
+}
+
+func NewWebhookHandler(webhookService domain.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+func (h *WebhookHandler) CreateEndpoint(c echo.Context) error {
+	var req WebhookEndpointParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	endpoint, secret, err := h.webhookService.CreateEndpoint(c.Request().Context(), webhookScope(c), req.URL, req.Events)
+	if err != nil {
+		return localizedError(c, webhookErrorStatus(err), "create_webhook_failed", err)
+	}
+
+	resp := toWebhookEndpointResponse(&endpoint)
+	resp.Secret = secret
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": resp,
+	})
+}
+
+func (h *WebhookHandler) GetEndpoints(c echo.Context) error {
+	endpoints, err := h.webhookService.GetEndpoints(c.Request().Context(), webhookScope(c))
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_webhooks_failed", err)
+	}
+
+	result := make([]WebhookEndpointResponse, len(endpoints))
+	for i, endpoint := range endpoints {
+		result[i] = toWebhookEndpointResponse(endpoint)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": result,
+	})
+}
+
+func (h *WebhookHandler) DisableEndpoint(c echo.Context) error {
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	if err = h.webhookService.DisableEndpoint(c.Request().Context(), webhookScope(c), endpointID); err != nil {
+		return localizedError(c, webhookErrorStatus(err), "disable_webhook_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+func (h *WebhookHandler) GetDeliveries(c echo.Context) error {
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+	limit, offset := pagination(c)
+
+	deliveries, err := h.webhookService.GetDeliveries(c.Request().Context(), webhookScope(c), endpointID, limit, offset)
+	if err != nil {
+		return localizedError(c, webhookErrorStatus(err), "get_webhook_deliveries_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": deliveries,
+	})
+}
+
+func (h *WebhookHandler) Redeliver(c echo.Context) error {
+	deliveryID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	delivery, err := h.webhookService.Redeliver(c.Request().Context(), webhookScope(c), deliveryID)
+	if err != nil {
+		return localizedError(c, webhookErrorStatus(err), "redeliver_webhook_failed", err)
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{
+		"status": "success",
+		"result": delivery,
+	})
+}
+
+// webhookScope is the authenticated merchant on merchant routes and nil on admin routes.
+func webhookScope(c echo.Context) *uuid.UUID {
+	if merchantID, ok := c.Get(middlewares.MerchantIDKey).(uuid.UUID); ok {
+		return &merchantID
+	}
+	return nil
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound),
+		errors.Is(err, domain.ErrDeliveryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrInvalidWebhookURL),
+		errors.Is(err, domain.ErrWebhookURLNotPublic),
+		errors.Is(err, domain.ErrInvalidWebhookEvents):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func toWebhookEndpointResponse(endpoint *domain.WebhookEndpoint) WebhookEndpointResponse {
+	return WebhookEndpointResponse{
+		EndpointID: endpoint.ID,
+		MerchantID: optionalID(endpoint.MerchantID),
+		URL:        endpoint.URL,
+		Events:     endpoint.Events,
+		Active:     endpoint.Active,
+		CreatedAt:  endpoint.CreatedAt.Format(time.DateTime),
+	}
+}
+
+type WebhookEndpointParam struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+}
+
+type WebhookEndpointResponse struct {
+	EndpointID uuid.UUID `json:"endpoint_id"`
+	MerchantID string    `json:"merchant_id,omitempty"`
+	URL        string    `json:"url"`
+	Events     []string  `json:"events"`
+	Active     bool      `json:"active"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  string    `json:"created_at"`
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"io"
+	"net/http"
+	"tahap2/internal/domain"
+	"tahap2/internal/middlewares"
+	"time"
+)
+
+type WithdrawalHandler struct {
+	withdrawalService domain.WithdrawalService
+}
+
+func NewWithdrawalHandler(withdrawalService domain.WithdrawalService) *WithdrawalHandler {
+	return &WithdrawalHandler{withdrawalService: withdrawalService}
+}
+
+type BankAccountParam struct {
+	BankCode      string `json:"bank_code" validate:"required,max=20"`
+	AccountNumber string `json:"account_number" validate:"required,number,max=34"`
+}
+
+// InquireBankAccount returns the holder name the bank has for an account, so the user can
+// check it before saving the account.
+func (h *WithdrawalHandler) InquireBankAccount(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req BankAccountParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	name, err := h.withdrawalService.InquireBankAccount(c.Request().Context(), userID, req.BankCode, req.AccountNumber)
+	if err != nil {
+		return localizedError(c, withdrawalErrorStatus(err), "bank_account_inquiry_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": BankAccountInquiryResponse{AccountName: name},
+	})
+}
+
+func (h *WithdrawalHandler) AddBankAccount(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req BankAccountParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	account, err := h.withdrawalService.AddBankAccount(c.Request().Context(), userID, req.BankCode, req.AccountNumber)
+	if err != nil {
+		return localizedError(c, withdrawalErrorStatus(err), "add_bank_account_failed", err)
+	}
+
+	return c.JSON(http.StatusCreated, echo.Map{
+		"status": "success",
+		"result": toBankAccountResponse(&account),
+	})
+}
+
+func (h *WithdrawalHandler) GetBankAccounts(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	accounts, err := h.withdrawalService.GetBankAccounts(c.Request().Context(), userID)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_bank_accounts_failed", err)
+	}
+
+	result := make([]BankAccountResponse, len(accounts))
+	for i, account := range accounts {
+		result[i] = toBankAccountResponse(account)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": result,
+	})
+}
+
+func (h *WithdrawalHandler) RemoveBankAccount(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	if err = h.withdrawalService.RemoveBankAccount(c.Request().Context(), userID, accountID); err != nil {
+		return localizedError(c, withdrawalErrorStatus(err), "remove_bank_account_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+func (h *WithdrawalHandler) CreateWithdrawal(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+
+	var req WithdrawalParam
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	withdrawal, err := h.withdrawalService.RequestWithdrawal(c.Request().Context(), userID, req.BankAccountID, req.Amount)
+	if err != nil {
+		return localizedError(c, withdrawalErrorStatus(err), "withdrawal_failed", err)
+	}
+
+	return c.JSON(http.StatusAccepted, echo.Map{
+		"status": "success",
+		"result": toWithdrawalResponse(&withdrawal),
+	})
+}
+
+func (h *WithdrawalHandler) GetWithdrawals(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	limit, offset := pagination(c)
+
+	withdrawals, err := h.withdrawalService.GetWithdrawals(c.Request().Context(), userID, limit, offset)
+	if err != nil {
+		return localizedError(c, http.StatusInternalServerError, "get_withdrawals_failed", err)
+	}
+
+	result := make([]WithdrawalResponse, len(withdrawals))
+	for i, withdrawal := range withdrawals {
+		result[i] = toWithdrawalResponse(withdrawal)
+	}
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": result,
+	})
+}
+
+func (h *WithdrawalHandler) GetWithdrawal(c echo.Context) error {
+	userID := c.Get(middlewares.UserIDKey).(uuid.UUID)
+	withdrawalID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return invalidParameter(c, "id", "uuid", "")
+	}
+
+	withdrawal, err := h.withdrawalService.GetWithdrawal(c.Request().Context(), userID, withdrawalID)
+	if err != nil {
+		return localizedError(c, withdrawalErrorStatus(err), "get_withdrawal_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"status": "success",
+		"result": toWithdrawalResponse(withdrawal),
+	})
+}
+
+// DisbursementCallback receives the outcome of a disbursement from the provider.
+func (h *WithdrawalHandler) DisbursementCallback(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, callbackBodyLimit))
+	if err != nil {
+		return refuse(c, http.StatusBadRequest, codeInvalidRequest)
+	}
+
+	err = h.withdrawalService.HandleCallback(c.Request().Context(), c.Param("provider"), c.Request().Header.Get(domain.DisbursementHeaderSignature), body)
+	if err != nil {
+		return localizedError(c, withdrawalErrorStatus(err), "callback_failed", err)
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{"status": "success"})
+}
+
+func withdrawalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrBankAccountNotFound),
+		errors.Is(err, domain.ErrWithdrawalNotFound),
+		errors.Is(err, domain.ErrUnknownDisbursementProvider):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrBankAccountRequired),
+		errors.Is(err, domain.ErrInvalidAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrBankAccountUnknown):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrBankAccountExists):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrInvalidDisbursementCallback):
+		return http.StatusUnauthorized
+	default:
+		return transactionErrorStatus(err)
+	}
+}
+
+func toBankAccountResponse(account *domain.BankAccount) BankAccountResponse {
+	return BankAccountResponse{
+		BankAccountID: account.ID.String(),
+		BankCode:      account.BankCode,
+		AccountNumber: account.AccountNumber,
+		AccountName:   account.AccountName,
+		CreatedAt:     account.CreatedAt.Format(time.DateTime),
+	}
+}
+
+func toWithdrawalResponse(withdrawal *domain.Withdrawal) WithdrawalResponse {
+	resp := WithdrawalResponse{
+		WithdrawalID:  withdrawal.ID.String(),
+		BankCode:      withdrawal.BankCode,
+		AccountNumber: withdrawal.AccountNumber,
+		AccountName:   withdrawal.AccountName,
+		Amount:        withdrawal.Amount,
+		Fee:           withdrawal.Fee,
+		Status:        withdrawal.Status,
+		FailureReason: withdrawal.FailureReason,
+		TransactionID: withdrawal.TransactionID.String(),
+		CreatedAt:     withdrawal.CreatedAt.Format(time.DateTime),
+	}
+	if withdrawal.CompletedAt != nil {
+		resp.CompletedAt = withdrawal.CompletedAt.Format(time.DateTime)
+	}
+	return resp
+}
+
+type WithdrawalParam struct {
+	BankAccountID uuid.UUID `json:"bank_account_id" validate:"nonnil"`
+	Amount        int64     `json:"amount" validate:"amount"`
+}
+
+type BankAccountInquiryResponse struct {
+	AccountName string `json:"account_name"`
+}
+
+type BankAccountResponse struct {
+	BankAccountID string `json:"bank_account_id"`
+	BankCode      string `json:"bank_code"`
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+	CreatedAt     string `json:"created_at"`
+}
+
+type WithdrawalResponse struct {
+	WithdrawalID  string `json:"withdrawal_id"`
+	BankCode      string `json:"bank_code"`
+	AccountNumber string `json:"account_number"`
+	AccountName   string `json:"account_name"`
+	Amount        int64  `json:"amount"`
+	Fee           int64  `json:"fee"`
+	Status        string `json:"status"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	TransactionID string `json:"transaction_id"`
+	CreatedAt     string `json:"created_at"`
+	CompletedAt   string `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// defaultCheckTimeout bounds every check, so one hanging dependency can't hold the probe up.
+const defaultCheckTimeout = 2 * time.Second
+
+// Check reports why a component isn't usable, nil when it is.
+type Check func(ctx context.Context) error
+
+// ComponentStatus is how a single component did in a readiness check. Error is left out of
+// the answer, it may name hosts or hold driver details, and is for the logs only.
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"-"`
+}
+
+// Report is the outcome of a readiness check.
+type Report struct {
+	Ready        bool                       `json:"ready"`
+	ShuttingDown bool                       `json:"shutting_down,omitempty"`
+	Components   map[string]ComponentStatus `json:"components"`
+}
+
+// Checker runs the readiness checks of every component the app depends on.
+type Checker struct {
+	mutex        sync.RWMutex
+	checks       map[string]Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks:  make(map[string]Check),
+		timeout: defaultCheckTimeout,
+	}
+}
+
+// Register adds the check of the component called name.
+func (c *Checker) Register(name string, check Check) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.checks[name] = check
+}
+
+// ShutDown makes every check from now on report not ready, so load balancers stop sending
+// requests while the ones in flight finish.
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Check runs every check concurrently. The app is ready when all of them pass and it isn't
+// shutting down.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	report := Report{
+		Ready:        !c.shuttingDown.Load(),
+		ShuttingDown: c.shuttingDown.Load(),
+		Components:   make(map[string]ComponentStatus, len(c.checks)),
+	}
+	var (
+		wg          sync.WaitGroup
+		reportMutex sync.Mutex
+	)
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			status := ComponentStatus{Status: StatusUp}
+			if err := check(checkCtx); err != nil {
+				status = ComponentStatus{Status: StatusDown, Error: err.Error()}
+			}
+			reportMutex.Lock()
+			defer reportMutex.Unlock()
+			report.Components[name] = status
+			if status.Status == StatusDown {
+				report.Ready = false
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
@@ -0,0 +1,33 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat is beaten by a long running worker to show it is still making progress.
+type Heartbeat struct {
+	last atomic.Int64
+}
+
+// Beat records that the worker is alive now.
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Check fails when the worker never beat or hasn't beaten for longer than maxSilence.
+func (h *Heartbeat) Check(maxSilence time.Duration) Check {
+	return func(context.Context) error {
+		last := h.last.Load()
+		if last == 0 {
+			return errors.New("not started")
+		}
+		if silence := time.Since(time.Unix(0, last)); silence > maxSilence {
+			return fmt.Errorf("no heartbeat for %s", silence.Round(time.Second))
+		}
+		return nil
+	}
+}
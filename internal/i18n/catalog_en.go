@@ -0,0 +1,129 @@
+package i18n
+
+// english holds the English messages. The English messages of domain errors are the errors'
+// own, they aren't repeated here.
+var english = map[string]Message{
+	"invalid_request":         {Other: "invalid body request"},
+	"token_failed":            {Other: "failed to generate token"},
+	"payment_failed":          {Other: "payment failed. : {reason}"},
+	"transfer_failed":         {Other: "transfer failed. : {reason}"},
+	"transfer_inquiry_failed": {Other: "transfer inquiry failed. : {reason}"},
+	"get_transactions_failed": {Other: "get transactions failed. : {reason}"},
+	"invalid_parameters":      {Other: "invalid request parameters"},
+	"internal_error":          {Other: "something went wrong, please try again later"},
+
+	"add_bank_account_failed":            {Other: "add bank account failed. : {reason}"},
+	"approve_kyc_failed":                 {Other: "approve kyc failed. : {reason}"},
+	"balance_adjustment_failed":          {Other: "balance adjustment failed. : {reason}"},
+	"bank_account_inquiry_failed":        {Other: "bank account inquiry failed. : {reason}"},
+	"callback_failed":                    {Other: "callback failed. : {reason}"},
+	"cancel_scheduled_transfer_failed":   {Other: "cancel scheduled transfer failed. : {reason}"},
+	"close_user_failed":                  {Other: "close user failed. : {reason}"},
+	"create_fee_rule_failed":             {Other: "create fee rule failed. : {reason}"},
+	"create_merchant_failed":             {Other: "create merchant failed. : {reason}"},
+	"create_qr_failed":                   {Other: "create qr failed. : {reason}"},
+	"create_scheduled_transfer_failed":   {Other: "create scheduled transfer failed. : {reason}"},
+	"create_webhook_failed":              {Other: "create webhook failed. : {reason}"},
+	"disable_webhook_failed":             {Other: "disable webhook failed. : {reason}"},
+	"freeze_user_failed":                 {Other: "freeze user failed. : {reason}"},
+	"get_audit_logs_failed":              {Other: "get audit logs failed. : {reason}"},
+	"get_bank_accounts_failed":           {Other: "get bank accounts failed. : {reason}"},
+	"get_fee_rules_failed":               {Other: "get fee rules failed. : {reason}"},
+	"get_kyc_document_failed":            {Other: "get kyc document failed. : {reason}"},
+	"get_kyc_status_failed":              {Other: "get kyc status failed. : {reason}"},
+	"get_kyc_submissions_failed":         {Other: "get kyc submissions failed. : {reason}"},
+	"get_limits_failed":                  {Other: "get limits failed. : {reason}"},
+	"get_merchant_failed":                {Other: "get merchant failed. : {reason}"},
+	"get_payments_failed":                {Other: "get payments failed. : {reason}"},
+	"get_qr_failed":                      {Other: "get qr failed. : {reason}"},
+	"get_reconciliation_run_failed":      {Other: "get reconciliation run failed. : {reason}"},
+	"get_reconciliation_runs_failed":     {Other: "get reconciliation runs failed. : {reason}"},
+	"get_repair_tasks_failed":            {Other: "get repair tasks failed. : {reason}"},
+	"get_scheduled_transfer_failed":      {Other: "get scheduled transfer failed. : {reason}"},
+	"get_scheduled_transfer_runs_failed": {Other: "get scheduled transfer runs failed. : {reason}"},
+	"get_scheduled_transfers_failed":     {Other: "get scheduled transfers failed. : {reason}"},
+	"get_statement_failed":               {Other: "get statement failed. : {reason}"},
+	"get_topup_failed":                   {Other: "get topup failed. : {reason}"},
+	"get_transaction_failed":             {Other: "get transaction failed. : {reason}"},
+	"get_user_failed":                    {Other: "get user failed. : {reason}"},
+	"get_webhook_deliveries_failed":      {Other: "get webhook deliveries failed. : {reason}"},
+	"get_webhooks_failed":                {Other: "get webhooks failed. : {reason}"},
+	"get_withdrawal_failed":              {Other: "get withdrawal failed. : {reason}"},
+	"get_withdrawals_failed":             {Other: "get withdrawals failed. : {reason}"},
+	"kyc_submission_failed":              {Other: "kyc submission failed. : {reason}"},
+	"qr_payment_failed":                  {Other: "qr payment failed. : {reason}"},
+	"reconciliation_failed":              {Other: "reconciliation failed. : {reason}"},
+	"redeliver_webhook_failed":           {Other: "redeliver webhook failed. : {reason}"},
+	"refund_failed":                      {Other: "refund failed. : {reason}"},
+	"reject_kyc_failed":                  {Other: "reject kyc failed. : {reason}"},
+	"remove_bank_account_failed":         {Other: "remove bank account failed. : {reason}"},
+	"render_qr_failed":                   {Other: "render qr failed. : {reason}"},
+	"resolve_repair_task_failed":         {Other: "resolve repair task failed. : {reason}"},
+	"rotate_api_key_failed":              {Other: "rotate api key failed. : {reason}"},
+	"search_users_failed":                {Other: "search users failed. : {reason}"},
+	"set_merchant_status_failed":         {Other: "set merchant status failed. : {reason}"},
+	"set_user_role_failed":               {Other: "set user role failed. : {reason}"},
+	"topup_failed":                       {Other: "topup failed. : {reason}"},
+	"unfreeze_user_failed":               {Other: "unfreeze user failed. : {reason}"},
+	"update_fee_rule_failed":             {Other: "update fee rule failed. : {reason}"},
+	"update_scheduled_transfer_failed":   {Other: "update scheduled transfer failed. : {reason}"},
+	"verify_audit_chain_failed":          {Other: "verify audit chain failed. : {reason}"},
+	"withdrawal_failed":                  {Other: "withdrawal failed. : {reason}"},
+
+	"auth.missing_token":         {Other: "Missing token"},
+	"auth.invalid_token_format":  {Other: "invalid token format"},
+	"auth.invalid_token":         {Other: "Invalid token"},
+	"auth.verify_failed":         {Other: "failed to verify account"},
+	"auth.account_closed":        {Other: "Account is closed"},
+	"auth.forbidden":             {Other: "Forbidden"},
+	"auth.missing_api_key":       {Other: "Missing api key"},
+	"auth.invalid_api_key":       {Other: "Invalid api key"},
+	"auth.merchant_suspended":    {Other: "Merchant is suspended"},
+	"auth.verify_api_key_failed": {Other: "failed to verify api key"},
+	"rate_limited": {
+		One:   "too many requests, try again in {count} second",
+		Other: "too many requests, try again in {count} seconds",
+	},
+	"rate_limit_unavailable": {Other: "requests can't be counted right now, try again in a moment"},
+
+	"limit_exceeded.per_transaction": {Other: "per transaction limit exceeded: at most {max} per transaction"},
+	"limit_exceeded.daily":           {Other: "daily limit exceeded: limit {max}, remaining {remaining}"},
+	"limit_exceeded.monthly":         {Other: "monthly limit exceeded: limit {max}, remaining {remaining}"},
+	"limit_exceeded.max_balance":     {Other: "maximum balance exceeded: limit {max}, room left {remaining}"},
+	"limit_exceeded.monthly_topup":   {Other: "monthly topup limit exceeded: limit {max}, remaining {remaining}"},
+	"limit_exceeded.transaction_count": {
+		One:   "transaction count limit exceeded: at most {count} transaction every {window}",
+		Other: "transaction count limit exceeded: at most {count} transactions every {window}",
+	},
+	"duration.seconds": {One: "{count} second", Other: "{count} seconds"},
+
+	"validation.required":   {Other: "{field} is required"},
+	"validation.phone":      {Other: "{field} must be a phone number such as +6281234567890 or 081234567890"},
+	"validation.pin":        {Other: "{field} must be exactly 6 digits"},
+	"validation.amount":     {Other: "{field} must be between {min} and {max}"},
+	"validation.adjustment": {Other: "{field} must not be zero and at most {max} either way"},
+	"validation.nonnil":     {Other: "{field} must be a non-nil id"},
+	"validation.remark": {
+		One:   "{field} must be at most {count} letter, digit, space or common punctuation",
+		Other: "{field} must be at most {count} letters, digits, spaces or common punctuation",
+	},
+	"validation.min":        {Other: "{field} must be at least {param}"},
+	"validation.max":        {Other: "{field} must be at most {param}"},
+	"validation.len":        {Other: "{field} must be exactly {param}"},
+	"validation.min.string": {One: "{field} must be at least {count} character long", Other: "{field} must be at least {count} characters long"},
+	"validation.max.string": {One: "{field} must be at most {count} character long", Other: "{field} must be at most {count} characters long"},
+	"validation.len.string": {One: "{field} must be exactly {count} character long", Other: "{field} must be exactly {count} characters long"},
+	"validation.min.items":  {One: "{field} must have at least {count} item", Other: "{field} must have at least {count} items"},
+	"validation.max.items":  {One: "{field} must have at most {count} item", Other: "{field} must have at most {count} items"},
+	"validation.len.items":  {One: "{field} must have exactly {count} item", Other: "{field} must have exactly {count} items"},
+	"validation.oneof":      {Other: "{field} must be one of {values}"},
+	"validation.number":     {Other: "{field} must only contain digits"},
+	"validation.datetime":   {Other: "{field} must be formatted as {param}"},
+	"validation.gtfield":    {Other: "{field} must be after {param}"},
+	"validation.uuid":       {Other: "{field} must be a UUID"},
+	"validation.duration":   {Other: "{field} must be a positive duration such as 30m"},
+	"validation.filesize":   {Other: "{field} must not be larger than {param}"},
+	"validation.url":        {Other: "{field} must be a URL"},
+	"validation.type":       {Other: "{field} must be of type {param}"},
+	"validation.invalid":    {Other: "{field} is invalid"},
+}
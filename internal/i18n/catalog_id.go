@@ -0,0 +1,204 @@
+package i18n
+
+// indonesian holds the Indonesian messages, domain errors included. Indonesian nouns don't
+// inflect for number, so no message needs a One form.
+var indonesian = map[string]Message{
+	"invalid_request":         {Other: "isi permintaan tidak valid"},
+	"token_failed":            {Other: "gagal membuat token"},
+	"payment_failed":          {Other: "pembayaran gagal. : {reason}"},
+	"transfer_failed":         {Other: "transfer gagal. : {reason}"},
+	"transfer_inquiry_failed": {Other: "pengecekan transfer gagal. : {reason}"},
+	"get_transactions_failed": {Other: "gagal mengambil transaksi. : {reason}"},
+	"invalid_parameters":      {Other: "parameter permintaan tidak valid"},
+	"internal_error":          {Other: "terjadi kesalahan, silakan coba lagi nanti"},
+
+	"add_bank_account_failed":            {Other: "gagal menambah rekening bank. : {reason}"},
+	"approve_kyc_failed":                 {Other: "gagal menyetujui kyc. : {reason}"},
+	"balance_adjustment_failed":          {Other: "penyesuaian saldo gagal. : {reason}"},
+	"bank_account_inquiry_failed":        {Other: "pengecekan rekening bank gagal. : {reason}"},
+	"callback_failed":                    {Other: "callback gagal. : {reason}"},
+	"cancel_scheduled_transfer_failed":   {Other: "gagal membatalkan transfer terjadwal. : {reason}"},
+	"close_user_failed":                  {Other: "gagal menutup pengguna. : {reason}"},
+	"create_fee_rule_failed":             {Other: "gagal membuat aturan biaya. : {reason}"},
+	"create_merchant_failed":             {Other: "gagal membuat merchant. : {reason}"},
+	"create_qr_failed":                   {Other: "gagal membuat qr. : {reason}"},
+	"create_scheduled_transfer_failed":   {Other: "gagal membuat transfer terjadwal. : {reason}"},
+	"create_webhook_failed":              {Other: "gagal membuat webhook. : {reason}"},
+	"disable_webhook_failed":             {Other: "gagal menonaktifkan webhook. : {reason}"},
+	"freeze_user_failed":                 {Other: "gagal membekukan pengguna. : {reason}"},
+	"get_audit_logs_failed":              {Other: "gagal mengambil log audit. : {reason}"},
+	"get_bank_accounts_failed":           {Other: "gagal mengambil rekening bank. : {reason}"},
+	"get_fee_rules_failed":               {Other: "gagal mengambil aturan biaya. : {reason}"},
+	"get_kyc_document_failed":            {Other: "gagal mengambil dokumen kyc. : {reason}"},
+	"get_kyc_status_failed":              {Other: "gagal mengambil status kyc. : {reason}"},
+	"get_kyc_submissions_failed":         {Other: "gagal mengambil pengajuan kyc. : {reason}"},
+	"get_limits_failed":                  {Other: "gagal mengambil batas. : {reason}"},
+	"get_merchant_failed":                {Other: "gagal mengambil merchant. : {reason}"},
+	"get_payments_failed":                {Other: "gagal mengambil pembayaran. : {reason}"},
+	"get_qr_failed":                      {Other: "gagal mengambil qr. : {reason}"},
+	"get_reconciliation_run_failed":      {Other: "gagal mengambil rekonsiliasi. : {reason}"},
+	"get_reconciliation_runs_failed":     {Other: "gagal mengambil daftar rekonsiliasi. : {reason}"},
+	"get_repair_tasks_failed":            {Other: "gagal mengambil tugas perbaikan. : {reason}"},
+	"get_scheduled_transfer_failed":      {Other: "gagal mengambil transfer terjadwal. : {reason}"},
+	"get_scheduled_transfer_runs_failed": {Other: "gagal mengambil riwayat transfer terjadwal. : {reason}"},
+	"get_scheduled_transfers_failed":     {Other: "gagal mengambil daftar transfer terjadwal. : {reason}"},
+	"get_statement_failed":               {Other: "gagal mengambil mutasi rekening. : {reason}"},
+	"get_topup_failed":                   {Other: "gagal mengambil isi saldo. : {reason}"},
+	"get_transaction_failed":             {Other: "gagal mengambil transaksi. : {reason}"},
+	"get_user_failed":                    {Other: "gagal mengambil pengguna. : {reason}"},
+	"get_webhook_deliveries_failed":      {Other: "gagal mengambil pengiriman webhook. : {reason}"},
+	"get_webhooks_failed":                {Other: "gagal mengambil webhook. : {reason}"},
+	"get_withdrawal_failed":              {Other: "gagal mengambil penarikan. : {reason}"},
+	"get_withdrawals_failed":             {Other: "gagal mengambil daftar penarikan. : {reason}"},
+	"kyc_submission_failed":              {Other: "pengajuan kyc gagal. : {reason}"},
+	"qr_payment_failed":                  {Other: "pembayaran qr gagal. : {reason}"},
+	"reconciliation_failed":              {Other: "rekonsiliasi gagal. : {reason}"},
+	"redeliver_webhook_failed":           {Other: "gagal mengirim ulang webhook. : {reason}"},
+	"refund_failed":                      {Other: "pengembalian dana gagal. : {reason}"},
+	"reject_kyc_failed":                  {Other: "gagal menolak kyc. : {reason}"},
+	"remove_bank_account_failed":         {Other: "gagal menghapus rekening bank. : {reason}"},
+	"render_qr_failed":                   {Other: "gagal membuat gambar qr. : {reason}"},
+	"resolve_repair_task_failed":         {Other: "gagal menyelesaikan tugas perbaikan. : {reason}"},
+	"rotate_api_key_failed":              {Other: "gagal mengganti api key. : {reason}"},
+	"search_users_failed":                {Other: "gagal mencari pengguna. : {reason}"},
+	"set_merchant_status_failed":         {Other: "gagal mengubah status merchant. : {reason}"},
+	"set_user_role_failed":               {Other: "gagal mengubah peran pengguna. : {reason}"},
+	"topup_failed":                       {Other: "isi saldo gagal. : {reason}"},
+	"unfreeze_user_failed":               {Other: "gagal mencairkan pengguna. : {reason}"},
+	"update_fee_rule_failed":             {Other: "gagal mengubah aturan biaya. : {reason}"},
+	"update_scheduled_transfer_failed":   {Other: "gagal mengubah transfer terjadwal. : {reason}"},
+	"verify_audit_chain_failed":          {Other: "gagal memverifikasi rantai audit. : {reason}"},
+	"withdrawal_failed":                  {Other: "penarikan gagal. : {reason}"},
+
+	"auth.missing_token":         {Other: "Token tidak ada"},
+	"auth.invalid_token_format":  {Other: "format token tidak valid"},
+	"auth.invalid_token":         {Other: "Token tidak valid"},
+	"auth.verify_failed":         {Other: "gagal memverifikasi akun"},
+	"auth.account_closed":        {Other: "Akun sudah ditutup"},
+	"auth.forbidden":             {Other: "Akses ditolak"},
+	"auth.missing_api_key":       {Other: "API key tidak ada"},
+	"auth.invalid_api_key":       {Other: "API key tidak valid"},
+	"auth.merchant_suspended":    {Other: "Merchant sedang ditangguhkan"},
+	"auth.verify_api_key_failed": {Other: "gagal memverifikasi API key"},
+	"rate_limited":               {Other: "terlalu banyak permintaan, coba lagi dalam {count} detik"},
+	"rate_limit_unavailable":     {Other: "permintaan tidak dapat dihitung saat ini, coba lagi sebentar lagi"},
+
+	"limit_exceeded.per_transaction":   {Other: "batas per transaksi terlampaui: paling banyak {max} per transaksi"},
+	"limit_exceeded.daily":             {Other: "batas harian terlampaui: batas {max}, sisa {remaining}"},
+	"limit_exceeded.monthly":           {Other: "batas bulanan terlampaui: batas {max}, sisa {remaining}"},
+	"limit_exceeded.max_balance":       {Other: "saldo maksimum terlampaui: batas {max}, sisa ruang {remaining}"},
+	"limit_exceeded.monthly_topup":     {Other: "batas isi saldo bulanan terlampaui: batas {max}, sisa {remaining}"},
+	"limit_exceeded.transaction_count": {Other: "batas jumlah transaksi terlampaui: paling banyak {count} transaksi setiap {window}"},
+	"duration.seconds":                 {Other: "{count} detik"},
+
+	"validation.required":   {Other: "{field} wajib diisi"},
+	"validation.phone":      {Other: "{field} harus berupa nomor telepon seperti +6281234567890 atau 081234567890"},
+	"validation.pin":        {Other: "{field} harus tepat 6 angka"},
+	"validation.amount":     {Other: "{field} harus antara {min} dan {max}"},
+	"validation.adjustment": {Other: "{field} tidak boleh nol dan paling banyak {max} ke arah mana pun"},
+	"validation.nonnil":     {Other: "{field} harus berupa id yang tidak kosong"},
+	"validation.remark":     {Other: "{field} paling banyak {count} huruf, angka, spasi atau tanda baca umum"},
+	"validation.min":        {Other: "{field} paling sedikit {param}"},
+	"validation.max":        {Other: "{field} paling banyak {param}"},
+	"validation.len":        {Other: "{field} harus tepat {param}"},
+	"validation.min.string": {Other: "{field} paling sedikit {count} karakter"},
+	"validation.max.string": {Other: "{field} paling banyak {count} karakter"},
+	"validation.len.string": {Other: "{field} harus tepat {count} karakter"},
+	"validation.min.items":  {Other: "{field} paling sedikit berisi {count} item"},
+	"validation.max.items":  {Other: "{field} paling banyak berisi {count} item"},
+	"validation.len.items":  {Other: "{field} harus berisi tepat {count} item"},
+	"validation.oneof":      {Other: "{field} harus salah satu dari {values}"},
+	"validation.number":     {Other: "{field} hanya boleh berisi angka"},
+	"validation.datetime":   {Other: "{field} harus berformat {param}"},
+	"validation.gtfield":    {Other: "{field} harus setelah {param}"},
+	"validation.uuid":       {Other: "{field} harus berupa UUID"},
+	"validation.duration":   {Other: "{field} harus berupa durasi positif seperti 30m"},
+	"validation.filesize":   {Other: "{field} tidak boleh lebih besar dari {param}"},
+	"validation.url":        {Other: "{field} harus berupa URL"},
+	"validation.type":       {Other: "{field} harus bertipe {param}"},
+	"validation.invalid":    {Other: "{field} tidak valid"},
+
+	"phone_number_registered": {Other: "nomor telepon sudah terdaftar"},
+	"phone_number_not_found":  {Other: "nomor telepon tidak ditemukan"},
+	"pin_mismatch":            {Other: "Nomor telepon dan PIN tidak cocok"},
+
+	"user_not_found":                {Other: "pengguna tidak ditemukan"},
+	"target_user_not_found":         {Other: "pengguna tujuan tidak ditemukan"},
+	"insufficient_balance":          {Other: "saldo tidak mencukupi"},
+	"self_transfer":                 {Other: "tidak dapat transfer ke akun sendiri"},
+	"recipient_unverified":          {Other: "akun penerima belum terverifikasi"},
+	"fee_exceeds_amount":            {Other: "jumlah terlalu kecil untuk menutup biaya"},
+	"limit_exceeded":                {Other: "batas transaksi terlampaui"},
+	"amount_overflow":               {Other: "jumlah di luar jangkauan"},
+	"account_frozen":                {Other: "akun dibekukan"},
+	"account_closed":                {Other: "akun sudah ditutup"},
+	"recipient_closed":              {Other: "akun penerima sudah ditutup"},
+	"balance_not_zero":              {Other: "saldo harus nol untuk menutup akun"},
+	"invalid_pin":                   {Other: "PIN salah"},
+	"invalid_pin_format":            {Other: "PIN harus tepat 6 angka"},
+	"feature_not_allowed":           {Other: "fitur ini tidak tersedia untuk tingkat akun Anda, silakan selesaikan verifikasi"},
+	"kyc_not_found":                 {Other: "pengajuan kyc tidak ditemukan"},
+	"kyc_already_pending":           {Other: "masih ada pengajuan kyc yang menunggu peninjauan"},
+	"kyc_already_reviewed":          {Other: "pengajuan kyc sudah ditinjau"},
+	"invalid_kyc_tier":              {Other: "requested_tier harus basic atau full"},
+	"invalid_id_number":             {Other: "id_number harus 16 angka"},
+	"invalid_date_of_birth":         {Other: "date_of_birth harus tanggal yang sudah lewat dan pengguna berusia paling sedikit 17 tahun"},
+	"kyc_document_required":         {Other: "gambar dokumen wajib untuk tingkat full"},
+	"kyc_reject_reason":             {Other: "alasan wajib diisi untuk menolak pengajuan"},
+	"unsupported_document":          {Other: "dokumen harus berupa file JPEG, PNG atau PDF"},
+	"transaction_not_found":         {Other: "transaksi tidak ditemukan"},
+	"reason_required":               {Other: "alasan wajib diisi"},
+	"invalid_adjustment":            {Other: "jumlah penyesuaian tidak boleh nol"},
+	"invalid_role":                  {Other: "role harus salah satu dari user, support, admin atau auditor"},
+	"self_role_change":              {Other: "Anda tidak dapat mengubah role Anda sendiri"},
+	"merchant_not_found":            {Other: "merchant tidak ditemukan"},
+	"merchant_inactive":             {Other: "merchant tidak sedang menerima pembayaran"},
+	"merchant_name_required":        {Other: "nama merchant wajib diisi"},
+	"invalid_merchant_state":        {Other: "status harus active atau suspended"},
+	"invalid_api_key":               {Other: "api key tidak valid"},
+	"not_refundable":                {Other: "hanya pembayaran yang berhasil yang dapat dikembalikan"},
+	"refund_exceeds_payment":        {Other: "pengembalian melebihi jumlah pembayaran yang dapat dikembalikan"},
+	"invalid_amount":                {Other: "jumlah harus lebih dari nol"},
+	"invalid_qr":                    {Other: "kode qr tidak valid"},
+	"qr_not_found":                  {Other: "kode qr tidak ditemukan"},
+	"qr_unavailable":                {Other: "kode qr sudah dibayar atau kedaluwarsa"},
+	"qr_amount_mismatch":            {Other: "jumlah tidak sesuai dengan kode qr"},
+	"invalid_qr_ttl":                {Other: "expires_in harus antara 1 menit dan 24 jam"},
+	"webhook_not_found":             {Other: "endpoint webhook tidak ditemukan"},
+	"delivery_not_found":            {Other: "pengiriman webhook tidak ditemukan"},
+	"invalid_webhook_url":           {Other: "url harus berupa url http atau https yang lengkap"},
+	"webhook_url_not_public":        {Other: "url harus mengarah ke alamat publik"},
+	"invalid_webhook_events":        {Other: "events harus satu atau lebih dari transaction.succeeded, transaction.failed atau refund.created"},
+	"intent_not_found":              {Other: "payment intent tidak ditemukan"},
+	"invalid_payment_method":        {Other: "method harus va atau card"},
+	"unknown_gateway":               {Other: "payment gateway tidak dikenal"},
+	"invalid_callback":              {Other: "tanda tangan callback gateway tidak valid"},
+	"gateway_amount_mismatch":       {Other: "jumlah yang dibayar tidak sesuai dengan payment intent"},
+	"bank_account_not_found":        {Other: "rekening bank tidak ditemukan"},
+	"bank_account_exists":           {Other: "rekening bank sudah tersimpan"},
+	"bank_account_required":         {Other: "bank_code dan account_number wajib diisi"},
+	"bank_account_unknown":          {Other: "bank tidak mengenal rekening ini"},
+	"withdrawal_not_found":          {Other: "penarikan tidak ditemukan"},
+	"unknown_disbursement_provider": {Other: "penyedia pencairan dana tidak dikenal"},
+	"invalid_disbursement_callback": {Other: "tanda tangan callback pencairan dana tidak valid"},
+	"invalid_statement_month":       {Other: "month harus berformat YYYY-MM dan bukan bulan yang akan datang"},
+	"invalid_statement_format":      {Other: "format harus csv atau pdf"},
+	"month_not_ended":               {Other: "bulan belum berakhir"},
+	"fee_rule_not_found":            {Other: "aturan biaya tidak ditemukan"},
+	"fee_rule_conflict":             {Other: "sudah ada aturan biaya aktif lain untuk jenis transaksi dan tingkat ini"},
+	"invalid_fee_kind":              {Other: "transaction_kind harus salah satu dari topup, payment, transfer atau withdrawal"},
+	"invalid_fee_tier":              {Other: "user_tier harus kosong atau salah satu dari unverified, basic atau full"},
+	"invalid_fee_type":              {Other: "fee_type harus salah satu dari flat, percentage atau tiered"},
+	"invalid_fee_amount":            {Other: "jumlah biaya tidak boleh negatif dan persentase paling banyak 10000 basis poin"},
+	"invalid_fee_cap":               {Other: "max_fee harus 0 atau paling sedikit min_fee"},
+	"invalid_fee_brackets":          {Other: "aturan tiered membutuhkan tiers dengan nilai up_to yang berbeda, aturan lain tidak"},
+	"run_not_found":                 {Other: "proses rekonsiliasi tidak ditemukan"},
+	"repair_task_not_found":         {Other: "tugas perbaikan tidak ditemukan"},
+	"repair_task_resolved":          {Other: "tugas perbaikan sudah diselesaikan"},
+	"invalid_repair_status":         {Other: "status harus open atau resolved"},
+	"schedule_not_found":            {Other: "transfer terjadwal tidak ditemukan"},
+	"invalid_frequency":             {Other: "frequency harus salah satu dari once, daily, weekly atau monthly"},
+	"schedule_in_past":              {Other: "start_at harus di masa depan"},
+	"invalid_schedule_end":          {Other: "end_at harus setelah start_at"},
+	"schedule_not_editable":         {Other: "transfer terjadwal sudah tidak aktif"},
+}
@@ -0,0 +1,65 @@
+package i18n
+
+import (
+	"errors"
+	"tahap2/internal/domain"
+)
+
+// coded is implemented by errors that have an entry in the catalog, such as domain.Error.
+type coded interface {
+	error
+	Code() string
+}
+
+// limitCodes names the message of each limit of a domain.LimitExceededError.
+var limitCodes = map[string]string{
+	domain.LimitPerTransaction:   "limit_exceeded.per_transaction",
+	domain.LimitDaily:            "limit_exceeded.daily",
+	domain.LimitMonthly:          "limit_exceeded.monthly",
+	domain.LimitTransactionCount: "limit_exceeded.transaction_count",
+	domain.LimitMaxBalance:       "limit_exceeded.max_balance",
+	domain.LimitMonthlyTopUp:     "limit_exceeded.monthly_topup",
+}
+
+// Code is the catalog code of err, empty when err isn't one clients are told about.
+func Code(err error) string {
+	var limitErr *domain.LimitExceededError
+	if errors.As(err, &limitErr) {
+		return limitCodes[limitErr.Limit]
+	}
+	var codedErr coded
+	if errors.As(err, &codedErr) {
+		return codedErr.Code()
+	}
+	return ""
+}
+
+// Error describes err in lang, leaving out what err was wrapped with. Coded errors that have
+// no translation are described by their own message, in English. Errors without a code are
+// internal, they are only described by a generic message.
+func Error(lang string, err error) string {
+	var limitErr *domain.LimitExceededError
+	if errors.As(err, &limitErr) {
+		if code, ok := limitCodes[limitErr.Limit]; ok {
+			return T(lang, code, limitArgs(limitErr))
+		}
+	}
+	var codedErr coded
+	if errors.As(err, &codedErr) {
+		if message, ok := lookup(lang, codedErr.Code()); ok {
+			return render(lang, message, nil)
+		}
+		return codedErr.Error()
+	}
+	return T(lang, "internal_error", nil)
+}
+
+func limitArgs(err *domain.LimitExceededError) Args {
+	if err.Limit == domain.LimitTransactionCount {
+		return Args{
+			"count":  err.Max,
+			"window": Phrase{Code: "duration.seconds", Args: Args{"count": int64(err.Window.Seconds())}},
+		}
+	}
+	return Args{"max": Money(err.Max), "remaining": Money(err.Remaining)}
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Money is an amount of rupiah, formatted the way lang writes currency.
+type Money int64
+
+// Phrase is a message placed inside another one, e.g. a duration that has plural forms of
+// its own.
+type Phrase struct {
+	Code string
+	Args Args
+}
+
+func format(lang string, value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case Money:
+		return FormatMoney(lang, int64(v))
+	case Phrase:
+		return T(lang, v.Code, v.Args)
+	case int:
+		return FormatNumber(lang, int64(v))
+	case int64:
+		return FormatNumber(lang, v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// FormatMoney writes amount as rupiah, Rp10.000 in Indonesian and IDR 10,000 in English.
+// Rupiah amounts have no minor unit.
+func FormatMoney(lang string, amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+	}
+	number := group(strconv.FormatInt(amount, 10), separator(lang))
+	if lang == Indonesian {
+		return sign + "Rp" + number
+	}
+	return sign + "IDR " + number
+}
+
+// FormatNumber writes n with the digits grouped by thousands, 10.000 in Indonesian and
+// 10,000 in English.
+func FormatNumber(lang string, n int64) string {
+	sign := ""
+	if n < 0 {
+		sign = "-"
+	}
+	return sign + group(strconv.FormatInt(n, 10), separator(lang))
+}
+
+func separator(lang string) byte {
+	if lang == Indonesian {
+		return '.'
+	}
+	return ','
+}
+
+// group puts sep between every three digits of the decimal number digits, leaving its sign
+// out.
+func group(digits string, sep byte) string {
+	if digits[0] == '-' {
+		digits = digits[1:]
+	}
+	grouped := make([]byte, 0, len(digits)+len(digits)/3)
+	for i := range len(digits) {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped = append(grouped, sep)
+		}
+		grouped = append(grouped, digits[i])
+	}
+	return string(grouped)
+}
@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	English    = "en"
+	Indonesian = "id"
+
+	// Default answers clients that don't ask for a language we have.
+	Default = English
+)
+
+// Languages are the languages there is a catalog for, in the order Accept-Language is
+// matched against.
+var Languages = []string{English, Indonesian}
+
+var matcher = language.NewMatcher([]language.Tag{language.English, language.Indonesian})
+
+// Supported reports whether there is a catalog for lang.
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Match picks the language of an Accept-Language header, Default when the header asks for
+// none we have.
+func Match(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return Default
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Languages[index]
+}
+
+// Message is the text of a code in one language. Other is used for every count that
+// isn't one, and for messages without a count. Languages that don't inflect for number,
+// such as Indonesian, only need Other.
+type Message struct {
+	One   string
+	Other string
+}
+
+// Args fill the {name} placeholders of a message. The count arg picks the plural form.
+type Args map[string]any
+
+// catalogs holds the messages of every language by code.
+var catalogs = map[string]map[string]Message{
+	English:    english,
+	Indonesian: indonesian,
+}
+
+// T renders the message of code in lang, falling back to English when lang has no
+// translation and to the code itself when no language has one.
+func T(lang, code string, args Args) string {
+	message, ok := lookup(lang, code)
+	if !ok {
+		return code
+	}
+	return render(lang, message, args)
+}
+
+func lookup(lang, code string) (Message, bool) {
+	if message, ok := catalogs[lang][code]; ok {
+		return message, true
+	}
+	message, ok := catalogs[Default][code]
+	return message, ok
+}
+
+func render(lang string, message Message, args Args) string {
+	text := message.Other
+	if count, ok := args["count"]; ok && message.One != "" && plural(lang, count) == pluralOne {
+		text = message.One
+	}
+	if len(args) == 0 {
+		return text
+	}
+
+	replacements := make([]string, 0, len(args)*2)
+	for name, value := range args {
+		replacements = append(replacements, "{"+name+"}", format(lang, value))
+	}
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+const (
+	pluralOne = iota
+	pluralOther
+)
+
+// plural is the CLDR plural category of count for integers: English has one and other,
+// Indonesian only other.
+func plural(lang string, count any) int {
+	if lang == English && fmt.Sprint(count) == "1" {
+		return pluralOne
+	}
+	return pluralOther
+}
@@ -0,0 +1,97 @@
+// Package logging builds the structured logger the app and its workers share. Records
+// logged with a context carry the id of the request they belong to, and attributes that
+// hold secrets or personal data are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"tahap2/internal/domain"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are never written.
+var secretKeys = map[string]bool{
+	"pin":           true,
+	"old_pin":       true,
+	"new_pin":       true,
+	"password":      true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"authorization": true,
+	"api_key":       true,
+	"secret":        true,
+	"signature":     true,
+}
+
+// phoneKeys are attribute keys holding phone numbers, only their last digits are written.
+var phoneKeys = map[string]bool{
+	"phone":               true,
+	"phone_number":        true,
+	"target_phone_number": true,
+}
+
+// New returns a logger writing to w. format is "json" or "text", the default, and level is
+// one of debug, info, warn or error, info by default.
+func New(w io.Writer, format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLevel(level), ReplaceAttr: redact}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key]:
+		return slog.String(a.Key, redacted)
+	case phoneKeys[key]:
+		return slog.String(a.Key, MaskPhone(a.Value.String()))
+	}
+	return a
+}
+
+// MaskPhone hides all but the last four digits of a phone number.
+func MaskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return strings.Repeat("*", len(phone))
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// contextHandler adds the request id found in the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := domain.RequestMetaFrom(ctx).RequestID; requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
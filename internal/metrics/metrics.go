@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tahap2"
+
+// Worker outcomes, the outcome label of WorkerJobs.
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+// registry holds everything served on /metrics. It is kept apart from the default registry so
+// only the metrics registered here are exposed.
+var registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests answered, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to answer HTTP requests, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	WorkerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "worker_processing_duration_seconds",
+		Help:      "Time taken by background workers to process a job, by worker.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"worker"})
+
+	WorkerJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "worker_jobs_total",
+		Help:      "Jobs processed by background workers, by worker and outcome.",
+	}, []string{"worker", "outcome"})
+
+	WorkerRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "worker_retries_total",
+		Help:      "Jobs scheduled to be tried again after failing, by worker.",
+	}, []string{"worker"})
+
+	MoneyMovements = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "money_movements_total",
+		Help:      "Settled topups, payments and transfers, by kind.",
+	}, []string{"kind"})
+
+	MoneyMovementAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "money_movement_amount_total",
+		Help:      "Amount of the settled topups, payments and transfers, fees excluded, by kind.",
+	}, []string{"kind"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		WorkerDuration,
+		WorkerJobs,
+		WorkerRetries,
+		MoneyMovements,
+		MoneyMovementAmount,
+	)
+}
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RecordMoneyMovement counts a settled money movement of kind.
+func RecordMoneyMovement(kind string, amount int64) {
+	MoneyMovements.WithLabelValues(kind).Inc()
+	MoneyMovementAmount.WithLabelValues(kind).Add(float64(amount))
+}
+
+// ObserveWorkerJob records how long a job of worker took since start and how it ended.
+func ObserveWorkerJob(worker, outcome string, start time.Time) {
+	WorkerDuration.WithLabelValues(worker).Observe(time.Since(start).Seconds())
+	WorkerJobs.WithLabelValues(worker, outcome).Inc()
+}
+
+// RegisterDBStats exposes the connection pool stats of db.
+func RegisterDBStats(db *sql.DB, name string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterQueueDepth exposes how many events of eventType are waiting to be picked up, as
+// reported by depth on every scrape.
+func RegisterQueueDepth(eventType string, depth func() int) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   namespace,
+		Name:        "event_bus_queue_depth",
+		Help:        "Events published on the event bus and not picked up by a worker yet, by event type.",
+		ConstLabels: prometheus.Labels{"event_type": eventType},
+	}, func() float64 { return float64(depth()) }))
+}
+
+// PendingTransferStats returns how many transfers wait for the worker and when the oldest
+// of them was created, zero when there is none.
+type PendingTransferStats func(ctx context.Context) (count int64, oldest time.Time, err error)
+
+// RegisterPendingTransfers exposes the count and age of the pending transfers, looked up on
+// every scrape.
+func RegisterPendingTransfers(stats PendingTransferStats) {
+	registry.MustRegister(&pendingTransfersCollector{stats: stats})
+}
+
+// pendingTransfersTimeout keeps a slow database from holding scrapes up.
+const pendingTransfersTimeout = 2 * time.Second
+
+var (
+	pendingTransfersDesc = prometheus.NewDesc(namespace+"_pending_transfers",
+		"Transfers waiting to be settled by the transfer worker.", nil, nil)
+	oldestPendingTransferDesc = prometheus.NewDesc(namespace+"_oldest_pending_transfer_age_seconds",
+		"Age of the oldest transfer waiting to be settled, 0 when there is none.", nil, nil)
+)
+
+type pendingTransfersCollector struct {
+	stats PendingTransferStats
+}
+
+func (c *pendingTransfersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingTransfersDesc
+	ch <- oldestPendingTransferDesc
+}
+
+func (c *pendingTransfersCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), pendingTransfersTimeout)
+	defer cancel()
+
+	count, oldest, err := c.stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(pendingTransfersDesc, err)
+		return
+	}
+	var age float64
+	if !oldest.IsZero() {
+		age = time.Since(oldest).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(pendingTransfersDesc, prometheus.GaugeValue, float64(count))
+	ch <- prometheus.MustNewConstMetric(oldestPendingTransferDesc, prometheus.GaugeValue, age)
+}
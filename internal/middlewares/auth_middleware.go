@@ -1,42 +1,71 @@
 package middlewares
 
 import (
+	"errors"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"net/http"
 	"strings"
+	"tahap2/internal/domain"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-const UserIDKey = "user_id"
+const (
+	UserIDKey   = "user_id"
+	UserRoleKey = "user_role"
+)
 
 var AccessTokenSecret = []byte("supersecretkey") // testing purpose
 
 type Claims struct {
 	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
 	jwt.RegisteredClaims
 }
 
-func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		authHeader := c.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Missing token"})
-		}
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == authHeader {
-			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "invalid token format"})
-		}
+// NewAuthMiddleware validates the access token and rejects tokens of closed accounts, so
+// closing an account takes effect immediately instead of when its tokens expire. The user's
+// language preference, when set, wins over Accept-Language.
+func NewAuthMiddleware(userRepo domain.UserRepository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader == "" {
+				return Refuse(c, http.StatusUnauthorized, "auth.missing_token", nil)
+			}
+			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+			if tokenString == authHeader {
+				return Refuse(c, http.StatusUnauthorized, "auth.invalid_token_format", nil)
+			}
+
+			token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+				return AccessTokenSecret, nil
+			})
+			if err != nil || !token.Valid {
+				return Refuse(c, http.StatusUnauthorized, "auth.invalid_token", nil)
+			}
+			claims := token.Claims.(*Claims)
+
+			user, err := userRepo.GetUserByID(c.Request().Context(), claims.UserID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return Refuse(c, http.StatusUnauthorized, "auth.invalid_token", nil)
+				}
+				return Refuse(c, http.StatusInternalServerError, "auth.verify_failed", nil)
+			}
+			if user.IsClosed() {
+				return Refuse(c, http.StatusUnauthorized, "auth.account_closed", nil)
+			}
 
-		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return AccessTokenSecret, nil
-		})
-		if err != nil || !token.Valid {
-			return c.JSON(http.StatusUnauthorized, echo.Map{"error": "Invalid token"})
+			c.Set(UserIDKey, claims.UserID)
+			// the stored role wins over the one in the token, so a demotion applies right away.
+			c.Set(UserRoleKey, user.Role)
+			if user.Language != "" {
+				SetLanguage(c, user.Language)
+			}
+			return next(c)
 		}
-		claims := token.Claims.(*Claims)
-		c.Set(UserIDKey, claims.UserID)
-		return next(c)
 	}
 }
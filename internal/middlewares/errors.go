@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"tahap2/internal/i18n"
+	"tahap2/internal/validation"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorResponse is the body of a refused request. Code identifies the error for clients,
+// Message describes it in the language of the request. Errors is set when the request broke
+// its validation rules, with one entry per field.
+type ErrorResponse struct {
+	Message string            `json:"message"`
+	Code    string            `json:"code,omitempty"`
+	Errors  validation.Errors `json:"errors,omitempty"`
+}
+
+// Refuse answers status with the message of code in the language of the request, so the
+// middlewares turn requests away in the same shape as the handlers.
+func Refuse(c echo.Context, status int, code string, args i18n.Args) error {
+	return c.JSON(status, ErrorResponse{Message: i18n.T(Language(c), code, args), Code: code})
+}
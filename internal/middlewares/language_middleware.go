@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"tahap2/internal/i18n"
+
+	"github.com/labstack/echo/v4"
+)
+
+const LanguageKey = "language"
+
+// LanguageMiddleware picks the language of the answer from Accept-Language. AuthMiddleware
+// replaces it with the user's preference when there is one.
+func LanguageMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+		SetLanguage(c, i18n.Match(c.Request().Header.Get("Accept-Language")))
+		return next(c)
+	}
+}
+
+// SetLanguage makes lang the language of the answer.
+func SetLanguage(c echo.Context, lang string) {
+	c.Set(LanguageKey, lang)
+	c.Response().Header().Set("Content-Language", lang)
+}
+
+// Language is the language to answer in, the default one when LanguageMiddleware didn't run.
+func Language(c echo.Context) string {
+	if lang, ok := c.Get(LanguageKey).(string); ok {
+		return lang
+	}
+	return i18n.Default
+}
@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"tahap2/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MerchantIDKey   = "merchant_id"
+	MerchantKeyHead = "X-API-Key"
+)
+
+// NewMerchantAuthMiddleware authenticates merchant servers by their API key. It is separate
+// from the consumer JWT flow, a user token is never accepted on merchant routes.
+func NewMerchantAuthMiddleware(merchantService domain.MerchantService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiKey := c.Request().Header.Get(MerchantKeyHead)
+			if apiKey == "" {
+				return Refuse(c, http.StatusUnauthorized, "auth.missing_api_key", nil)
+			}
+
+			merchant, err := merchantService.Authenticate(c.Request().Context(), apiKey)
+			if err != nil {
+				switch {
+				case errors.Is(err, domain.ErrInvalidAPIKey), errors.Is(err, domain.ErrMerchantNotFound):
+					return Refuse(c, http.StatusUnauthorized, "auth.invalid_api_key", nil)
+				case errors.Is(err, domain.ErrMerchantInactive):
+					return Refuse(c, http.StatusForbidden, "auth.merchant_suspended", nil)
+				default:
+					return Refuse(c, http.StatusInternalServerError, "auth.verify_api_key_failed", nil)
+				}
+			}
+
+			c.Set(MerchantIDKey, merchant.ID)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"strconv"
+	"tahap2/internal/metrics"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsMiddleware counts and times every request. Requests are labelled with the route
+// they matched rather than their path, so ids in paths don't make a series each.
+func MetricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		if err := next(c); err != nil {
+			// lets echo write the error response now, so its status is the one counted.
+			c.Error(err)
+		}
+
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		method, status := c.Request().Method, strconv.Itoa(c.Response().Status)
+		metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
@@ -0,0 +1,96 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"tahap2/internal/domain"
+	"tahap2/internal/i18n"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RateLimitKey returns the part of a bucket key a request is counted by.
+type RateLimitKey func(c echo.Context) string
+
+// KeyByIP counts requests per client IP.
+func KeyByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// KeyByUser counts requests per authenticated user, and per client IP before authentication.
+// It must run after AuthMiddleware to see the user.
+func KeyByUser(c echo.Context) string {
+	if userID, ok := c.Get(UserIDKey).(uuid.UUID); ok {
+		return "user:" + userID.String()
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRoute counts requests per route, so each endpoint gets a bucket of its own.
+func KeyByRoute(c echo.Context) string {
+	return "route:" + c.Request().Method + " " + c.Path()
+}
+
+// RateLimitPolicy limits the requests sharing the same keys to Limit. Name keeps the buckets
+// of different policies apart. FailClosed turns requests away while the store is failing,
+// for the routes where an unlimited burst is worse than being unavailable.
+type RateLimitPolicy struct {
+	Name       string
+	Limit      domain.RateLimit
+	Keys       []RateLimitKey
+	FailClosed bool
+}
+
+// failClosedRetryAfter is the Retry-After sent while a failing store turns requests away.
+const failClosedRetryAfter = 5
+
+// NewRateLimitMiddleware answers 429 once the bucket of a request is empty. Every answer
+// carries the RateLimit-* headers, rejections a Retry-After too. Requests pass when the store
+// fails, an outage of the store must not take the API down with it, unless the policy fails
+// closed, then they are answered 503.
+func NewRateLimitMiddleware(store domain.RateLimitStore, policy RateLimitPolicy, logger *slog.Logger) echo.MiddlewareFunc {
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit.Limit, int(policy.Limit.Period.Seconds()))
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			parts := make([]string, 0, len(policy.Keys)+1)
+			parts = append(parts, policy.Name)
+			for _, key := range policy.Keys {
+				parts = append(parts, key(c))
+			}
+
+			ctx := c.Request().Context()
+			result, err := store.Take(ctx, strings.Join(parts, "|"), policy.Limit, time.Now())
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to check rate limit", "policy", policy.Name, "error", err)
+				if policy.FailClosed {
+					c.Response().Header().Set("Retry-After", strconv.Itoa(failClosedRetryAfter))
+					return Refuse(c, http.StatusServiceUnavailable, "rate_limit_unavailable", nil)
+				}
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Policy", policyHeader)
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+			if !result.Allowed {
+				retryAfter := ceilSeconds(result.RetryAfter)
+				header.Set("Retry-After", strconv.Itoa(retryAfter))
+				return Refuse(c, http.StatusTooManyRequests, "rate_limited", i18n.Args{"count": retryAfter})
+			}
+			return next(c)
+		}
+	}
+}
+
+// ceilSeconds rounds d up to whole seconds, the headers can't carry fractions.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,134 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"tahap2/internal/domain"
+	"tahap2/internal/storage"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	return domain.RateLimitResult{}, errors.New("connection refused")
+}
+
+// rateLimitedServer serves /login and /register under policy, and /transfer under the same
+// policy keyed by the user the X-User header names.
+func rateLimitedServer(store domain.RateLimitStore, policy RateLimitPolicy) *echo.Echo {
+	e := echo.New()
+	e.IPExtractor = echo.ExtractIPDirect()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	limited := NewRateLimitMiddleware(store, policy, logger)
+	e.POST("/login", ok, limited)
+	e.POST("/register", ok, limited)
+
+	policy.Keys = []RateLimitKey{KeyByUser}
+	authenticated := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if userID, err := uuid.Parse(c.Request().Header.Get("X-User")); err == nil {
+				c.Set(UserIDKey, userID)
+			}
+			return next(c)
+		}
+	}
+	e.POST("/transfer", ok, authenticated, NewRateLimitMiddleware(store, policy, logger))
+	return e
+}
+
+func serve(e *echo.Echo, path, ip, user string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.RemoteAddr = ip + ":40000"
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitMiddlewareKeys(t *testing.T) {
+	e := rateLimitedServer(storage.NewMemoryRateLimitStore(), RateLimitPolicy{
+		Name:  "auth",
+		Limit: domain.RateLimit{Limit: 2, Period: time.Minute},
+		Keys:  []RateLimitKey{KeyByIP, KeyByRoute},
+	})
+	alice, bob := uuid.NewString(), uuid.NewString()
+
+	for i := range 2 {
+		rec := serve(e, "/login", "10.0.0.1", "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+		if got, want := rec.Header().Get("RateLimit-Remaining"), []string{"1", "0"}[i]; got != want {
+			t.Errorf("request %d: %s remaining, want %s", i+1, got, want)
+		}
+	}
+	rec := serve(e, "/login", "10.0.0.1", "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the limit: status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After %q, want 30", got)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code != "rate_limited" || resp.Message == "" {
+		t.Errorf("body %s, want an error response coded rate_limited", rec.Body)
+	}
+
+	// every ip, route and user has a bucket of its own.
+	for _, request := range []struct{ path, ip, user string }{
+		{"/login", "10.0.0.2", ""},
+		{"/register", "10.0.0.1", ""},
+		{"/transfer", "10.0.0.1", alice},
+		{"/transfer", "10.0.0.1", alice},
+		{"/transfer", "10.0.0.1", bob},
+		{"/transfer", "10.0.0.3", ""},
+	} {
+		if rec := serve(e, request.path, request.ip, request.user); rec.Code != http.StatusOK {
+			t.Errorf("%s from %s as %q: status %d", request.path, request.ip, request.user, rec.Code)
+		}
+	}
+	// a user is counted wherever the requests come from.
+	if rec := serve(e, "/transfer", "10.0.0.4", alice); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("third transfer of the same user: status %d", rec.Code)
+	}
+}
+
+func TestRateLimitMiddlewareStoreFailing(t *testing.T) {
+	policy := RateLimitPolicy{
+		Name:  "auth",
+		Limit: domain.RateLimit{Limit: 1, Period: time.Minute},
+		Keys:  []RateLimitKey{KeyByIP},
+	}
+	for range 3 {
+		if rec := serve(rateLimitedServer(failingRateLimitStore{}, policy), "/login", "10.0.0.1", ""); rec.Code != http.StatusOK {
+			t.Fatalf("failing open: status %d", rec.Code)
+		}
+	}
+
+	policy.FailClosed = true
+	rec := serve(rateLimitedServer(failingRateLimitStore{}, policy), "/login", "10.0.0.1", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("failing closed: status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After %q, want 5", got)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Code != "rate_limit_unavailable" || resp.Message == "" {
+		t.Errorf("body %s, want an error response coded rate_limit_unavailable", rec.Body)
+	}
+}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"net/http"
+	"tahap2/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequirePermission only lets requests through whose role grants permission.
+// It must run after AuthMiddleware, which puts the role from the token on the context.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get(UserRoleKey).(string)
+			if !domain.RoleHasPermission(role, permission) {
+				return Refuse(c, http.StatusForbidden, "auth.forbidden", nil)
+			}
+			return next(c)
+		}
+	}
+}
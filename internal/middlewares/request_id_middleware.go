@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maxRequestIDLength caps ids taken from clients, longer ones are replaced.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware keeps the X-Request-ID a client or proxy sent, or generates one, and
+// returns it in the response. It must run before RequestMetaMiddleware, which reads it.
+func RequestIDMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		requestID := req.Header.Get(echo.HeaderXRequestID)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+			req.Header.Set(echo.HeaderXRequestID, requestID)
+		}
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		return next(c)
+	}
+}
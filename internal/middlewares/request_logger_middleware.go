@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorKey holds the error a handler answered with a generic message, the record keeps what
+// the client wasn't told.
+const ErrorKey = "error"
+
+// NewRequestLoggerMiddleware logs every request once it has been answered. It must run after
+// RequestMetaMiddleware so the record carries the request id.
+func NewRequestLoggerMiddleware(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			if err := next(c); err != nil {
+				// lets echo write the error response now, so its status is the one logged.
+				c.Error(err)
+			}
+
+			req, resp := c.Request(), c.Response()
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", resp.Status),
+				slog.Int64("bytes", resp.Size),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("ip", c.RealIP()),
+			}
+			if userID, ok := c.Get(UserIDKey).(uuid.UUID); ok {
+				attrs = append(attrs, slog.String("user_id", userID.String()))
+			}
+			if err, ok := c.Get(ErrorKey).(error); ok {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+
+			level := slog.LevelInfo
+			if resp.Status >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(req.Context(), level, "request", attrs...)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"tahap2/internal/domain"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequestMetaMiddleware stores the client address, user agent and request id on the request
+// context, where the audit log picks them up.
+func RequestMetaMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		ctx := domain.WithRequestMeta(req.Context(), domain.RequestMeta{
+			IPAddress: c.RealIP(),
+			UserAgent: req.UserAgent(),
+			RequestID: req.Header.Get(echo.HeaderXRequestID),
+		})
+		c.SetRequest(req.WithContext(ctx))
+		return next(c)
+	}
+}
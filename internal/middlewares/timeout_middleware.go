@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"context"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NewTimeoutMiddleware gives every request a deadline, so the queries it runs are cancelled
+// once the client can't be answered in time anymore. Routes in overrides get their own
+// timeout instead of timeout, a zero one means no deadline. It must run after routing, the
+// route is what overrides are looked up by.
+func NewTimeoutMiddleware(timeout time.Duration, overrides map[string]time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			routeTimeout, ok := overrides[c.Path()]
+			if !ok {
+				routeTimeout = timeout
+			}
+			if routeTimeout <= 0 {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request().Context(), routeTimeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
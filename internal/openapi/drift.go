@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+)
+
+// CheckRoutes compares the routes echo serves below prefix with the documented ones. The
+// error lists every route that is served but not documented and every one documented but not
+// served.
+func (d *Document) CheckRoutes(routes []*echo.Route, prefix string) error {
+	served := make(map[string]bool)
+	for _, route := range routes {
+		if !isHTTPMethod(route.Method) || !hasPrefix(route.Path, prefix) {
+			continue
+		}
+		served[route.Method+" "+PathOf(route.Path)] = true
+	}
+
+	var errs []error
+	documented := make(map[string]bool)
+	for _, op := range d.operations() {
+		documented[op] = true
+	}
+	for _, op := range sortedKeys(served) {
+		if !documented[op] {
+			errs = append(errs, fmt.Errorf("%s is served but not documented", op))
+		}
+	}
+	for _, op := range sortedKeys(documented) {
+		if !served[op] {
+			errs = append(errs, fmt.Errorf("%s is documented but not served", op))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isHTTPMethod leaves out the routes echo registers itself, such as the not found handlers of
+// groups with middleware.
+func isHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
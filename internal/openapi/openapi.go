@@ -0,0 +1,383 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	Version = "3.0.3"
+
+	ContentTypeJSON      = "application/json"
+	ContentTypeMultipart = "multipart/form-data"
+
+	// the schemas of error bodies, handlers and middlewares answer alike.
+	errorSchema      = "Error"
+	fieldErrorSchema = "FieldError"
+)
+
+// Document is an OpenAPI 3 document, limited to the parts this API makes use of.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// RequiredField reports whether a field of a request body is required, the document
+	// doesn't know the rules handlers check requests with. No request field is required
+	// when it is nil.
+	RequiredField func(field reflect.StructField) bool `json:"-"`
+
+	// components names the schema registered for each type.
+	components map[reflect.Type]string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+	Patch  *Operation `json:"patch,omitempty"`
+}
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// Param is a path, query or header parameter of a route. Path parameters that aren't listed
+// are documented as plain strings.
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Route describes an operation the way the handler sees it, the OpenAPI operation is
+// generated from it.
+type Route struct {
+	Method string
+	// Path is in echo's form, e.g. /api/v1/users/:id.
+	Path    string
+	Tag     string
+	Summary string
+	// Security names the security scheme guarding the route, empty for public routes.
+	Security string
+	// Forbidden is set when the middlewares of the route can turn authenticated callers away,
+	// such as RBAC or a suspended merchant.
+	Forbidden   bool
+	RateLimited bool
+	// RateLimitFailsClosed is set when the rate limit answers 503 while its store is failing.
+	RateLimitFailsClosed bool
+	Params               []Param
+	// Request is the body, a value of the type the handler binds or a *Schema. It is sent as
+	// RequestContentType, JSON when empty.
+	Request            any
+	RequestContentType string
+	// Status is the success status, 200 when zero.
+	Status int
+	// Response is the result of the success envelope, a value of the type the handler answers
+	// with or a *Schema. The envelope only carries a status when it is nil.
+	Response any
+	// Files lists the content types the route can answer with a file instead of JSON. When
+	// there is no Response, the route always answers with a file.
+	Files []string
+	// Errors lists the statuses the handler answers with an error message.
+	Errors []int
+}
+
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: map[string]*Schema{
+				errorSchema: {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"message": {Type: "string", Description: "In the language picked from Accept-Language or the user's preference."},
+						"code":    {Type: "string", Description: "Identifies the error, whatever the language of the message."},
+						"errors": {
+							Type:        "array",
+							Description: "Set when the request broke its validation rules, one entry per field.",
+							Items:       ref(fieldErrorSchema),
+						},
+					},
+					Required: []string{"message"},
+				},
+				fieldErrorSchema: {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"field":   {Type: "string"},
+						"code":    {Type: "string", Description: "The rule the field broke."},
+						"param":   {Type: "string", Description: "What the rule was given, e.g. the maximum length."},
+						"message": {Type: "string"},
+					},
+					Required: []string{"field", "code", "message"},
+				},
+			},
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+		components: make(map[reflect.Type]string),
+	}
+}
+
+// AddSecurityScheme registers a scheme routes can name in Security.
+func (d *Document) AddSecurityScheme(name string, scheme SecurityScheme) {
+	d.Components.SecuritySchemes[name] = &scheme
+}
+
+// AddRoute documents a route, registering the schemas of its body and result.
+func (d *Document) AddRoute(route Route) {
+	op := &Operation{
+		Summary:   route.Summary,
+		Responses: make(map[string]*Response),
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if route.Security != "" {
+		op.Security = []map[string][]string{{route.Security: {}}}
+	}
+	op.Parameters = d.parameters(route)
+
+	if route.Request != nil {
+		contentType := route.RequestContentType
+		if contentType == "" {
+			contentType = ContentTypeJSON
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{contentType: {Schema: d.schema(route.Request, false)}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status), Content: make(map[string]*MediaType)}
+	if route.Response != nil || len(route.Files) == 0 {
+		success.Content[ContentTypeJSON] = &MediaType{Schema: d.envelope(route.Response)}
+	}
+	for _, contentType := range route.Files {
+		success.Content[contentType] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	errors := map[int]bool{http.StatusInternalServerError: true}
+	for _, status := range route.Errors {
+		errors[status] = true
+	}
+	errors[http.StatusUnauthorized] = errors[http.StatusUnauthorized] || route.Security != ""
+	errors[http.StatusForbidden] = errors[http.StatusForbidden] || route.Forbidden
+	errors[http.StatusTooManyRequests] = errors[http.StatusTooManyRequests] || route.RateLimited
+	errors[http.StatusServiceUnavailable] = errors[http.StatusServiceUnavailable] || route.RateLimitFailsClosed
+	for status, refused := range errors {
+		if refused {
+			op.Responses[strconv.Itoa(status)] = errorResponse(status)
+		}
+	}
+	if route.RateLimited {
+		op.Responses[strconv.Itoa(http.StatusTooManyRequests)].Headers = map[string]*Header{
+			"Retry-After": {Description: "Seconds until the next request is allowed.", Schema: &Schema{Type: "integer"}},
+		}
+	}
+	if route.RateLimitFailsClosed {
+		op.Responses[strconv.Itoa(http.StatusServiceUnavailable)].Headers = map[string]*Header{
+			"Retry-After": {Description: "Seconds to wait while the rate limit can't be checked.", Schema: &Schema{Type: "integer"}},
+		}
+	}
+
+	path := PathOf(route.Path)
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	*item.operation(route.Method) = op
+}
+
+// parameters lists the declared parameters, followed by the path parameters that weren't.
+func (d *Document) parameters(route Route) []*Parameter {
+	var params []*Parameter
+	declared := make(map[string]bool)
+	for _, param := range route.Params {
+		schema := param.Schema
+		if schema == nil {
+			schema = &Schema{Type: "string"}
+		}
+		params = append(params, &Parameter{
+			Name:        param.Name,
+			In:          param.In,
+			Description: param.Description,
+			Required:    param.Required || param.In == "path",
+			Schema:      schema,
+		})
+		if param.In == "path" {
+			declared[param.Name] = true
+		}
+	}
+	for _, name := range pathParams(route.Path) {
+		if !declared[name] {
+			params = append(params, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return params
+}
+
+// envelope wraps result the way handlers answer, {"status": "success", "result": ...}.
+func (d *Document) envelope(result any) *Schema {
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status": {Type: "string", Enum: []string{"success"}},
+		},
+		Required: []string{"status"},
+	}
+	if result != nil {
+		schema.Properties["result"] = d.schema(result, true)
+		schema.Required = append(schema.Required, "result")
+	}
+	return schema
+}
+
+func (d *Document) schema(v any, response bool) *Schema {
+	if schema, ok := v.(*Schema); ok {
+		return schema
+	}
+	return d.schemaOf(reflect.TypeOf(v), response)
+}
+
+func (item *PathItem) operation(method string) **Operation {
+	switch method {
+	case http.MethodGet:
+		return &item.Get
+	case http.MethodPut:
+		return &item.Put
+	case http.MethodPost:
+		return &item.Post
+	case http.MethodDelete:
+		return &item.Delete
+	case http.MethodPatch:
+		return &item.Patch
+	}
+	panic("openapi: unsupported method " + method)
+}
+
+func errorResponse(status int) *Response {
+	return &Response{
+		Description: http.StatusText(status),
+		Content:     map[string]*MediaType{ContentTypeJSON: {Schema: ref(errorSchema)}},
+	}
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var pathParamPattern = regexp.MustCompile(`:([^/]+)`)
+
+// PathOf turns an echo path into an OpenAPI one, /users/:id into /users/{id}.
+func PathOf(echoPath string) string {
+	return pathParamPattern.ReplaceAllString(echoPath, "{$1}")
+}
+
+func pathParams(echoPath string) []string {
+	var names []string
+	for _, match := range pathParamPattern.FindAllStringSubmatch(echoPath, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// operations lists every documented operation as "METHOD /path".
+func (d *Document) operations() []string {
+	var ops []string
+	for path, item := range d.Paths {
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch} {
+			if *item.operation(method) != nil {
+				ops = append(ops, method+" "+path)
+			}
+		}
+	}
+	return ops
+}
+
+// hasPrefix reports whether path is prefix itself or below it.
+func hasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
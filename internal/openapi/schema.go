@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	uuidType          = reflect.TypeOf(uuid.UUID{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf describes how encoding/json encodes t. Named structs become components and are
+// referenced. Response schemas require every field that isn't omitempty, encoding/json always
+// writes those, request schemas require what RequiredField says the handler requires.
+func (d *Document) schemaOf(t reflect.Type, response bool) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t.Kind() != reflect.Pointer && t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return d.schemaOf(t.Elem(), response)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem(), response)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem(), response)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t, response)
+		}
+		return d.component(t, response)
+	default:
+		// interfaces can hold anything.
+		return &Schema{}
+	}
+}
+
+// component registers the schema of a named struct once and refers to it.
+func (d *Document) component(t reflect.Type, response bool) *Schema {
+	if name, ok := d.components[t]; ok {
+		return ref(name)
+	}
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; taken {
+		// another package has a type of the same name.
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	// registered before the fields, so recursive types end up referring to themselves.
+	schema := &Schema{}
+	d.components[t] = name
+	d.Components.Schemas[name] = schema
+	*schema = *d.structSchema(t, response)
+	return ref(name)
+}
+
+func (d *Document) structSchema(t reflect.Type, response bool) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(schema, t, response)
+	return schema
+}
+
+// addFields adds the fields of t the way encoding/json encodes them, promoting the fields of
+// embedded structs.
+func (d *Document) addFields(schema *Schema, t reflect.Type, response bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				d.addFields(schema, fieldType, response)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldSchema := d.schemaOf(fieldType, response)
+		if hasOption(opts, "string") {
+			fieldSchema = &Schema{Type: "string"}
+		}
+		omitEmpty := hasOption(opts, "omitempty")
+		if fieldType.Kind() == reflect.Pointer && !omitEmpty {
+			fieldSchema = nullable(fieldSchema)
+		}
+		schema.Properties[name] = fieldSchema
+		if response && !omitEmpty || !response && d.RequiredField != nil && d.RequiredField(field) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// nullable allows null besides what schema allows. A reference can't carry siblings in
+// OpenAPI 3.0, so it is wrapped.
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+	schema.Nullable = true
+	return schema
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
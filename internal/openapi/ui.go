@@ -0,0 +1,74 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	swaggerfiles "github.com/swaggo/files/v2"
+)
+
+// Handler serves the document as JSON. It is encoded once, the document doesn't change
+// after startup.
+func (d *Document) Handler() (http.Handler, error) {
+	body, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode openapi document: %w", err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		_, _ = w.Write(body)
+	}), nil
+}
+
+var uiPage = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="swagger-ui.css">
+  <link rel="stylesheet" href="index.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="swagger-ui-bundle.js"></script>
+  <script src="swagger-ui-standalone-preset.js"></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({
+        url: {{.SpecURL}},
+        dom_id: "#swagger-ui",
+        deepLinking: true,
+        persistAuthorization: true,
+        presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+        layout: "StandaloneLayout",
+      });
+    };
+  </script>
+</body>
+</html>
+`))
+
+// UIHandler serves Swagger UI below prefix, showing the document at specURL. The assets are
+// embedded, the UI works without access to the internet.
+func UIHandler(prefix, title, specURL string) http.Handler {
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerfiles.FS)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := strings.TrimPrefix(r.URL.Path, prefix)
+		if page != "" && page != "/" && page != "/index.html" {
+			files.ServeHTTP(w, r)
+			return
+		}
+		// the assets are referred to relatively, so the page has to be below the prefix.
+		if page == "" {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := uiPage.Execute(w, struct{ Title, SpecURL string }{title, specURL}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+// Package pdf writes simple text documents in PDF format. Pages are written out as soon
+// as they are finished, so a document of any length is produced in constant memory.
+//
+// Only the standard Helvetica and Courier fonts are available, text outside of Latin-1 is
+// replaced by '?'.
+package pdf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Page size in points, A4 portrait.
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+)
+
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+	Courier
+	CourierBold
+)
+
+var fontNames = [...]string{"Helvetica", "Helvetica-Bold", "Courier", "Courier-Bold"}
+
+// Objects 1 and 2 are the catalog and the page tree, the fonts follow. They are written
+// last, when all pages are known.
+const (
+	catalogObject = 1
+	pagesObject   = 2
+	firstFont     = 3
+	firstFree     = firstFont + len(fontNames)
+)
+
+// Document is a PDF being written to an io.Writer. Drawing methods apply to the current
+// page, which is started by NewPage. Errors are sticky and returned by Close.
+type Document struct {
+	w       *bufio.Writer
+	written int64
+	offsets map[int]int64
+	next    int
+	pages   []int
+	page    *bytes.Buffer
+	err     error
+}
+
+func NewDocument(w io.Writer) *Document {
+	d := &Document{
+		w:       bufio.NewWriter(w),
+		offsets: map[int]int64{},
+		next:    firstFree,
+	}
+	// the binary comment tells transfer programs the file is not plain text.
+	d.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	return d
+}
+
+// NewPage finishes the current page, if any, and starts an empty one.
+func (d *Document) NewPage() {
+	d.finishPage()
+	d.page = &bytes.Buffer{}
+}
+
+// Text draws s with its baseline starting at x, y, measured in points from the bottom left.
+func (d *Document) Text(x, y float64, font Font, size float64, s string) {
+	if d.page == nil {
+		d.NewPage()
+	}
+	fmt.Fprintf(d.page, "BT /F%d %s Tf %s %s Td (%s) Tj ET\n", int(font), num(size), num(x), num(y), escape(s))
+}
+
+// TextRight draws s so that it ends at x, see TextWidth.
+func (d *Document) TextRight(x, y float64, font Font, size float64, s string) {
+	d.Text(x-TextWidth(font, size, s), y, font, size, s)
+}
+
+// Line draws a thin line from x1, y1 to x2, y2.
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	if d.page == nil {
+		d.NewPage()
+	}
+	fmt.Fprintf(d.page, "0.5 w %s %s m %s %s l S\n", num(x1), num(y1), num(x2), num(y2))
+}
+
+// TextWidth returns the width of s in points. The proportional Helvetica fonts are
+// approximated with an average character width.
+func TextWidth(font Font, size float64, s string) float64 {
+	perChar := 0.6
+	if font == Helvetica || font == HelveticaBold {
+		perChar = 0.55
+	}
+	return float64(len([]rune(s))) * perChar * size
+}
+
+// Close finishes the last page and writes the document structure. A document has at
+// least one page.
+func (d *Document) Close() error {
+	if d.page == nil && len(d.pages) == 0 {
+		d.NewPage()
+	}
+	d.finishPage()
+
+	kids := &bytes.Buffer{}
+	for i, page := range d.pages {
+		if i > 0 {
+			kids.WriteByte(' ')
+		}
+		fmt.Fprintf(kids, "%d 0 R", page)
+	}
+	d.object(pagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(d.pages)))
+	d.object(catalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObject))
+	for i, name := range fontNames {
+		d.object(firstFont+i, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	xref := d.written
+	d.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", d.next))
+	for n := 1; n < d.next; n++ {
+		d.write(fmt.Sprintf("%010d 00000 n \n", d.offsets[n]))
+	}
+	d.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", d.next, catalogObject, xref))
+
+	if d.err != nil {
+		return d.err
+	}
+	return d.w.Flush()
+}
+
+// finishPage writes the content stream and page object of the current page.
+func (d *Document) finishPage() {
+	if d.page == nil {
+		return
+	}
+	content, page := d.allocate(), d.allocate()
+	d.object(content, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", d.page.Len(), d.page.Bytes()))
+
+	fonts := &bytes.Buffer{}
+	for i := range fontNames {
+		fmt.Fprintf(fonts, " /F%d %d 0 R", i, firstFont+i)
+	}
+	d.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font <<%s >> >> /Contents %d 0 R >>",
+		pagesObject, num(PageWidth), num(PageHeight), fonts, content))
+	d.pages = append(d.pages, page)
+	d.page = nil
+}
+
+func (d *Document) allocate() int {
+	n := d.next
+	d.next++
+	return n
+}
+
+func (d *Document) object(n int, body string) {
+	d.offsets[n] = d.written
+	d.write(strconv.Itoa(n) + " 0 obj\n" + body + "\nendobj\n")
+}
+
+func (d *Document) write(s string) {
+	if d.err != nil {
+		return
+	}
+	n, err := d.w.WriteString(s)
+	d.written += int64(n)
+	d.err = err
+}
+
+// escape encodes s as the body of a PDF string literal in WinAnsi.
+func escape(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
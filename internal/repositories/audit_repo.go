@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+// auditChainLock is the advisory lock key that serializes appends to the audit chain.
+const auditChainLock = 7_203_411
+
+type AuditRepo struct {
+	DB *gorm.DB
+}
+
+func NewAuditRepo(db *gorm.DB) *AuditRepo {
+	return &AuditRepo{DB: db}
+}
+
+func (r *AuditRepo) AppendAuditLog(ctx context.Context, entry *domain.AuditLog) error {
+	return conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return err
+		}
+
+		var last domain.AuditLog
+		err := tx.Order("sequence DESC").First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+		// postgres keeps microseconds, truncate so the stored value hashes the same.
+		entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		entry.Hash = entry.ComputeHash()
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *AuditRepo) GetAuditLogs(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditLog, error) {
+	db := conn(ctx, r.DB)
+	if filter.EventType != "" {
+		db = db.Where("event_type = ?", filter.EventType)
+	}
+	if filter.ActorID != nil {
+		db = db.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.SubjectID != "" {
+		db = db.Where("subject_id = ?", filter.SubjectID)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+
+	var logs []*domain.AuditLog
+	err := db.Order("sequence DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *AuditRepo) WalkAuditLogs(ctx context.Context, fn func(entry *domain.AuditLog) error) error {
+	var batch []*domain.AuditLog
+	return conn(ctx, r.DB).Order("sequence").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
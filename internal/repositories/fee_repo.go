@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type FeeRuleRepo struct {
+	DB *gorm.DB
+}
+
+func NewFeeRuleRepo(db *gorm.DB) *FeeRuleRepo {
+	return &FeeRuleRepo{DB: db}
+}
+
+func (r *FeeRuleRepo) GetActiveFeeRules(ctx context.Context, kind string) ([]*domain.FeeRule, error) {
+	var rules []*domain.FeeRule
+	err := conn(ctx, r.DB).Where("transaction_kind = ? AND active = ?", kind, true).Find(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *FeeRuleRepo) GetFeeRules(ctx context.Context, kind string) ([]*domain.FeeRule, error) {
+	query := conn(ctx, r.DB).Order("transaction_kind, user_tier, created_at DESC")
+	if kind != "" {
+		query = query.Where("transaction_kind = ?", kind)
+	}
+	var rules []*domain.FeeRule
+	if err := query.Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *FeeRuleRepo) GetFeeRuleByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.FeeRule, error) {
+	var rule domain.FeeRule
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *FeeRuleRepo) CreateFeeRule(ctx context.Context, rule *domain.FeeRule) error {
+	// every column is written, so a rule created inactive isn't made active by the column default.
+	err := conn(ctx, r.DB).Select("*").Create(rule).Error
+	// the partial unique index allows a single active rule per kind and tier.
+	if isUniqueViolation(err) {
+		return domain.ErrFeeRuleConflict
+	}
+	return err
+}
+
+func (r *FeeRuleRepo) UpdateFeeRule(ctx context.Context, rule *domain.FeeRule) error {
+	err := conn(ctx, r.DB).Save(rule).Error
+	if isUniqueViolation(err) {
+		return domain.ErrFeeRuleConflict
+	}
+	return err
+}
+
+type SystemAccountRepo struct {
+	DB *gorm.DB
+}
+
+func NewSystemAccountRepo(db *gorm.DB) *SystemAccountRepo {
+	return &SystemAccountRepo{DB: db}
+}
+
+func (r *SystemAccountRepo) EnsureSystemAccount(ctx context.Context, code, name string) (*domain.SystemAccount, error) {
+	account := domain.SystemAccount{Code: code, Name: name}
+	err := conn(ctx, r.DB).Where("code = ?", code).FirstOrCreate(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *SystemAccountRepo) CreditSystemAccount(ctx context.Context, code string, amount int64) (*domain.SystemAccount, error) {
+	db := conn(ctx, r.DB)
+	var account domain.SystemAccount
+	err := db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("code = ?", code).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("system account " + code + " not found")
+		}
+		return nil, err
+	}
+
+	account.Balance += amount
+	account.UpdatedAt = time.Now()
+	if err = db.Save(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
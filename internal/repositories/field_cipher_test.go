@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFieldCipher(t *testing.T) {
+	cipher, err := NewFieldCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := cipher.Encrypt("3171234567890001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix) || strings.Contains(encrypted, "3171234567890001") {
+		t.Fatalf("encrypted = %q", encrypted)
+	}
+	if again, _ := cipher.Encrypt("3171234567890001"); again == encrypted {
+		t.Fatal("the same value was encrypted twice to the same ciphertext")
+	}
+	if got, err := cipher.Decrypt(encrypted); err != nil || got != "3171234567890001" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	// values stored before encryption are read as they are.
+	if got, err := cipher.Decrypt("3171234567890001"); err != nil || got != "3171234567890001" {
+		t.Fatalf("Decrypt of a clear value = %q, %v", got, err)
+	}
+
+	other, _ := NewFieldCipher(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Fatal("decrypted with another key")
+	}
+	if _, err := NewFieldCipher([]byte("short")); err == nil {
+		t.Fatal("accepted a short key")
+	}
+}
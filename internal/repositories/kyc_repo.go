@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+)
+
+// KYCRepo stores submissions with their id number encrypted, callers only see it in clear.
+type KYCRepo struct {
+	DB     *gorm.DB
+	cipher *FieldCipher
+}
+
+func NewKYCRepo(db *gorm.DB, cipher *FieldCipher) *KYCRepo {
+	return &KYCRepo{DB: db, cipher: cipher}
+}
+
+func (r *KYCRepo) CreateSubmission(ctx context.Context, submission *domain.KYCSubmission) error {
+	err := r.withEncryptedIDNumber(submission, func() error {
+		return conn(ctx, r.DB).Create(submission).Error
+	})
+	// the partial unique index allows a single pending submission per user.
+	if isUniqueViolation(err) {
+		return domain.ErrKYCAlreadyPending
+	}
+	return err
+}
+
+func (r *KYCRepo) GetSubmissionByID(ctx context.Context, id uuid.UUID) (*domain.KYCSubmission, error) {
+	var submission domain.KYCSubmission
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&submission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &submission, r.decryptIDNumber(&submission)
+}
+
+func (r *KYCRepo) GetLatestSubmissionByUserID(ctx context.Context, userID uuid.UUID) (*domain.KYCSubmission, error) {
+	var submission domain.KYCSubmission
+	err := conn(ctx, r.DB).Where("user_id = ?", userID).Order("created_at DESC").First(&submission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &submission, r.decryptIDNumber(&submission)
+}
+
+func (r *KYCRepo) GetSubmissionsByStatus(ctx context.Context, status string, limit, offset int) ([]*domain.KYCSubmission, error) {
+	var submissions []*domain.KYCSubmission
+	err := conn(ctx, r.DB).Where("status = ?", status).
+		Order("created_at").
+		Limit(limit).
+		Offset(offset).
+		Find(&submissions).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, submission := range submissions {
+		if err = r.decryptIDNumber(submission); err != nil {
+			return nil, err
+		}
+	}
+	return submissions, nil
+}
+
+func (r *KYCRepo) UpdateSubmission(ctx context.Context, submission *domain.KYCSubmission) error {
+	return r.withEncryptedIDNumber(submission, func() error {
+		return conn(ctx, r.DB).Save(submission).Error
+	})
+}
+
+// EncryptIDNumbers encrypts the id numbers stored before they were encrypted and returns
+// how many it encrypted.
+func (r *KYCRepo) EncryptIDNumbers(ctx context.Context) (int, error) {
+	var submissions []domain.KYCSubmission
+	err := conn(ctx, r.DB).Select("id", "id_number").
+		Where("id_number NOT LIKE ?", encryptedPrefix+"%").
+		Find(&submissions).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, submission := range submissions {
+		encrypted, err := r.cipher.Encrypt(submission.IDNumber)
+		if err != nil {
+			return i, err
+		}
+		err = conn(ctx, r.DB).Model(&domain.KYCSubmission{}).
+			Where("id = ? AND id_number = ?", submission.ID, submission.IDNumber).
+			UpdateColumn("id_number", encrypted).Error
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(submissions), nil
+}
+
+// withEncryptedIDNumber runs write with the id number of submission encrypted, and puts the
+// clear one back afterwards.
+func (r *KYCRepo) withEncryptedIDNumber(submission *domain.KYCSubmission, write func() error) error {
+	idNumber := submission.IDNumber
+	encrypted, err := r.cipher.Encrypt(idNumber)
+	if err != nil {
+		return err
+	}
+	submission.IDNumber = encrypted
+	defer func() { submission.IDNumber = idNumber }()
+	return write()
+}
+
+func (r *KYCRepo) decryptIDNumber(submission *domain.KYCSubmission) error {
+	idNumber, err := r.cipher.Decrypt(submission.IDNumber)
+	if err != nil {
+		return err
+	}
+	submission.IDNumber = idNumber
+	return nil
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+)
+
+type TransactionLimitRepo struct {
+	DB *gorm.DB
+}
+
+func NewTransactionLimitRepo(db *gorm.DB) *TransactionLimitRepo {
+	return &TransactionLimitRepo{DB: db}
+}
+
+func (r *TransactionLimitRepo) GetLimitByTier(ctx context.Context, tier string) (*domain.TransactionLimit, error) {
+	var limit domain.TransactionLimit
+	err := conn(ctx, r.DB).Where("tier = ?", tier).First(&limit).Error
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type MerchantRepo struct {
+	DB *gorm.DB
+}
+
+func NewMerchantRepo(db *gorm.DB) *MerchantRepo {
+	return &MerchantRepo{DB: db}
+}
+
+func (r *MerchantRepo) CreateMerchant(ctx context.Context, merchant *domain.Merchant) error {
+	return conn(ctx, r.DB).Create(merchant).Error
+}
+
+func (r *MerchantRepo) GetMerchantByID(ctx context.Context, id uuid.UUID) (*domain.Merchant, error) {
+	var merchant domain.Merchant
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&merchant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+// GetMerchantByIDForUpdate loads the merchant and locks its row until the surrounding transaction ends.
+func (r *MerchantRepo) GetMerchantByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.Merchant, error) {
+	var merchant domain.Merchant
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&merchant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &merchant, nil
+}
+
+func (r *MerchantRepo) UpdateMerchant(ctx context.Context, merchant *domain.Merchant) error {
+	return conn(ctx, r.DB).Save(merchant).Error
+}
+
+func (r *MerchantRepo) CreateAPIKey(ctx context.Context, key *domain.MerchantAPIKey) error {
+	return conn(ctx, r.DB).Create(key).Error
+}
+
+func (r *MerchantRepo) GetActiveAPIKeyByHash(ctx context.Context, keyHash string) (*domain.MerchantAPIKey, error) {
+	var key domain.MerchantAPIKey
+	err := conn(ctx, r.DB).Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *MerchantRepo) RevokeAPIKeys(ctx context.Context, merchantID uuid.UUID) error {
+	return conn(ctx, r.DB).Model(&domain.MerchantAPIKey{}).
+		Where("merchant_id = ? AND revoked_at IS NULL", merchantID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type PaymentIntentRepo struct {
+	DB *gorm.DB
+}
+
+func NewPaymentIntentRepo(db *gorm.DB) *PaymentIntentRepo {
+	return &PaymentIntentRepo{DB: db}
+}
+
+func (r *PaymentIntentRepo) CreateIntent(ctx context.Context, intent *domain.PaymentIntent) error {
+	return conn(ctx, r.DB).Create(intent).Error
+}
+
+func (r *PaymentIntentRepo) UpdateIntent(ctx context.Context, intent *domain.PaymentIntent) error {
+	return conn(ctx, r.DB).Save(intent).Error
+}
+
+func (r *PaymentIntentRepo) GetIntentByID(ctx context.Context, id uuid.UUID) (*domain.PaymentIntent, error) {
+	var intent domain.PaymentIntent
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&intent).Error
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (r *PaymentIntentRepo) GetIntentByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.PaymentIntent, error) {
+	var intent domain.PaymentIntent
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&intent).Error
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (r *PaymentIntentRepo) GetIntentByProviderRefForUpdate(ctx context.Context, provider, reference string) (*domain.PaymentIntent, error) {
+	var intent domain.PaymentIntent
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("provider = ? AND provider_ref = ?", provider, reference).
+		First(&intent).Error
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (r *PaymentIntentRepo) GetStalePendingIntents(ctx context.Context, olderThan time.Time, limit int) ([]*domain.PaymentIntent, error) {
+	var intents []*domain.PaymentIntent
+	err := conn(ctx, r.DB).
+		Where("status = ? AND provider_ref <> '' AND updated_at < ?", domain.IntentStatusPending, olderThan).
+		Order("updated_at").
+		Limit(limit).
+		Find(&intents).Error
+	if err != nil {
+		return nil, err
+	}
+	return intents, nil
+}
+
+func (r *PaymentIntentRepo) TouchPendingIntent(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.DB).Model(&domain.PaymentIntent{}).
+		Where("id = ? AND status = ?", id, domain.IntentStatusPending).
+		Update("updated_at", time.Now()).Error
+}
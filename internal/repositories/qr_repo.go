@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type QRRepo struct {
+	DB *gorm.DB
+}
+
+func NewQRRepo(db *gorm.DB) *QRRepo {
+	return &QRRepo{DB: db}
+}
+
+func (r *QRRepo) CreateQRCode(ctx context.Context, qr *domain.QRCode) error {
+	return conn(ctx, r.DB).Create(qr).Error
+}
+
+func (r *QRRepo) GetQRCodeByID(ctx context.Context, id uuid.UUID) (*domain.QRCode, error) {
+	var qr domain.QRCode
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&qr).Error
+	if err != nil {
+		return nil, err
+	}
+	return &qr, nil
+}
+
+func (r *QRRepo) ClaimQRCode(ctx context.Context, id, userID uuid.UUID, now time.Time) (bool, error) {
+	res := conn(ctx, r.DB).Model(&domain.QRCode{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, domain.QRStatusActive, now).
+		Updates(map[string]any{"status": domain.QRStatusUsed, "paid_by": userID, "updated_at": now})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *QRRepo) SetQRCodeTransaction(ctx context.Context, id, transactionID uuid.UUID) error {
+	return conn(ctx, r.DB).Model(&domain.QRCode{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"transaction_id": transactionID, "updated_at": time.Now()}).Error
+}
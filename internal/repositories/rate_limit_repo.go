@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+// RateLimitRepo keeps the rate limit buckets in Postgres so every replica counts against the
+// same ones.
+type RateLimitRepo struct {
+	DB *gorm.DB
+}
+
+func NewRateLimitRepo(db *gorm.DB) *RateLimitRepo {
+	return &RateLimitRepo{DB: db}
+}
+
+// Take locks the bucket of key, creating it full when there is none, for as long as it takes
+// to take a token from it.
+func (r *RateLimitRepo) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	var result domain.RateLimitResult
+	err := conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		bucket := domain.RateLimitBucket{Key: key, Tokens: float64(limit.Limit), UpdatedAt: now, FullAt: now}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&bucket).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&bucket).Error
+		if err != nil {
+			return err
+		}
+
+		var tokens float64
+		tokens, result = limit.Take(bucket.Tokens, bucket.UpdatedAt, now)
+		return tx.Model(&bucket).Updates(map[string]any{"tokens": tokens, "updated_at": now, "full_at": now.Add(result.ResetAfter)}).Error
+	})
+	return result, err
+}
+
+// DeleteFullBuckets deletes the buckets that are full again by now, a request finding none
+// creates it full.
+func (r *RateLimitRepo) DeleteFullBuckets(ctx context.Context, now time.Time) (int64, error) {
+	result := conn(ctx, r.DB).Where("full_at <= ?", now).Delete(&domain.RateLimitBucket{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"tahap2/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to TEST_DATABASE_URL, the test is skipped without one. The database is
+// migrated with the models the test needs, nothing is dropped.
+func testDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestRateLimitRepoTake(t *testing.T) {
+	db := testDB(t, &domain.RateLimitBucket{})
+	repo, ctx := NewRateLimitRepo(db), context.Background()
+	limit := domain.RateLimit{Limit: 3, Period: 30 * time.Second}
+	key, other := "test|"+uuid.NewString(), "test|"+uuid.NewString()
+	t.Cleanup(func() { db.Where("key IN ?", []string{key, other}).Delete(&domain.RateLimitBucket{}) })
+	now := time.Now().Truncate(time.Microsecond)
+
+	for i := range limit.Limit {
+		if result, err := repo.Take(ctx, key, limit, now); err != nil || !result.Allowed {
+			t.Fatalf("request %d: allowed %v, %v", i+1, result.Allowed, err)
+		}
+	}
+	result, err := repo.Take(ctx, key, limit, now)
+	if err != nil || result.Allowed || result.RetryAfter != 10*time.Second {
+		t.Fatalf("request past the burst: allowed %v, retry after %s, %v", result.Allowed, result.RetryAfter, err)
+	}
+	if result, err = repo.Take(ctx, other, limit, now); err != nil || !result.Allowed {
+		t.Errorf("other key: allowed %v, %v", result.Allowed, err)
+	}
+	if result, err = repo.Take(ctx, key, limit, now.Add(10*time.Second)); err != nil || !result.Allowed {
+		t.Errorf("refilled bucket: allowed %v, %v", result.Allowed, err)
+	}
+
+	// the emptied bucket is full 30s after its last take, the other 10s after its one.
+	deleted, err := repo.DeleteFullBuckets(ctx, now.Add(20*time.Second))
+	if err != nil {
+		t.Fatalf("DeleteFullBuckets: %v", err)
+	}
+	var keys []string
+	db.Model(&domain.RateLimitBucket{}).Where("key IN ?", []string{key, other}).Pluck("key", &keys)
+	if deleted < 1 || len(keys) != 1 || keys[0] != key {
+		t.Errorf("%d deleted, %v left, want only %s", deleted, keys, key)
+	}
+}
+
+// TestRateLimitRepoReplicas takes from the same bucket through two pools, as two replicas
+// would. Together they can't pass more requests than the limit.
+func TestRateLimitRepoReplicas(t *testing.T) {
+	first := testDB(t, &domain.RateLimitBucket{})
+	second := testDB(t)
+	limit := domain.RateLimit{Limit: 20, Period: time.Hour}
+	key := "test|" + uuid.NewString()
+	t.Cleanup(func() { first.Where("key = ?", key).Delete(&domain.RateLimitBucket{}) })
+	now := time.Now()
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for i := range 60 {
+		repo := NewRateLimitRepo(first)
+		if i%2 == 1 {
+			repo = NewRateLimitRepo(second)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := repo.Take(context.Background(), key, limit, now)
+			if err != nil {
+				t.Errorf("Take: %v", err)
+			}
+			if result.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := allowed.Load(); got != int64(limit.Limit) {
+		t.Errorf("%d requests allowed, want %d", got, limit.Limit)
+	}
+}
@@ -0,0 +1,135 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type ReconciliationRepo struct {
+	DB *gorm.DB
+}
+
+func NewReconciliationRepo(db *gorm.DB) *ReconciliationRepo {
+	return &ReconciliationRepo{DB: db}
+}
+
+// ledgerEffect is Transaction.BalanceEffect in SQL, it takes the DEBIT type as argument.
+const ledgerEffect = `CASE WHEN transaction_type = ? THEN amount - fee ELSE -(amount + fee) END`
+
+// postedLines keeps the ledger lines that moved a balance: the successful ones and the
+// withdrawal holds that already left the balance while pending.
+func postedLines(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ? OR (status = ? AND kind = ?)",
+		domain.TransactionStatusSuccess, domain.TransactionStatusPending, domain.TransactionKindWithdrawal)
+}
+
+// walletsQuery lists every balance the ledger has lines for.
+const walletsQuery = `
+SELECT id, 'user' AS wallet_type, balance FROM users
+UNION ALL SELECT id, 'merchant', balance FROM merchants
+UNION ALL SELECT id, 'system', balance FROM system_accounts`
+
+func (r *ReconciliationRepo) CountWallets(ctx context.Context) (int64, error) {
+	var count int64
+	err := conn(ctx, r.DB).Raw(`SELECT COUNT(*) FROM (` + walletsQuery + `) AS wallets`).Scan(&count).Error
+	return count, err
+}
+
+func (r *ReconciliationRepo) GetBalanceMismatches(ctx context.Context) ([]domain.BalanceMismatch, error) {
+	db := conn(ctx, r.DB)
+	ledger := postedLines(db.Model(&domain.Transaction{})).
+		Select("user_id, SUM("+ledgerEffect+")::bigint AS balance", domain.TransactionTypeDebit).
+		Group("user_id")
+
+	var mismatches []domain.BalanceMismatch
+	err := db.Raw(`
+WITH ledger AS (?), wallets AS (`+walletsQuery+`)
+SELECT w.id AS wallet_id, w.wallet_type, w.balance,
+	COALESCE(l.balance, 0) AS ledger_balance,
+	w.balance - COALESCE(l.balance, 0) AS drift
+FROM wallets w
+LEFT JOIN ledger l ON l.user_id = w.id
+WHERE w.balance <> COALESCE(l.balance, 0)
+ORDER BY ABS(w.balance - COALESCE(l.balance, 0)) DESC`, ledger).Scan(&mismatches).Error
+	if err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+func (r *ReconciliationRepo) GetStuckTransfers(ctx context.Context, createdBefore time.Time) ([]domain.StuckTransfer, error) {
+	var transfers []domain.StuckTransfer
+	err := conn(ctx, r.DB).Model(&domain.Transaction{}).
+		Select("id AS transaction_id, user_id, amount, created_at").
+		Where("kind = ? AND status = ? AND created_at < ?", domain.TransactionKindTransfer, domain.TransactionStatusPending, createdBefore).
+		Order("created_at").
+		Scan(&transfers).Error
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (r *ReconciliationRepo) CreateRun(ctx context.Context, run *domain.ReconciliationRun) error {
+	return conn(ctx, r.DB).Create(run).Error
+}
+
+func (r *ReconciliationRepo) GetRuns(ctx context.Context, limit, offset int) ([]*domain.ReconciliationRun, error) {
+	var runs []*domain.ReconciliationRun
+	err := conn(ctx, r.DB).Order("started_at DESC").Limit(limit).Offset(offset).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *ReconciliationRepo) GetRunByID(ctx context.Context, id uuid.UUID) (*domain.ReconciliationRun, error) {
+	var run domain.ReconciliationRun
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&run).Error
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *ReconciliationRepo) HasOpenRepairTask(ctx context.Context, kind string, subjectID uuid.UUID) (bool, error) {
+	var count int64
+	err := conn(ctx, r.DB).Model(&domain.RepairTask{}).
+		Where("kind = ? AND subject_id = ? AND status = ?", kind, subjectID, domain.RepairTaskStatusOpen).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *ReconciliationRepo) CreateRepairTask(ctx context.Context, task *domain.RepairTask) error {
+	return conn(ctx, r.DB).Create(task).Error
+}
+
+func (r *ReconciliationRepo) UpdateRepairTask(ctx context.Context, task *domain.RepairTask) error {
+	return conn(ctx, r.DB).Save(task).Error
+}
+
+func (r *ReconciliationRepo) GetRepairTaskByID(ctx context.Context, id uuid.UUID) (*domain.RepairTask, error) {
+	var task domain.RepairTask
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&task).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetRepairTasks returns tasks with status, or all tasks when status is empty, newest first.
+func (r *ReconciliationRepo) GetRepairTasks(ctx context.Context, status string, limit, offset int) ([]*domain.RepairTask, error) {
+	var tasks []*domain.RepairTask
+	query := conn(ctx, r.DB)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&tasks).Error
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type ScheduledTransferRepo struct {
+	DB *gorm.DB
+}
+
+func NewScheduledTransferRepo(db *gorm.DB) *ScheduledTransferRepo {
+	return &ScheduledTransferRepo{DB: db}
+}
+
+func (r *ScheduledTransferRepo) CreateSchedule(ctx context.Context, schedule *domain.ScheduledTransfer) error {
+	return conn(ctx, r.DB).Create(schedule).Error
+}
+
+func (r *ScheduledTransferRepo) GetScheduleByID(ctx context.Context, id uuid.UUID) (*domain.ScheduledTransfer, error) {
+	var schedule domain.ScheduledTransfer
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *ScheduledTransferRepo) GetSchedulesByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.ScheduledTransfer, error) {
+	var schedules []*domain.ScheduledTransfer
+	err := conn(ctx, r.DB).Where("user_id = ?", userID).Order("created_at DESC").Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *ScheduledTransferRepo) UpdateSchedule(ctx context.Context, schedule *domain.ScheduledTransfer) error {
+	return conn(ctx, r.DB).Save(schedule).Error
+}
+
+func (r *ScheduledTransferRepo) ClaimDueRuns(ctx context.Context, now time.Time, limit int) ([]*domain.ScheduledTransferRun, error) {
+	var runs []*domain.ScheduledTransferRun
+	err := conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		var schedules []*domain.ScheduledTransfer
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", domain.ScheduleStatusActive, now).
+			Order("next_run_at").
+			Limit(limit).
+			Find(&schedules).Error
+		if err != nil {
+			return err
+		}
+
+		for _, schedule := range schedules {
+			// the transfer is stored under an id known beforehand, so a run left behind can
+			// be told apart from one whose transfer was made.
+			transactionID := uuid.New()
+			run := &domain.ScheduledTransferRun{
+				ScheduleID:    schedule.ID,
+				ScheduledFor:  schedule.NextRunAt,
+				TransactionID: &transactionID,
+				Status:        domain.ScheduleRunStatusRunning,
+			}
+			// the occurrence may already have been recorded by a replica that crashed
+			// before advancing the schedule, in which case it must not run again.
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+			if res.Error != nil {
+				return res.Error
+			}
+
+			snapshot := *schedule
+			schedule.Advance(now)
+			schedule.UpdatedAt = now
+			if err = tx.Save(schedule).Error; err != nil {
+				return err
+			}
+
+			if res.RowsAffected == 0 {
+				continue
+			}
+			run.Schedule = &snapshot
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *ScheduledTransferRepo) ClaimStaleRuns(ctx context.Context, staleBefore, now time.Time, limit int) ([]*domain.ScheduledTransferRun, error) {
+	var runs []*domain.ScheduledTransferRun
+	err := conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		var stale []*domain.ScheduledTransferRun
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND updated_at < ?", domain.ScheduleRunStatusRunning, staleBefore).
+			Order("updated_at").
+			Limit(limit).
+			Find(&stale).Error
+		if err != nil {
+			return err
+		}
+
+		for _, run := range stale {
+			var schedule domain.ScheduledTransfer
+			if err = tx.Where("id = ?", run.ScheduleID).First(&schedule).Error; err != nil {
+				return err
+			}
+
+			switch {
+			case schedule.Status == domain.ScheduleStatusCancelled:
+				run.Status = domain.ScheduleRunStatusFailed
+				run.Error = "schedule was cancelled before the run was resumed"
+				run.TransactionID = nil
+			default:
+				var made int64
+				err = tx.Model(&domain.Transaction{}).Where("id = ?", *run.TransactionID).Count(&made).Error
+				if err != nil {
+					return err
+				}
+				if made > 0 {
+					run.Status = domain.ScheduleRunStatusSuccess
+				}
+			}
+			// the run is leased again, so no other replica resumes it meanwhile.
+			run.UpdatedAt = now
+			if err = tx.Omit("Schedule").Save(run).Error; err != nil {
+				return err
+			}
+
+			if run.Status != domain.ScheduleRunStatusRunning {
+				continue
+			}
+			run.Schedule = &schedule
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *ScheduledTransferRepo) UpdateRun(ctx context.Context, run *domain.ScheduledTransferRun) error {
+	return conn(ctx, r.DB).Omit("Schedule").Save(run).Error
+}
+
+func (r *ScheduledTransferRepo) GetRunsByScheduleID(ctx context.Context, scheduleID uuid.UUID) ([]*domain.ScheduledTransferRun, error) {
+	var runs []*domain.ScheduledTransferRun
+	err := conn(ctx, r.DB).Where("schedule_id = ?", scheduleID).Order("scheduled_for DESC").Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type StatementRepo struct {
+	DB *gorm.DB
+}
+
+func NewStatementRepo(db *gorm.DB) *StatementRepo {
+	return &StatementRepo{DB: db}
+}
+
+func (r *StatementRepo) GetBalanceAt(ctx context.Context, userID uuid.UUID, t time.Time) (int64, error) {
+	var balance int64
+	err := postedLines(conn(ctx, r.DB).Model(&domain.Transaction{})).
+		Select("COALESCE(SUM("+ledgerEffect+"), 0)::bigint", domain.TransactionTypeDebit).
+		Where("user_id = ? AND created_at < ?", userID, t).
+		Scan(&balance).Error
+	return balance, err
+}
+
+func (r *StatementRepo) StreamLines(ctx context.Context, userID uuid.UUID, from, to time.Time, fn func(*domain.Transaction) error) error {
+	db := conn(ctx, r.DB)
+	rows, err := postedLines(db.Model(&domain.Transaction{})).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, from, to).
+		Order("created_at, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line domain.Transaction
+		if err = db.ScanRows(rows, &line); err != nil {
+			return err
+		}
+		if err = fn(&line); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *StatementRepo) GetUnarchivedUserIDs(ctx context.Context, month string, from, to time.Time, limit int) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+	err := conn(ctx, r.DB).Model(&domain.Transaction{}).
+		Distinct("transactions.user_id").
+		Joins("JOIN users ON users.id = transactions.user_id").
+		Where("transactions.created_at >= ? AND transactions.created_at < ?", from, to).
+		Where("NOT EXISTS (SELECT 1 FROM statement_archives a WHERE a.user_id = transactions.user_id AND a.month = ?)", month).
+		Limit(limit).
+		Pluck("transactions.user_id", &userIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+func (r *StatementRepo) GetArchive(ctx context.Context, userID uuid.UUID, month string) (*domain.StatementArchive, error) {
+	var archive domain.StatementArchive
+	err := conn(ctx, r.DB).Where("user_id = ? AND month = ?", userID, month).First(&archive).Error
+	if err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+func (r *StatementRepo) CreateArchive(ctx context.Context, archive *domain.StatementArchive) error {
+	return conn(ctx, r.DB).Create(archive).Error
+}
@@ -2,9 +2,12 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"tahap2/internal/domain"
+	"time"
 )
 
 type TransactionRepo struct {
@@ -16,12 +19,12 @@ func NewTransactionRepo(db *gorm.DB) *TransactionRepo {
 }
 
 func (r *TransactionRepo) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
-	return r.DB.Create(transaction).Error
+	return conn(ctx, r.DB).Create(transaction).Error
 }
 
 func (r *TransactionRepo) GetTransactionsByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Transaction, error) {
 	var trans []*domain.Transaction
-	err := r.DB.Where("user_id = ?", userID).Find(&trans).Error
+	err := conn(ctx, r.DB).Where("user_id = ?", userID).Find(&trans).Error
 	if err != nil {
 		return nil, err
 	}
@@ -30,13 +33,64 @@ func (r *TransactionRepo) GetTransactionsByUserID(ctx context.Context, userID uu
 
 func (r *TransactionRepo) GetTransactionByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
 	var trans *domain.Transaction
-	err := r.DB.Where("id = ?", id).First(&trans).Error
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&trans).Error
 	if err != nil {
 		return nil, err
 	}
 	return trans, nil
 }
 
+// GetTransactionByIDForUpdate loads the transaction and locks its row until the surrounding
+// transaction ends.
+func (r *TransactionRepo) GetTransactionByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	var trans domain.Transaction
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&trans).Error
+	if err != nil {
+		return nil, err
+	}
+	return &trans, nil
+}
+
 func (r *TransactionRepo) UpdateTransaction(ctx context.Context, trans *domain.Transaction) error {
-	return r.DB.Save(trans).Error
+	return conn(ctx, r.DB).Save(trans).Error
+}
+
+func (r *TransactionRepo) SumTransactions(ctx context.Context, userID uuid.UUID, transactionType string, kinds []string, since time.Time) (domain.TransactionStats, error) {
+	var stats domain.TransactionStats
+	err := conn(ctx, r.DB).Model(&domain.Transaction{}).
+		Select("COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("user_id = ? AND transaction_type = ? AND created_at >= ?", userID, transactionType, since).
+		Where("kind IN ? AND status IN ?", kinds, []string{domain.TransactionStatusPending, domain.TransactionStatusSuccess}).
+		Scan(&stats).Error
+	return stats, err
+}
+
+func (r *TransactionRepo) SumChildTransactions(ctx context.Context, referenceID uuid.UUID, kind string) (int64, error) {
+	var total int64
+	err := conn(ctx, r.DB).Model(&domain.Transaction{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("reference_id = ? AND kind = ? AND status = ?", referenceID, kind, domain.TransactionStatusSuccess).
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *TransactionRepo) GetTransactionsByUserIDPaged(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Transaction, error) {
+	var trans []*domain.Transaction
+	err := conn(ctx, r.DB).Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&trans).Error
+	if err != nil {
+		return nil, err
+	}
+	return trans, nil
+}
+
+func (r *TransactionRepo) GetPendingTransferStats(ctx context.Context) (int64, time.Time, error) {
+	var stats struct {
+		Count  int64
+		Oldest sql.NullTime
+	}
+	err := conn(ctx, r.DB).Model(&domain.Transaction{}).
+		Select("COUNT(*) AS count, MIN(created_at) AS oldest").
+		Where("kind = ? AND status = ?", domain.TransactionKindTransfer, domain.TransactionStatusPending).
+		Scan(&stats).Error
+	return stats.Count, stats.Oldest.Time, err
 }
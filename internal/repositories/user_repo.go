@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"github.com/google/uuid"
+	"strings"
 	"tahap2/internal/domain"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepo struct {
@@ -18,24 +20,31 @@ func NewUserRepository(db *gorm.DB) *UserRepo {
 }
 
 func (r *UserRepo) CreateUser(ctx context.Context, user *domain.User) error {
-	return r.DB.Create(user).Error
+	return conn(ctx, r.DB).Create(user).Error
 }
 
-func (r *UserRepo) GetUserByEmail(email string) (*domain.User, error) {
+func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var user domain.User
-	err := r.DB.Where("email = ?", email).First(&user).Error
+	err := conn(ctx, r.DB).Where("email = ?", email).First(&user).Error
 	return &user, err
 }
 
-func (r *UserRepo) GetUserByID(userID uuid.UUID) (*domain.User, error) {
+func (r *UserRepo) GetUserByID(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
 	var user domain.User
-	err := r.DB.Where("id = ?", userID).First(&user).Error
+	err := conn(ctx, r.DB).Where("id = ?", userID).First(&user).Error
 	return &user, err
 }
 
-func (r *UserRepo) GetUserByPhoneNumber(phoneNumber string) (*domain.User, error) {
+// GetUserByIDForUpdate loads the user and locks its row until the surrounding transaction ends.
+func (r *UserRepo) GetUserByIDForUpdate(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
 	var user domain.User
-	err := r.DB.Where("phone_number = ?", phoneNumber).First(&user).Error
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", userID).First(&user).Error
+	return &user, err
+}
+
+func (r *UserRepo) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*domain.User, error) {
+	var user domain.User
+	err := conn(ctx, r.DB).Where("phone_number = ?", phoneNumber).First(&user).Error
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, err
 	}
@@ -43,5 +52,21 @@ func (r *UserRepo) GetUserByPhoneNumber(phoneNumber string) (*domain.User, error
 }
 
 func (r *UserRepo) UpdateUser(ctx context.Context, user *domain.User) error {
-	return r.DB.Save(user).Error
+	return conn(ctx, r.DB).Save(user).Error
+}
+
+func (r *UserRepo) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	var users []*domain.User
+	db := conn(ctx, r.DB)
+	if query != "" {
+		escaped := escapeLike(query)
+		like := "%" + strings.ToLower(escaped) + "%"
+		db = db.Where(`phone_number LIKE ? ESCAPE '\' OR LOWER(first_name) LIKE ? ESCAPE '\' OR LOWER(last_name) LIKE ? ESCAPE '\'`,
+			escaped+"%", like, like)
+	}
+	err := db.Order("created_at DESC").Limit(limit).Offset(offset).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
 }
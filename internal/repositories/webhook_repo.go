@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type WebhookRepo struct {
+	DB *gorm.DB
+}
+
+func NewWebhookRepo(db *gorm.DB) *WebhookRepo {
+	return &WebhookRepo{DB: db}
+}
+
+func (r *WebhookRepo) CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	return conn(ctx, r.DB).Create(endpoint).Error
+}
+
+func (r *WebhookRepo) GetEndpointByID(ctx context.Context, id uuid.UUID) (*domain.WebhookEndpoint, error) {
+	var endpoint domain.WebhookEndpoint
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&endpoint).Error
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *WebhookRepo) GetEndpoints(ctx context.Context, merchantID *uuid.UUID) ([]*domain.WebhookEndpoint, error) {
+	db := conn(ctx, r.DB)
+	if merchantID != nil {
+		db = db.Where("merchant_id = ?", *merchantID)
+	}
+
+	var endpoints []*domain.WebhookEndpoint
+	err := db.Order("created_at DESC").Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *WebhookRepo) GetEndpointsForEvent(ctx context.Context, merchantID *uuid.UUID) ([]*domain.WebhookEndpoint, error) {
+	db := conn(ctx, r.DB).Where("active")
+	if merchantID != nil {
+		db = db.Where("merchant_id IS NULL OR merchant_id = ?", *merchantID)
+	} else {
+		db = db.Where("merchant_id IS NULL")
+	}
+
+	var endpoints []*domain.WebhookEndpoint
+	err := db.Find(&endpoints).Error
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *WebhookRepo) UpdateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	return conn(ctx, r.DB).Save(endpoint).Error
+}
+
+func (r *WebhookRepo) CreateDeliveries(ctx context.Context, deliveries []*domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return conn(ctx, r.DB).Create(deliveries).Error
+}
+
+func (r *WebhookRepo) GetDeliveryByID(ctx context.Context, id uuid.UUID) (*domain.WebhookDelivery, error) {
+	var delivery domain.WebhookDelivery
+	err := conn(ctx, r.DB).Preload("AttemptLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempt")
+	}).Where("id = ?", id).First(&delivery).Error
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *WebhookRepo) GetDeliveriesByEndpoint(ctx context.Context, endpointID uuid.UUID, limit, offset int) ([]*domain.WebhookDelivery, error) {
+	var deliveries []*domain.WebhookDelivery
+	err := conn(ctx, r.DB).Preload("AttemptLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempt")
+	}).Where("endpoint_id = ?", endpointID).Order("created_at DESC").Limit(limit).Offset(offset).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*domain.WebhookDelivery, error) {
+	var deliveries []*domain.WebhookDelivery
+	err := conn(ctx, r.DB).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", domain.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&domain.WebhookDelivery{}).Where("id IN ?", ids).
+			Updates(map[string]any{"next_attempt_at": now.Add(lease), "updated_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *WebhookRepo) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	return conn(ctx, r.DB).Omit("AttemptLog").Save(delivery).Error
+}
+
+func (r *WebhookRepo) CreateAttempt(ctx context.Context, attempt *domain.WebhookAttempt) error {
+	return conn(ctx, r.DB).Create(attempt).Error
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type WithdrawalRepo struct {
+	DB *gorm.DB
+}
+
+func NewWithdrawalRepo(db *gorm.DB) *WithdrawalRepo {
+	return &WithdrawalRepo{DB: db}
+}
+
+func (r *WithdrawalRepo) CreateBankAccount(ctx context.Context, account *domain.BankAccount) error {
+	return conn(ctx, r.DB).Create(account).Error
+}
+
+func (r *WithdrawalRepo) GetBankAccountByID(ctx context.Context, id uuid.UUID) (*domain.BankAccount, error) {
+	var account domain.BankAccount
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&account).Error
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *WithdrawalRepo) GetBankAccountsByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.BankAccount, error) {
+	var accounts []*domain.BankAccount
+	err := conn(ctx, r.DB).Where("user_id = ?", userID).Order("created_at").Find(&accounts).Error
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// DeleteBankAccount removes the saved account, withdrawals keep their own copy of its details.
+func (r *WithdrawalRepo) DeleteBankAccount(ctx context.Context, id uuid.UUID) error {
+	return conn(ctx, r.DB).Where("id = ?", id).Delete(&domain.BankAccount{}).Error
+}
+
+func (r *WithdrawalRepo) CreateWithdrawal(ctx context.Context, withdrawal *domain.Withdrawal) error {
+	return conn(ctx, r.DB).Create(withdrawal).Error
+}
+
+func (r *WithdrawalRepo) UpdateWithdrawal(ctx context.Context, withdrawal *domain.Withdrawal) error {
+	return conn(ctx, r.DB).Save(withdrawal).Error
+}
+
+func (r *WithdrawalRepo) GetWithdrawalByID(ctx context.Context, id uuid.UUID) (*domain.Withdrawal, error) {
+	var withdrawal domain.Withdrawal
+	err := conn(ctx, r.DB).Where("id = ?", id).First(&withdrawal).Error
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+func (r *WithdrawalRepo) GetWithdrawalByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.Withdrawal, error) {
+	var withdrawal domain.Withdrawal
+	err := conn(ctx, r.DB).Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&withdrawal).Error
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+func (r *WithdrawalRepo) GetWithdrawalsByUserID(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Withdrawal, error) {
+	var withdrawals []*domain.Withdrawal
+	err := conn(ctx, r.DB).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&withdrawals).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
+
+func (r *WithdrawalRepo) GetPendingWithdrawals(ctx context.Context, staleBefore time.Time, limit int) ([]*domain.Withdrawal, error) {
+	var withdrawals []*domain.Withdrawal
+	err := conn(ctx, r.DB).
+		Where("status = ? OR (status = ? AND COALESCE(provider_ref, '') = '' AND updated_at < ?)",
+			domain.WithdrawalStatusPending, domain.WithdrawalStatusProcessing, staleBefore).
+		Order("created_at").
+		Limit(limit).
+		Find(&withdrawals).Error
+	if err != nil {
+		return nil, err
+	}
+	return withdrawals, nil
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type AdminService struct {
+	transactor        domain.Transactor
+	userRepo          domain.UserRepository
+	transactionRepo   domain.TransactionRepository
+	systemAccountRepo domain.SystemAccountRepository
+	limitService      domain.LimitService
+	auditService      domain.AuditService
+}
+
+func NewAdminService(transactor domain.Transactor, userRepo domain.UserRepository, transactionRepo domain.TransactionRepository,
+	systemAccountRepo domain.SystemAccountRepository, limitService domain.LimitService, auditService domain.AuditService) *AdminService {
+	return &AdminService{
+		transactor:        transactor,
+		userRepo:          userRepo,
+		transactionRepo:   transactionRepo,
+		systemAccountRepo: systemAccountRepo,
+		limitService:      limitService,
+		auditService:      auditService,
+	}
+}
+
+func (s *AdminService) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*domain.User, error) {
+	// phone numbers are stored in E.164, a number typed as 0812… searches +62812….
+	return s.userRepo.SearchUsers(ctx, domain.NormalizePhoneNumber(strings.TrimSpace(query)), limit, offset)
+}
+
+func (s *AdminService) GetUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AdminService) FreezeUser(ctx context.Context, actorID, userID uuid.UUID) (domain.User, error) {
+	return s.updateUser(ctx, actorID, domain.AuditUserFrozen, userID, nil, func(ctx context.Context, user *domain.User) error {
+		if user.IsClosed() {
+			return domain.ErrAccountClosed
+		}
+		user.Status = domain.UserStatusFrozen
+		return nil
+	})
+}
+
+func (s *AdminService) UnfreezeUser(ctx context.Context, actorID, userID uuid.UUID) (domain.User, error) {
+	return s.updateUser(ctx, actorID, domain.AuditUserUnfrozen, userID, nil, func(ctx context.Context, user *domain.User) error {
+		if user.IsClosed() {
+			return domain.ErrAccountClosed
+		}
+		user.Status = domain.UserStatusActive
+		return nil
+	})
+}
+
+func (s *AdminService) CloseUser(ctx context.Context, actorID, userID uuid.UUID, reason string, payout bool) (domain.User, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return domain.User{}, domain.ErrReasonRequired
+	}
+
+	note := map[string]any{"reason": reason, "payout": payout}
+	return s.updateUser(ctx, actorID, domain.AuditUserClosed, userID, note, func(ctx context.Context, user *domain.User) error {
+		if user.IsClosed() {
+			return domain.ErrAccountClosed
+		}
+		if user.Balance != 0 {
+			if !payout {
+				return domain.ErrBalanceNotZero
+			}
+			if err := s.payOutBalance(ctx, user, reason); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		user.Status = domain.UserStatusClosed
+		user.ClosedAt = &now
+		return nil
+	})
+}
+
+// payOutBalance moves the whole balance of user to the closure payout account, from which
+// operations settle it outside of the wallet.
+func (s *AdminService) payOutBalance(ctx context.Context, user *domain.User, reason string) error {
+	amount := user.Balance
+	payoutAccount, err := s.systemAccountRepo.CreditSystemAccount(ctx, domain.SystemAccountClosurePayout, amount)
+	if err != nil {
+		return err
+	}
+
+	userLine := domain.Transaction{
+		Status:          domain.TransactionStatusSuccess,
+		UserID:          user.ID,
+		TransactionType: domain.TransactionTypeCredit,
+		Kind:            domain.TransactionKindPayout,
+		Amount:          amount,
+		Remark:          "account closure payout: " + reason,
+		BalanceBefore:   amount,
+		BalanceAfter:    0,
+	}
+	if err = s.transactionRepo.CreateTransaction(ctx, &userLine); err != nil {
+		return err
+	}
+	payoutLine := domain.Transaction{
+		Status:          domain.TransactionStatusSuccess,
+		UserID:          payoutAccount.ID,
+		TransactionType: domain.TransactionTypeDebit,
+		Kind:            domain.TransactionKindPayout,
+		Amount:          amount,
+		Remark:          "account closure payout for " + user.ID.String(),
+		BalanceBefore:   payoutAccount.Balance - amount,
+		BalanceAfter:    payoutAccount.Balance,
+		ReferenceID:     &userLine.ID,
+	}
+	if err = s.transactionRepo.CreateTransaction(ctx, &payoutLine); err != nil {
+		return err
+	}
+
+	user.Balance = 0
+	return nil
+}
+
+func (s *AdminService) SetUserRole(ctx context.Context, actorID, userID uuid.UUID, role string) (domain.User, error) {
+	if !domain.IsValidRole(role) {
+		return domain.User{}, domain.ErrInvalidRole
+	}
+	// keeps the last admin from locking everyone out by demoting themselves.
+	if actorID == userID {
+		return domain.User{}, domain.ErrSelfRoleChange
+	}
+
+	return s.updateUser(ctx, actorID, domain.AuditRoleChanged, userID, nil, func(ctx context.Context, user *domain.User) error {
+		user.Role = role
+		return nil
+	})
+}
+
+func (s *AdminService) GetTransaction(ctx context.Context, transactionID uuid.UUID) (*domain.Transaction, error) {
+	trans, err := s.transactionRepo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrTransactionNotFound
+		}
+		return nil, err
+	}
+	return trans, nil
+}
+
+func (s *AdminService) GetUserTransactions(ctx context.Context, userID uuid.UUID) ([]*domain.Transaction, error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.transactionRepo.GetTransactionsByUserID(ctx, userID)
+}
+
+// AdjustBalance credits or debits a wallet outside of the normal flows, e.g. to correct a
+// failed reconciliation. The reason is stored as the transaction remark. A credit taking the
+// wallet over the maximum balance of its tier is refused unless the adjustment overrides
+// the limit, which is then recorded in the audit log.
+func (s *AdminService) AdjustBalance(ctx context.Context, adjustment domain.BalanceAdjustment) (domain.Transaction, error) {
+	reason := strings.TrimSpace(adjustment.Reason)
+	if reason == "" {
+		return domain.Transaction{}, domain.ErrReasonRequired
+	}
+	if adjustment.Amount == 0 {
+		return domain.Transaction{}, domain.ErrInvalidAdjustment
+	}
+
+	var (
+		newTransaction domain.Transaction
+		overridden     *domain.LimitExceededError
+	)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, adjustment.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if adjustment.Amount > 0 {
+			err = s.limitService.CheckIncoming(ctx, user, adjustment.Amount)
+			if !errors.As(err, &overridden) {
+				if err != nil {
+					return err
+				}
+			} else if !adjustment.OverrideLimit {
+				return err
+			}
+		}
+
+		balBefore := user.Balance
+		user.Balance, err = domain.AddAmounts(user.Balance, adjustment.Amount)
+		if err != nil {
+			return err
+		}
+		if user.Balance < 0 {
+			return domain.ErrInsufficientBalance
+		}
+		user.UpdatedAt = time.Now()
+		if err = s.userRepo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+
+		transactionType, amount := domain.TransactionTypeDebit, adjustment.Amount
+		if adjustment.Amount < 0 {
+			transactionType, amount = domain.TransactionTypeCredit, -adjustment.Amount
+		}
+		newTransaction = domain.Transaction{
+			Status:          domain.TransactionStatusSuccess,
+			UserID:          user.ID,
+			TransactionType: transactionType,
+			Kind:            domain.TransactionKindAdjustment,
+			Amount:          amount,
+			Remark:          reason,
+			BalanceBefore:   balBefore,
+			BalanceAfter:    user.Balance,
+		}
+		return s.transactionRepo.CreateTransaction(ctx, &newTransaction)
+	})
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	after := map[string]any{
+		"balance":        newTransaction.BalanceAfter,
+		"transaction_id": newTransaction.ID.String(),
+		"reason":         reason,
+	}
+	if overridden != nil {
+		after["overridden_limit"] = overridden.Limit
+		after["limit_max"] = overridden.Max
+	}
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditBalanceAdjusted,
+		ActorID:     adjustment.ActorID,
+		SubjectType: "user",
+		SubjectID:   adjustment.UserID.String(),
+		Before:      map[string]any{"balance": newTransaction.BalanceBefore},
+		After:       after,
+	})
+	return newTransaction, nil
+}
+
+func (s *AdminService) EnsureAdmin(ctx context.Context, phoneNumber string) error {
+	user, err := s.userRepo.GetUserByPhoneNumber(ctx, domain.NormalizePhoneNumber(phoneNumber))
+	if err != nil {
+		return err
+	}
+	if user.ID == uuid.Nil {
+		return domain.ErrUserNotFound
+	}
+	if user.Role == domain.RoleAdmin {
+		return nil
+	}
+
+	_, err = s.updateUser(ctx, uuid.Nil, domain.AuditRoleChanged, user.ID, map[string]any{"reason": "bootstrap admin"}, func(ctx context.Context, user *domain.User) error {
+		user.Role = domain.RoleAdmin
+		return nil
+	})
+	return err
+}
+
+// updateUser applies change to the locked user row, saves it and audits the fields that
+// changed as eventType, with note added to the after state.
+func (s *AdminService) updateUser(ctx context.Context, actorID uuid.UUID, eventType string, userID uuid.UUID, note map[string]any,
+	change func(ctx context.Context, user *domain.User) error) (domain.User, error) {
+	var (
+		user   *domain.User
+		before map[string]any
+	)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+
+		before = userSnapshot(user)
+		if err = change(ctx, user); err != nil {
+			return err
+		}
+		user.UpdatedAt = time.Now()
+		return s.userRepo.UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	changedBefore, changedAfter := diffSnapshots(before, userSnapshot(user))
+	for key, value := range note {
+		changedAfter[key] = value
+	}
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   eventType,
+		ActorID:     actorID,
+		SubjectType: "user",
+		SubjectID:   userID.String(),
+		Before:      changedBefore,
+		After:       changedAfter,
+	})
+	return *user, nil
+}
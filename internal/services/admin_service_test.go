@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"tahap2/internal/domain"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAdjustBalanceOverMaxBalance(t *testing.T) {
+	store := newMemStore()
+	user := domain.User{ID: uuid.New(), Tier: domain.UserTierUnverified, Balance: 1_500_000, Status: domain.UserStatusActive}
+	store.users[user.ID] = user
+	userRepo, transactionRepo := &memUserRepo{store: store}, &memTransactionRepo{store: store}
+	limits := NewLimitService(defaultLimits{}, userRepo, transactionRepo)
+	service := NewAdminService(&memTransactor{}, userRepo, transactionRepo, &memSystemAccountRepo{store: store}, limits, &memAuditService{store: store})
+
+	adjustment := domain.BalanceAdjustment{UserID: user.ID, ActorID: uuid.New(), Amount: 1_000_000, Reason: "refund of a failed topup"}
+	if _, err := service.AdjustBalance(context.Background(), adjustment); !errors.Is(err, domain.ErrLimitExceeded) {
+		t.Fatalf("err = %v, want the maximum balance exceeded", err)
+	}
+	if len(store.audit) != 0 || store.users[user.ID].Balance != 1_500_000 {
+		t.Fatalf("refused adjustment changed something: audit %+v, balance %d", store.audit, store.users[user.ID].Balance)
+	}
+
+	adjustment.OverrideLimit = true
+	if _, err := service.AdjustBalance(context.Background(), adjustment); err != nil {
+		t.Fatal(err)
+	}
+	if balance := store.users[user.ID].Balance; balance != 2_500_000 {
+		t.Fatalf("balance = %d", balance)
+	}
+	if len(store.audit) != 1 || store.audit[0].After["overridden_limit"] != domain.LimitMaxBalance {
+		t.Fatalf("audit = %+v", store.audit)
+	}
+}
+
+func TestCloseUserWithBalance(t *testing.T) {
+	store := newMemStore()
+	pin, err := hashPin("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := domain.User{ID: uuid.New(), PhoneNumber: "+6281234567890", Pin: pin, Tier: domain.UserTierBasic, Balance: 75_000, Status: domain.UserStatusActive}
+	store.users[user.ID] = user
+	userRepo, transactionRepo := &memUserRepo{store: store}, &memTransactionRepo{store: store}
+	service := NewAdminService(&memTransactor{}, userRepo, transactionRepo, &memSystemAccountRepo{store: store},
+		NewLimitService(defaultLimits{}, userRepo, transactionRepo), &memAuditService{store: store})
+	ctx, actor := context.Background(), uuid.New()
+
+	if _, err = service.CloseUser(ctx, actor, user.ID, "customer request", false); !errors.Is(err, domain.ErrBalanceNotZero) {
+		t.Fatalf("closing without payout: err = %v, want ErrBalanceNotZero", err)
+	}
+	if got := store.users[user.ID]; got.IsClosed() || got.Balance != 75_000 || len(store.transactions) != 0 || len(store.audit) != 0 {
+		t.Fatalf("refused closure changed something: user %+v, %d lines, audit %+v", got, len(store.transactions), store.audit)
+	}
+
+	closed, err := service.CloseUser(ctx, actor, user.ID, "customer request", true)
+	if err != nil {
+		t.Fatalf("closing with payout: %v", err)
+	}
+	if !closed.IsClosed() || closed.ClosedAt == nil || closed.Balance != 0 {
+		t.Fatalf("closed user = %+v", closed)
+	}
+	if payout := store.systemAccounts[domain.SystemAccountClosurePayout]; payout.Balance != 75_000 {
+		t.Errorf("payout account holds %d, want 75000", payout.Balance)
+	}
+	var paidOut int64
+	for _, line := range store.transactions {
+		if line.Kind != domain.TransactionKindPayout {
+			t.Errorf("unexpected line %+v", line)
+		}
+		if line.UserID == user.ID {
+			paidOut += line.Amount
+			if line.TransactionType != domain.TransactionTypeCredit || line.BalanceBefore != 75_000 || line.BalanceAfter != 0 {
+				t.Errorf("user's payout line = %+v", line)
+			}
+		}
+	}
+	if paidOut != 75_000 || len(store.transactions) != 2 {
+		t.Errorf("%d lines paying out %d, want 2 lines paying out 75000", len(store.transactions), paidOut)
+	}
+	if len(store.audit) != 1 || store.audit[0].EventType != domain.AuditUserClosed {
+		t.Errorf("audit = %+v", store.audit)
+	}
+
+	if _, err = service.CloseUser(ctx, actor, user.ID, "again", true); !errors.Is(err, domain.ErrAccountClosed) {
+		t.Errorf("closing twice: err = %v, want ErrAccountClosed", err)
+	}
+	if _, err = service.UnfreezeUser(ctx, actor, user.ID); !errors.Is(err, domain.ErrAccountClosed) {
+		t.Errorf("reopening: err = %v, want ErrAccountClosed", err)
+	}
+}
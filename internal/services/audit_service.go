@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"log/slog"
+	"tahap2/internal/domain"
+)
+
+type AuditService struct {
+	auditRepo domain.AuditRepository
+	logger    *slog.Logger
+}
+
+func NewAuditService(auditRepo domain.AuditRepository, logger *slog.Logger) *AuditService {
+	return &AuditService{auditRepo: auditRepo, logger: logger}
+}
+
+func (s *AuditService) Record(ctx context.Context, entry domain.AuditEntry) {
+	meta := domain.RequestMetaFrom(ctx)
+	auditLog := domain.AuditLog{
+		EventType:   entry.EventType,
+		SubjectType: entry.SubjectType,
+		SubjectID:   entry.SubjectID,
+		IPAddress:   meta.IPAddress,
+		UserAgent:   meta.UserAgent,
+		RequestID:   meta.RequestID,
+		Before:      entry.Before,
+		After:       entry.After,
+	}
+	if entry.ActorID != uuid.Nil {
+		auditLog.ActorID = &entry.ActorID
+	}
+
+	// the entry is written on its own rather than inside the caller's transaction, so the
+	// chain lock is never held while money is being moved.
+	if err := s.auditRepo.AppendAuditLog(context.WithoutCancel(ctx), &auditLog); err != nil {
+		s.logger.ErrorContext(ctx, "failed to record audit event", "event_type", entry.EventType, "subject_id", entry.SubjectID, "error", err)
+	}
+}
+
+func (s *AuditService) GetAuditLogs(ctx context.Context, filter domain.AuditFilter) ([]*domain.AuditLog, error) {
+	return s.auditRepo.GetAuditLogs(ctx, filter)
+}
+
+// VerifyChain recomputes every hash and reports the first entry that doesn't match its
+// stored hash or doesn't point at its predecessor.
+func (s *AuditService) VerifyChain(ctx context.Context) (domain.AuditChainReport, error) {
+	report := domain.AuditChainReport{Valid: true}
+	var prevHash string
+	var prevSequence int64
+
+	errBroken := errors.New("audit chain broken")
+	err := s.auditRepo.WalkAuditLogs(ctx, func(entry *domain.AuditLog) error {
+		report.EntriesChecked++
+		if entry.Sequence != prevSequence+1 || entry.PrevHash != prevHash || entry.ComputeHash() != entry.Hash {
+			report.Valid = false
+			report.BrokenAt = &entry.Sequence
+			return errBroken
+		}
+		prevHash, prevSequence = entry.Hash, entry.Sequence
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBroken) {
+		return domain.AuditChainReport{}, err
+	}
+
+	return report, nil
+}
+
+// userSnapshot is the part of a user that audit entries diff.
+func userSnapshot(user *domain.User) map[string]any {
+	return map[string]any{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"address":    user.Address,
+		"balance":    user.Balance,
+		"tier":       user.Tier,
+		"role":       user.Role,
+		"status":     user.Status,
+		"language":   user.Language,
+	}
+}
+
+// diffSnapshots keeps only the fields that changed between before and after.
+func diffSnapshots(before, after map[string]any) (map[string]any, map[string]any) {
+	changedBefore, changedAfter := map[string]any{}, map[string]any{}
+	for key, value := range after {
+		if before[key] != value {
+			changedBefore[key] = before[key]
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
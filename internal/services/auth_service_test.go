@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"tahap2/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLoginRecordsUnknownPhoneNumbers(t *testing.T) {
+	store := newMemStore()
+	service := NewAuthService(&memTransactor{}, &memUserRepo{store: store}, &memAuditService{store: store})
+
+	_, err := service.Login(context.Background(), "081234567890", "123456")
+	if !errors.Is(err, domain.ErrPhoneNumberNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(store.audit) != 1 {
+		t.Fatalf("%d audit entries", len(store.audit))
+	}
+	entry := store.audit[0]
+	if entry.EventType != domain.AuditLoginFailed || entry.SubjectType != "phone_number" || entry.SubjectID != "+6281234567890" {
+		t.Fatalf("entry = %+v", entry)
+	}
+}
+
+func TestLoginRecordsPinMismatches(t *testing.T) {
+	store := newMemStore()
+	pin, err := hashPin("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := domain.User{ID: uuid.New(), PhoneNumber: "+6281234567890", Pin: pin, Status: domain.UserStatusActive}
+	store.users[user.ID] = user
+	service := NewAuthService(&memTransactor{}, &memUserRepo{store: store}, &memAuditService{store: store})
+
+	if _, err = service.Login(context.Background(), "081234567890", "654321"); !errors.Is(err, domain.ErrPinMismatch) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(store.audit) != 1 || store.audit[0].SubjectID != user.ID.String() {
+		t.Fatalf("audit = %+v", store.audit)
+	}
+
+	if _, err = service.Login(context.Background(), "081234567890", "123456"); err != nil {
+		t.Fatalf("login with the local number form: %v", err)
+	}
+}
+
+func TestLoginAfterClosure(t *testing.T) {
+	store := newMemStore()
+	pin, err := hashPin("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAt := time.Now()
+	user := domain.User{ID: uuid.New(), PhoneNumber: "+6281234567890", Pin: pin, Status: domain.UserStatusClosed, ClosedAt: &closedAt}
+	store.users[user.ID] = user
+	service := NewAuthService(&memTransactor{}, &memUserRepo{store: store}, &memAuditService{store: store})
+
+	if _, err = service.Login(context.Background(), "081234567890", "123456"); !errors.Is(err, domain.ErrAccountClosed) {
+		t.Fatalf("err = %v, want ErrAccountClosed", err)
+	}
+	if len(store.audit) != 1 || store.audit[0].EventType != domain.AuditLoginFailed || store.audit[0].After["reason"] != "account closed" {
+		t.Fatalf("audit = %+v", store.audit)
+	}
+	// the pin is checked first, a wrong one doesn't tell the account is closed.
+	if _, err = service.Login(context.Background(), "081234567890", "654321"); !errors.Is(err, domain.ErrPinMismatch) {
+		t.Errorf("wrong pin: err = %v, want ErrPinMismatch", err)
+	}
+}
@@ -5,25 +5,30 @@ import (
 	"errors"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"regexp"
 	"tahap2/internal/domain"
 	"time"
 )
 
 type AuthService struct {
-	userRepo domain.UserRepository
+	transactor   domain.Transactor
+	userRepo     domain.UserRepository
+	auditService domain.AuditService
 }
 
-func NewAuthService(userRepo domain.UserRepository) *AuthService {
-	return &AuthService{userRepo: userRepo}
+func NewAuthService(transactor domain.Transactor, userRepo domain.UserRepository, auditService domain.AuditService) *AuthService {
+	return &AuthService{transactor: transactor, userRepo: userRepo, auditService: auditService}
 }
 
 func (s *AuthService) Register(ctx context.Context, user domain.User) (domain.User, error) {
-	existUser, err := s.userRepo.GetUserByPhoneNumber(user.PhoneNumber)
+	user.PhoneNumber = domain.NormalizePhoneNumber(user.PhoneNumber)
+	existUser, err := s.userRepo.GetUserByPhoneNumber(ctx, user.PhoneNumber)
 	if err != nil {
 		return domain.User{}, err
 	}
 	if existUser.PhoneNumber != "" {
-		return domain.User{}, errors.New("phone number already registered")
+		return domain.User{}, domain.ErrPhoneNumberRegistered
 	}
 	hashedPin, err := hashPin(user.Pin)
 	if err != nil {
@@ -35,24 +40,59 @@ func (s *AuthService) Register(ctx context.Context, user domain.User) (domain.Us
 	return user, err
 }
 
-func (s *AuthService) Login(ctx context.Context, phoneNumber, pin string) (string, error) {
-	user, err := s.userRepo.GetUserByPhoneNumber(phoneNumber)
+func (s *AuthService) Login(ctx context.Context, phoneNumber, pin string) (domain.User, error) {
+	phoneNumber = domain.NormalizePhoneNumber(phoneNumber)
+	user, err := s.userRepo.GetUserByPhoneNumber(ctx, phoneNumber)
 	if err != nil {
-		return "", errors.New("phone number not found")
+		return domain.User{}, err
+	}
+	if user.ID == uuid.Nil {
+		s.recordFailedLogin(ctx, user, phoneNumber, "unknown phone number")
+		return domain.User{}, domain.ErrPhoneNumberNotFound
 	}
 	if err = checkPin(user.Pin, pin); err != nil {
-		return "", errors.New("Phone number and PIN doesn't match")
+		s.recordFailedLogin(ctx, user, phoneNumber, "pin mismatch")
+		return domain.User{}, domain.ErrPinMismatch
+	}
+	if user.IsClosed() {
+		s.recordFailedLogin(ctx, user, phoneNumber, "account closed")
+		return domain.User{}, domain.ErrAccountClosed
 	}
 
-	return user.ID.String(), nil
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditLogin,
+		ActorID:     user.ID,
+		SubjectType: "user",
+		SubjectID:   user.ID.String(),
+	})
+	return *user, nil
+}
+
+// recordFailedLogin audits a rejected login. Unknown phone numbers are recorded too, as
+// they are what credential stuffing looks like.
+func (s *AuthService) recordFailedLogin(ctx context.Context, user *domain.User, phoneNumber, reason string) {
+	entry := domain.AuditEntry{
+		EventType:   domain.AuditLoginFailed,
+		SubjectType: "phone_number",
+		SubjectID:   phoneNumber,
+		After:       map[string]any{"reason": reason},
+	}
+	if user.ID != uuid.Nil {
+		entry.SubjectType, entry.SubjectID = "user", user.ID.String()
+	}
+	s.auditService.Record(ctx, entry)
 }
 
 func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, firstname, lastname, address string) (domain.User, error) {
-	user, err := s.userRepo.GetUserByID(userID)
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return domain.User{}, err
 	}
+	if user.IsClosed() {
+		return domain.User{}, domain.ErrAccountClosed
+	}
 
+	before := userSnapshot(user)
 	user.FirstName = firstname
 	user.LastName = lastname
 	user.Address = address
@@ -62,9 +102,132 @@ func (s *AuthService) UpdateProfile(ctx context.Context, userID uuid.UUID, first
 		return domain.User{}, err
 	}
 
+	changedBefore, changedAfter := diffSnapshots(before, userSnapshot(user))
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditProfileUpdated,
+		ActorID:     userID,
+		SubjectType: "user",
+		SubjectID:   userID.String(),
+		Before:      changedBefore,
+		After:       changedAfter,
+	})
+	return *user, nil
+}
+
+func (s *AuthService) SetLanguage(ctx context.Context, userID uuid.UUID, language string) (domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if user.IsClosed() {
+		return domain.User{}, domain.ErrAccountClosed
+	}
+
+	before := userSnapshot(user)
+	user.Language = language
+	user.UpdatedAt = time.Now()
+	err = s.userRepo.UpdateUser(ctx, user)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	changedBefore, changedAfter := diffSnapshots(before, userSnapshot(user))
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditProfileUpdated,
+		ActorID:     userID,
+		SubjectType: "user",
+		SubjectID:   userID.String(),
+		Before:      changedBefore,
+		After:       changedAfter,
+	})
 	return *user, nil
 }
 
+func (s *AuthService) ChangePin(ctx context.Context, userID uuid.UUID, oldPin, newPin string) error {
+	if !pinRegex.MatchString(newPin) {
+		return domain.ErrInvalidPinFormat
+	}
+
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if user.IsClosed() {
+			return domain.ErrAccountClosed
+		}
+		if err = checkPin(user.Pin, oldPin); err != nil {
+			return domain.ErrInvalidPin
+		}
+
+		user.Pin, err = hashPin(newPin)
+		if err != nil {
+			return errors.New("failed to hash pin")
+		}
+		user.UpdatedAt = time.Now()
+		return s.userRepo.UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	// the hashes themselves are never written to the audit log.
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditPinChanged,
+		ActorID:     userID,
+		SubjectType: "user",
+		SubjectID:   userID.String(),
+	})
+	return nil
+}
+
+func (s *AuthService) CloseAccount(ctx context.Context, userID uuid.UUID, pin string) error {
+	var prevStatus string
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if err = checkPin(user.Pin, pin); err != nil {
+			return domain.ErrInvalidPin
+		}
+		if user.IsClosed() {
+			return domain.ErrAccountClosed
+		}
+		if user.Balance != 0 {
+			return domain.ErrBalanceNotZero
+		}
+
+		now := time.Now()
+		prevStatus = user.Status
+		user.Status = domain.UserStatusClosed
+		user.ClosedAt = &now
+		user.UpdatedAt = now
+		return s.userRepo.UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditAccountClosed,
+		ActorID:     userID,
+		SubjectType: "user",
+		SubjectID:   userID.String(),
+		Before:      map[string]any{"status": prevStatus},
+		After:       map[string]any{"status": domain.UserStatusClosed},
+	})
+	return nil
+}
+
+var pinRegex = regexp.MustCompile(`^\d{6}$`)
+
 type RegisterParam struct {
 	FirstName   string
 	LastName    string
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"tahap2/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memStore keeps users, ledger lines, withdrawals and system accounts in memory for the
+// service tests. The fake repositories embed their domain interface, so a method a test
+// doesn't expect panics instead of quietly doing nothing. Rows are copied in and out, like
+// a database would.
+type memStore struct {
+	mu             sync.Mutex
+	users          map[uuid.UUID]domain.User
+	transactions   map[uuid.UUID]domain.Transaction
+	withdrawals    map[uuid.UUID]domain.Withdrawal
+	bankAccounts   map[uuid.UUID]domain.BankAccount
+	intents        map[uuid.UUID]domain.PaymentIntent
+	merchants      map[uuid.UUID]domain.Merchant
+	systemAccounts map[string]domain.SystemAccount
+	audit          []domain.AuditEntry
+}
+
+func newMemStore() *memStore {
+	return &memStore{
+		users:          map[uuid.UUID]domain.User{},
+		transactions:   map[uuid.UUID]domain.Transaction{},
+		withdrawals:    map[uuid.UUID]domain.Withdrawal{},
+		bankAccounts:   map[uuid.UUID]domain.BankAccount{},
+		intents:        map[uuid.UUID]domain.PaymentIntent{},
+		merchants:      map[uuid.UUID]domain.Merchant{},
+		systemAccounts: map[string]domain.SystemAccount{},
+	}
+}
+
+// memTransactor runs one transaction at a time, which serialises them the way row locks
+// would. A transaction started within another joins it, like the gorm transactor.
+type memTransactor struct {
+	mu sync.Mutex
+}
+
+type inMemTransaction struct{}
+
+func (t *memTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(inMemTransaction{}) != nil {
+		return fn(ctx)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fn(context.WithValue(ctx, inMemTransaction{}, true))
+}
+
+type memUserRepo struct {
+	domain.UserRepository
+	store *memStore
+}
+
+func (r *memUserRepo) GetUserByID(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	user, ok := r.store.users[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// GetUserByPhoneNumber returns an empty user for unknown numbers, like UserRepo.
+func (r *memUserRepo) GetUserByPhoneNumber(ctx context.Context, phoneNumber string) (*domain.User, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, user := range r.store.users {
+		if user.PhoneNumber == phoneNumber {
+			return &user, nil
+		}
+	}
+	return &domain.User{}, nil
+}
+
+func (r *memUserRepo) GetUserByIDForUpdate(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	return r.GetUserByID(ctx, userID)
+}
+
+func (r *memUserRepo) UpdateUser(ctx context.Context, user *domain.User) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.users[user.ID] = *user
+	return nil
+}
+
+type memTransactionRepo struct {
+	domain.TransactionRepository
+	store *memStore
+}
+
+func (r *memTransactionRepo) GetTransactionByID(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	transaction, ok := r.store.transactions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &transaction, nil
+}
+
+func (r *memTransactionRepo) GetTransactionByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.Transaction, error) {
+	return r.GetTransactionByID(ctx, id)
+}
+
+func (r *memTransactionRepo) CreateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if transaction.ID == uuid.Nil {
+		transaction.ID = uuid.New()
+	}
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	r.store.transactions[transaction.ID] = *transaction
+	return nil
+}
+
+func (r *memTransactionRepo) SumTransactions(ctx context.Context, userID uuid.UUID, transactionType string, kinds []string, since time.Time) (domain.TransactionStats, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var stats domain.TransactionStats
+	for _, transaction := range r.store.transactions {
+		counted := transaction.Status == domain.TransactionStatusPending || transaction.Status == domain.TransactionStatusSuccess
+		if transaction.UserID == userID && transaction.TransactionType == transactionType && counted &&
+			slices.Contains(kinds, transaction.Kind) && !transaction.CreatedAt.Before(since) {
+			stats.Total += transaction.Amount
+			stats.Count++
+		}
+	}
+	return stats, nil
+}
+
+func (r *memTransactionRepo) GetTransactionsByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Transaction, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var transactions []*domain.Transaction
+	for _, transaction := range r.store.transactions {
+		if transaction.UserID == userID {
+			transactions = append(transactions, &transaction)
+		}
+	}
+	return transactions, nil
+}
+
+func (r *memTransactionRepo) SumChildTransactions(ctx context.Context, referenceID uuid.UUID, kind string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var total int64
+	for _, transaction := range r.store.transactions {
+		if transaction.ReferenceID != nil && *transaction.ReferenceID == referenceID && transaction.Kind == kind &&
+			transaction.Status == domain.TransactionStatusSuccess {
+			total += transaction.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *memTransactionRepo) UpdateTransaction(ctx context.Context, transaction *domain.Transaction) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.transactions[transaction.ID] = *transaction
+	return nil
+}
+
+type memWithdrawalRepo struct {
+	domain.WithdrawalRepository
+	store *memStore
+}
+
+func (r *memWithdrawalRepo) GetBankAccountByID(ctx context.Context, id uuid.UUID) (*domain.BankAccount, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	account, ok := r.store.bankAccounts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &account, nil
+}
+
+func (r *memWithdrawalRepo) CreateWithdrawal(ctx context.Context, withdrawal *domain.Withdrawal) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if withdrawal.ID == uuid.Nil {
+		withdrawal.ID = uuid.New()
+	}
+	r.store.withdrawals[withdrawal.ID] = *withdrawal
+	return nil
+}
+
+func (r *memWithdrawalRepo) UpdateWithdrawal(ctx context.Context, withdrawal *domain.Withdrawal) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.withdrawals[withdrawal.ID] = *withdrawal
+	return nil
+}
+
+func (r *memWithdrawalRepo) GetWithdrawalByID(ctx context.Context, id uuid.UUID) (*domain.Withdrawal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	withdrawal, ok := r.store.withdrawals[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &withdrawal, nil
+}
+
+func (r *memWithdrawalRepo) GetWithdrawalByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.Withdrawal, error) {
+	return r.GetWithdrawalByID(ctx, id)
+}
+
+func (r *memWithdrawalRepo) GetPendingWithdrawals(ctx context.Context, staleBefore time.Time, limit int) ([]*domain.Withdrawal, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	var withdrawals []*domain.Withdrawal
+	for _, withdrawal := range r.store.withdrawals {
+		if withdrawal.IsSubmittable(staleBefore) && len(withdrawals) < limit {
+			withdrawals = append(withdrawals, &withdrawal)
+		}
+	}
+	return withdrawals, nil
+}
+
+type memPaymentIntentRepo struct {
+	domain.PaymentIntentRepository
+	store *memStore
+}
+
+func (r *memPaymentIntentRepo) CreateIntent(ctx context.Context, intent *domain.PaymentIntent) error {
+	return r.UpdateIntent(ctx, intent)
+}
+
+func (r *memPaymentIntentRepo) UpdateIntent(ctx context.Context, intent *domain.PaymentIntent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.intents[intent.ID] = *intent
+	return nil
+}
+
+func (r *memPaymentIntentRepo) GetIntentByID(ctx context.Context, id uuid.UUID) (*domain.PaymentIntent, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	intent, ok := r.store.intents[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &intent, nil
+}
+
+func (r *memPaymentIntentRepo) GetIntentByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.PaymentIntent, error) {
+	return r.GetIntentByID(ctx, id)
+}
+
+func (r *memPaymentIntentRepo) GetIntentByProviderRefForUpdate(ctx context.Context, provider, reference string) (*domain.PaymentIntent, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, intent := range r.store.intents {
+		if intent.Provider == provider && intent.ProviderRef == reference && reference != "" {
+			return &intent, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type memMerchantRepo struct {
+	domain.MerchantRepository
+	store *memStore
+}
+
+func (r *memMerchantRepo) GetMerchantByIDForUpdate(ctx context.Context, id uuid.UUID) (*domain.Merchant, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	merchant, ok := r.store.merchants[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &merchant, nil
+}
+
+func (r *memMerchantRepo) UpdateMerchant(ctx context.Context, merchant *domain.Merchant) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.merchants[merchant.ID] = *merchant
+	return nil
+}
+
+type memSystemAccountRepo struct {
+	domain.SystemAccountRepository
+	store *memStore
+}
+
+func (r *memSystemAccountRepo) CreditSystemAccount(ctx context.Context, code string, amount int64) (*domain.SystemAccount, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	account, ok := r.store.systemAccounts[code]
+	if !ok {
+		account = domain.SystemAccount{ID: uuid.New(), Code: code, Name: code}
+	}
+	account.Balance += amount
+	r.store.systemAccounts[code] = account
+	return &account, nil
+}
+
+type memAuditService struct {
+	domain.AuditService
+	store *memStore
+}
+
+func (s *memAuditService) Record(ctx context.Context, entry domain.AuditEntry) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	s.store.audit = append(s.store.audit, entry)
+}
+
+// flatFee charges the same fee on everything.
+type flatFee int64
+
+func (f flatFee) CalculateFee(ctx context.Context, kind string, user *domain.User, amount int64) (int64, error) {
+	return int64(f), nil
+}
+
+// noLimits lets every amount through.
+type noLimits struct {
+	domain.LimitService
+}
+
+func (noLimits) CheckOutgoing(ctx context.Context, user *domain.User, amount int64) error {
+	return nil
+}
+
+func (noLimits) CheckIncoming(ctx context.Context, user *domain.User, amount int64) error {
+	return nil
+}
+
+func (noLimits) CheckTopUp(ctx context.Context, user *domain.User, amount int64) error {
+	return nil
+}
+
+// noWebhooks drops every webhook event.
+type noWebhooks struct {
+	domain.WebhookService
+}
+
+func (noWebhooks) Publish(ctx context.Context, event domain.WebhookEvent) {}
+
+// defaultLimits has no tier configured, so the built-in limits apply.
+type defaultLimits struct{}
+
+func (defaultLimits) GetLimitByTier(ctx context.Context, tier string) (*domain.TransactionLimit, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+// tierLimits configures the limits of some tiers, the others fall back to the defaults.
+type tierLimits map[string]domain.TransactionLimit
+
+func (l tierLimits) GetLimitByTier(ctx context.Context, tier string) (*domain.TransactionLimit, error) {
+	limit, ok := l[tier]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &limit, nil
+}
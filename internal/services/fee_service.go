@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type FeeService struct {
+	transactor   domain.Transactor
+	feeRuleRepo  domain.FeeRuleRepository
+	auditService domain.AuditService
+}
+
+func NewFeeService(transactor domain.Transactor, feeRuleRepo domain.FeeRuleRepository, auditService domain.AuditService) *FeeService {
+	return &FeeService{transactor: transactor, feeRuleRepo: feeRuleRepo, auditService: auditService}
+}
+
+// CalculateFee picks the rule for the user's tier, falling back to the rule that applies to
+// every tier. Transactions without a matching rule are free.
+func (s *FeeService) CalculateFee(ctx context.Context, kind string, user *domain.User, amount int64) (int64, error) {
+	rules, err := s.feeRuleRepo.GetActiveFeeRules(ctx, kind)
+	if err != nil {
+		return 0, err
+	}
+
+	var rule *domain.FeeRule
+	for _, r := range rules {
+		if r.UserTier == user.Tier {
+			rule = r
+			break
+		}
+		if r.UserTier == "" && rule == nil {
+			rule = r
+		}
+	}
+	if rule == nil {
+		return 0, nil
+	}
+
+	return rule.Calculate(amount), nil
+}
+
+func (s *FeeService) GetFeeRules(ctx context.Context, kind string) ([]*domain.FeeRule, error) {
+	return s.feeRuleRepo.GetFeeRules(ctx, kind)
+}
+
+func (s *FeeService) CreateFeeRule(ctx context.Context, actorID uuid.UUID, rule domain.FeeRule) (domain.FeeRule, error) {
+	if err := rule.Validate(); err != nil {
+		return domain.FeeRule{}, err
+	}
+
+	now := time.Now()
+	rule.ID = uuid.New()
+	rule.CreatedAt, rule.UpdatedAt = now, now
+	if err := s.feeRuleRepo.CreateFeeRule(ctx, &rule); err != nil {
+		return domain.FeeRule{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditFeeRuleCreated,
+		ActorID:     actorID,
+		SubjectType: "fee_rule",
+		SubjectID:   rule.ID.String(),
+		After:       rule.AuditState(),
+	})
+	return rule, nil
+}
+
+func (s *FeeService) UpdateFeeRule(ctx context.Context, actorID uuid.UUID, rule domain.FeeRule) (domain.FeeRule, error) {
+	if err := rule.Validate(); err != nil {
+		return domain.FeeRule{}, err
+	}
+
+	var before map[string]any
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		current, err := s.feeRuleRepo.GetFeeRuleByIDForUpdate(ctx, rule.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrFeeRuleNotFound
+			}
+			return err
+		}
+		before = current.AuditState()
+
+		rule.CreatedAt = current.CreatedAt
+		rule.UpdatedAt = time.Now()
+		return s.feeRuleRepo.UpdateFeeRule(ctx, &rule)
+	})
+	if err != nil {
+		return domain.FeeRule{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditFeeRuleUpdated,
+		ActorID:     actorID,
+		SubjectType: "fee_rule",
+		SubjectID:   rule.ID.String(),
+		Before:      before,
+		After:       rule.AuditState(),
+	})
+	return rule, nil
+}
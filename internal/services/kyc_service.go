@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io"
+	"regexp"
+	"strings"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const minimumKYCAge = 17
+
+var idNumberRegex = regexp.MustCompile(`^\d{16}$`)
+
+// documentExtensions are the document content types accepted for KYC uploads.
+var documentExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+type KYCService struct {
+	transactor   domain.Transactor
+	kycRepo      domain.KYCRepository
+	userRepo     domain.UserRepository
+	blobStore    domain.BlobStore
+	auditService domain.AuditService
+}
+
+func NewKYCService(transactor domain.Transactor, kycRepo domain.KYCRepository, userRepo domain.UserRepository, blobStore domain.BlobStore,
+	auditService domain.AuditService) *KYCService {
+	return &KYCService{
+		transactor:   transactor,
+		kycRepo:      kycRepo,
+		userRepo:     userRepo,
+		blobStore:    blobStore,
+		auditService: auditService,
+	}
+}
+
+func (s *KYCService) Submit(ctx context.Context, submission domain.KYCSubmission, document *domain.KYCDocument) (domain.KYCSubmission, error) {
+	if err := validateSubmission(submission, document); err != nil {
+		return domain.KYCSubmission{}, err
+	}
+
+	// saves storing the document of a submission that would be refused. Submissions racing
+	// this one are refused by CreateSubmission, the pending index allows a single one.
+	latest, err := s.kycRepo.GetLatestSubmissionByUserID(ctx, submission.UserID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.KYCSubmission{}, err
+	}
+	if latest != nil && latest.Status == domain.KYCStatusPending {
+		return domain.KYCSubmission{}, domain.ErrKYCAlreadyPending
+	}
+
+	submission.ID = uuid.New()
+	submission.Status = domain.KYCStatusPending
+	if document != nil {
+		ext, ok := documentExtensions[document.ContentType]
+		if !ok {
+			return domain.KYCSubmission{}, domain.ErrUnsupportedDocument
+		}
+		submission.DocumentKey = fmt.Sprintf("kyc/%s/%s%s", submission.UserID, submission.ID, ext)
+		submission.DocumentType = document.ContentType
+		if err = s.blobStore.Put(ctx, submission.DocumentKey, document.Content); err != nil {
+			return domain.KYCSubmission{}, fmt.Errorf("failed to store document: %w", err)
+		}
+	}
+
+	err = s.kycRepo.CreateSubmission(ctx, &submission)
+	if err != nil {
+		if submission.DocumentKey != "" {
+			_ = s.blobStore.Delete(ctx, submission.DocumentKey)
+		}
+		return domain.KYCSubmission{}, err
+	}
+
+	return submission, nil
+}
+
+func (s *KYCService) GetLatestSubmission(ctx context.Context, userID uuid.UUID) (*domain.KYCSubmission, error) {
+	submission, err := s.kycRepo.GetLatestSubmissionByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrKYCNotFound
+		}
+		return nil, err
+	}
+	return submission, nil
+}
+
+func (s *KYCService) GetReviewQueue(ctx context.Context, status string, limit, offset int) ([]*domain.KYCSubmission, error) {
+	if status == "" {
+		status = domain.KYCStatusPending
+	}
+	return s.kycRepo.GetSubmissionsByStatus(ctx, status, limit, offset)
+}
+
+func (s *KYCService) GetDocument(ctx context.Context, submissionID uuid.UUID) (io.ReadCloser, string, error) {
+	submission, err := s.getSubmission(ctx, submissionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if submission.DocumentKey == "" {
+		return nil, "", domain.ErrKYCNotFound
+	}
+
+	content, err := s.blobStore.Get(ctx, submission.DocumentKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, submission.DocumentType, nil
+}
+
+// Approve marks the submission as approved and upgrades the user to the requested tier, if
+// it is above the one they have.
+func (s *KYCService) Approve(ctx context.Context, reviewerID, submissionID uuid.UUID) (domain.KYCSubmission, error) {
+	var (
+		submission        *domain.KYCSubmission
+		prevTier, newTier string
+	)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		submission, err = s.review(ctx, reviewerID, submissionID, domain.KYCStatusApproved, "")
+		if err != nil {
+			return err
+		}
+
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, submission.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		prevTier, newTier = user.Tier, user.Tier
+		// a submission for a lower tier than the user already has doesn't downgrade them.
+		if !domain.TierAbove(submission.RequestedTier, user.Tier) {
+			return nil
+		}
+		newTier = submission.RequestedTier
+		user.Tier = newTier
+		user.UpdatedAt = time.Now()
+		return s.userRepo.UpdateUser(ctx, user)
+	})
+	if err != nil {
+		return domain.KYCSubmission{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditKYCApproved,
+		ActorID:     reviewerID,
+		SubjectType: "user",
+		SubjectID:   submission.UserID.String(),
+		Before:      map[string]any{"tier": prevTier},
+		After:       map[string]any{"tier": newTier, "submission_id": submission.ID.String()},
+	})
+	return *submission, nil
+}
+
+func (s *KYCService) Reject(ctx context.Context, reviewerID, submissionID uuid.UUID, reason string) (domain.KYCSubmission, error) {
+	if strings.TrimSpace(reason) == "" {
+		return domain.KYCSubmission{}, domain.ErrKYCRejectReason
+	}
+
+	var submission *domain.KYCSubmission
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		submission, err = s.review(ctx, reviewerID, submissionID, domain.KYCStatusRejected, reason)
+		return err
+	})
+	if err != nil {
+		return domain.KYCSubmission{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditKYCRejected,
+		ActorID:     reviewerID,
+		SubjectType: "user",
+		SubjectID:   submission.UserID.String(),
+		After:       map[string]any{"submission_id": submission.ID.String(), "reason": reason},
+	})
+	return *submission, nil
+}
+
+// review moves a pending submission to status and records who reviewed it.
+func (s *KYCService) review(ctx context.Context, reviewerID, submissionID uuid.UUID, status, rejectReason string) (*domain.KYCSubmission, error) {
+	submission, err := s.getSubmission(ctx, submissionID)
+	if err != nil {
+		return nil, err
+	}
+	if submission.Status != domain.KYCStatusPending {
+		return nil, domain.ErrKYCAlreadyReviewed
+	}
+
+	now := time.Now()
+	submission.Status = status
+	submission.RejectReason = rejectReason
+	submission.ReviewedAt = &now
+	submission.UpdatedAt = now
+	if reviewerID != uuid.Nil {
+		submission.ReviewedBy = &reviewerID
+	}
+	if err = s.kycRepo.UpdateSubmission(ctx, submission); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+func (s *KYCService) getSubmission(ctx context.Context, submissionID uuid.UUID) (*domain.KYCSubmission, error) {
+	submission, err := s.kycRepo.GetSubmissionByID(ctx, submissionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrKYCNotFound
+		}
+		return nil, err
+	}
+	return submission, nil
+}
+
+func validateSubmission(submission domain.KYCSubmission, document *domain.KYCDocument) error {
+	if submission.RequestedTier != domain.UserTierBasic && submission.RequestedTier != domain.UserTierFull {
+		return domain.ErrInvalidKYCTier
+	}
+	if !idNumberRegex.MatchString(submission.IDNumber) {
+		return domain.ErrInvalidIDNumber
+	}
+	adultSince := time.Now().AddDate(-minimumKYCAge, 0, 0)
+	if submission.DateOfBirth.IsZero() || submission.DateOfBirth.After(adultSince) {
+		return domain.ErrInvalidDateOfBirth
+	}
+	if submission.RequestedTier == domain.UserTierFull && document == nil {
+		return domain.ErrKYCDocumentRequired
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"tahap2/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// racingKYCRepo has no submission when asked, but refuses the new one like the pending
+// index does when another submission was created in between.
+type racingKYCRepo struct {
+	domain.KYCRepository
+}
+
+func (racingKYCRepo) GetLatestSubmissionByUserID(ctx context.Context, userID uuid.UUID) (*domain.KYCSubmission, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (racingKYCRepo) CreateSubmission(ctx context.Context, submission *domain.KYCSubmission) error {
+	return domain.ErrKYCAlreadyPending
+}
+
+type memBlobStore struct {
+	domain.BlobStore
+	blobs map[string]string
+}
+
+func (s *memBlobStore) Put(ctx context.Context, key string, content io.Reader) error {
+	b, err := io.ReadAll(content)
+	s.blobs[key] = string(b)
+	return err
+}
+
+func (s *memBlobStore) Delete(ctx context.Context, key string) error {
+	delete(s.blobs, key)
+	return nil
+}
+
+func TestSubmitRacingAnotherSubmission(t *testing.T) {
+	store := newMemStore()
+	blobs := &memBlobStore{blobs: map[string]string{}}
+	service := NewKYCService(&memTransactor{}, racingKYCRepo{}, &memUserRepo{store: store}, blobs, &memAuditService{store: store})
+
+	_, err := service.Submit(context.Background(), domain.KYCSubmission{
+		UserID:        uuid.New(),
+		RequestedTier: domain.UserTierFull,
+		IDNumber:      "3171234567890001",
+		DateOfBirth:   time.Now().AddDate(-30, 0, 0),
+	}, &domain.KYCDocument{ContentType: "image/png", Content: strings.NewReader("png")})
+	if !errors.Is(err, domain.ErrKYCAlreadyPending) {
+		t.Fatalf("err = %v, want already pending", err)
+	}
+	if len(blobs.blobs) != 0 {
+		t.Fatalf("document of the refused submission kept: %v", blobs.blobs)
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+// outgoingKinds are the transaction kinds counted against the outgoing limits.
+var outgoingKinds = []string{domain.TransactionKindPayment, domain.TransactionKindTransfer, domain.TransactionKindWithdrawal}
+
+type LimitService struct {
+	limitRepo       domain.TransactionLimitRepository
+	userRepo        domain.UserRepository
+	transactionRepo domain.TransactionRepository
+}
+
+func NewLimitService(limitRepo domain.TransactionLimitRepository, userRepo domain.UserRepository, transactionRepo domain.TransactionRepository) *LimitService {
+	return &LimitService{
+		limitRepo:       limitRepo,
+		userRepo:        userRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+func (s *LimitService) CheckOutgoing(ctx context.Context, user *domain.User, amount int64) error {
+	remaining, err := s.remaining(ctx, user, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if remaining.MaxPerTransaction > 0 && amount > remaining.MaxPerTransaction {
+		return &domain.LimitExceededError{Limit: domain.LimitPerTransaction, Max: remaining.MaxPerTransaction, Remaining: remaining.MaxPerTransaction}
+	}
+	if remaining.DailyLimit > 0 && amount > remaining.DailyRemaining {
+		return &domain.LimitExceededError{Limit: domain.LimitDaily, Max: remaining.DailyLimit, Remaining: remaining.DailyRemaining}
+	}
+	if remaining.MonthlyLimit > 0 && amount > remaining.MonthlyRemaining {
+		return &domain.LimitExceededError{Limit: domain.LimitMonthly, Max: remaining.MonthlyLimit, Remaining: remaining.MonthlyRemaining}
+	}
+	if remaining.WindowLimit > 0 && remaining.WindowRemaining < 1 {
+		return &domain.LimitExceededError{Limit: domain.LimitTransactionCount, Max: remaining.WindowLimit, Remaining: 0,
+			Window: time.Duration(remaining.WindowSeconds) * time.Second}
+	}
+
+	return nil
+}
+
+func (s *LimitService) CheckIncoming(ctx context.Context, user *domain.User, amount int64) error {
+	limit, err := s.limitFor(ctx, user.Tier)
+	if err != nil {
+		return err
+	}
+
+	balance, err := domain.AddAmounts(user.Balance, amount)
+	if err != nil {
+		return err
+	}
+	if limit.MaxBalance > 0 && balance > limit.MaxBalance {
+		return &domain.LimitExceededError{Limit: domain.LimitMaxBalance, Max: limit.MaxBalance, Remaining: max(limit.MaxBalance-user.Balance, 0)}
+	}
+
+	return nil
+}
+
+func (s *LimitService) CheckTopUp(ctx context.Context, user *domain.User, amount int64) error {
+	remaining, err := s.remaining(ctx, user, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if remaining.MonthlyTopUpLimit > 0 && amount > remaining.MonthlyTopUpRemaining {
+		return &domain.LimitExceededError{Limit: domain.LimitMonthlyTopUp, Max: remaining.MonthlyTopUpLimit, Remaining: remaining.MonthlyTopUpRemaining}
+	}
+
+	return s.CheckIncoming(ctx, user, amount)
+}
+
+func (s *LimitService) GetRemainingLimits(ctx context.Context, userID uuid.UUID) (domain.RemainingLimits, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrUserNotFound
+		}
+		return domain.RemainingLimits{}, err
+	}
+
+	return s.remaining(ctx, user, time.Now())
+}
+
+func (s *LimitService) remaining(ctx context.Context, user *domain.User, now time.Time) (domain.RemainingLimits, error) {
+	limit, err := s.limitFor(ctx, user.Tier)
+	if err != nil {
+		return domain.RemainingLimits{}, err
+	}
+
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	daily, err := s.transactionRepo.SumTransactions(ctx, user.ID, domain.TransactionTypeCredit, outgoingKinds, startOfDay)
+	if err != nil {
+		return domain.RemainingLimits{}, err
+	}
+	monthly, err := s.transactionRepo.SumTransactions(ctx, user.ID, domain.TransactionTypeCredit, outgoingKinds, startOfMonth)
+	if err != nil {
+		return domain.RemainingLimits{}, err
+	}
+	var window domain.TransactionStats
+	if limit.MaxCountPerWindow > 0 {
+		since := now.Add(-time.Duration(limit.WindowSeconds) * time.Second)
+		window, err = s.transactionRepo.SumTransactions(ctx, user.ID, domain.TransactionTypeCredit, outgoingKinds, since)
+		if err != nil {
+			return domain.RemainingLimits{}, err
+		}
+	}
+	topUps, err := s.transactionRepo.SumTransactions(ctx, user.ID, domain.TransactionTypeDebit, []string{domain.TransactionKindTopUp}, startOfMonth)
+	if err != nil {
+		return domain.RemainingLimits{}, err
+	}
+
+	return domain.RemainingLimits{
+		Tier:                  limit.Tier,
+		MaxPerTransaction:     limit.MaxPerTransaction,
+		DailyLimit:            limit.DailyOutgoing,
+		DailyRemaining:        max(limit.DailyOutgoing-daily.Total, 0),
+		MonthlyLimit:          limit.MonthlyOutgoing,
+		MonthlyRemaining:      max(limit.MonthlyOutgoing-monthly.Total, 0),
+		WindowLimit:           limit.MaxCountPerWindow,
+		WindowRemaining:       max(limit.MaxCountPerWindow-window.Count, 0),
+		WindowSeconds:         limit.WindowSeconds,
+		MaxBalance:            limit.MaxBalance,
+		BalanceRemaining:      max(limit.MaxBalance-user.Balance, 0),
+		MonthlyTopUpLimit:     limit.MonthlyTopUp,
+		MonthlyTopUpRemaining: max(limit.MonthlyTopUp-topUps.Total, 0),
+	}, nil
+}
+
+// limitFor returns the configured limits of tier, or the built-in defaults when the
+// tier isn't configured.
+func (s *LimitService) limitFor(ctx context.Context, tier string) (*domain.TransactionLimit, error) {
+	limit, err := s.limitRepo.GetLimitByTier(ctx, tier)
+	if err == nil {
+		return limit, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	defaults, ok := domain.DefaultTransactionLimits[tier]
+	if !ok {
+		defaults = domain.DefaultTransactionLimits[domain.UserTierUnverified]
+	}
+	return &defaults, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"tahap2/internal/domain"
+	"tahap2/internal/workers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// limitFixture has a sender of the basic tier, whose limits the test configures, and a
+// recipient to send to.
+type limitFixture struct {
+	store     *memStore
+	limits    *LimitService
+	transfers *TransactionService
+	sender    domain.User
+	recipient domain.User
+}
+
+func newLimitFixture(limit domain.TransactionLimit) *limitFixture {
+	f := &limitFixture{store: newMemStore()}
+	f.sender = domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Balance: 500_000, Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	f.recipient = domain.User{ID: uuid.New(), PhoneNumber: "+6281200000002", Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	f.store.users[f.sender.ID], f.store.users[f.recipient.ID] = f.sender, f.recipient
+
+	limit.Tier = domain.UserTierBasic
+	userRepo, transactionRepo := &memUserRepo{store: f.store}, &memTransactionRepo{store: f.store}
+	f.limits = NewLimitService(tierLimits{domain.UserTierBasic: limit}, userRepo, transactionRepo)
+	f.transfers = NewTransactionService(&memTransactor{}, userRepo, transactionRepo, &memSystemAccountRepo{store: f.store}, nil,
+		flatFee(0), f.limits, &memAuditService{store: f.store}, noWebhooks{}, workers.NewEventBus(), false)
+	return f
+}
+
+// sent records an outgoing transfer of the sender made at createdAt.
+func (f *limitFixture) sent(amount int64, status string, createdAt time.Time) {
+	line := domain.Transaction{ID: uuid.New(), UserID: f.sender.ID, TransactionType: domain.TransactionTypeCredit,
+		Kind: domain.TransactionKindTransfer, Amount: amount, Status: status, CreatedAt: createdAt}
+	f.store.transactions[line.ID] = line
+}
+
+func (f *limitFixture) transfer(amount int64) error {
+	_, err := f.transfers.ProcessTransfer(context.Background(), f.sender.ID, f.recipient.PhoneNumber, amount, "", uuid.New())
+	return err
+}
+
+func TestTransferLimits(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		limit  domain.TransactionLimit
+		before func(f *limitFixture)
+		amount int64
+		// wantLimit is the limit the transfer breaks, empty when it goes through.
+		wantLimit string
+	}{
+		{name: "within every limit", limit: domain.TransactionLimit{MaxPerTransaction: 50_000, DailyOutgoing: 100_000}, amount: 50_000},
+		{name: "per transaction", limit: domain.TransactionLimit{MaxPerTransaction: 50_000}, amount: 50_001, wantLimit: domain.LimitPerTransaction},
+		{
+			name:      "daily",
+			limit:     domain.TransactionLimit{DailyOutgoing: 100_000},
+			before:    func(f *limitFixture) { f.sent(80_000, domain.TransactionStatusSuccess, now) },
+			amount:    20_001,
+			wantLimit: domain.LimitDaily,
+		},
+		{
+			name:      "daily counts pending transfers",
+			limit:     domain.TransactionLimit{DailyOutgoing: 100_000},
+			before:    func(f *limitFixture) { f.sent(80_000, domain.TransactionStatusPending, now) },
+			amount:    20_001,
+			wantLimit: domain.LimitDaily,
+		},
+		{
+			name:   "daily leaves out failed transfers",
+			limit:  domain.TransactionLimit{DailyOutgoing: 100_000},
+			before: func(f *limitFixture) { f.sent(80_000, domain.TransactionStatusFailed, now) },
+			amount: 100_000,
+		},
+		{
+			name:      "monthly",
+			limit:     domain.TransactionLimit{MonthlyOutgoing: 100_000},
+			before:    func(f *limitFixture) { f.sent(100_000, domain.TransactionStatusSuccess, now) },
+			amount:    1,
+			wantLimit: domain.LimitMonthly,
+		},
+		{
+			name:  "transaction count",
+			limit: domain.TransactionLimit{MaxCountPerWindow: 2, WindowSeconds: 60},
+			before: func(f *limitFixture) {
+				f.sent(1_000, domain.TransactionStatusSuccess, now.Add(-30*time.Second))
+				f.sent(1_000, domain.TransactionStatusSuccess, now.Add(-10*time.Second))
+			},
+			amount:    1_000,
+			wantLimit: domain.LimitTransactionCount,
+		},
+		{
+			name:   "transaction count after the window",
+			limit:  domain.TransactionLimit{MaxCountPerWindow: 2, WindowSeconds: 60},
+			before: func(f *limitFixture) { f.sent(1_000, domain.TransactionStatusSuccess, now.Add(-2*time.Minute)) },
+			amount: 1_000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newLimitFixture(tt.limit)
+			if tt.before != nil {
+				tt.before(f)
+			}
+			lines := len(f.store.transactions)
+
+			err := f.transfer(tt.amount)
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("ProcessTransfer = %v", err)
+				}
+				return
+			}
+			var limitErr *domain.LimitExceededError
+			if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit {
+				t.Fatalf("ProcessTransfer = %v, want the %s limit exceeded", err, tt.wantLimit)
+			}
+			if len(f.store.transactions) != lines {
+				t.Error("refused transfer recorded")
+			}
+		})
+	}
+}
+
+// TestAdminReversalOutsideLimits takes a credit back from a user who reached the daily limit.
+// Reversals are adjustments, they aren't held to the user's limits nor counted against them,
+// but can't take more than the wallet holds.
+func TestAdminReversalOutsideLimits(t *testing.T) {
+	f := newLimitFixture(domain.TransactionLimit{DailyOutgoing: 100_000, MaxCountPerWindow: 1, WindowSeconds: 60})
+	f.sent(100_000, domain.TransactionStatusSuccess, time.Now())
+	admin := NewAdminService(&memTransactor{}, &memUserRepo{store: f.store}, &memTransactionRepo{store: f.store},
+		&memSystemAccountRepo{store: f.store}, f.limits, &memAuditService{store: f.store})
+	ctx := context.Background()
+	before, err := f.limits.GetRemainingLimits(ctx, f.sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reversal, err := admin.AdjustBalance(ctx, domain.BalanceAdjustment{UserID: f.sender.ID, ActorID: uuid.New(), Amount: -150_000, Reason: "reverse topup credited twice"})
+	if err != nil {
+		t.Fatalf("AdjustBalance = %v", err)
+	}
+	if reversal.TransactionType != domain.TransactionTypeCredit || reversal.Amount != 150_000 || reversal.BalanceAfter != 350_000 {
+		t.Errorf("reversal = %+v", reversal)
+	}
+	after, err := f.limits.GetRemainingLimits(ctx, f.sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.DailyRemaining != before.DailyRemaining || after.WindowRemaining != before.WindowRemaining {
+		t.Errorf("reversal counted against the limits: %+v, before %+v", after, before)
+	}
+
+	_, err = admin.AdjustBalance(ctx, domain.BalanceAdjustment{UserID: f.sender.ID, ActorID: uuid.New(), Amount: -350_001, Reason: "reverse"})
+	if !errors.Is(err, domain.ErrInsufficientBalance) {
+		t.Errorf("reversal past the balance: err = %v, want ErrInsufficientBalance", err)
+	}
+	if balance := f.store.users[f.sender.ID].Balance; balance != 350_000 {
+		t.Errorf("balance = %d, want 350000", balance)
+	}
+}
+
+// TestTierCaps checks topups and incoming transfers against the balance and monthly topup
+// caps of the built-in tier limits.
+func TestTierCaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		tier    string
+		balance int64
+		// toppedUp is what the user topped up earlier this month.
+		toppedUp  int64
+		amount    int64
+		wantLimit string
+	}{
+		{name: "unverified under the balance cap", tier: domain.UserTierUnverified, balance: 1_500_000, amount: 500_000},
+		{name: "unverified over the balance cap", tier: domain.UserTierUnverified, balance: 1_500_000, amount: 500_001, wantLimit: domain.LimitMaxBalance},
+		{name: "basic over the unverified cap", tier: domain.UserTierBasic, balance: 1_500_000, amount: 1_000_000},
+		{name: "basic over the balance cap", tier: domain.UserTierBasic, balance: 9_000_000, amount: 1_000_001, wantLimit: domain.LimitMaxBalance},
+		{name: "unverified monthly topups", tier: domain.UserTierUnverified, toppedUp: 4_500_000, amount: 500_000},
+		{name: "unverified over monthly topups", tier: domain.UserTierUnverified, toppedUp: 4_500_000, amount: 500_001, wantLimit: domain.LimitMonthlyTopUp},
+		{name: "full over the basic monthly topups", tier: domain.UserTierFull, toppedUp: 20_000_000, amount: 1_000_000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore()
+			user := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000002", Balance: tt.balance, Tier: tt.tier, Status: domain.UserStatusActive}
+			sender := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Balance: 5_000_000, Tier: domain.UserTierFull, Status: domain.UserStatusActive}
+			store.users[user.ID], store.users[sender.ID] = user, sender
+			if tt.toppedUp > 0 {
+				line := domain.Transaction{ID: uuid.New(), UserID: user.ID, TransactionType: domain.TransactionTypeDebit,
+					Kind: domain.TransactionKindTopUp, Amount: tt.toppedUp, Status: domain.TransactionStatusSuccess, CreatedAt: time.Now()}
+				store.transactions[line.ID] = line
+			}
+			userRepo, transactionRepo := &memUserRepo{store: store}, &memTransactionRepo{store: store}
+			limits := NewLimitService(defaultLimits{}, userRepo, transactionRepo)
+			service := NewTransactionService(&memTransactor{}, userRepo, transactionRepo, &memSystemAccountRepo{store: store}, nil,
+				flatFee(0), limits, &memAuditService{store: store}, noWebhooks{}, workers.NewEventBus(), false)
+			ctx := context.Background()
+
+			_, err := service.CreditTopUp(ctx, user.ID, tt.amount, "topup")
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("CreditTopUp = %v", err)
+				}
+				return
+			}
+			var limitErr *domain.LimitExceededError
+			if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit {
+				t.Fatalf("CreditTopUp = %v, want the %s limit exceeded", err, tt.wantLimit)
+			}
+			if balance := store.users[user.ID].Balance; balance != tt.balance {
+				t.Errorf("refused topup changed the balance to %d", balance)
+			}
+
+			// a transfer is held to the balance cap only, topups don't limit it.
+			_, err = service.ProcessTransfer(ctx, sender.ID, user.PhoneNumber, tt.amount, "", uuid.New())
+			if tt.wantLimit == domain.LimitMaxBalance {
+				if !errors.As(err, &limitErr) || limitErr.Limit != domain.LimitMaxBalance {
+					t.Errorf("ProcessTransfer = %v, want the maximum balance exceeded", err)
+				}
+			} else if err != nil {
+				t.Errorf("ProcessTransfer = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const merchantKeyPrefix = "mk_"
+
+type MerchantService struct {
+	transactor      domain.Transactor
+	merchantRepo    domain.MerchantRepository
+	userRepo        domain.UserRepository
+	transactionRepo domain.TransactionRepository
+	auditService    domain.AuditService
+	webhookService  domain.WebhookService
+}
+
+func NewMerchantService(transactor domain.Transactor, merchantRepo domain.MerchantRepository, userRepo domain.UserRepository,
+	transactionRepo domain.TransactionRepository, auditService domain.AuditService, webhookService domain.WebhookService) *MerchantService {
+	return &MerchantService{
+		transactor:      transactor,
+		merchantRepo:    merchantRepo,
+		userRepo:        userRepo,
+		transactionRepo: transactionRepo,
+		auditService:    auditService,
+		webhookService:  webhookService,
+	}
+}
+
+func (s *MerchantService) CreateMerchant(ctx context.Context, actorID uuid.UUID, name string) (domain.Merchant, domain.IssuedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return domain.Merchant{}, domain.IssuedAPIKey{}, domain.ErrMerchantNameRequired
+	}
+
+	merchant := domain.Merchant{Name: name, Status: domain.MerchantStatusActive}
+	var key domain.IssuedAPIKey
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.merchantRepo.CreateMerchant(ctx, &merchant); err != nil {
+			return err
+		}
+		var err error
+		key, err = s.issueAPIKey(ctx, merchant.ID)
+		return err
+	})
+	if err != nil {
+		return domain.Merchant{}, domain.IssuedAPIKey{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditMerchantCreated,
+		ActorID:     actorID,
+		SubjectType: "merchant",
+		SubjectID:   merchant.ID.String(),
+		After:       map[string]any{"name": merchant.Name, "api_key_prefix": key.Prefix},
+	})
+	return merchant, key, nil
+}
+
+func (s *MerchantService) GetMerchant(ctx context.Context, merchantID uuid.UUID) (*domain.Merchant, error) {
+	merchant, err := s.merchantRepo.GetMerchantByID(ctx, merchantID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrMerchantNotFound
+		}
+		return nil, err
+	}
+	return merchant, nil
+}
+
+func (s *MerchantService) RotateAPIKey(ctx context.Context, actorID, merchantID uuid.UUID) (domain.IssuedAPIKey, error) {
+	var key domain.IssuedAPIKey
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if _, err := s.lockMerchant(ctx, merchantID); err != nil {
+			return err
+		}
+		if err := s.merchantRepo.RevokeAPIKeys(ctx, merchantID); err != nil {
+			return err
+		}
+		var err error
+		key, err = s.issueAPIKey(ctx, merchantID)
+		return err
+	})
+	if err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditMerchantKeyRotated,
+		ActorID:     actorID,
+		SubjectType: "merchant",
+		SubjectID:   merchantID.String(),
+		After:       map[string]any{"api_key_prefix": key.Prefix},
+	})
+	return key, nil
+}
+
+func (s *MerchantService) SetMerchantStatus(ctx context.Context, actorID, merchantID uuid.UUID, status string) (domain.Merchant, error) {
+	if status != domain.MerchantStatusActive && status != domain.MerchantStatusSuspended {
+		return domain.Merchant{}, domain.ErrInvalidMerchantState
+	}
+
+	var (
+		merchant   *domain.Merchant
+		prevStatus string
+	)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		merchant, err = s.lockMerchant(ctx, merchantID)
+		if err != nil {
+			return err
+		}
+		prevStatus = merchant.Status
+		merchant.Status = status
+		merchant.UpdatedAt = time.Now()
+		return s.merchantRepo.UpdateMerchant(ctx, merchant)
+	})
+	if err != nil {
+		return domain.Merchant{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditMerchantStatusChanged,
+		ActorID:     actorID,
+		SubjectType: "merchant",
+		SubjectID:   merchantID.String(),
+		Before:      map[string]any{"status": prevStatus},
+		After:       map[string]any{"status": status},
+	})
+	return *merchant, nil
+}
+
+// Authenticate looks the key up by its hash. Suspended merchants keep their keys but
+// can't use them until they are reactivated.
+func (s *MerchantService) Authenticate(ctx context.Context, apiKey string) (*domain.Merchant, error) {
+	if !strings.HasPrefix(apiKey, merchantKeyPrefix) {
+		return nil, domain.ErrInvalidAPIKey
+	}
+
+	key, err := s.merchantRepo.GetActiveAPIKeyByHash(ctx, hashAPIKey(apiKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	merchant, err := s.GetMerchant(ctx, key.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	if !merchant.IsActive() {
+		return nil, domain.ErrMerchantInactive
+	}
+	return merchant, nil
+}
+
+func (s *MerchantService) GetPayments(ctx context.Context, merchantID uuid.UUID, limit, offset int) ([]*domain.Transaction, error) {
+	return s.transactionRepo.GetTransactionsByUserIDPaged(ctx, merchantID, limit, offset)
+}
+
+// Refund returns part or all of a payment from the merchant's settlement wallet to the
+// payer. PaymentID is the merchant's side of the payment. The payment fee is not refunded.
+func (s *MerchantService) Refund(ctx context.Context, refund domain.Refund) (domain.Transaction, error) {
+	if refund.Amount <= 0 {
+		return domain.Transaction{}, domain.ErrInvalidAmount
+	}
+
+	var merchantLine, payerLine domain.Transaction
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		payment, err := s.transactionRepo.GetTransactionByID(ctx, refund.PaymentID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrTransactionNotFound
+			}
+			return err
+		}
+		// another merchant's payment is reported as missing rather than forbidden.
+		if payment.UserID != refund.MerchantID {
+			return domain.ErrTransactionNotFound
+		}
+		if payment.Kind != domain.TransactionKindPayment || payment.TransactionType != domain.TransactionTypeDebit ||
+			payment.Status != domain.TransactionStatusSuccess || payment.ReferenceID == nil {
+			return domain.ErrNotRefundable
+		}
+		original, err := s.transactionRepo.GetTransactionByID(ctx, *payment.ReferenceID)
+		if err != nil {
+			return err
+		}
+
+		// same lock order as payments, payer first, so the two can't deadlock.
+		payer, err := s.userRepo.GetUserByIDForUpdate(ctx, original.UserID)
+		if err != nil {
+			return err
+		}
+		merchant, err := s.lockMerchant(ctx, refund.MerchantID)
+		if err != nil {
+			return err
+		}
+		if payer.IsClosed() {
+			return domain.ErrRecipientClosed
+		}
+
+		refunded, err := s.transactionRepo.SumChildTransactions(ctx, payment.ID, domain.TransactionKindRefund)
+		if err != nil {
+			return err
+		}
+		if refunded+refund.Amount > payment.Amount {
+			return domain.ErrRefundExceedsPayment
+		}
+		if merchant.Balance < refund.Amount {
+			return domain.ErrInsufficientBalance
+		}
+
+		// the money was the payer's to begin with, so refunds skip the tier limits.
+		merchantBefore := merchant.Balance
+		merchant.Balance -= refund.Amount
+		merchant.UpdatedAt = time.Now()
+		if err = s.merchantRepo.UpdateMerchant(ctx, merchant); err != nil {
+			return err
+		}
+		payerBefore := payer.Balance
+		payer.Balance, err = domain.AddAmounts(payer.Balance, refund.Amount)
+		if err != nil {
+			return err
+		}
+		payer.UpdatedAt = time.Now()
+		if err = s.userRepo.UpdateUser(ctx, payer); err != nil {
+			return err
+		}
+
+		merchantLine = domain.Transaction{
+			Status:          domain.TransactionStatusSuccess,
+			UserID:          merchant.ID,
+			TransactionType: domain.TransactionTypeCredit,
+			Kind:            domain.TransactionKindRefund,
+			Amount:          refund.Amount,
+			Remark:          refund.Reason,
+			BalanceBefore:   merchantBefore,
+			BalanceAfter:    merchant.Balance,
+			ReferenceID:     &payment.ID,
+			MerchantID:      &merchant.ID,
+		}
+		if err = s.transactionRepo.CreateTransaction(ctx, &merchantLine); err != nil {
+			return err
+		}
+		payerLine = domain.Transaction{
+			Status:          domain.TransactionStatusSuccess,
+			UserID:          payer.ID,
+			TransactionType: domain.TransactionTypeDebit,
+			Kind:            domain.TransactionKindRefund,
+			Amount:          refund.Amount,
+			Remark:          "refund from " + merchant.Name,
+			BalanceBefore:   payerBefore,
+			BalanceAfter:    payer.Balance,
+			ReferenceID:     &original.ID,
+			MerchantID:      &merchant.ID,
+		}
+		return s.transactionRepo.CreateTransaction(ctx, &payerLine)
+	})
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	after := payerLine.AuditState()
+	after["reason"] = refund.Reason
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditRefund,
+		ActorID:     refund.MerchantID,
+		SubjectType: "transaction",
+		SubjectID:   merchantLine.ID.String(),
+		After:       after,
+	})
+	s.webhookService.Publish(ctx, domain.WebhookEvent{
+		Type:       domain.WebhookEventRefundCreated,
+		MerchantID: &refund.MerchantID,
+		Data:       domain.NewWebhookTransaction(merchantLine),
+	})
+	return merchantLine, nil
+}
+
+func (s *MerchantService) lockMerchant(ctx context.Context, merchantID uuid.UUID) (*domain.Merchant, error) {
+	merchant, err := s.merchantRepo.GetMerchantByIDForUpdate(ctx, merchantID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrMerchantNotFound
+		}
+		return nil, err
+	}
+	return merchant, nil
+}
+
+func (s *MerchantService) issueAPIKey(ctx context.Context, merchantID uuid.UUID) (domain.IssuedAPIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+	key := merchantKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := domain.MerchantAPIKey{
+		MerchantID: merchantID,
+		Prefix:     key[:len(merchantKeyPrefix)+6],
+		KeyHash:    hashAPIKey(key),
+	}
+	if err := s.merchantRepo.CreateAPIKey(ctx, &apiKey); err != nil {
+		return domain.IssuedAPIKey{}, err
+	}
+	return domain.IssuedAPIKey{MerchantAPIKey: apiKey, Key: key}, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"tahap2/internal/domain"
+	"tahap2/internal/workers"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestRefund pays a merchant 100000 and refunds the payment in parts. Refunds of a payment
+// can't add up to more than was paid.
+func TestRefund(t *testing.T) {
+	store := newMemStore()
+	payer := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Balance: 150_000, Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	merchant := domain.Merchant{ID: uuid.New(), Name: "Warung Sari", Status: domain.MerchantStatusActive}
+	other := domain.Merchant{ID: uuid.New(), Name: "Toko Lain", Status: domain.MerchantStatusActive}
+	store.users[payer.ID] = payer
+	store.merchants[merchant.ID], store.merchants[other.ID] = merchant, other
+
+	transactor, userRepo, transactionRepo := &memTransactor{}, &memUserRepo{store: store}, &memTransactionRepo{store: store}
+	merchantRepo, auditService := &memMerchantRepo{store: store}, &memAuditService{store: store}
+	payments := NewTransactionService(transactor, userRepo, transactionRepo, &memSystemAccountRepo{store: store}, merchantRepo,
+		flatFee(0), noLimits{}, auditService, noWebhooks{}, workers.NewEventBus(), false)
+	service := NewMerchantService(transactor, merchantRepo, userRepo, transactionRepo, auditService, noWebhooks{})
+	ctx := context.Background()
+
+	if _, err := payments.ProcessPayment(ctx, payer.ID, merchant.ID, 100_000, "order 17"); err != nil {
+		t.Fatalf("ProcessPayment = %v", err)
+	}
+	var paymentID uuid.UUID
+	for _, line := range store.transactions {
+		if line.UserID == merchant.ID && line.Kind == domain.TransactionKindPayment {
+			paymentID = line.ID
+		}
+	}
+	if paymentID == uuid.Nil {
+		t.Fatal("no payment line for the merchant")
+	}
+
+	steps := []struct {
+		name       string
+		merchantID uuid.UUID
+		amount     int64
+		wantErr    error
+		// payerBalance and merchantBalance are the balances after the step.
+		payerBalance, merchantBalance int64
+	}{
+		{"more than the payment", merchant.ID, 100_001, domain.ErrRefundExceedsPayment, 50_000, 100_000},
+		{"zero", merchant.ID, 0, domain.ErrInvalidAmount, 50_000, 100_000},
+		{"another merchant's payment", other.ID, 1_000, domain.ErrTransactionNotFound, 50_000, 100_000},
+		{"first part", merchant.ID, 30_000, nil, 80_000, 70_000},
+		{"second part", merchant.ID, 30_000, nil, 110_000, 40_000},
+		{"more than what is left", merchant.ID, 40_001, domain.ErrRefundExceedsPayment, 110_000, 40_000},
+		{"the rest", merchant.ID, 40_000, nil, 150_000, 0},
+		{"after the full refund", merchant.ID, 1, domain.ErrRefundExceedsPayment, 150_000, 0},
+	}
+	for _, step := range steps {
+		lines := len(store.transactions)
+		refund, err := service.Refund(ctx, domain.Refund{MerchantID: step.merchantID, PaymentID: paymentID, Amount: step.amount, Reason: "returned"})
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: Refund = %v, want %v", step.name, err, step.wantErr)
+		}
+		if err == nil && (refund.Kind != domain.TransactionKindRefund || refund.Amount != step.amount || *refund.ReferenceID != paymentID) {
+			t.Errorf("%s: refund = %+v", step.name, refund)
+		}
+		if err != nil && len(store.transactions) != lines {
+			t.Errorf("%s: refused refund recorded", step.name)
+		}
+		if got := store.users[payer.ID].Balance; got != step.payerBalance {
+			t.Errorf("%s: payer balance = %d, want %d", step.name, got, step.payerBalance)
+		}
+		if got := store.merchants[merchant.ID].Balance; got != step.merchantBalance {
+			t.Errorf("%s: merchant balance = %d, want %d", step.name, got, step.merchantBalance)
+		}
+	}
+}
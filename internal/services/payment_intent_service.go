@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log/slog"
+	"tahap2/internal/domain"
+	"tahap2/internal/metrics"
+	"time"
+)
+
+type PaymentIntentService struct {
+	transactor   domain.Transactor
+	intentRepo   domain.PaymentIntentRepository
+	userRepo     domain.UserRepository
+	limitService domain.LimitService
+	transService domain.TransactionService
+	auditService domain.AuditService
+	logger       *slog.Logger
+	gateways     map[string]domain.PaymentGateway
+	// defaultGateway opens the charges of new intents, the others only settle old ones.
+	defaultGateway domain.PaymentGateway
+}
+
+func NewPaymentIntentService(transactor domain.Transactor, intentRepo domain.PaymentIntentRepository, userRepo domain.UserRepository,
+	limitService domain.LimitService, transService domain.TransactionService, auditService domain.AuditService, logger *slog.Logger,
+	defaultGateway domain.PaymentGateway, otherGateways ...domain.PaymentGateway) *PaymentIntentService {
+	gateways := map[string]domain.PaymentGateway{defaultGateway.Name(): defaultGateway}
+	for _, gateway := range otherGateways {
+		gateways[gateway.Name()] = gateway
+	}
+
+	return &PaymentIntentService{
+		transactor:     transactor,
+		intentRepo:     intentRepo,
+		userRepo:       userRepo,
+		limitService:   limitService,
+		transService:   transService,
+		auditService:   auditService,
+		logger:         logger,
+		gateways:       gateways,
+		defaultGateway: defaultGateway,
+	}
+}
+
+func (s *PaymentIntentService) CreateTopUpIntent(ctx context.Context, userID uuid.UUID, amount int64, method string) (domain.PaymentIntent, error) {
+	if amount <= 0 {
+		return domain.PaymentIntent{}, domain.ErrInvalidAmount
+	}
+	if method != domain.PaymentMethodVA && method != domain.PaymentMethodCard {
+		return domain.PaymentIntent{}, domain.ErrInvalidPaymentMethod
+	}
+
+	// reject up front what would be refused once paid, the checks run again when crediting.
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrUserNotFound
+		}
+		return domain.PaymentIntent{}, err
+	}
+	if user.IsClosed() {
+		return domain.PaymentIntent{}, domain.ErrAccountClosed
+	}
+	if !domain.TierAllows(user.Tier, domain.FeatureTopUp) {
+		return domain.PaymentIntent{}, domain.ErrFeatureNotAllowed
+	}
+	if err = s.limitService.CheckTopUp(ctx, user, amount); err != nil {
+		return domain.PaymentIntent{}, err
+	}
+
+	// the intent is saved before the charge is opened, so a payment for the charge always has
+	// an intent to credit. The gateway echoes the id back as the order id.
+	intent := domain.PaymentIntent{
+		ID:       uuid.New(),
+		UserID:   userID,
+		Amount:   amount,
+		Method:   method,
+		Provider: s.defaultGateway.Name(),
+		Status:   domain.IntentStatusPending,
+	}
+	if err = s.intentRepo.CreateIntent(ctx, &intent); err != nil {
+		return domain.PaymentIntent{}, err
+	}
+	charge, err := s.defaultGateway.CreateCharge(ctx, &intent)
+	if err != nil {
+		intent.Status = domain.IntentStatusFailed
+		intent.FailureReason = err.Error()
+		intent.UpdatedAt = time.Now()
+		if updateErr := s.intentRepo.UpdateIntent(ctx, &intent); updateErr != nil {
+			s.logger.ErrorContext(ctx, "failed to fail payment intent", "intent_id", intent.ID, "error", updateErr)
+		}
+		return domain.PaymentIntent{}, fmt.Errorf("error opening charge: %w", err)
+	}
+	intent.ProviderRef = charge.Reference
+	intent.VANumber = charge.VANumber
+	intent.PaymentURL = charge.PaymentURL
+	intent.ExpiresAt = charge.ExpiresAt
+	intent.UpdatedAt = time.Now()
+	if err = s.intentRepo.UpdateIntent(ctx, &intent); err != nil {
+		// the callback still finds the intent by its order id.
+		return domain.PaymentIntent{}, err
+	}
+
+	return intent, nil
+}
+
+func (s *PaymentIntentService) GetIntent(ctx context.Context, userID, intentID uuid.UUID) (*domain.PaymentIntent, error) {
+	intent, err := s.intentRepo.GetIntentByID(ctx, intentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrIntentNotFound
+		}
+		return nil, err
+	}
+	if intent.UserID != userID {
+		return nil, domain.ErrIntentNotFound
+	}
+
+	return intent, nil
+}
+
+func (s *PaymentIntentService) HandleCallback(ctx context.Context, provider, signature string, body []byte) error {
+	gateway, ok := s.gateways[provider]
+	if !ok {
+		return domain.ErrUnknownGateway
+	}
+	status, err := gateway.ParseCallback(signature, body)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.apply(ctx, provider, status)
+	return err
+}
+
+func (s *PaymentIntentService) Reconcile(ctx context.Context, staleAfter time.Duration, limit int) (int, error) {
+	intents, err := s.intentRepo.GetStalePendingIntents(ctx, time.Now().Add(-staleAfter), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, intent := range intents {
+		gateway, ok := s.gateways[intent.Provider]
+		if !ok {
+			s.logger.ErrorContext(ctx, "cannot reconcile payment intent", "intent_id", intent.ID, "provider", intent.Provider, "error", domain.ErrUnknownGateway)
+			continue
+		}
+		status, err := gateway.GetChargeStatus(ctx, intent.ProviderRef)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to query charge", "intent_id", intent.ID, "provider_ref", intent.ProviderRef, "error", err)
+			continue
+		}
+		if status.Status == domain.IntentStatusPending {
+			if err = s.intentRepo.TouchPendingIntent(ctx, intent.ID); err != nil {
+				s.logger.ErrorContext(ctx, "failed to touch payment intent", "intent_id", intent.ID, "error", err)
+			}
+			continue
+		}
+
+		applied, err := s.apply(ctx, intent.Provider, status)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to reconcile payment intent", "intent_id", intent.ID, "error", err)
+			continue
+		}
+		if applied {
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+// apply moves a pending intent to the status the gateway reported, crediting the wallet when
+// it was paid. The intent row is locked while this happens and anything but a pending intent
+// is left alone, so callbacks and reconciliation can race or repeat and the topup is still
+// credited exactly once. It reports whether the intent changed.
+func (s *PaymentIntentService) apply(ctx context.Context, provider string, status domain.GatewayChargeStatus) (bool, error) {
+	var (
+		intent  *domain.PaymentIntent
+		credit  domain.Transaction
+		applied bool
+	)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		intent, err = s.lockIntent(ctx, provider, status)
+		if err != nil {
+			return err
+		}
+		if !intent.IsPending() {
+			return nil
+		}
+
+		switch {
+		case status.Status == domain.IntentStatusFailed, status.Status == domain.IntentStatusExpired:
+			intent.Status = status.Status
+		case status.Status != domain.IntentStatusPaid:
+			return nil
+		case status.Amount != intent.Amount:
+			intent.Status = domain.IntentStatusFailed
+			intent.FailureReason = domain.ErrGatewayAmountMismatch.Error()
+		default:
+			credit, err = s.transService.CreditTopUp(ctx, intent.UserID, intent.Amount, "topup via "+provider)
+			if err != nil && !isTopUpRejection(err) {
+				return err
+			}
+			if err != nil {
+				// the money has reached the gateway but can't be put in the wallet, the
+				// intent is failed so support can refund it.
+				intent.Status = domain.IntentStatusFailed
+				intent.FailureReason = err.Error()
+			} else {
+				now := time.Now()
+				intent.Status = domain.IntentStatusPaid
+				intent.TransactionID = &credit.ID
+				intent.PaidAt = &now
+			}
+		}
+
+		intent.UpdatedAt = time.Now()
+		applied = true
+		return s.intentRepo.UpdateIntent(ctx, intent)
+	})
+	if err != nil || !applied {
+		return false, err
+	}
+
+	switch {
+	case intent.TransactionID != nil:
+		metrics.RecordMoneyMovement(domain.TransactionKindTopUp, credit.Amount)
+		s.auditService.Record(ctx, domain.AuditEntry{
+			EventType:   domain.AuditTopUp,
+			ActorID:     credit.UserID,
+			SubjectType: "transaction",
+			SubjectID:   credit.ID.String(),
+			After:       credit.AuditState(),
+		})
+	case intent.FailureReason != "":
+		s.logger.WarnContext(ctx, "payment intent paid but not credited", "intent_id", intent.ID, "provider", provider, "reason", intent.FailureReason)
+	}
+
+	return true, nil
+}
+
+// lockIntent loads the intent a charge was opened for. Intents whose charge reference
+// couldn't be stored are found by the order id the gateway echoes back, and adopt the
+// reference.
+func (s *PaymentIntentService) lockIntent(ctx context.Context, provider string, status domain.GatewayChargeStatus) (*domain.PaymentIntent, error) {
+	intent, err := s.intentRepo.GetIntentByProviderRefForUpdate(ctx, provider, status.Reference)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return intent, err
+	}
+
+	intentID, parseErr := uuid.Parse(status.OrderID)
+	if parseErr != nil {
+		return nil, domain.ErrIntentNotFound
+	}
+	intent, err = s.intentRepo.GetIntentByIDForUpdate(ctx, intentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrIntentNotFound
+		}
+		return nil, err
+	}
+	if intent.Provider != provider || intent.ProviderRef != "" {
+		return nil, domain.ErrIntentNotFound
+	}
+	intent.ProviderRef = status.Reference
+	intent.UpdatedAt = time.Now()
+	if err = s.intentRepo.UpdateIntent(ctx, intent); err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// isTopUpRejection tells the errors that refuse a topup for good apart from failures that
+// are worth retrying.
+func isTopUpRejection(err error) bool {
+	for _, rejection := range []error{
+		domain.ErrUserNotFound,
+		domain.ErrAccountClosed,
+		domain.ErrFeatureNotAllowed,
+		domain.ErrFeeExceedsAmount,
+		domain.ErrLimitExceeded,
+		domain.ErrAmountOverflow,
+	} {
+		if errors.Is(err, rejection) {
+			return true
+		}
+	}
+	return false
+}
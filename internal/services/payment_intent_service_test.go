@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"tahap2/internal/domain"
+	"tahap2/internal/gateway"
+	"tahap2/internal/workers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testGateway       = "sandbox"
+	testGatewaySecret = "sandbox-secret"
+)
+
+// gatewayCallback is a callback the fake gateway sent, as the callback route received it.
+type gatewayCallback struct {
+	signature string
+	body      []byte
+	err       error
+}
+
+type intentFixture struct {
+	store   *memStore
+	service *PaymentIntentService
+	user    domain.User
+	// gatewayURL serves the fake gateway, whose callbacks land on callbacks.
+	gatewayURL string
+	callbacks  chan gatewayCallback
+}
+
+// newIntentFixture wires the service to the fake gateway through the sandbox client. The
+// gateway posts its callbacks to an httptest server that hands them to HandleCallback like
+// the real route does.
+func newIntentFixture(t *testing.T) *intentFixture {
+	t.Helper()
+	f := &intentFixture{store: newMemStore(), callbacks: make(chan gatewayCallback, 10)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	callbackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature := r.Header.Get(domain.GatewayHeaderSignature)
+		err := f.service.HandleCallback(r.Context(), testGateway, signature, body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		f.callbacks <- gatewayCallback{signature: signature, body: body, err: err}
+	}))
+	t.Cleanup(callbackServer.Close)
+	fake := gateway.NewFakeGateway(testGatewaySecret, callbackServer.URL, "http://gateway.test", time.Hour, false, logger)
+	gatewayServer := httptest.NewServer(fake.Handler())
+	t.Cleanup(gatewayServer.Close)
+	f.gatewayURL = gatewayServer.URL
+
+	transactor, userRepo, auditService := &memTransactor{}, &memUserRepo{store: f.store}, &memAuditService{store: f.store}
+	transService := NewTransactionService(transactor, userRepo, &memTransactionRepo{store: f.store}, &memSystemAccountRepo{store: f.store},
+		nil, flatFee(0), noLimits{}, auditService, noWebhooks{}, workers.NewEventBus(), false)
+	f.service = NewPaymentIntentService(transactor, &memPaymentIntentRepo{store: f.store}, userRepo, noLimits{}, transService,
+		auditService, logger, gateway.NewSandboxGateway(testGateway, gatewayServer.URL, testGatewaySecret))
+
+	f.user = domain.User{ID: uuid.New(), Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	f.store.users[f.user.ID] = f.user
+	return f
+}
+
+// pay pays the charge of intent at the fake gateway, which calls back before answering.
+func (f *intentFixture) pay(t *testing.T, intent domain.PaymentIntent) gatewayCallback {
+	t.Helper()
+	resp, err := http.Post(f.gatewayURL+"/charges/"+intent.ProviderRef+"/pay", "", nil)
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("pay answered %d", resp.StatusCode)
+	}
+	select {
+	case callback := <-f.callbacks:
+		return callback
+	case <-time.After(time.Second):
+		t.Fatal("no callback")
+		return gatewayCallback{}
+	}
+}
+
+func (f *intentFixture) balance() int64 {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.users[f.user.ID].Balance
+}
+
+func (f *intentFixture) intent(id uuid.UUID) domain.PaymentIntent {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.intents[id]
+}
+
+func (f *intentFixture) topUps() int {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	count := 0
+	for _, transaction := range f.store.transactions {
+		if transaction.Kind == domain.TransactionKindTopUp {
+			count++
+		}
+	}
+	return count
+}
+
+func TestTopUpCreditedOnceOnDuplicateCallbacks(t *testing.T) {
+	f := newIntentFixture(t)
+	ctx := context.Background()
+	intent, err := f.service.CreateTopUpIntent(ctx, f.user.ID, 50_000, domain.PaymentMethodVA)
+	if err != nil {
+		t.Fatalf("CreateTopUpIntent: %v", err)
+	}
+
+	callback := f.pay(t, intent)
+	if callback.err != nil {
+		t.Fatalf("HandleCallback: %v", callback.err)
+	}
+	paid := f.intent(intent.ID)
+	if paid.Status != domain.IntentStatusPaid || paid.TransactionID == nil {
+		t.Fatalf("intent is %s with transaction %v, want paid", paid.Status, paid.TransactionID)
+	}
+
+	// gateways deliver at least once, the same callback can arrive again, even concurrently.
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() { errs <- f.service.HandleCallback(ctx, testGateway, callback.signature, callback.body) }()
+	}
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Errorf("duplicate HandleCallback: %v", err)
+		}
+	}
+	if err = f.service.HandleCallback(ctx, testGateway, callback.signature, callback.body); err != nil {
+		t.Errorf("duplicate HandleCallback: %v", err)
+	}
+
+	if got := f.balance(); got != 50_000 {
+		t.Errorf("balance = %d, want 50000", got)
+	}
+	if got := f.topUps(); got != 1 {
+		t.Errorf("%d topups recorded, want 1", got)
+	}
+	if got := f.intent(intent.ID); *got.TransactionID != *paid.TransactionID {
+		t.Errorf("intent moved to transaction %s, want %s", got.TransactionID, paid.TransactionID)
+	}
+}
+
+func TestTopUpCallbackSignature(t *testing.T) {
+	f := newIntentFixture(t)
+	ctx := context.Background()
+	intent, err := f.service.CreateTopUpIntent(ctx, f.user.ID, 50_000, domain.PaymentMethodVA)
+	if err != nil {
+		t.Fatalf("CreateTopUpIntent: %v", err)
+	}
+	body, _ := json.Marshal(gateway.Charge{
+		Reference: intent.ProviderRef,
+		OrderID:   intent.ID.String(),
+		Amount:    intent.Amount,
+		Method:    intent.Method,
+		Status:    domain.IntentStatusPaid,
+	})
+	tampered, _ := json.Marshal(gateway.Charge{
+		Reference: intent.ProviderRef,
+		OrderID:   intent.ID.String(),
+		Amount:    intent.Amount * 10,
+		Method:    intent.Method,
+		Status:    domain.IntentStatusPaid,
+	})
+
+	tests := []struct {
+		name      string
+		signature string
+		body      []byte
+	}{
+		{"missing", "", body},
+		{"signed with another secret", gateway.Sign("another-secret", body), body},
+		{"signed for another body", gateway.Sign(testGatewaySecret, body), tampered},
+		{"not hex", "not-a-signature", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.service.HandleCallback(ctx, testGateway, tt.signature, tt.body)
+			if !errors.Is(err, domain.ErrInvalidCallback) {
+				t.Errorf("HandleCallback = %v, want ErrInvalidCallback", err)
+			}
+			if got := f.intent(intent.ID); got.Status != domain.IntentStatusPending {
+				t.Errorf("intent is %s, want pending", got.Status)
+			}
+			if got := f.balance(); got != 0 {
+				t.Errorf("balance = %d, want 0", got)
+			}
+		})
+	}
+
+	if err = f.service.HandleCallback(ctx, "other", gateway.Sign(testGatewaySecret, body), body); !errors.Is(err, domain.ErrUnknownGateway) {
+		t.Errorf("HandleCallback from an unknown gateway = %v, want ErrUnknownGateway", err)
+	}
+	// the same body signed with the shared secret goes through.
+	if err = f.service.HandleCallback(ctx, testGateway, gateway.Sign(testGatewaySecret, body), body); err != nil {
+		t.Fatalf("HandleCallback: %v", err)
+	}
+	if got := f.balance(); got != 50_000 {
+		t.Errorf("balance = %d, want 50000", got)
+	}
+}
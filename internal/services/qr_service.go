@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log/slog"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const (
+	minDynamicQRTTL = time.Minute
+	maxDynamicQRTTL = 24 * time.Hour
+)
+
+type QRService struct {
+	transactor      domain.Transactor
+	qrRepo          domain.QRRepository
+	merchantService domain.MerchantService
+	transService    domain.TransactionService
+	logger          *slog.Logger
+}
+
+func NewQRService(transactor domain.Transactor, qrRepo domain.QRRepository, merchantService domain.MerchantService,
+	transService domain.TransactionService, logger *slog.Logger) *QRService {
+	return &QRService{
+		transactor:      transactor,
+		qrRepo:          qrRepo,
+		merchantService: merchantService,
+		transService:    transService,
+		logger:          logger,
+	}
+}
+
+// GetStaticQR returns the merchant's reusable payload, the payer enters the amount.
+func (s *QRService) GetStaticQR(ctx context.Context, merchantID uuid.UUID) (string, error) {
+	merchant, err := s.merchantService.GetMerchant(ctx, merchantID)
+	if err != nil {
+		return "", err
+	}
+
+	return domain.QRPayload{
+		MerchantID:   merchant.ID,
+		MerchantName: merchant.Name,
+	}.Encode(), nil
+}
+
+func (s *QRService) CreateDynamicQR(ctx context.Context, merchantID uuid.UUID, amount int64, ttl time.Duration) (domain.QRCode, string, error) {
+	if amount <= 0 {
+		return domain.QRCode{}, "", domain.ErrInvalidAmount
+	}
+	if ttl < minDynamicQRTTL || ttl > maxDynamicQRTTL {
+		return domain.QRCode{}, "", domain.ErrInvalidQRTTL
+	}
+	merchant, err := s.merchantService.GetMerchant(ctx, merchantID)
+	if err != nil {
+		return domain.QRCode{}, "", err
+	}
+
+	qr := domain.QRCode{
+		MerchantID: merchant.ID,
+		Amount:     amount,
+		Status:     domain.QRStatusActive,
+		ExpiresAt:  time.Now().Add(ttl),
+	}
+	if err = s.qrRepo.CreateQRCode(ctx, &qr); err != nil {
+		return domain.QRCode{}, "", err
+	}
+
+	return qr, dynamicPayload(merchant, &qr), nil
+}
+
+func (s *QRService) GetDynamicQR(ctx context.Context, merchantID, qrID uuid.UUID) (domain.QRCode, string, error) {
+	qr, err := s.getQRCode(ctx, qrID)
+	if err != nil {
+		return domain.QRCode{}, "", err
+	}
+	if qr.MerchantID != merchantID {
+		return domain.QRCode{}, "", domain.ErrQRNotFound
+	}
+	merchant, err := s.merchantService.GetMerchant(ctx, merchantID)
+	if err != nil {
+		return domain.QRCode{}, "", err
+	}
+
+	return *qr, dynamicPayload(merchant, qr), nil
+}
+
+// PayQR validates a scanned payload and pays the merchant behind it. A dynamic code is
+// claimed in the transaction paying it so it can't be paid twice.
+func (s *QRService) PayQR(ctx context.Context, payment domain.QRPayment) (domain.Transaction, error) {
+	payload, err := domain.ParseQRPayload(payment.Payload)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	if !payload.Dynamic {
+		if payment.Amount <= 0 {
+			return domain.Transaction{}, domain.ErrInvalidAmount
+		}
+		return s.transService.ProcessPayment(ctx, payment.UserID, payload.MerchantID, payment.Amount, payment.Remarks)
+	}
+
+	// the stored code is authoritative, the payload only points at it.
+	qr, err := s.getQRCode(ctx, payload.ReferenceID)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	if qr.MerchantID != payload.MerchantID || qr.Amount != payload.Amount {
+		return domain.Transaction{}, domain.ErrInvalidQR
+	}
+	if payment.Amount != 0 && payment.Amount != qr.Amount {
+		return domain.Transaction{}, domain.ErrQRAmountMismatch
+	}
+
+	// the claim, the payment and the link to it commit together, a rejected payment leaves
+	// the code active for the next attempt. The payment is only reported once committed.
+	var trans domain.Transaction
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		claimed, err := s.qrRepo.ClaimQRCode(ctx, qr.ID, payment.UserID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return domain.ErrQRUnavailable
+		}
+
+		trans, err = s.transService.PayMerchant(ctx, payment.UserID, qr.MerchantID, qr.Amount, payment.Remarks)
+		if err != nil {
+			return err
+		}
+		return s.qrRepo.SetQRCodeTransaction(ctx, qr.ID, trans.ID)
+	})
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+
+	s.transService.PaymentCommitted(ctx, trans)
+	return trans, nil
+}
+
+func (s *QRService) getQRCode(ctx context.Context, qrID uuid.UUID) (*domain.QRCode, error) {
+	qr, err := s.qrRepo.GetQRCodeByID(ctx, qrID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrQRNotFound
+		}
+		return nil, err
+	}
+	return qr, nil
+}
+
+func dynamicPayload(merchant *domain.Merchant, qr *domain.QRCode) string {
+	return domain.QRPayload{
+		Dynamic:      true,
+		MerchantID:   merchant.ID,
+		MerchantName: merchant.Name,
+		Amount:       qr.Amount,
+		ReferenceID:  qr.ID,
+	}.Encode()
+}
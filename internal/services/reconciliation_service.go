@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type ReconciliationService struct {
+	reconciliationRepo domain.ReconciliationRepository
+	auditService       domain.AuditService
+}
+
+func NewReconciliationService(reconciliationRepo domain.ReconciliationRepository, auditService domain.AuditService) *ReconciliationService {
+	return &ReconciliationService{
+		reconciliationRepo: reconciliationRepo,
+		auditService:       auditService,
+	}
+}
+
+// Run compares every wallet with its ledger and looks for transfers stuck in pending, then
+// stores the report. It only reads balances, fixing them is left to back-office staff.
+func (s *ReconciliationService) Run(ctx context.Context, opts domain.ReconciliationOptions) (domain.ReconciliationRun, error) {
+	run := domain.ReconciliationRun{
+		StartedAt:  time.Now(),
+		StuckAfter: opts.StuckAfter.String(),
+	}
+
+	var err error
+	if run.WalletsChecked, err = s.reconciliationRepo.CountWallets(ctx); err != nil {
+		return domain.ReconciliationRun{}, err
+	}
+	if run.Mismatches, err = s.reconciliationRepo.GetBalanceMismatches(ctx); err != nil {
+		return domain.ReconciliationRun{}, err
+	}
+	if run.StuckTransfers, err = s.reconciliationRepo.GetStuckTransfers(ctx, run.StartedAt.Add(-opts.StuckAfter)); err != nil {
+		return domain.ReconciliationRun{}, err
+	}
+	run.FinishedAt = time.Now()
+
+	// the id is assigned up front so the tasks can point at the run, which is stored last
+	// because the report includes how many tasks were opened.
+	run.ID = uuid.New()
+	if opts.OpenRepairTasks {
+		if run.RepairTasksOpened, err = s.openRepairTasks(ctx, &run); err != nil {
+			return domain.ReconciliationRun{}, err
+		}
+	}
+	if err = s.reconciliationRepo.CreateRun(ctx, &run); err != nil {
+		return domain.ReconciliationRun{}, err
+	}
+	return run, nil
+}
+
+// openRepairTasks opens a task for every finding of run that isn't already being worked on,
+// so a drift that persists across runs is reported once.
+func (s *ReconciliationService) openRepairTasks(ctx context.Context, run *domain.ReconciliationRun) (int, error) {
+	var tasks []domain.RepairTask
+	for _, mismatch := range run.Mismatches {
+		tasks = append(tasks, domain.RepairTask{
+			Kind:        domain.RepairTaskBalanceDrift,
+			SubjectType: mismatch.WalletType,
+			SubjectID:   mismatch.WalletID,
+			Details: map[string]any{
+				"balance":        mismatch.Balance,
+				"ledger_balance": mismatch.LedgerBalance,
+				"drift":          mismatch.Drift,
+			},
+		})
+	}
+	for _, transfer := range run.StuckTransfers {
+		tasks = append(tasks, domain.RepairTask{
+			Kind:        domain.RepairTaskStuckTransfer,
+			SubjectType: "transaction",
+			SubjectID:   transfer.TransactionID,
+			Details: map[string]any{
+				"user_id":    transfer.UserID.String(),
+				"amount":     transfer.Amount,
+				"created_at": transfer.CreatedAt,
+			},
+		})
+	}
+
+	opened := 0
+	for _, task := range tasks {
+		exists, err := s.reconciliationRepo.HasOpenRepairTask(ctx, task.Kind, task.SubjectID)
+		if err != nil {
+			return opened, err
+		}
+		if exists {
+			continue
+		}
+		task.RunID = run.ID
+		task.Status = domain.RepairTaskStatusOpen
+		if err = s.reconciliationRepo.CreateRepairTask(ctx, &task); err != nil {
+			return opened, err
+		}
+		opened++
+	}
+	return opened, nil
+}
+
+func (s *ReconciliationService) GetRuns(ctx context.Context, limit, offset int) ([]*domain.ReconciliationRun, error) {
+	return s.reconciliationRepo.GetRuns(ctx, limit, offset)
+}
+
+func (s *ReconciliationService) GetRun(ctx context.Context, runID uuid.UUID) (*domain.ReconciliationRun, error) {
+	run, err := s.reconciliationRepo.GetRunByID(ctx, runID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrRunNotFound
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+func (s *ReconciliationService) GetRepairTasks(ctx context.Context, status string, limit, offset int) ([]*domain.RepairTask, error) {
+	if status != "" && status != domain.RepairTaskStatusOpen && status != domain.RepairTaskStatusResolved {
+		return nil, domain.ErrInvalidRepairStatus
+	}
+	return s.reconciliationRepo.GetRepairTasks(ctx, status, limit, offset)
+}
+
+// ResolveRepairTask closes a task once staff fixed its finding, resolution says how.
+func (s *ReconciliationService) ResolveRepairTask(ctx context.Context, actorID, taskID uuid.UUID, resolution string) (domain.RepairTask, error) {
+	resolution = strings.TrimSpace(resolution)
+	if resolution == "" {
+		return domain.RepairTask{}, domain.ErrReasonRequired
+	}
+
+	task, err := s.reconciliationRepo.GetRepairTaskByID(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrRepairTaskNotFound
+		}
+		return domain.RepairTask{}, err
+	}
+	if task.Status == domain.RepairTaskStatusResolved {
+		return domain.RepairTask{}, domain.ErrRepairTaskResolved
+	}
+
+	now := time.Now()
+	task.Status = domain.RepairTaskStatusResolved
+	task.Resolution = resolution
+	task.ResolvedBy = &actorID
+	task.ResolvedAt = &now
+	if err = s.reconciliationRepo.UpdateRepairTask(ctx, task); err != nil {
+		return domain.RepairTask{}, err
+	}
+
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   domain.AuditRepairTaskResolved,
+		ActorID:     actorID,
+		SubjectType: "repair_task",
+		SubjectID:   task.ID.String(),
+		Before:      map[string]any{"status": domain.RepairTaskStatusOpen},
+		After: map[string]any{
+			"status":     task.Status,
+			"kind":       task.Kind,
+			"subject_id": task.SubjectID.String(),
+			"resolution": resolution,
+		},
+	})
+	return *task, nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type ScheduledTransferService struct {
+	scheduleRepo domain.ScheduledTransferRepository
+	transService domain.TransactionService
+}
+
+func NewScheduledTransferService(scheduleRepo domain.ScheduledTransferRepository, transService domain.TransactionService) *ScheduledTransferService {
+	return &ScheduledTransferService{
+		scheduleRepo: scheduleRepo,
+		transService: transService,
+	}
+}
+
+func (s *ScheduledTransferService) CreateSchedule(ctx context.Context, schedule domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	schedule.TargetPhoneNumber = domain.NormalizePhoneNumber(schedule.TargetPhoneNumber)
+	if err := s.validateSchedule(ctx, schedule); err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+
+	schedule.NextRunAt = schedule.StartAt
+	schedule.Status = domain.ScheduleStatusActive
+	err := s.scheduleRepo.CreateSchedule(ctx, &schedule)
+	if err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduledTransferService) GetSchedules(ctx context.Context, userID uuid.UUID) ([]*domain.ScheduledTransfer, error) {
+	return s.scheduleRepo.GetSchedulesByUserID(ctx, userID)
+}
+
+func (s *ScheduledTransferService) GetSchedule(ctx context.Context, userID, scheduleID uuid.UUID) (*domain.ScheduledTransfer, error) {
+	schedule, err := s.scheduleRepo.GetScheduleByID(ctx, scheduleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	// other users' schedules are reported as missing so their IDs can't be probed.
+	if schedule.UserID != userID {
+		return nil, domain.ErrScheduleNotFound
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduledTransferService) UpdateSchedule(ctx context.Context, userID uuid.UUID, update domain.ScheduledTransfer) (domain.ScheduledTransfer, error) {
+	schedule, err := s.GetSchedule(ctx, userID, update.ID)
+	if err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+	if schedule.Status != domain.ScheduleStatusActive {
+		return domain.ScheduledTransfer{}, domain.ErrScheduleNotEditable
+	}
+
+	update.UserID = userID
+	update.TargetPhoneNumber = domain.NormalizePhoneNumber(update.TargetPhoneNumber)
+	if err = s.validateSchedule(ctx, update); err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+
+	schedule.TargetPhoneNumber = update.TargetPhoneNumber
+	schedule.Amount = update.Amount
+	schedule.Remark = update.Remark
+	schedule.Frequency = update.Frequency
+	schedule.StartAt = update.StartAt
+	schedule.EndAt = update.EndAt
+	schedule.NextRunAt = update.StartAt
+	schedule.UpdatedAt = time.Now()
+	err = s.scheduleRepo.UpdateSchedule(ctx, schedule)
+	if err != nil {
+		return domain.ScheduledTransfer{}, err
+	}
+
+	return *schedule, nil
+}
+
+// CancelSchedule stops future runs but keeps the schedule and its run history.
+func (s *ScheduledTransferService) CancelSchedule(ctx context.Context, userID, scheduleID uuid.UUID) error {
+	schedule, err := s.GetSchedule(ctx, userID, scheduleID)
+	if err != nil {
+		return err
+	}
+	if schedule.Status != domain.ScheduleStatusActive {
+		return domain.ErrScheduleNotEditable
+	}
+
+	schedule.Status = domain.ScheduleStatusCancelled
+	schedule.UpdatedAt = time.Now()
+	return s.scheduleRepo.UpdateSchedule(ctx, schedule)
+}
+
+func (s *ScheduledTransferService) GetScheduleRuns(ctx context.Context, userID, scheduleID uuid.UUID) ([]*domain.ScheduledTransferRun, error) {
+	if _, err := s.GetSchedule(ctx, userID, scheduleID); err != nil {
+		return nil, err
+	}
+
+	return s.scheduleRepo.GetRunsByScheduleID(ctx, scheduleID)
+}
+
+func (s *ScheduledTransferService) validateSchedule(ctx context.Context, schedule domain.ScheduledTransfer) error {
+	switch schedule.Frequency {
+	case domain.ScheduleFrequencyOnce, domain.ScheduleFrequencyDaily, domain.ScheduleFrequencyWeekly, domain.ScheduleFrequencyMonthly:
+	default:
+		return domain.ErrInvalidFrequency
+	}
+	if !schedule.StartAt.After(time.Now()) {
+		return domain.ErrScheduleInPast
+	}
+	if schedule.EndAt != nil && !schedule.EndAt.After(schedule.StartAt) {
+		return domain.ErrInvalidScheduleEnd
+	}
+
+	// run the same recipient checks a manual transfer would, so a schedule can't be
+	// created for a recipient that will be rejected on every run.
+	_, err := s.transService.InquiryTransfer(ctx, schedule.UserID, schedule.TargetPhoneNumber, schedule.Amount)
+	return err
+}
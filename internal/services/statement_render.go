@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"tahap2/internal/domain"
+	"tahap2/internal/pdf"
+	"time"
+)
+
+// statementHeader is what a statement shows above its lines.
+type statementHeader struct {
+	Name           string
+	PhoneNumber    string
+	From           time.Time
+	To             time.Time // exclusive
+	OpeningBalance int64
+	GeneratedAt    time.Time
+}
+
+// statementRenderer writes a statement in one format as its parts become available, so the
+// lines never have to be held in memory.
+type statementRenderer interface {
+	Begin(header statementHeader) error
+	Line(line *domain.Transaction, balance int64) error
+	End(totals []domain.StatementTotal, closingBalance int64) error
+}
+
+func newStatementRenderer(format string, w io.Writer, loc *time.Location) statementRenderer {
+	if format == domain.StatementFormatPDF {
+		return &pdfStatement{doc: pdf.NewDocument(w), loc: loc}
+	}
+	return &csvStatement{w: csv.NewWriter(w), loc: loc}
+}
+
+// csvStatement writes the header as label and value rows, then the lines as a table and the
+// totals after them.
+type csvStatement struct {
+	w   *csv.Writer
+	loc *time.Location
+}
+
+func (s *csvStatement) Begin(header statementHeader) error {
+	s.w.Write([]string{"Statement", header.Name, header.PhoneNumber})
+	s.w.Write([]string{"Period", header.From.Format(time.DateOnly), header.To.AddDate(0, 0, -1).Format(time.DateOnly)})
+	s.w.Write([]string{"Generated at", header.GeneratedAt.In(s.loc).Format(time.RFC3339)})
+	s.w.Write([]string{"Opening balance", strconv.FormatInt(header.OpeningBalance, 10)})
+	s.w.Write(nil)
+	return s.w.Write([]string{"date", "transaction_id", "kind", "type", "status", "remark", "amount", "fee", "change", "balance"})
+}
+
+func (s *csvStatement) Line(line *domain.Transaction, balance int64) error {
+	return s.w.Write([]string{
+		line.CreatedAt.In(s.loc).Format(time.RFC3339),
+		line.ID.String(),
+		line.Kind,
+		line.TransactionType,
+		line.Status,
+		line.Remark,
+		strconv.FormatInt(line.Amount, 10),
+		strconv.FormatInt(line.Fee, 10),
+		strconv.FormatInt(line.BalanceEffect(), 10),
+		strconv.FormatInt(balance, 10),
+	})
+}
+
+func (s *csvStatement) End(totals []domain.StatementTotal, closingBalance int64) error {
+	s.w.Write(nil)
+	s.w.Write([]string{"kind", "count", "in", "out"})
+	for _, total := range totals {
+		s.w.Write([]string{total.Kind, strconv.Itoa(total.Count), strconv.FormatInt(total.In, 10), strconv.FormatInt(total.Out, 10)})
+	}
+	s.w.Write(nil)
+	s.w.Write([]string{"Closing balance", strconv.FormatInt(closingBalance, 10)})
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// Layout of the PDF statement in points.
+const (
+	pdfMargin     = 40.0
+	pdfTop        = pdf.PageHeight - pdfMargin
+	pdfBottom     = 60.0
+	pdfLineHeight = 10.0
+	pdfTextSize   = 7.0
+	pdfRemarkLen  = 28
+)
+
+// pdfColumn is a column of the line table, amounts are aligned to the right edge.
+type pdfColumn struct {
+	title string
+	x     float64
+	right bool
+}
+
+var pdfColumns = []pdfColumn{
+	{"Date", pdfMargin, false},
+	{"Kind", 112, false},
+	{"Status", 158, false},
+	{"Remark", 192, false},
+	{"Amount", 375, true},
+	{"Fee", 420, true},
+	{"Change", 487, true},
+	{"Balance", pdf.PageWidth - pdfMargin, true},
+}
+
+type pdfStatement struct {
+	doc  *pdf.Document
+	loc  *time.Location
+	page int
+	y    float64
+}
+
+func (s *pdfStatement) Begin(header statementHeader) error {
+	s.newPage(false)
+	s.doc.Text(pdfMargin, s.y, pdf.HelveticaBold, 16, "Account statement")
+	s.y -= 24
+	for _, row := range [][2]string{
+		{"Name", header.Name},
+		{"Phone number", header.PhoneNumber},
+		{"Period", header.From.Format(time.DateOnly) + " to " + header.To.AddDate(0, 0, -1).Format(time.DateOnly)},
+		{"Generated at", header.GeneratedAt.In(s.loc).Format(time.DateTime)},
+		{"Opening balance", strconv.FormatInt(header.OpeningBalance, 10)},
+	} {
+		s.doc.Text(pdfMargin, s.y, pdf.HelveticaBold, 9, row[0])
+		s.doc.Text(pdfMargin+90, s.y, pdf.Helvetica, 9, row[1])
+		s.y -= 13
+	}
+	s.y -= 10
+	s.tableHeader()
+	return nil
+}
+
+func (s *pdfStatement) Line(line *domain.Transaction, balance int64) error {
+	if s.y < pdfBottom {
+		s.newPage(true)
+	}
+	s.row(pdf.Courier,
+		line.CreatedAt.In(s.loc).Format("2006-01-02 15:04"),
+		line.Kind,
+		line.Status,
+		truncate(line.Remark, pdfRemarkLen),
+		strconv.FormatInt(line.Amount, 10),
+		strconv.FormatInt(line.Fee, 10),
+		fmt.Sprintf("%+d", line.BalanceEffect()),
+		strconv.FormatInt(balance, 10),
+	)
+	return nil
+}
+
+func (s *pdfStatement) End(totals []domain.StatementTotal, closingBalance int64) error {
+	// the totals are kept on one page with the closing balance.
+	if s.y-float64(len(totals)+5)*pdfLineHeight < pdfBottom {
+		s.newPage(false)
+	}
+	s.y -= pdfLineHeight
+	s.doc.Text(pdfMargin, s.y, pdf.HelveticaBold, 10, "Totals by kind")
+	s.y -= pdfLineHeight + 4
+	for i, title := range []string{"Kind", "Count", "In", "Out"} {
+		s.totalCell(i, pdf.CourierBold, title)
+	}
+	s.y -= pdfLineHeight
+	for _, total := range totals {
+		if s.y < pdfBottom {
+			s.newPage(false)
+		}
+		s.totalCell(0, pdf.Courier, total.Kind)
+		s.totalCell(1, pdf.Courier, strconv.Itoa(total.Count))
+		s.totalCell(2, pdf.Courier, strconv.FormatInt(total.In, 10))
+		s.totalCell(3, pdf.Courier, strconv.FormatInt(total.Out, 10))
+		s.y -= pdfLineHeight
+	}
+	s.y -= pdfLineHeight
+	s.doc.Text(pdfMargin, s.y, pdf.HelveticaBold, 10, "Closing balance")
+	s.doc.Text(pdfMargin+90, s.y, pdf.HelveticaBold, 10, strconv.FormatInt(closingBalance, 10))
+	return s.doc.Close()
+}
+
+// totalCell draws the i-th column of the totals table, the numbers are right aligned.
+func (s *pdfStatement) totalCell(i int, font pdf.Font, text string) {
+	if i == 0 {
+		s.doc.Text(pdfMargin, s.y, font, pdfTextSize, text)
+		return
+	}
+	s.doc.TextRight(pdfMargin+100+float64(i)*90, s.y, font, pdfTextSize, text)
+}
+
+func (s *pdfStatement) newPage(withTable bool) {
+	s.doc.NewPage()
+	s.page++
+	s.doc.Text(pdfMargin, pdfMargin-10, pdf.Helvetica, 8, fmt.Sprintf("Page %d", s.page))
+	s.y = pdfTop
+	if withTable {
+		s.tableHeader()
+	}
+}
+
+func (s *pdfStatement) tableHeader() {
+	titles := make([]string, len(pdfColumns))
+	for i, column := range pdfColumns {
+		titles[i] = column.title
+	}
+	s.row(pdf.CourierBold, titles...)
+	s.doc.Line(pdfMargin, s.y+pdfLineHeight-3, pdf.PageWidth-pdfMargin, s.y+pdfLineHeight-3)
+	s.y -= 2
+}
+
+func (s *pdfStatement) row(font pdf.Font, cells ...string) {
+	for i, column := range pdfColumns {
+		if column.right {
+			s.doc.TextRight(column.x, s.y, font, pdfTextSize, cells[i])
+		} else {
+			s.doc.Text(column.x, s.y, font, pdfTextSize, cells[i])
+		}
+	}
+	s.y -= pdfLineHeight
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"sort"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type StatementService struct {
+	statementRepo domain.StatementRepository
+	userRepo      domain.UserRepository
+	blobStore     domain.BlobStore
+	logger        *slog.Logger
+	location      *time.Location
+}
+
+func NewStatementService(statementRepo domain.StatementRepository, userRepo domain.UserRepository, blobStore domain.BlobStore,
+	logger *slog.Logger) *StatementService {
+	return &StatementService{
+		statementRepo: statementRepo,
+		userRepo:      userRepo,
+		blobStore:     blobStore,
+		logger:        logger,
+		location:      time.Local,
+	}
+}
+
+// WriteStatement serves archived months from the blob store and renders the others from
+// the ledger while writing them.
+func (s *StatementService) WriteStatement(ctx context.Context, userID uuid.UUID, month time.Time, format string, w io.Writer) error {
+	if !domain.IsValidStatementFormat(format) {
+		return domain.ErrInvalidStatementFormat
+	}
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	monthKey := month.Format(domain.StatementMonthLayout)
+	if _, err = s.statementRepo.GetArchive(ctx, userID, monthKey); err == nil {
+		content, err := s.blobStore.Get(ctx, statementKey(userID, monthKey, format))
+		if err == nil {
+			defer content.Close()
+			_, err = io.Copy(w, content)
+			return err
+		}
+		s.logger.WarnContext(ctx, "archived statement is unreadable, rendering it again", "user_id", userID, "month", monthKey, "error", err)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	_, err = s.render(ctx, user, month, format, w)
+	return err
+}
+
+func (s *StatementService) ArchiveMonth(ctx context.Context, month time.Time, limit int) (int, error) {
+	from, to := month, month.AddDate(0, 1, 0)
+	if to.After(time.Now()) {
+		return 0, domain.ErrMonthNotEnded
+	}
+	monthKey := month.Format(domain.StatementMonthLayout)
+
+	userIDs, err := s.statementRepo.GetUnarchivedUserIDs(ctx, monthKey, from, to, limit)
+	if err != nil {
+		return 0, err
+	}
+	for i, userID := range userIDs {
+		if err = s.archive(ctx, userID, month); err != nil {
+			return i, fmt.Errorf("archive statement %s of %s: %w", monthKey, userID, err)
+		}
+	}
+	return len(userIDs), nil
+}
+
+// archive stores the statement of the user in every format, then records the archive so it
+// is served from the blob store from then on.
+func (s *StatementService) archive(ctx context.Context, userID uuid.UUID, month time.Time) error {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	monthKey := month.Format(domain.StatementMonthLayout)
+	var closingBalance int64
+	for _, format := range []string{domain.StatementFormatCSV, domain.StatementFormatPDF} {
+		pr, pw := io.Pipe()
+		go func() {
+			var err error
+			closingBalance, err = s.render(ctx, user, month, format, pw)
+			pw.CloseWithError(err)
+		}()
+		err = s.blobStore.Put(ctx, statementKey(userID, monthKey, format), pr)
+		// unblocks the renderer when Put gave up early.
+		pr.CloseWithError(err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.statementRepo.CreateArchive(ctx, &domain.StatementArchive{
+		UserID:         userID,
+		Month:          monthKey,
+		ClosingBalance: closingBalance,
+	})
+}
+
+// render rebuilds the month from the ledger, starting from the balance the lines before it
+// add up to, and returns the closing balance.
+func (s *StatementService) render(ctx context.Context, user *domain.User, month time.Time, format string, w io.Writer) (int64, error) {
+	from, to := month, month.AddDate(0, 1, 0)
+	opening, err := s.statementRepo.GetBalanceAt(ctx, user.ID, from)
+	if err != nil {
+		return 0, err
+	}
+
+	renderer := newStatementRenderer(format, w, s.location)
+	err = renderer.Begin(statementHeader{
+		Name:           user.FirstName + " " + user.LastName,
+		PhoneNumber:    user.PhoneNumber,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		GeneratedAt:    time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	balance := opening
+	totals := map[string]*domain.StatementTotal{}
+	err = s.statementRepo.StreamLines(ctx, user.ID, from, to, func(line *domain.Transaction) error {
+		effect := line.BalanceEffect()
+		balance += effect
+
+		total, ok := totals[line.Kind]
+		if !ok {
+			total = &domain.StatementTotal{Kind: line.Kind}
+			totals[line.Kind] = total
+		}
+		total.Count++
+		if effect > 0 {
+			total.In += effect
+		} else {
+			total.Out -= effect
+		}
+		return renderer.Line(line, balance)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sorted := make([]domain.StatementTotal, 0, len(totals))
+	for _, total := range totals {
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Kind < sorted[j].Kind })
+	return balance, renderer.End(sorted, balance)
+}
+
+func (s *StatementService) getUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func statementKey(userID uuid.UUID, month, format string) string {
+	return fmt.Sprintf("statements/%s/%s.%s", userID, month, format)
+}
@@ -5,148 +5,485 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
+	"strings"
 	"tahap2/internal/domain"
+	"tahap2/internal/metrics"
+	"tahap2/internal/tracing"
 	"tahap2/internal/workers"
+	"time"
 )
 
 type TransactionService struct {
-	userRepo        domain.UserRepository
-	transactionRepo domain.TransactionRepository
-	eventBus        *workers.EventBus
+	transactor        domain.Transactor
+	userRepo          domain.UserRepository
+	transactionRepo   domain.TransactionRepository
+	systemAccountRepo domain.SystemAccountRepository
+	merchantRepo      domain.MerchantRepository
+	feeCalculator     domain.FeeCalculator
+	limitService      domain.LimitService
+	auditService      domain.AuditService
+	webhookService    domain.WebhookService
+	eventBus          *workers.EventBus
+	// requireVerifiedRecipient rejects transfers to users that haven't passed KYC.
+	requireVerifiedRecipient bool
 }
 
-func NewTransactionService(userRepo domain.UserRepository, transactionRepo domain.TransactionRepository, eventBus *workers.EventBus) *TransactionService {
+func NewTransactionService(transactor domain.Transactor, userRepo domain.UserRepository, transactionRepo domain.TransactionRepository,
+	systemAccountRepo domain.SystemAccountRepository, merchantRepo domain.MerchantRepository, feeCalculator domain.FeeCalculator, limitService domain.LimitService,
+	auditService domain.AuditService, webhookService domain.WebhookService, eventBus *workers.EventBus, requireVerifiedRecipient bool) *TransactionService {
 	return &TransactionService{
-		userRepo:        userRepo,
-		transactionRepo: transactionRepo,
-		eventBus:        eventBus,
+		transactor:        transactor,
+		userRepo:          userRepo,
+		transactionRepo:   transactionRepo,
+		systemAccountRepo: systemAccountRepo,
+		merchantRepo:      merchantRepo,
+		feeCalculator:     feeCalculator,
+		limitService:      limitService,
+		auditService:      auditService,
+		webhookService:    webhookService,
+		eventBus:          eventBus,
+
+		requireVerifiedRecipient: requireVerifiedRecipient,
 	}
 }
 
-func (s *TransactionService) ProcessTopUp(userID uuid.UUID, amount int64) (domain.Transaction, error) {
-	user, err := s.userRepo.GetUserByID(userID)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = errors.New("user not found")
+// CreditTopUp credits a topup that has been paid through a payment gateway. It joins the
+// transaction in ctx, so the caller can mark the payment intent as paid in the same commit.
+// Recording the audit event is left to the caller, after that commit.
+func (s *TransactionService) CreditTopUp(ctx context.Context, userID uuid.UUID, amount int64, remark string) (_ domain.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.CreditTopUp", userAttr(userID), amountAttr(amount))
+	defer func() { tracing.End(span, err) }()
+
+	var newTransaction domain.Transaction
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if user.IsClosed() {
+			return domain.ErrAccountClosed
+		}
+		if !domain.TierAllows(user.Tier, domain.FeatureTopUp) {
+			return domain.ErrFeatureNotAllowed
+		}
+
+		// the topup fee is taken out of the topped up amount.
+		fee, err := s.feeCalculator.CalculateFee(ctx, domain.TransactionKindTopUp, user, amount)
+		if err != nil {
+			return fmt.Errorf("error calculating fee: %w", err)
+		}
+		if fee >= amount {
+			return domain.ErrFeeExceedsAmount
+		}
+		if err = s.limitService.CheckTopUp(ctx, user, amount); err != nil {
+			return err
+		}
+
+		balBefore := user.Balance
+		user.Balance, err = domain.AddAmounts(user.Balance, amount-fee)
+		if err != nil {
+			return err
+		}
+		balAfter := user.Balance
+		err = s.userRepo.UpdateUser(ctx, user)
+		if err != nil {
+			return fmt.Errorf("error updating user: %w", err)
+		}
+
+		newTransaction = domain.Transaction{
+			Status:          domain.TransactionStatusSuccess, // the gateway already confirmed the payment
+			UserID:          userID,
+			TransactionType: domain.TransactionTypeDebit,
+			Kind:            domain.TransactionKindTopUp,
+			Amount:          amount,
+			Fee:             fee,
+			Remark:          remark,
+			BalanceBefore:   balBefore,
+			BalanceAfter:    balAfter,
+		}
+		err = s.transactionRepo.CreateTransaction(ctx, &newTransaction)
+		if err != nil {
+			return err
 		}
+
+		return s.postFee(ctx, newTransaction)
+	})
+	if err != nil {
 		return domain.Transaction{}, err
 	}
 
-	balBefore := user.Balance
-	user.Balance += amount
-	balAfter := user.Balance
-	err = s.userRepo.UpdateUser(context.TODO(), user)
+	return newTransaction, nil
+}
+
+func (s *TransactionService) ProcessPayment(ctx context.Context, userID, merchantID uuid.UUID, amount int64, remarks string) (_ domain.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.ProcessPayment", userAttr(userID), amountAttr(amount),
+		attribute.String("merchant.id", merchantID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	newTransaction, err := s.PayMerchant(ctx, userID, merchantID, amount, remarks)
 	if err != nil {
-		return domain.Transaction{}, fmt.Errorf("error updating user: %w", err)
+		return domain.Transaction{}, err
 	}
 
-	newTransaction := domain.Transaction{
-		Status:          "success", // direct success status since it doesn't run on background
-		UserID:          userID,
-		TransactionType: "DEBIT",
-		Amount:          amount,
-		Remark:          "topup",
-		BalanceBefore:   balBefore,
-		BalanceAfter:    balAfter,
+	s.PaymentCommitted(ctx, newTransaction)
+	return newTransaction, nil
+}
+
+// PayMerchant makes the payment of ProcessPayment. It joins the transaction in ctx, so the
+// caller can commit the payment with changes of its own, and leaves recording it to the
+// caller's PaymentCommitted after that commit.
+func (s *TransactionService) PayMerchant(ctx context.Context, userID, merchantID uuid.UUID, amount int64, remarks string) (_ domain.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.PayMerchant", userAttr(userID), amountAttr(amount),
+		attribute.String("merchant.id", merchantID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	var newTransaction domain.Transaction
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if err = user.CanSendMoney(); err != nil {
+			return err
+		}
+		if !domain.TierAllows(user.Tier, domain.FeaturePayment) {
+			return domain.ErrFeatureNotAllowed
+		}
+		// the user is locked before the merchant, refunds take the locks in the same order.
+		merchant, err := s.merchantRepo.GetMerchantByIDForUpdate(ctx, merchantID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrMerchantNotFound
+			}
+			return err
+		}
+		if !merchant.IsActive() {
+			return domain.ErrMerchantInactive
+		}
+
+		fee, err := s.feeCalculator.CalculateFee(ctx, domain.TransactionKindPayment, user, amount)
+		if err != nil {
+			return fmt.Errorf("error calculating fee: %w", err)
+		}
+		total, err := domain.AddAmounts(amount, fee)
+		if err != nil {
+			return err
+		}
+		if total > user.Balance {
+			return domain.ErrInsufficientBalance
+		}
+		if err = s.limitService.CheckOutgoing(ctx, user, amount); err != nil {
+			return err
+		}
+
+		balBefore := user.Balance
+		user.Balance -= total
+		balAfter := user.Balance
+		err = s.userRepo.UpdateUser(ctx, user)
+		if err != nil {
+			return fmt.Errorf("error updating user: %w", err)
+		}
+
+		newTransaction = domain.Transaction{
+			Status:          domain.TransactionStatusSuccess, // direct success status since it doesn't run on background
+			UserID:          userID,
+			TransactionType: domain.TransactionTypeCredit,
+			Kind:            domain.TransactionKindPayment,
+			Amount:          amount,
+			Fee:             fee,
+			Remark:          remarks,
+			BalanceBefore:   balBefore,
+			BalanceAfter:    balAfter,
+			MerchantID:      &merchant.ID,
+		}
+		err = s.transactionRepo.CreateTransaction(ctx, &newTransaction)
+		if err != nil {
+			return err
+		}
+		if err = s.settleToMerchant(ctx, merchant, newTransaction); err != nil {
+			return err
+		}
+
+		return s.postFee(ctx, newTransaction)
+	})
+	if err != nil {
+		return domain.Transaction{}, err
 	}
-	err = s.transactionRepo.CreateTransaction(context.TODO(), &newTransaction)
+
+	return newTransaction, nil
+}
+
+// PaymentCommitted audits, counts and announces a committed payment. Called before the
+// commit, the audit chain lock would be held while money is moved and a payment rolled back
+// would still be reported.
+func (s *TransactionService) PaymentCommitted(ctx context.Context, payment domain.Transaction) {
+	s.recordMoneyMovement(ctx, domain.AuditPayment, payment)
+	metrics.RecordMoneyMovement(domain.TransactionKindPayment, payment.Amount)
+	s.webhookService.Publish(ctx, domain.WebhookEvent{
+		Type:       domain.WebhookEventTransactionSucceeded,
+		MerchantID: payment.MerchantID,
+		Data:       domain.NewWebhookTransaction(payment),
+	})
+}
+
+func (s *TransactionService) ProcessTransfer(ctx context.Context, userID uuid.UUID, targetPhoneNumber string, amount int64, remarks string, transactionID uuid.UUID) (_ domain.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.ProcessTransfer", userAttr(userID), amountAttr(amount))
+	defer func() { tracing.End(span, err) }()
+
+	var (
+		newTransaction domain.Transaction
+		target         *domain.User
+	)
+	// both rows stay locked until the pending transfer is stored, so concurrent transfers
+	// can't both pass the balance and limit checks of the sender, nor the maximum balance
+	// of the recipient.
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// the recipient is looked up first, so both rows are locked in the order the
+		// worker locks them.
+		resolved, err := s.resolveTransferTarget(ctx, userID, targetPhoneNumber)
+		if err != nil {
+			return err
+		}
+		var user *domain.User
+		user, target, err = workers.LockTransferParties(ctx, s.userRepo, userID, resolved.ID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if err = user.CanSendMoney(); err != nil {
+			return err
+		}
+		if !domain.TierAllows(user.Tier, domain.FeatureTransfer) {
+			return domain.ErrFeatureNotAllowed
+		}
+		if target.IsClosed() {
+			return domain.ErrRecipientClosed
+		}
+
+		fee, err := s.feeCalculator.CalculateFee(ctx, domain.TransactionKindTransfer, user, amount)
+		if err != nil {
+			return fmt.Errorf("error calculating fee: %w", err)
+		}
+		total, err := domain.AddAmounts(amount, fee)
+		if err != nil {
+			return err
+		}
+		if total > user.Balance {
+			return domain.ErrInsufficientBalance
+		}
+		if err = s.limitService.CheckOutgoing(ctx, user, amount); err != nil {
+			return err
+		}
+		// reject up front when the recipient can't hold the money. Transfers to it that are
+		// still pending aren't counted, the worker checks again before anything is debited.
+		if err = s.limitService.CheckIncoming(ctx, target, amount); err != nil {
+			return err
+		}
+
+		balBefore := user.Balance
+		user.Balance -= total
+		balAfter := user.Balance
+
+		newTransaction = domain.Transaction{
+			ID:              transactionID,
+			Status:          domain.TransactionStatusPending, // status will be updated in task queue
+			UserID:          userID,
+			TransactionType: domain.TransactionTypeCredit,
+			Kind:            domain.TransactionKindTransfer,
+			Amount:          amount,
+			Fee:             fee,
+			Remark:          remarks,
+			BalanceBefore:   balBefore,
+			BalanceAfter:    balAfter,
+		}
+		return s.transactionRepo.CreateTransaction(ctx, &newTransaction)
+	})
 	if err != nil {
 		return domain.Transaction{}, err
 	}
 
+	s.recordMoneyMovement(ctx, domain.AuditTransferCreated, newTransaction)
+
+	// publish transfer transaction to be process in background.
+	go s.eventBus.Publish(workers.EventTypeTransfer, workers.TransferParam{
+		Meta:         workers.NewEventMeta(ctx),
+		TransferInfo: newTransaction,
+		TargetID:     target.ID,
+	})
+
 	return newTransaction, nil
 }
 
-func (s *TransactionService) ProcessPayment(userID uuid.UUID, amount int64, remarks string) (domain.Transaction, error) {
-	user, err := s.userRepo.GetUserByID(userID)
+// InquiryTransfer resolves the recipient of a transfer and returns what the sender
+// will be charged, without moving any money.
+func (s *TransactionService) InquiryTransfer(ctx context.Context, userID uuid.UUID, targetPhoneNumber string, amount int64) (_ domain.TransferInquiry, err error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.InquiryTransfer", userAttr(userID), amountAttr(amount))
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = errors.New("user not found")
+			err = domain.ErrUserNotFound
 		}
-		return domain.Transaction{}, err
+		return domain.TransferInquiry{}, err
+	}
+	if err = user.CanSendMoney(); err != nil {
+		return domain.TransferInquiry{}, err
 	}
-	if amount > user.Balance {
-		return domain.Transaction{}, errors.New("insufficient balance")
+	if !domain.TierAllows(user.Tier, domain.FeatureTransfer) {
+		return domain.TransferInquiry{}, domain.ErrFeatureNotAllowed
 	}
 
-	balBefore := user.Balance
-	user.Balance -= amount
-	balAfter := user.Balance
-	err = s.userRepo.UpdateUser(context.TODO(), user)
+	target, err := s.resolveTransferTarget(ctx, userID, targetPhoneNumber)
 	if err != nil {
-		return domain.Transaction{}, fmt.Errorf("error updating user: %w", err)
+		return domain.TransferInquiry{}, err
 	}
 
-	newTransaction := domain.Transaction{
-		Status:          "success", // direct success status since it doesn't run on background
-		UserID:          userID,
-		TransactionType: "CREDIT",
-		Amount:          amount,
-		Remark:          remarks,
-		BalanceBefore:   balBefore,
-		BalanceAfter:    balAfter,
+	fee, err := s.feeCalculator.CalculateFee(ctx, domain.TransactionKindTransfer, user, amount)
+	if err != nil {
+		return domain.TransferInquiry{}, fmt.Errorf("error calculating fee: %w", err)
 	}
-	err = s.transactionRepo.CreateTransaction(context.TODO(), &newTransaction)
+	total, err := domain.AddAmounts(amount, fee)
 	if err != nil {
-		return domain.Transaction{}, err
+		return domain.TransferInquiry{}, err
 	}
 
-	return newTransaction, nil
+	return domain.TransferInquiry{
+		RecipientName:        maskName(target.FirstName + " " + target.LastName),
+		RecipientPhoneNumber: target.PhoneNumber,
+		Amount:               amount,
+		Fee:                  fee,
+		Total:                total,
+	}, nil
 }
 
-func (s *TransactionService) ProcessTransfer(userID, target uuid.UUID, amount int64, remarks string) (domain.Transaction, error) {
-	user, err := s.userRepo.GetUserByID(userID)
+// resolveTransferTarget looks up the recipient by phone number and rejects transfers to
+// the sender itself, to closed accounts and, when required, to accounts not verified yet.
+func (s *TransactionService) resolveTransferTarget(ctx context.Context, userID uuid.UUID, phoneNumber string) (*domain.User, error) {
+	target, err := s.userRepo.GetUserByPhoneNumber(ctx, domain.NormalizePhoneNumber(phoneNumber))
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = errors.New("user not found")
-		}
-		return domain.Transaction{}, err
+		return nil, err
+	}
+	if target.ID == uuid.Nil {
+		return nil, domain.ErrTargetUserNotFound
 	}
-	if amount > user.Balance {
-		return domain.Transaction{}, errors.New("insufficient balance")
+	if target.ID == userID {
+		return nil, domain.ErrSelfTransfer
 	}
+	if target.IsClosed() {
+		return nil, domain.ErrRecipientClosed
+	}
+	if s.requireVerifiedRecipient && !target.IsVerified() {
+		return nil, domain.ErrRecipientUnverified
+	}
+
+	return target, nil
+}
 
-	_, err = s.userRepo.GetUserByID(target)
+func (s *TransactionService) GetAllTransactions(ctx context.Context, userID uuid.UUID) (_ []*domain.Transaction, err error) {
+	ctx, span := tracing.Start(ctx, "TransactionService.GetAllTransactions", userAttr(userID))
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			err = errors.New("user target not found")
+			err = domain.ErrUserNotFound
 		}
-		return domain.Transaction{}, err
+		return nil, err
+	}
+	if user.IsClosed() {
+		return nil, domain.ErrAccountClosed
+	}
+
+	transactions, err := s.transactionRepo.GetTransactionsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	balBefore := user.Balance
-	user.Balance -= amount
-	balAfter := user.Balance
+	return transactions, nil
+}
+
+// settleToMerchant credits the amount of payment to the merchant's settlement wallet and
+// records it on the merchant's ledger.
+func (s *TransactionService) settleToMerchant(ctx context.Context, merchant *domain.Merchant, payment domain.Transaction) error {
+	balBefore := merchant.Balance
+	balAfter, err := domain.AddAmounts(merchant.Balance, payment.Amount)
+	if err != nil {
+		return err
+	}
+	merchant.Balance = balAfter
+	merchant.UpdatedAt = time.Now()
+	if err = s.merchantRepo.UpdateMerchant(ctx, merchant); err != nil {
+		return fmt.Errorf("error updating merchant: %w", err)
+	}
 
-	newTransaction := domain.Transaction{
-		Status:          "pending", // status will be updated in task queue
-		UserID:          userID,
-		TransactionType: "CREDIT",
-		Amount:          amount,
-		Remark:          remarks,
+	merchantLine := domain.Transaction{
+		Status:          domain.TransactionStatusSuccess,
+		UserID:          merchant.ID,
+		TransactionType: domain.TransactionTypeDebit,
+		Kind:            domain.TransactionKindPayment,
+		Amount:          payment.Amount,
+		Remark:          payment.Remark,
 		BalanceBefore:   balBefore,
 		BalanceAfter:    balAfter,
+		ReferenceID:     &payment.ID,
+		MerchantID:      &merchant.ID,
+	}
+	return s.transactionRepo.CreateTransaction(ctx, &merchantLine)
+}
+
+// postFee credits the fee of trans to the revenue account and records it as its own line.
+func (s *TransactionService) postFee(ctx context.Context, trans domain.Transaction) error {
+	if trans.Fee == 0 {
+		return nil
 	}
-	err = s.transactionRepo.CreateTransaction(context.TODO(), &newTransaction)
+
+	revenue, err := s.systemAccountRepo.CreditSystemAccount(ctx, domain.SystemAccountFeeRevenue, trans.Fee)
 	if err != nil {
-		return domain.Transaction{}, err
+		return fmt.Errorf("error posting fee: %w", err)
 	}
+	feeLine := domain.NewFeeLine(revenue, trans)
+	return s.transactionRepo.CreateTransaction(ctx, &feeLine)
+}
 
-	// publish transfer transaction to be process in background.
-	go s.eventBus.Publish(workers.EventTypeTransfer, workers.TransferParam{
-		TransferInfo: newTransaction,
-		TargetID:     target,
+func (s *TransactionService) recordMoneyMovement(ctx context.Context, eventType string, trans domain.Transaction) {
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   eventType,
+		ActorID:     trans.UserID,
+		SubjectType: "transaction",
+		SubjectID:   trans.ID.String(),
+		After:       trans.AuditState(),
 	})
+}
 
-	return newTransaction, nil
+func userAttr(userID uuid.UUID) attribute.KeyValue {
+	return attribute.String("user.id", userID.String())
 }
 
-func (s *TransactionService) GetAllTransactions(userID uuid.UUID) ([]*domain.Transaction, error) {
-	transactions, err := s.transactionRepo.GetTransactionsByUserID(context.TODO(), userID)
-	if err != nil {
-		return nil, err
-	}
+func amountAttr(amount int64) attribute.KeyValue {
+	return attribute.Int64("transaction.amount", amount)
+}
 
-	return transactions, nil
+// maskName hides all but the first and last letter of every word, e.g. "John Doe" becomes "J**n D*e".
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		runes := []rune(word)
+		if len(runes) <= 2 {
+			words[i] = string(runes[0]) + strings.Repeat("*", len(runes)-1)
+			continue
+		}
+		words[i] = string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+	}
+	return strings.Join(words, " ")
 }
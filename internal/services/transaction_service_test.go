@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"tahap2/internal/domain"
+	"tahap2/internal/handlers"
+	"tahap2/internal/middlewares"
+	"tahap2/internal/tracing"
+	"tahap2/internal/validation"
+	"tahap2/internal/workers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestTransferSpanTree follows a transfer from the request to the worker settling it, the
+// worker's span has to join the request's trace through the event published for it.
+func TestTransferSpanTree(t *testing.T) {
+	exporter := tracing.SetupInMemory()
+	store := newMemStore()
+	sender := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Balance: 100_000, Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	recipient := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000002", Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	store.users[sender.ID], store.users[recipient.ID] = sender, recipient
+
+	transactor, eventBus := &memTransactor{}, workers.NewEventBus()
+	userRepo, transactionRepo, systemAccountRepo := &memUserRepo{store: store}, &memTransactionRepo{store: store}, &memSystemAccountRepo{store: store}
+	auditService := &memAuditService{store: store}
+	service := NewTransactionService(transactor, userRepo, transactionRepo, systemAccountRepo, nil, flatFee(1000), noLimits{},
+		auditService, noWebhooks{}, eventBus, false)
+	worker := workers.NewTransactionWorker(eventBus, transactor, userRepo, transactionRepo, systemAccountRepo, noLimits{},
+		auditService, noWebhooks{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.StartWorker(ctx)
+	// the worker beats once it has subscribed, a transfer published before would be lost.
+	waitFor(t, func() bool { return worker.Check()(ctx) == nil })
+
+	e := echo.New()
+	e.Validator = validation.New()
+	e.Use(otelecho.Middleware(tracing.ServiceName))
+	authenticated := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(middlewares.UserIDKey, sender.ID)
+			return next(c)
+		}
+	}
+	e.POST("/api/v1/transfer", handlers.NewTransactionHandler(service).TransferHandler, authenticated)
+
+	body := `{"phone_number": "081200000002", "amount": 25000, "remarks": "lunch"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transfer", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	var spans tracetest.SpanStubs
+	waitFor(t, func() bool {
+		spans = exporter.GetSpans()
+		return findSpan(spans, "TransactionWorker.processTransfer") != nil
+	})
+	var server *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	if server == nil {
+		t.Fatalf("no server span in %v", spanNames(spans))
+	}
+	serviceSpan := findSpan(spans, "TransactionService.ProcessTransfer")
+	if serviceSpan == nil {
+		t.Fatalf("no service span in %v", spanNames(spans))
+	}
+	workerSpan := findSpan(spans, "TransactionWorker.processTransfer")
+
+	if serviceSpan.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("service span's parent is %s, want the server span %s", serviceSpan.Parent.SpanID(), server.SpanContext.SpanID())
+	}
+	if workerSpan.Parent.SpanID() != serviceSpan.SpanContext.SpanID() {
+		t.Errorf("worker span's parent is %s, want the service span %s", workerSpan.Parent.SpanID(), serviceSpan.SpanContext.SpanID())
+	}
+	if workerSpan.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Errorf("worker span is in trace %s, want %s", workerSpan.SpanContext.TraceID(), server.SpanContext.TraceID())
+	}
+	if !workerSpan.Parent.IsRemote() {
+		t.Error("worker span's parent should come from the event, not the request's context")
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) *tracetest.SpanStub {
+	for i := range spans {
+		if spans[i].Name == name {
+			return &spans[i]
+		}
+	}
+	return nil
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name
+	}
+	return names
+}
+
+// TestTransferRecordsSettledBalances tops the sender up between the request and the worker,
+// the sender's line has to show the wallet the money was taken from when it settled.
+func TestTransferRecordsSettledBalances(t *testing.T) {
+	store := newMemStore()
+	sender := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Balance: 100_000, Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	recipient := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000002", Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	store.users[sender.ID], store.users[recipient.ID] = sender, recipient
+
+	transactor, eventBus := &memTransactor{}, workers.NewEventBus()
+	userRepo, transactionRepo, systemAccountRepo := &memUserRepo{store: store}, &memTransactionRepo{store: store}, &memSystemAccountRepo{store: store}
+	auditService := &memAuditService{store: store}
+	service := NewTransactionService(transactor, userRepo, transactionRepo, systemAccountRepo, nil, flatFee(1000), noLimits{},
+		auditService, noWebhooks{}, eventBus, false)
+	worker := workers.NewTransactionWorker(eventBus, transactor, userRepo, transactionRepo, systemAccountRepo, noLimits{},
+		auditService, noWebhooks{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// nothing listens yet, the transfer stays pending.
+	transfer, err := service.ProcessTransfer(ctx, sender.ID, recipient.PhoneNumber, 25_000, "lunch", uuid.Nil)
+	if err != nil {
+		t.Fatalf("ProcessTransfer: %v", err)
+	}
+	if transfer.BalanceBefore != 100_000 || transfer.BalanceAfter != 74_000 {
+		t.Fatalf("requested balances %d → %d, want 100000 → 74000", transfer.BalanceBefore, transfer.BalanceAfter)
+	}
+	topUp := store.users[sender.ID]
+	topUp.Balance += 50_000
+	store.users[sender.ID] = topUp
+
+	go worker.StartWorker(ctx)
+	waitFor(t, func() bool { return worker.Check()(ctx) == nil })
+	eventBus.Publish(workers.EventTypeTransfer, workers.TransferParam{TransferInfo: transfer, TargetID: recipient.ID})
+
+	var settled *domain.Transaction
+	waitFor(t, func() bool {
+		settled, err = transactionRepo.GetTransactionByID(ctx, transfer.ID)
+		return err == nil && settled.Status == domain.TransactionStatusSuccess
+	})
+	if settled.BalanceBefore != 150_000 || settled.BalanceAfter != 124_000 {
+		t.Errorf("settled balances %d → %d, want 150000 → 124000", settled.BalanceBefore, settled.BalanceAfter)
+	}
+	if got, _ := userRepo.GetUserByID(ctx, sender.ID); got.Balance != settled.BalanceAfter {
+		t.Errorf("sender balance %d, want the line's %d", got.Balance, settled.BalanceAfter)
+	}
+}
+
+// newTransferService returns a transaction service charging a fee of 1000 whose transfers
+// nobody settles.
+func newTransferService(store *memStore, requireVerifiedRecipient bool) *TransactionService {
+	return NewTransactionService(&memTransactor{}, &memUserRepo{store: store}, &memTransactionRepo{store: store},
+		&memSystemAccountRepo{store: store}, nil, flatFee(1000), noLimits{}, &memAuditService{store: store}, noWebhooks{},
+		workers.NewEventBus(), requireVerifiedRecipient)
+}
+
+func TestTransferByPhoneNumber(t *testing.T) {
+	sender := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Balance: 100_000, Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	recipient := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000002", FirstName: "Budi", LastName: "Santoso",
+		Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	unverified := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000003", FirstName: "Sri", Status: domain.UserStatusActive}
+	closed := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000004", Tier: domain.UserTierBasic, Status: domain.UserStatusClosed}
+
+	tests := []struct {
+		name                     string
+		phoneNumber              string
+		requireVerifiedRecipient bool
+		wantRecipient            domain.User
+		wantErr                  error
+	}{
+		{name: "local number", phoneNumber: "081200000002", wantRecipient: recipient},
+		{name: "formatted number", phoneNumber: "+62 812-0000-0002", wantRecipient: recipient},
+		{name: "without the plus", phoneNumber: "6281200000002", wantRecipient: recipient},
+		{name: "unknown number", phoneNumber: "081299999999", wantErr: domain.ErrTargetUserNotFound},
+		{name: "own number", phoneNumber: "081200000001", wantErr: domain.ErrSelfTransfer},
+		{name: "closed recipient", phoneNumber: "081200000004", wantErr: domain.ErrRecipientClosed},
+		{name: "unverified recipient", phoneNumber: "081200000003", wantRecipient: unverified},
+		{name: "unverified recipient refused", phoneNumber: "081200000003", requireVerifiedRecipient: true, wantErr: domain.ErrRecipientUnverified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore()
+			for _, user := range []domain.User{sender, recipient, unverified, closed} {
+				store.users[user.ID] = user
+			}
+			service := newTransferService(store, tt.requireVerifiedRecipient)
+			ctx := context.Background()
+
+			inquiry, err := service.InquiryTransfer(ctx, sender.ID, tt.phoneNumber, 25_000)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("InquiryTransfer = %v, want %v", err, tt.wantErr)
+			}
+			transfer, err := service.ProcessTransfer(ctx, sender.ID, tt.phoneNumber, 25_000, "lunch", uuid.Nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProcessTransfer = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(store.transactions) != 0 {
+					t.Errorf("refused transfer recorded %d lines", len(store.transactions))
+				}
+				return
+			}
+
+			if inquiry.RecipientPhoneNumber != tt.wantRecipient.PhoneNumber || inquiry.Fee != 1000 || inquiry.Total != 26_000 {
+				t.Errorf("inquiry = %+v", inquiry)
+			}
+			if transfer.Status != domain.TransactionStatusPending || transfer.UserID != sender.ID || transfer.Amount != 25_000 {
+				t.Errorf("transfer = %+v", transfer)
+			}
+		})
+	}
+}
+
+func TestInquiryTransferMasksRecipientName(t *testing.T) {
+	store := newMemStore()
+	sender := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000001", Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	recipient := domain.User{ID: uuid.New(), PhoneNumber: "+6281200000002", FirstName: "Budi", LastName: "Santoso",
+		Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	store.users[sender.ID], store.users[recipient.ID] = sender, recipient
+
+	inquiry, err := newTransferService(store, false).InquiryTransfer(context.Background(), sender.ID, "081200000002", 25_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inquiry.RecipientName != "B**i S*****o" {
+		t.Errorf("recipient name = %q, want B**i S*****o", inquiry.RecipientName)
+	}
+}
+
+func TestGetAllTransactions(t *testing.T) {
+	store := newMemStore()
+	user := domain.User{ID: uuid.New(), Status: domain.UserStatusActive}
+	other := domain.User{ID: uuid.New(), Status: domain.UserStatusActive}
+	store.users[user.ID], store.users[other.ID] = user, other
+	for _, owner := range []uuid.UUID{user.ID, other.ID, user.ID} {
+		line := domain.Transaction{ID: uuid.New(), UserID: owner, Kind: domain.TransactionKindTopUp, Amount: 10_000}
+		store.transactions[line.ID] = line
+	}
+	service := newTransferService(store, false)
+	ctx := context.Background()
+
+	transactions, err := service.GetAllTransactions(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("%d transactions, want the user's 2", len(transactions))
+	}
+	for _, transaction := range transactions {
+		if transaction.UserID != user.ID {
+			t.Errorf("transaction of %s listed", transaction.UserID)
+		}
+	}
+
+	if _, err = service.GetAllTransactions(ctx, uuid.New()); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v, want ErrUserNotFound", err)
+	}
+	user.Status = domain.UserStatusClosed
+	store.users[user.ID] = user
+	if _, err = service.GetAllTransactions(ctx, user.ID); !errors.Is(err, domain.ErrAccountClosed) {
+		t.Errorf("closed account: err = %v, want ErrAccountClosed", err)
+	}
+}
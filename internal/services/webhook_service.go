@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"tahap2/internal/domain"
+	"time"
+)
+
+type WebhookService struct {
+	webhookRepo domain.WebhookRepository
+	logger      *slog.Logger
+}
+
+func NewWebhookService(webhookRepo domain.WebhookRepository, logger *slog.Logger) *WebhookService {
+	return &WebhookService{webhookRepo: webhookRepo, logger: logger}
+}
+
+func (s *WebhookService) CreateEndpoint(ctx context.Context, merchantID *uuid.UUID, rawURL string, events []string) (domain.WebhookEndpoint, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.WebhookEndpoint{}, "", domain.ErrInvalidWebhookURL
+	}
+	if err = checkWebhookHost(ctx, u.Hostname()); err != nil {
+		return domain.WebhookEndpoint{}, "", err
+	}
+	if len(events) == 0 {
+		return domain.WebhookEndpoint{}, "", domain.ErrInvalidWebhookEvents
+	}
+	for _, event := range events {
+		if !slices.Contains(domain.WebhookEventTypes, event) {
+			return domain.WebhookEndpoint{}, "", domain.ErrInvalidWebhookEvents
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return domain.WebhookEndpoint{}, "", err
+	}
+	endpoint := domain.WebhookEndpoint{
+		MerchantID: merchantID,
+		URL:        u.String(),
+		Secret:     "whsec_" + hex.EncodeToString(secret),
+		Events:     slices.Compact(slices.Sorted(slices.Values(events))),
+		Active:     true,
+	}
+	if err = s.webhookRepo.CreateEndpoint(ctx, &endpoint); err != nil {
+		return domain.WebhookEndpoint{}, "", err
+	}
+
+	return endpoint, endpoint.Secret, nil
+}
+
+func (s *WebhookService) GetEndpoints(ctx context.Context, merchantID *uuid.UUID) ([]*domain.WebhookEndpoint, error) {
+	return s.webhookRepo.GetEndpoints(ctx, merchantID)
+}
+
+func (s *WebhookService) DisableEndpoint(ctx context.Context, merchantID *uuid.UUID, endpointID uuid.UUID) error {
+	endpoint, err := s.getEndpoint(ctx, merchantID, endpointID)
+	if err != nil {
+		return err
+	}
+
+	endpoint.Active = false
+	endpoint.UpdatedAt = time.Now()
+	return s.webhookRepo.UpdateEndpoint(ctx, endpoint)
+}
+
+func (s *WebhookService) GetDeliveries(ctx context.Context, merchantID *uuid.UUID, endpointID uuid.UUID, limit, offset int) ([]*domain.WebhookDelivery, error) {
+	if _, err := s.getEndpoint(ctx, merchantID, endpointID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.GetDeliveriesByEndpoint(ctx, endpointID, limit, offset)
+}
+
+func (s *WebhookService) Redeliver(ctx context.Context, merchantID *uuid.UUID, deliveryID uuid.UUID) (domain.WebhookDelivery, error) {
+	original, err := s.webhookRepo.GetDeliveryByID(ctx, deliveryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrDeliveryNotFound
+		}
+		return domain.WebhookDelivery{}, err
+	}
+	endpoint, err := s.getEndpoint(ctx, merchantID, original.EndpointID)
+	if err != nil {
+		if errors.Is(err, domain.ErrWebhookNotFound) {
+			err = domain.ErrDeliveryNotFound
+		}
+		return domain.WebhookDelivery{}, err
+	}
+
+	// the event id stays the same, so receivers can tell it is a repeat.
+	delivery := &domain.WebhookDelivery{
+		EndpointID:    endpoint.ID,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        domain.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+		RedeliveryOf:  &original.ID,
+	}
+	if err = s.webhookRepo.CreateDeliveries(ctx, []*domain.WebhookDelivery{delivery}); err != nil {
+		return domain.WebhookDelivery{}, err
+	}
+	return *delivery, nil
+}
+
+func (s *WebhookService) Publish(ctx context.Context, event domain.WebhookEvent) {
+	endpoints, err := s.webhookRepo.GetEndpointsForEvent(ctx, event.MerchantID)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to look up webhook endpoints", "event_type", event.Type, "error", err)
+		return
+	}
+
+	eventID := uuid.New()
+	now := time.Now()
+	payload, err := json.Marshal(webhookPayload{
+		ID:        eventID,
+		Type:      event.Type,
+		CreatedAt: now.UTC().Format(time.RFC3339),
+		Data:      event.Data,
+	})
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to encode webhook event", "event_type", event.Type, "error", err)
+		return
+	}
+
+	var deliveries []*domain.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event.Type) {
+			continue
+		}
+		deliveries = append(deliveries, &domain.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       eventID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        domain.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	if err = s.webhookRepo.CreateDeliveries(context.WithoutCancel(ctx), deliveries); err != nil {
+		s.logger.ErrorContext(ctx, "failed to queue webhook event", "event_type", event.Type, "error", err)
+	}
+}
+
+// checkWebhookHost refuses a host that resolves to an address webhooks may not be sent to.
+// The dispatcher checks again when it connects, as the name can resolve elsewhere by then.
+func checkWebhookHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return domain.ErrInvalidWebhookURL
+	}
+	for _, addr := range addrs {
+		if !domain.WebhookAddressAllowed(addr) {
+			return domain.ErrWebhookURLNotPublic
+		}
+	}
+	return nil
+}
+
+// getEndpoint loads the endpoint, hiding endpoints of other merchants.
+func (s *WebhookService) getEndpoint(ctx context.Context, merchantID *uuid.UUID, endpointID uuid.UUID) (*domain.WebhookEndpoint, error) {
+	endpoint, err := s.webhookRepo.GetEndpointByID(ctx, endpointID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	if merchantID != nil && (endpoint.MerchantID == nil || *endpoint.MerchantID != *merchantID) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return endpoint, nil
+}
+
+// webhookPayload is the body every webhook is sent with.
+type webhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt string    `json:"created_at"`
+	Data      any       `json:"data"`
+}
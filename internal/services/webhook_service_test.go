@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"tahap2/internal/domain"
+	"testing"
+)
+
+type memWebhookRepo struct {
+	domain.WebhookRepository
+	endpoints []domain.WebhookEndpoint
+}
+
+func (r *memWebhookRepo) CreateEndpoint(ctx context.Context, endpoint *domain.WebhookEndpoint) error {
+	r.endpoints = append(r.endpoints, *endpoint)
+	return nil
+}
+
+func TestCreateEndpointRefusesInternalAddresses(t *testing.T) {
+	repo := &memWebhookRepo{}
+	service := NewWebhookService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	events := []string{domain.WebhookEventTransactionSucceeded}
+
+	for _, url := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://10.1.2.3/hook",
+		"https://172.16.0.1/hook",
+		"https://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://100.100.100.200/hook",
+		"http://0.0.0.0/hook",
+		"http://[::1]/hook",
+		"http://[fe80::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		_, _, err := service.CreateEndpoint(context.Background(), nil, url, events)
+		if !errors.Is(err, domain.ErrWebhookURLNotPublic) {
+			t.Errorf("%s: err = %v", url, err)
+		}
+	}
+
+	for _, url := range []string{"ftp://203.0.113.10/hook", "http:///hook", "http://no-such-host.invalid/hook"} {
+		_, _, err := service.CreateEndpoint(context.Background(), nil, url, events)
+		if !errors.Is(err, domain.ErrInvalidWebhookURL) {
+			t.Errorf("%s: err = %v", url, err)
+		}
+	}
+
+	if len(repo.endpoints) != 0 {
+		t.Fatalf("%d endpoints were registered", len(repo.endpoints))
+	}
+}
+
+func TestCreateEndpointAcceptsPublicAddresses(t *testing.T) {
+	repo := &memWebhookRepo{}
+	service := NewWebhookService(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, url := range []string{"https://203.0.113.10/hook", "http://[2001:db8::10]:8443/hook"} {
+		endpoint, secret, err := service.CreateEndpoint(context.Background(), nil, url,
+			[]string{domain.WebhookEventRefundCreated, domain.WebhookEventTransactionSucceeded, domain.WebhookEventRefundCreated})
+		if err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		if secret == "" || endpoint.Secret != secret {
+			t.Fatalf("%s: secret %q, endpoint secret %q", url, secret, endpoint.Secret)
+		}
+		if len(endpoint.Events) != 2 {
+			t.Fatalf("%s: events = %v", url, endpoint.Events)
+		}
+	}
+}
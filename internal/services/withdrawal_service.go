@@ -0,0 +1,497 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log/slog"
+	"strings"
+	"tahap2/internal/domain"
+	"tahap2/internal/workers"
+	"time"
+)
+
+const (
+	// pendingWithdrawalBatch is how many unsubmitted withdrawals SubmitPending picks up at once.
+	pendingWithdrawalBatch = 50
+	// submitLease is how long a claimed submission may take before the withdrawal is submitted
+	// again. It is well above the provider's timeout, so a submission still in flight isn't
+	// doubled, and a repeat is harmless anyway as providers recognise the external id.
+	submitLease = 2 * time.Minute
+)
+
+type WithdrawalService struct {
+	transactor        domain.Transactor
+	withdrawalRepo    domain.WithdrawalRepository
+	userRepo          domain.UserRepository
+	transactionRepo   domain.TransactionRepository
+	systemAccountRepo domain.SystemAccountRepository
+	feeCalculator     domain.FeeCalculator
+	limitService      domain.LimitService
+	auditService      domain.AuditService
+	provider          domain.DisbursementProvider
+	eventBus          *workers.EventBus
+	logger            *slog.Logger
+}
+
+func NewWithdrawalService(transactor domain.Transactor, withdrawalRepo domain.WithdrawalRepository, userRepo domain.UserRepository,
+	transactionRepo domain.TransactionRepository, systemAccountRepo domain.SystemAccountRepository, feeCalculator domain.FeeCalculator,
+	limitService domain.LimitService, auditService domain.AuditService, provider domain.DisbursementProvider, eventBus *workers.EventBus,
+	logger *slog.Logger) *WithdrawalService {
+	return &WithdrawalService{
+		transactor:        transactor,
+		withdrawalRepo:    withdrawalRepo,
+		userRepo:          userRepo,
+		transactionRepo:   transactionRepo,
+		systemAccountRepo: systemAccountRepo,
+		feeCalculator:     feeCalculator,
+		limitService:      limitService,
+		auditService:      auditService,
+		provider:          provider,
+		eventBus:          eventBus,
+		logger:            logger,
+	}
+}
+
+func (s *WithdrawalService) InquireBankAccount(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (string, error) {
+	bankCode, accountNumber = strings.TrimSpace(bankCode), strings.TrimSpace(accountNumber)
+	if bankCode == "" || accountNumber == "" {
+		return "", domain.ErrBankAccountRequired
+	}
+	if _, err := s.getWithdrawingUser(ctx, userID); err != nil {
+		return "", err
+	}
+
+	return s.provider.InquireAccount(ctx, bankCode, accountNumber)
+}
+
+func (s *WithdrawalService) AddBankAccount(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (domain.BankAccount, error) {
+	accountName, err := s.InquireBankAccount(ctx, userID, bankCode, accountNumber)
+	if err != nil {
+		return domain.BankAccount{}, err
+	}
+	bankCode, accountNumber = strings.TrimSpace(bankCode), strings.TrimSpace(accountNumber)
+
+	saved, err := s.withdrawalRepo.GetBankAccountsByUserID(ctx, userID)
+	if err != nil {
+		return domain.BankAccount{}, err
+	}
+	for _, account := range saved {
+		if account.BankCode == bankCode && account.AccountNumber == accountNumber {
+			return domain.BankAccount{}, domain.ErrBankAccountExists
+		}
+	}
+
+	account := domain.BankAccount{
+		UserID:        userID,
+		BankCode:      bankCode,
+		AccountNumber: accountNumber,
+		AccountName:   accountName,
+	}
+	if err = s.withdrawalRepo.CreateBankAccount(ctx, &account); err != nil {
+		return domain.BankAccount{}, err
+	}
+
+	return account, nil
+}
+
+func (s *WithdrawalService) GetBankAccounts(ctx context.Context, userID uuid.UUID) ([]*domain.BankAccount, error) {
+	return s.withdrawalRepo.GetBankAccountsByUserID(ctx, userID)
+}
+
+func (s *WithdrawalService) RemoveBankAccount(ctx context.Context, userID, bankAccountID uuid.UUID) error {
+	if _, err := s.getBankAccount(ctx, userID, bankAccountID); err != nil {
+		return err
+	}
+	return s.withdrawalRepo.DeleteBankAccount(ctx, bankAccountID)
+}
+
+func (s *WithdrawalService) RequestWithdrawal(ctx context.Context, userID, bankAccountID uuid.UUID, amount int64) (domain.Withdrawal, error) {
+	if amount <= 0 {
+		return domain.Withdrawal{}, domain.ErrInvalidAmount
+	}
+	account, err := s.getBankAccount(ctx, userID, bankAccountID)
+	if err != nil {
+		return domain.Withdrawal{}, err
+	}
+
+	var (
+		withdrawal domain.Withdrawal
+		hold       domain.Transaction
+	)
+	err = s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		user, err := s.userRepo.GetUserByIDForUpdate(ctx, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrUserNotFound
+			}
+			return err
+		}
+		if err = user.CanSendMoney(); err != nil {
+			return err
+		}
+		if !domain.TierAllows(user.Tier, domain.FeatureWithdraw) {
+			return domain.ErrFeatureNotAllowed
+		}
+
+		fee, err := s.feeCalculator.CalculateFee(ctx, domain.TransactionKindWithdrawal, user, amount)
+		if err != nil {
+			return fmt.Errorf("error calculating fee: %w", err)
+		}
+		total, err := domain.AddAmounts(amount, fee)
+		if err != nil {
+			return err
+		}
+		if total > user.Balance {
+			return domain.ErrInsufficientBalance
+		}
+		if err = s.limitService.CheckOutgoing(ctx, user, amount); err != nil {
+			return err
+		}
+
+		// the hold: the money leaves the balance now and the pending line stays until the
+		// provider reports back.
+		balBefore := user.Balance
+		user.Balance -= total
+		if err = s.userRepo.UpdateUser(ctx, user); err != nil {
+			return fmt.Errorf("error updating user: %w", err)
+		}
+		hold = domain.Transaction{
+			Status:          domain.TransactionStatusPending,
+			UserID:          userID,
+			TransactionType: domain.TransactionTypeCredit,
+			Kind:            domain.TransactionKindWithdrawal,
+			Amount:          amount,
+			Fee:             fee,
+			Remark:          "withdrawal to " + account.BankCode + " " + account.AccountNumber,
+			BalanceBefore:   balBefore,
+			BalanceAfter:    user.Balance,
+		}
+		if err = s.transactionRepo.CreateTransaction(ctx, &hold); err != nil {
+			return err
+		}
+
+		withdrawal = domain.Withdrawal{
+			UserID:        userID,
+			BankAccountID: account.ID,
+			BankCode:      account.BankCode,
+			AccountNumber: account.AccountNumber,
+			AccountName:   account.AccountName,
+			Amount:        amount,
+			Fee:           fee,
+			Status:        domain.WithdrawalStatusPending,
+			Provider:      s.provider.Name(),
+			TransactionID: hold.ID,
+		}
+		return s.withdrawalRepo.CreateWithdrawal(ctx, &withdrawal)
+	})
+	if err != nil {
+		return domain.Withdrawal{}, err
+	}
+
+	s.recordWithdrawal(ctx, domain.AuditWithdrawalHeld, &withdrawal, hold)
+
+	// hand the withdrawal to the provider in background.
+	go s.eventBus.Publish(workers.EventTypeWithdrawal, workers.WithdrawalParam{
+		Meta:         workers.NewEventMeta(ctx),
+		WithdrawalID: withdrawal.ID,
+	})
+
+	return withdrawal, nil
+}
+
+func (s *WithdrawalService) GetWithdrawal(ctx context.Context, userID, withdrawalID uuid.UUID) (*domain.Withdrawal, error) {
+	withdrawal, err := s.withdrawalRepo.GetWithdrawalByID(ctx, withdrawalID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrWithdrawalNotFound
+		}
+		return nil, err
+	}
+	if withdrawal.UserID != userID {
+		return nil, domain.ErrWithdrawalNotFound
+	}
+
+	return withdrawal, nil
+}
+
+func (s *WithdrawalService) GetWithdrawals(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*domain.Withdrawal, error) {
+	return s.withdrawalRepo.GetWithdrawalsByUserID(ctx, userID, limit, offset)
+}
+
+// Submit claims a pending withdrawal and sends it to the provider. When the provider can't be
+// reached the withdrawal goes back to pending to be submitted again, which is safe because
+// providers recognise a repeated external id. A claim that was never finished, because the
+// process died or the requeue failed, is taken over once it is older than submitLease.
+func (s *WithdrawalService) Submit(ctx context.Context, withdrawalID uuid.UUID) error {
+	var withdrawal *domain.Withdrawal
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		withdrawal, err = s.withdrawalRepo.GetWithdrawalByIDForUpdate(ctx, withdrawalID)
+		if err != nil {
+			return err
+		}
+		if !withdrawal.IsSubmittable(time.Now().Add(-submitLease)) {
+			withdrawal = nil
+			return nil
+		}
+		withdrawal.Status = domain.WithdrawalStatusProcessing
+		withdrawal.UpdatedAt = time.Now()
+		return s.withdrawalRepo.UpdateWithdrawal(ctx, withdrawal)
+	})
+	if err != nil || withdrawal == nil {
+		return err
+	}
+
+	result, err := s.provider.Disburse(ctx, domain.Disbursement{
+		ExternalID:    withdrawal.ID.String(),
+		BankCode:      withdrawal.BankCode,
+		AccountNumber: withdrawal.AccountNumber,
+		AccountName:   withdrawal.AccountName,
+		Amount:        withdrawal.Amount,
+	})
+	if err != nil {
+		if requeueErr := s.requeue(ctx, withdrawal.ID); requeueErr != nil {
+			s.logger.ErrorContext(ctx, "failed to requeue withdrawal", "withdrawal_id", withdrawal.ID, "error", requeueErr)
+		}
+		return fmt.Errorf("error submitting disbursement: %w", err)
+	}
+
+	return s.settle(ctx, withdrawal.ID, result)
+}
+
+// requeue puts a withdrawal the provider couldn't be asked about back to pending, unless a
+// callback has settled it in the meantime.
+func (s *WithdrawalService) requeue(ctx context.Context, withdrawalID uuid.UUID) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		withdrawal, err := s.withdrawalRepo.GetWithdrawalByIDForUpdate(ctx, withdrawalID)
+		if err != nil {
+			return err
+		}
+		if withdrawal.Status != domain.WithdrawalStatusProcessing || withdrawal.ProviderRef != "" {
+			return nil
+		}
+		withdrawal.Status = domain.WithdrawalStatusPending
+		withdrawal.UpdatedAt = time.Now()
+		return s.withdrawalRepo.UpdateWithdrawal(ctx, withdrawal)
+	})
+}
+
+func (s *WithdrawalService) SubmitPending(ctx context.Context) error {
+	withdrawals, err := s.withdrawalRepo.GetPendingWithdrawals(ctx, time.Now().Add(-submitLease), pendingWithdrawalBatch)
+	if err != nil {
+		return err
+	}
+	for _, withdrawal := range withdrawals {
+		if err = s.Submit(ctx, withdrawal.ID); err != nil {
+			s.logger.ErrorContext(ctx, "failed to submit withdrawal", "withdrawal_id", withdrawal.ID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *WithdrawalService) HandleCallback(ctx context.Context, provider, signature string, body []byte) error {
+	if provider != s.provider.Name() {
+		return domain.ErrUnknownDisbursementProvider
+	}
+	result, err := s.provider.ParseCallback(signature, body)
+	if err != nil {
+		return err
+	}
+	withdrawalID, err := uuid.Parse(result.ExternalID)
+	if err != nil {
+		return domain.ErrWithdrawalNotFound
+	}
+
+	return s.settle(ctx, withdrawalID, result)
+}
+
+// settle records what the provider reported about a withdrawal. A success captures the hold,
+// a failure or timeout releases it back to the wallet, anything else only keeps the provider's
+// reference. Settled withdrawals are left alone, so repeated callbacks are harmless.
+func (s *WithdrawalService) settle(ctx context.Context, withdrawalID uuid.UUID, result domain.DisbursementResult) error {
+	var (
+		withdrawal *domain.Withdrawal
+		hold       *domain.Transaction
+		eventType  string
+	)
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		withdrawal, err = s.withdrawalRepo.GetWithdrawalByIDForUpdate(ctx, withdrawalID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = domain.ErrWithdrawalNotFound
+			}
+			return err
+		}
+		if withdrawal.IsSettled() {
+			return nil
+		}
+		if result.Reference != "" {
+			withdrawal.ProviderRef = result.Reference
+		}
+
+		switch result.Status {
+		case domain.DisbursementStatusSucceeded:
+			hold, err = s.capture(ctx, withdrawal)
+			eventType = domain.AuditWithdrawalCaptured
+		case domain.DisbursementStatusFailed, domain.DisbursementStatusTimeout:
+			reason := result.Reason
+			if reason == "" {
+				reason = "disbursement " + result.Status
+			}
+			hold, err = s.release(ctx, withdrawal, reason)
+			eventType = domain.AuditWithdrawalReleased
+		default:
+			withdrawal.Status = domain.WithdrawalStatusProcessing
+		}
+		if err != nil {
+			return err
+		}
+
+		withdrawal.UpdatedAt = time.Now()
+		return s.withdrawalRepo.UpdateWithdrawal(ctx, withdrawal)
+	})
+	if err != nil {
+		return err
+	}
+
+	if hold != nil {
+		s.recordWithdrawal(ctx, eventType, withdrawal, *hold)
+	}
+	return nil
+}
+
+// capture completes the hold: the pending line succeeds, the fee goes to revenue and the
+// amount is booked on the disbursements account, which is settled against the bank.
+func (s *WithdrawalService) capture(ctx context.Context, withdrawal *domain.Withdrawal) (*domain.Transaction, error) {
+	hold, err := s.transactionRepo.GetTransactionByID(ctx, withdrawal.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading hold: %w", err)
+	}
+	hold.Status = domain.TransactionStatusSuccess
+	if err = s.transactionRepo.UpdateTransaction(ctx, hold); err != nil {
+		return nil, err
+	}
+
+	if hold.Fee > 0 {
+		revenue, err := s.systemAccountRepo.CreditSystemAccount(ctx, domain.SystemAccountFeeRevenue, hold.Fee)
+		if err != nil {
+			return nil, fmt.Errorf("error posting fee: %w", err)
+		}
+		feeLine := domain.NewFeeLine(revenue, *hold)
+		if err = s.transactionRepo.CreateTransaction(ctx, &feeLine); err != nil {
+			return nil, err
+		}
+	}
+
+	disbursements, err := s.systemAccountRepo.CreditSystemAccount(ctx, domain.SystemAccountDisbursements, hold.Amount)
+	if err != nil {
+		return nil, err
+	}
+	payoutLine := domain.Transaction{
+		Status:          domain.TransactionStatusSuccess,
+		UserID:          disbursements.ID,
+		TransactionType: domain.TransactionTypeDebit,
+		Kind:            domain.TransactionKindWithdrawal,
+		Amount:          hold.Amount,
+		Remark:          "withdrawal " + withdrawal.ID.String() + " via " + withdrawal.Provider,
+		BalanceBefore:   disbursements.Balance - hold.Amount,
+		BalanceAfter:    disbursements.Balance,
+		ReferenceID:     &hold.ID,
+	}
+	if err = s.transactionRepo.CreateTransaction(ctx, &payoutLine); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	withdrawal.Status = domain.WithdrawalStatusSucceeded
+	withdrawal.CompletedAt = &now
+	return hold, nil
+}
+
+// release gives the held amount and fee back to the user and fails the pending line.
+func (s *WithdrawalService) release(ctx context.Context, withdrawal *domain.Withdrawal, reason string) (*domain.Transaction, error) {
+	hold, err := s.transactionRepo.GetTransactionByID(ctx, withdrawal.TransactionID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading hold: %w", err)
+	}
+	user, err := s.userRepo.GetUserByIDForUpdate(ctx, withdrawal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := domain.AddAmounts(hold.Amount, hold.Fee)
+	if err != nil {
+		return nil, err
+	}
+	user.Balance, err = domain.AddAmounts(user.Balance, total)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error updating user: %w", err)
+	}
+
+	hold.Status = domain.TransactionStatusFailed
+	if err = s.transactionRepo.UpdateTransaction(ctx, hold); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	withdrawal.Status = domain.WithdrawalStatusFailed
+	withdrawal.FailureReason = reason
+	withdrawal.CompletedAt = &now
+	return hold, nil
+}
+
+// getWithdrawingUser returns the user when they may withdraw at all.
+func (s *WithdrawalService) getWithdrawingUser(ctx context.Context, userID uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if err = user.CanSendMoney(); err != nil {
+		return nil, err
+	}
+	if !domain.TierAllows(user.Tier, domain.FeatureWithdraw) {
+		return nil, domain.ErrFeatureNotAllowed
+	}
+	return user, nil
+}
+
+func (s *WithdrawalService) getBankAccount(ctx context.Context, userID, bankAccountID uuid.UUID) (*domain.BankAccount, error) {
+	account, err := s.withdrawalRepo.GetBankAccountByID(ctx, bankAccountID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = domain.ErrBankAccountNotFound
+		}
+		return nil, err
+	}
+	if account.UserID != userID {
+		return nil, domain.ErrBankAccountNotFound
+	}
+	return account, nil
+}
+
+func (s *WithdrawalService) recordWithdrawal(ctx context.Context, eventType string, withdrawal *domain.Withdrawal, hold domain.Transaction) {
+	after := hold.AuditState()
+	after["withdrawal_id"] = withdrawal.ID.String()
+	after["bank_code"] = withdrawal.BankCode
+	after["account_number"] = withdrawal.AccountNumber
+	if withdrawal.FailureReason != "" {
+		after["failure_reason"] = withdrawal.FailureReason
+	}
+	s.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   eventType,
+		ActorID:     withdrawal.UserID,
+		SubjectType: "withdrawal",
+		SubjectID:   withdrawal.ID.String(),
+		After:       after,
+	})
+}
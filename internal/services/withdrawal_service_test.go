@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"tahap2/internal/domain"
+	"tahap2/internal/gateway"
+	"tahap2/internal/workers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testProvider = "fakebank"
+	testSecret   = "fakebank-secret"
+	testFee      = 2500
+)
+
+type withdrawalFixture struct {
+	store   *memStore
+	service *WithdrawalService
+	user    domain.User
+	// callbacks gets what HandleCallback returned for each callback the provider sent.
+	callbacks chan error
+}
+
+// newWithdrawalFixture wires the service to the fake provider, whose callbacks are posted to
+// an httptest server that hands them to HandleCallback like the real route does.
+func newWithdrawalFixture(t *testing.T) *withdrawalFixture {
+	t.Helper()
+	f := &withdrawalFixture{store: newMemStore(), callbacks: make(chan error, 10)}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := f.service.HandleCallback(r.Context(), testProvider, r.Header.Get(domain.DisbursementHeaderSignature), body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		f.callbacks <- err
+	}))
+	t.Cleanup(server.Close)
+
+	provider := gateway.NewFakeDisbursementProvider(testProvider, testSecret, server.URL, 10*time.Millisecond, logger)
+	f.service = NewWithdrawalService(&memTransactor{}, &memWithdrawalRepo{store: f.store}, &memUserRepo{store: f.store},
+		&memTransactionRepo{store: f.store}, &memSystemAccountRepo{store: f.store}, flatFee(testFee), noLimits{},
+		&memAuditService{store: f.store}, provider, workers.NewEventBus(), logger)
+
+	f.user = domain.User{ID: uuid.New(), Balance: 100_000, Tier: domain.UserTierBasic, Status: domain.UserStatusActive}
+	f.store.users[f.user.ID] = f.user
+	return f
+}
+
+// request holds amount for a withdrawal to accountNumber, whose last digit picks what the
+// fake provider reports.
+func (f *withdrawalFixture) request(t *testing.T, accountNumber string, amount int64) domain.Withdrawal {
+	t.Helper()
+	account := domain.BankAccount{ID: uuid.New(), UserID: f.user.ID, BankCode: "bca", AccountNumber: accountNumber, AccountName: "HOLDER"}
+	f.store.bankAccounts[account.ID] = account
+
+	withdrawal, err := f.service.RequestWithdrawal(context.Background(), f.user.ID, account.ID, amount)
+	if err != nil {
+		t.Fatalf("RequestWithdrawal: %v", err)
+	}
+	return withdrawal
+}
+
+func (f *withdrawalFixture) waitCallback(t *testing.T) {
+	t.Helper()
+	select {
+	case err := <-f.callbacks:
+		if err != nil {
+			t.Fatalf("callback rejected: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback from the provider")
+	}
+}
+
+func (f *withdrawalFixture) withdrawal(id uuid.UUID) domain.Withdrawal {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.withdrawals[id]
+}
+
+func (f *withdrawalFixture) balance() int64 {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.users[f.user.ID].Balance
+}
+
+func (f *withdrawalFixture) holdStatus(withdrawal domain.Withdrawal) string {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	return f.store.transactions[withdrawal.TransactionID].Status
+}
+
+func TestWithdrawalSucceeds(t *testing.T) {
+	f := newWithdrawalFixture(t)
+	withdrawal := f.request(t, "1234567890", 50_000)
+	if got := f.balance(); got != 100_000-50_000-testFee {
+		t.Fatalf("balance after hold = %d", got)
+	}
+
+	if err := f.service.Submit(context.Background(), withdrawal.ID); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if got := f.withdrawal(withdrawal.ID); got.Status != domain.WithdrawalStatusProcessing || got.ProviderRef == "" {
+		t.Fatalf("after submit: status %q, provider ref %q", got.Status, got.ProviderRef)
+	}
+
+	f.waitCallback(t)
+	got := f.withdrawal(withdrawal.ID)
+	if got.Status != domain.WithdrawalStatusSucceeded || got.CompletedAt == nil {
+		t.Fatalf("status = %q, completed at %v", got.Status, got.CompletedAt)
+	}
+	if status := f.holdStatus(got); status != domain.TransactionStatusSuccess {
+		t.Fatalf("hold status = %q", status)
+	}
+	if got := f.balance(); got != 100_000-50_000-testFee {
+		t.Fatalf("balance after capture = %d", got)
+	}
+	if got := f.store.systemAccounts[domain.SystemAccountDisbursements].Balance; got != 50_000 {
+		t.Fatalf("disbursements balance = %d", got)
+	}
+	if got := f.store.systemAccounts[domain.SystemAccountFeeRevenue].Balance; got != testFee {
+		t.Fatalf("fee revenue balance = %d", got)
+	}
+}
+
+func TestWithdrawalFailedReleasesHold(t *testing.T) {
+	for _, accountNumber := range []string{"1234567891", "1234567892"} {
+		f := newWithdrawalFixture(t)
+		withdrawal := f.request(t, accountNumber, 50_000)
+		if err := f.service.Submit(context.Background(), withdrawal.ID); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+
+		f.waitCallback(t)
+		got := f.withdrawal(withdrawal.ID)
+		if got.Status != domain.WithdrawalStatusFailed || got.FailureReason == "" {
+			t.Fatalf("account %s: status %q, failure reason %q", accountNumber, got.Status, got.FailureReason)
+		}
+		if status := f.holdStatus(got); status != domain.TransactionStatusFailed {
+			t.Fatalf("account %s: hold status = %q", accountNumber, status)
+		}
+		if got := f.balance(); got != 100_000 {
+			t.Fatalf("account %s: balance after release = %d", accountNumber, got)
+		}
+	}
+}
+
+func TestSubmitPendingResubmitsInterruptedWithdrawals(t *testing.T) {
+	f := newWithdrawalFixture(t)
+	stale := f.request(t, "1234567890", 10_000)
+	fresh := f.request(t, "2234567890", 10_000)
+
+	// both were claimed and the process died before the provider answered, one long enough
+	// ago for the claim to have lapsed.
+	for id, claimedAt := range map[uuid.UUID]time.Time{
+		stale.ID: time.Now().Add(-submitLease - time.Minute),
+		fresh.ID: time.Now(),
+	} {
+		withdrawal := f.withdrawal(id)
+		withdrawal.Status = domain.WithdrawalStatusProcessing
+		withdrawal.UpdatedAt = claimedAt
+		f.store.withdrawals[id] = withdrawal
+	}
+
+	if err := f.service.SubmitPending(context.Background()); err != nil {
+		t.Fatalf("SubmitPending: %v", err)
+	}
+	if got := f.withdrawal(stale.ID); got.ProviderRef == "" {
+		t.Fatal("the stale claim wasn't submitted again")
+	}
+	if got := f.withdrawal(fresh.ID); got.ProviderRef != "" {
+		t.Fatal("a claim still in its lease was submitted again")
+	}
+
+	f.waitCallback(t)
+	if got := f.withdrawal(stale.ID); got.Status != domain.WithdrawalStatusSucceeded {
+		t.Fatalf("stale withdrawal status = %q", got.Status)
+	}
+}
+
+func TestSubmitSkipsSubmittedWithdrawals(t *testing.T) {
+	f := newWithdrawalFixture(t)
+	withdrawal := f.request(t, "1234567890", 10_000)
+	if err := f.service.Submit(context.Background(), withdrawal.ID); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	ref := f.withdrawal(withdrawal.ID).ProviderRef
+
+	// a lapsed claim that has a reference was accepted by the provider and only waits for
+	// its callback.
+	f.store.mu.Lock()
+	submitted := f.store.withdrawals[withdrawal.ID]
+	submitted.UpdatedAt = time.Now().Add(-submitLease - time.Minute)
+	f.store.withdrawals[withdrawal.ID] = submitted
+	f.store.mu.Unlock()
+	if err := f.service.SubmitPending(context.Background()); err != nil {
+		t.Fatalf("SubmitPending: %v", err)
+	}
+	if got := f.withdrawal(withdrawal.ID).ProviderRef; got != ref {
+		t.Fatalf("provider ref changed from %q to %q", ref, got)
+	}
+	f.waitCallback(t)
+}
+
+func TestWithdrawalCallbackRejectsForgedSignature(t *testing.T) {
+	f := newWithdrawalFixture(t)
+	withdrawal := f.request(t, "1234567890", 10_000)
+
+	body := []byte(`{"external_id":"` + withdrawal.ID.String() + `","reference":"fd_forged","status":"succeeded"}`)
+	for _, signature := range []string{"", gateway.Sign("wrong-secret", body)} {
+		err := f.service.HandleCallback(context.Background(), testProvider, signature, body)
+		if !errors.Is(err, domain.ErrInvalidDisbursementCallback) {
+			t.Fatalf("signature %q: err = %v", signature, err)
+		}
+	}
+	if got := f.withdrawal(withdrawal.ID); got.Status != domain.WithdrawalStatusPending {
+		t.Fatalf("status = %q", got.Status)
+	}
+
+	err := f.service.HandleCallback(context.Background(), "otherbank", gateway.Sign(testSecret, body), body)
+	if !errors.Is(err, domain.ErrUnknownDisbursementProvider) {
+		t.Fatalf("other provider: err = %v", err)
+	}
+}
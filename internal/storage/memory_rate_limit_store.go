@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"tahap2/internal/domain"
+	"time"
+)
+
+// rateLimitSweepEvery is how many takes pass between removals of the buckets that are full
+// again, which would behave the same as a new one.
+const rateLimitSweepEvery = 1024
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// MemoryRateLimitStore keeps the buckets of a single node in memory.
+type MemoryRateLimitStore struct {
+	mutex   sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.takes++
+	if s.takes%rateLimitSweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Limit), last: now}
+		s.buckets[key] = bucket
+	}
+	tokens, result := limit.Take(bucket.tokens, bucket.last, now)
+	bucket.tokens, bucket.last, bucket.full = tokens, now, now.Add(result.ResetAfter)
+	return result, nil
+}
+
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !bucket.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"tahap2/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreKeys(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx, limit := context.Background(), domain.RateLimit{Limit: 2, Period: time.Minute}
+	now := time.Now()
+
+	for _, key := range []string{"login|ip:10.0.0.1", "login|ip:10.0.0.2"} {
+		for i := range limit.Limit {
+			if result, _ := store.Take(ctx, key, limit, now); !result.Allowed {
+				t.Fatalf("%s: request %d refused", key, i+1)
+			}
+		}
+		if result, _ := store.Take(ctx, key, limit, now); result.Allowed {
+			t.Fatalf("%s: request past the limit allowed", key)
+		}
+	}
+	// a key emptying its bucket leaves the others alone.
+	if result, _ := store.Take(ctx, "transfer|ip:10.0.0.1", limit, now); !result.Allowed {
+		t.Error("the bucket of another policy was emptied")
+	}
+	// half the period brings one token back.
+	if result, _ := store.Take(ctx, "login|ip:10.0.0.1", limit, now.Add(30*time.Second)); !result.Allowed {
+		t.Error("refilled bucket refused")
+	}
+}
+
+func TestMemoryRateLimitStoreConcurrent(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx, limit := context.Background(), domain.RateLimit{Limit: 50, Period: time.Hour}
+	now := time.Now()
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, _ := store.Take(ctx, "key", limit, now); result.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := allowed.Load(); got != int64(limit.Limit) {
+		t.Errorf("%d requests allowed, want %d", got, limit.Limit)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	ctx, limit := context.Background(), domain.RateLimit{Limit: 10, Period: time.Minute}
+	now := time.Now()
+
+	store.Take(ctx, "idle", limit, now)
+	for range rateLimitSweepEvery - 2 {
+		store.Take(ctx, "busy", limit, now)
+	}
+	// the sweep runs on this take. The idle bucket is full again 6s after its one request,
+	// the busy one, emptied, takes a minute.
+	store.Take(ctx, "busy", limit, now.Add(30*time.Second))
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("full bucket kept")
+	}
+	// swept, it would have been created full again.
+	if bucket := store.buckets["busy"]; bucket.tokens != 4 {
+		t.Errorf("bucket in use swept, it holds %v tokens instead of 4", bucket.tokens)
+	}
+}
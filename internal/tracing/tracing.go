@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName names the service in every span and is the tracer's instrumentation name.
+const ServiceName = "tahap2"
+
+// Exporters accepted by Setup. The OTLP exporter is configured through the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup installs the global tracer provider exporting to exporter, or one that records
+// nothing when exporter is empty or none. The returned function flushes the spans left and
+// must be called before exiting.
+func Setup(ctx context.Context, exporter string, stdout io.Writer) (func(context.Context) error, error) {
+	var spanExporter sdktrace.SpanExporter
+	switch exporter {
+	case "", ExporterNone:
+		otel.SetTextMapPropagator(propagator())
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		var err error
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(stdout))
+		if err != nil {
+			return nil, err
+		}
+	case ExporterOTLP:
+		var err error
+		spanExporter, err = otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+
+	provider := install(sdktrace.WithBatcher(spanExporter))
+	return provider.Shutdown, nil
+}
+
+// SetupInMemory installs a global tracer provider keeping every span in memory, as soon as
+// it ends, so tests can inspect them.
+func SetupInMemory() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	install(sdktrace.WithSyncer(exporter))
+	return exporter
+}
+
+func install(exporter sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	provider := sdktrace.NewTracerProvider(
+		exporter,
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator())
+	return provider
+}
+
+func propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// Start starts a span named name as a child of the one in ctx, if any. It goes through the
+// global provider every time, so spans started before Setup are not lost either.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(ServiceName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks span as failed with err, if there is one.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// End ends span, marking it as failed with err if there is one.
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
+
+// Inject returns the trace context of ctx in a form that can travel with an event.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns parent carrying the trace context Inject took, so the spans started from
+// it join the trace the event was published in.
+func Extract(parent context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return parent
+	}
+	return otel.GetTextMapPropagator().Extract(parent, propagation.MapCarrier(carrier))
+}
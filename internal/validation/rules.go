@@ -0,0 +1,93 @@
+package validation
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"tahap2/internal/domain"
+	"tahap2/internal/i18n"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	RulePhone  = "phone"
+	RulePIN    = "pin"
+	RuleAmount = "amount"
+	RuleNonNil = "nonnil"
+	RuleRemark = "remark"
+	// RuleAdjustment is an amount that may be negative, to take money out, but not zero.
+	RuleAdjustment = "adjustment"
+)
+
+var (
+	// phonePattern is an E.164 number, a plus and at most 15 digits of which the first isn't 0.
+	// Numbers are checked once normalized, so local forms such as 08… pass too.
+	phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	pinPattern   = regexp.MustCompile(`^[0-9]{6}$`)
+	// remarkPattern allows letters and digits of any script, spaces and common punctuation, so
+	// remarks can't carry markup or control characters into statements and notifications.
+	remarkPattern = regexp.MustCompile(`^[\p{L}\p{M}\p{N} .,:;!?'"()/&@#%+\-_]*$`)
+)
+
+var rules = map[string]validator.Func{
+	RulePhone: func(fl validator.FieldLevel) bool {
+		return phonePattern.MatchString(domain.NormalizePhoneNumber(fl.Field().String()))
+	},
+	RulePIN: func(fl validator.FieldLevel) bool {
+		return pinPattern.MatchString(fl.Field().String())
+	},
+	// amount is a positive amount of at most domain.MaxAmount.
+	RuleAmount: func(fl validator.FieldLevel) bool {
+		amount := fl.Field().Int()
+		return amount > 0 && amount <= domain.MaxAmount
+	},
+	RuleAdjustment: func(fl validator.FieldLevel) bool {
+		amount := fl.Field().Int()
+		return amount != 0 && amount >= -domain.MaxAmount && amount <= domain.MaxAmount
+	},
+	RuleNonNil: func(fl validator.FieldLevel) bool {
+		id, ok := fl.Field().Interface().(uuid.UUID)
+		return ok && id != uuid.Nil
+	},
+	RuleRemark: func(fl validator.FieldLevel) bool {
+		remark := fl.Field().String()
+		return utf8.RuneCountInString(remark) <= domain.MaxRemarkLength && remarkPattern.MatchString(remark)
+	},
+}
+
+// message explains in lang why field broke the rule code.
+func message(lang, field, code, param string, kind reflect.Kind) string {
+	args := i18n.Args{"field": field, "param": param}
+	switch code {
+	case "required", RulePhone, RulePIN, RuleNonNil, "number", "url", "type", "gtfield", "uuid", "duration", "filesize":
+	case RuleAmount:
+		args["min"], args["max"] = i18n.Money(1), i18n.Money(domain.MaxAmount)
+	case RuleAdjustment:
+		args["max"] = i18n.Money(domain.MaxAmount)
+	case RuleRemark:
+		args["count"] = domain.MaxRemarkLength
+	case "min", "max", "len":
+		count, err := strconv.Atoi(param)
+		switch {
+		case err != nil:
+		case kind == reflect.String:
+			code, args["count"] = code+".string", count
+		case kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map:
+			code, args["count"] = code+".items", count
+		}
+	case "oneof":
+		args["values"] = strings.Join(strings.Fields(param), ", ")
+	case "datetime":
+		if param == time.DateOnly {
+			args["param"] = "YYYY-MM-DD"
+		}
+	default:
+		code = "invalid"
+	}
+	return i18n.T(lang, "validation."+code, args)
+}
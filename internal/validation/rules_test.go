@@ -0,0 +1,83 @@
+package validation
+
+import (
+	"errors"
+	"tahap2/internal/domain"
+	"testing"
+)
+
+type ruleRequest struct {
+	PhoneNumber string `json:"phone_number" validate:"omitempty,phone"`
+	Pin         string `json:"pin" validate:"omitempty,pin"`
+	Amount      int64  `json:"amount" validate:"omitempty,amount"`
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		request ruleRequest
+		// field is the field refused with the rule of the same name, empty when the request passes.
+		field, code string
+	}{
+		{name: "local phone", request: ruleRequest{PhoneNumber: "081234567890"}},
+		{name: "phone with country code", request: ruleRequest{PhoneNumber: "6281234567890"}},
+		{name: "e164 phone", request: ruleRequest{PhoneNumber: "+6281234567890"}},
+		{name: "phone with spaces and dashes", request: ruleRequest{PhoneNumber: "0812-3456 7890"}},
+		{name: "foreign phone", request: ruleRequest{PhoneNumber: "+14155550123"}},
+		{name: "phone with letters", request: ruleRequest{PhoneNumber: "0812abc4567"}, field: "phone_number", code: RulePhone},
+		{name: "short phone", request: ruleRequest{PhoneNumber: "081234"}, field: "phone_number", code: RulePhone},
+		{name: "long phone", request: ruleRequest{PhoneNumber: "+6281234567890123"}, field: "phone_number", code: RulePhone},
+		{name: "phone of a plus", request: ruleRequest{PhoneNumber: "+"}, field: "phone_number", code: RulePhone},
+		{name: "phone after zero country code", request: ruleRequest{PhoneNumber: "+0812345678"}, field: "phone_number", code: RulePhone},
+
+		{name: "pin", request: ruleRequest{Pin: "123456"}},
+		{name: "short pin", request: ruleRequest{Pin: "12345"}, field: "pin", code: RulePIN},
+		{name: "long pin", request: ruleRequest{Pin: "1234567"}, field: "pin", code: RulePIN},
+		{name: "pin with letters", request: ruleRequest{Pin: "12a456"}, field: "pin", code: RulePIN},
+		{name: "pin of other digits", request: ruleRequest{Pin: "١٢٣٤٥٦"}, field: "pin", code: RulePIN},
+
+		{name: "smallest amount", request: ruleRequest{Amount: 1}},
+		{name: "largest amount", request: ruleRequest{Amount: domain.MaxAmount}},
+		{name: "negative amount", request: ruleRequest{Amount: -1}, field: "amount", code: RuleAmount},
+		{name: "amount past the maximum", request: ruleRequest{Amount: domain.MaxAmount + 1}, field: "amount", code: RuleAmount},
+	}
+	v := New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate(tt.request)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			var fieldErrs Errors
+			if !errors.As(err, &fieldErrs) || len(fieldErrs) != 1 {
+				t.Fatalf("Validate = %v, want one field error", err)
+			}
+			if got := fieldErrs[0]; got.Field != tt.field || got.Code != tt.code {
+				t.Errorf("refused %s for %s, want %s for %s", got.Field, got.Code, tt.field, tt.code)
+			}
+		})
+	}
+}
+
+// TestRulesRefuseZero checks a missing amount or PIN is refused, the rules are what makes
+// those fields required.
+func TestRulesRefuseZero(t *testing.T) {
+	request := struct {
+		Pin    string `json:"pin" validate:"pin"`
+		Amount int64  `json:"amount" validate:"amount"`
+	}{}
+	var fieldErrs Errors
+	if err := New().Validate(request); !errors.As(err, &fieldErrs) {
+		t.Fatalf("Validate = %v, want field errors", err)
+	}
+	codes := make(map[string]string)
+	for _, fieldErr := range fieldErrs {
+		codes[fieldErr.Field] = fieldErr.Code
+	}
+	if codes["pin"] != RulePIN || codes["amount"] != RuleAmount {
+		t.Errorf("refused %v, want pin and amount", codes)
+	}
+}
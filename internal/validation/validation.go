@@ -0,0 +1,145 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"tahap2/internal/i18n"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is why a single field of a request was refused. Code is the rule that failed,
+// Param what the rule was given, e.g. the maximum for max.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+
+	// kind is the kind of the field, the message depends on it.
+	kind reflect.Kind
+}
+
+// Errors lists every field of a request that was refused.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, ", ")
+}
+
+// Localize returns the errors with their messages in lang.
+func (e Errors) Localize(lang string) Errors {
+	localized := make(Errors, len(e))
+	for i, fieldErr := range e {
+		fieldErr.Message = message(lang, fieldErr.Field, fieldErr.Code, fieldErr.Param, fieldErr.kind)
+		localized[i] = fieldErr
+	}
+	return localized
+}
+
+// Validator checks requests against their validate tags. Fields are reported by their JSON
+// name, or their form name for forms.
+type Validator struct {
+	validate *validator.Validate
+}
+
+func New() *Validator {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	validate.RegisterTagNameFunc(fieldName)
+	for tag, rule := range rules {
+		if err := validate.RegisterValidation(tag, rule); err != nil {
+			panic(fmt.Sprintf("validation: failed to register %s: %v", tag, err))
+		}
+	}
+	return &Validator{validate: validate}
+}
+
+// Validate returns Errors when i breaks any of its rules.
+func (v *Validator) Validate(i any) error {
+	err := v.validate.Struct(i)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	fieldErrs := make(Errors, len(validationErrs))
+	for n, fe := range validationErrs {
+		param := fe.Param()
+		if strings.HasSuffix(fe.Tag(), "field") {
+			// rules such as gtfield are given the Go name of the other field.
+			param = otherFieldName(i, param)
+		}
+		fieldErrs[n] = NewFieldError(fieldPath(fe), fe.Tag(), param, fe.Kind())
+	}
+	return fieldErrs
+}
+
+// otherFieldName is the name the client knows the field called name of the request i by.
+func otherFieldName(i any, name string) string {
+	t := reflect.TypeOf(i)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return name
+	}
+	if field, ok := t.FieldByName(name); ok {
+		return fieldName(field)
+	}
+	return name
+}
+
+// NewFieldError describes the field that broke the rule code in English, kind is the kind
+// of the field.
+func NewFieldError(field, code, param string, kind reflect.Kind) FieldError {
+	return FieldError{
+		Field:   field,
+		Code:    code,
+		Param:   param,
+		Message: message(i18n.English, field, code, param, kind),
+		kind:    kind,
+	}
+}
+
+// Required reports whether field has to be set for its validate tags to pass, either required
+// or a rule that refuses the zero value, unless the field is omitempty.
+func Required(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		switch rule {
+		case "omitempty", "dive":
+			// rules after dive apply to the items, not the field.
+			return false
+		case "required", RulePhone, RulePIN, RuleAmount, RuleAdjustment, RuleNonNil:
+			return true
+		}
+	}
+	return false
+}
+
+// fieldPath is the path of the field below the request, e.g. events[0].
+func fieldPath(fe validator.FieldError) string {
+	_, path, _ := strings.Cut(fe.Namespace(), ".")
+	if path == "" {
+		return fe.Field()
+	}
+	return path
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -1,23 +1,35 @@
 package workers
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/uuid"
 	"sync"
 	"tahap2/internal/domain"
+	"tahap2/internal/tracing"
+	"time"
 )
 
 const (
-	EventTypeTransfer = "transfer"
+	EventTypeTransfer   = "transfer"
+	EventTypeWithdrawal = "withdrawal"
 )
 
+// drainPollInterval is how often Drain looks whether the events were picked up.
+const drainPollInterval = 50 * time.Millisecond
+
 type EventBus struct {
 	subscribers map[string][]chan interface{}
 	mutex       sync.RWMutex
+	// waiting counts the publishers still blocked on a full subscriber channel.
+	waiting      map[string]int
+	waitingMutex sync.Mutex
 }
 
 func NewEventBus() *EventBus {
 	return &EventBus{
 		subscribers: make(map[string][]chan interface{}),
+		waiting:     make(map[string]int),
 	}
 }
 
@@ -33,6 +45,9 @@ func (eb *EventBus) Subscribe(eventType string) chan interface{} {
 
 // Publish an event to all subscribers
 func (eb *EventBus) Publish(eventType string, data interface{}) {
+	eb.addWaiting(eventType, 1)
+	defer eb.addWaiting(eventType, -1)
+
 	eb.mutex.RLock()
 	defer eb.mutex.RUnlock()
 
@@ -43,6 +58,48 @@ func (eb *EventBus) Publish(eventType string, data interface{}) {
 	}
 }
 
+// Depth returns how many events of eventType have been published and not picked up by every
+// subscriber yet, the buffered ones and the ones whose publisher is still waiting.
+func (eb *EventBus) Depth(eventType string) int {
+	eb.waitingMutex.Lock()
+	depth := eb.waiting[eventType]
+	eb.waitingMutex.Unlock()
+
+	eb.mutex.RLock()
+	defer eb.mutex.RUnlock()
+	for _, ch := range eb.subscribers[eventType] {
+		depth += len(ch)
+	}
+	return depth
+}
+
+// Drain waits until every event of eventTypes published so far has been picked up by the
+// subscribers, or until ctx is done.
+func (eb *EventBus) Drain(ctx context.Context, eventTypes ...string) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		depth := 0
+		for _, eventType := range eventTypes {
+			depth += eb.Depth(eventType)
+		}
+		if depth == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d events left: %w", depth, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (eb *EventBus) addWaiting(eventType string, delta int) {
+	eb.waitingMutex.Lock()
+	defer eb.waitingMutex.Unlock()
+	eb.waiting[eventType] += delta
+}
+
 // Close all channels
 func (eb *EventBus) Close() {
 	eb.mutex.Lock()
@@ -55,7 +112,35 @@ func (eb *EventBus) Close() {
 	}
 }
 
+// EventMeta ties the work done for an event to the request that published it.
+type EventMeta struct {
+	RequestID string
+	// TraceContext is the trace the event was published in.
+	TraceContext map[string]string
+}
+
+// NewEventMeta takes the metadata of the request ctx belongs to, if any.
+func NewEventMeta(ctx context.Context) EventMeta {
+	return EventMeta{
+		RequestID:    domain.RequestMetaFrom(ctx).RequestID,
+		TraceContext: tracing.Inject(ctx),
+	}
+}
+
+// Context returns parent carrying the request id and the trace, so logs, audit entries and
+// spans written while handling the event can be correlated with the request.
+func (m EventMeta) Context(parent context.Context) context.Context {
+	ctx := domain.WithRequestMeta(parent, domain.RequestMeta{RequestID: m.RequestID})
+	return tracing.Extract(ctx, m.TraceContext)
+}
+
 type TransferParam struct {
+	Meta         EventMeta
 	TransferInfo domain.Transaction
 	TargetID     uuid.UUID
 }
+
+type WithdrawalParam struct {
+	Meta         EventMeta
+	WithdrawalID uuid.UUID
+}
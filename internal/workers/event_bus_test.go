@@ -0,0 +1,64 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"tahap2/internal/domain"
+	"tahap2/internal/tracing"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEventBusDrain(t *testing.T) {
+	eventBus := NewEventBus()
+	events := eventBus.Subscribe(EventTypeTransfer)
+	eventBus.Publish(EventTypeTransfer, "first")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := eventBus.Drain(ctx, EventTypeTransfer); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain with nobody reading = %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-events
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := eventBus.Drain(ctx, EventTypeTransfer, EventTypeWithdrawal); err != nil {
+		t.Fatalf("Drain = %v", err)
+	}
+}
+
+// TestEventMetaCarriesRequest publishes an event through the bus and checks the subscriber
+// picks up the request id and the trace of the publishing request.
+func TestEventMetaCarriesRequest(t *testing.T) {
+	tracing.SetupInMemory()
+	ctx := domain.WithRequestMeta(context.Background(), domain.RequestMeta{RequestID: "req-1"})
+	ctx, span := tracing.Start(ctx, "publisher")
+	defer span.End()
+
+	eventBus := NewEventBus()
+	events := eventBus.Subscribe(EventTypeTransfer)
+	go eventBus.Publish(EventTypeTransfer, TransferParam{Meta: NewEventMeta(ctx)})
+
+	var param TransferParam
+	select {
+	case event := <-events:
+		param = event.(TransferParam)
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	received := param.Meta.Context(context.Background())
+	if requestID := domain.RequestMetaFrom(received).RequestID; requestID != "req-1" {
+		t.Errorf("request id = %q", requestID)
+	}
+	got, want := trace.SpanContextFromContext(received), span.SpanContext()
+	if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() || !got.IsRemote() {
+		t.Errorf("span context = %+v, want the publisher's %+v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const (
+	defaultLedgerReconcileInterval = time.Hour
+	// defaultStuckTransferAfter leaves the transfer worker plenty of time before a pending
+	// transfer counts as stuck.
+	defaultStuckTransferAfter = 30 * time.Minute
+)
+
+// LedgerReconciler periodically checks that every wallet matches its ledger and opens repair
+// tasks for what doesn't. Runs only read balances, so replicas can run it too.
+type LedgerReconciler struct {
+	reconciliationService domain.ReconciliationService
+	logger                *slog.Logger
+	interval              time.Duration
+	stuckAfter            time.Duration
+}
+
+func NewLedgerReconciler(reconciliationService domain.ReconciliationService, logger *slog.Logger) *LedgerReconciler {
+	return &LedgerReconciler{
+		reconciliationService: reconciliationService,
+		logger:                logger,
+		interval:              defaultLedgerReconcileInterval,
+		stuckAfter:            defaultStuckTransferAfter,
+	}
+}
+
+// StartReconciler runs the reconciler until ctx is cancelled
+func (r *LedgerReconciler) StartReconciler(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		run, err := r.reconciliationService.Run(ctx, domain.ReconciliationOptions{StuckAfter: r.stuckAfter, OpenRepairTasks: true})
+		if err != nil {
+			r.logger.ErrorContext(ctx, "failed to reconcile ledger", "error", err)
+		} else if !run.IsClean() {
+			r.logger.WarnContext(ctx, "ledger reconciliation found issues", "run_id", run.ID, "mismatches", len(run.Mismatches),
+				"stuck_transfers", len(run.StuckTransfers), "repair_tasks_opened", run.RepairTasksOpened)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
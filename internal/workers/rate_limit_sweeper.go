@@ -0,0 +1,48 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const defaultRateLimitSweepInterval = 10 * time.Minute
+
+// RateLimitSweeper deletes the rate limit buckets kept in Postgres once they are full again,
+// every client ever seen would otherwise keep a row. Deleting is idempotent, so replicas can
+// run it too.
+type RateLimitSweeper struct {
+	bucketRepo domain.RateLimitBucketRepository
+	logger     *slog.Logger
+	interval   time.Duration
+}
+
+func NewRateLimitSweeper(bucketRepo domain.RateLimitBucketRepository, logger *slog.Logger) *RateLimitSweeper {
+	return &RateLimitSweeper{
+		bucketRepo: bucketRepo,
+		logger:     logger,
+		interval:   defaultRateLimitSweepInterval,
+	}
+}
+
+// StartSweeper runs the sweeper until ctx is cancelled
+func (s *RateLimitSweeper) StartSweeper(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := s.bucketRepo.DeleteFullBuckets(ctx, time.Now())
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to delete full rate limit buckets", "error", err)
+		} else if deleted > 0 {
+			s.logger.DebugContext(ctx, "deleted full rate limit buckets", "count", deleted)
+		}
+	}
+}
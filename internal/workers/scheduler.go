@@ -0,0 +1,124 @@
+package workers
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log/slog"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const (
+	defaultScheduleInterval  = time.Minute
+	defaultScheduleBatchSize = 100
+	// defaultScheduleRunLease is how long a run may stay running before it is taken as
+	// interrupted and resumed, far longer than a transfer takes to be made.
+	defaultScheduleRunLease = 10 * time.Minute
+)
+
+// TransferScheduler periodically executes due scheduled transfers. Claiming is done with
+// row locks in the repository, so every replica can run its own scheduler.
+type TransferScheduler struct {
+	scheduleRepo domain.ScheduledTransferRepository
+	transService domain.TransactionService
+	logger       *slog.Logger
+	interval     time.Duration
+	batchSize    int
+	runLease     time.Duration
+}
+
+func NewTransferScheduler(scheduleRepo domain.ScheduledTransferRepository, transService domain.TransactionService, logger *slog.Logger) *TransferScheduler {
+	return &TransferScheduler{
+		scheduleRepo: scheduleRepo,
+		transService: transService,
+		logger:       logger,
+		interval:     defaultScheduleInterval,
+		batchSize:    defaultScheduleBatchSize,
+		runLease:     defaultScheduleRunLease,
+	}
+}
+
+// StartScheduler runs the scheduler until ctx is cancelled
+func (s *TransferScheduler) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *TransferScheduler) runDue(ctx context.Context) {
+	s.resumeStale(ctx)
+	for {
+		runs, err := s.scheduleRepo.ClaimDueRuns(ctx, time.Now(), s.batchSize)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to claim scheduled transfers", "error", err)
+			return
+		}
+		for _, run := range runs {
+			// runs claimed but not executed before shutting down are resumed once their
+			// lease runs out.
+			if ctx.Err() != nil {
+				return
+			}
+			s.execute(ctx, run)
+		}
+		if len(runs) < s.batchSize {
+			return
+		}
+	}
+}
+
+// resumeStale executes again the runs a stopped replica left running.
+func (s *TransferScheduler) resumeStale(ctx context.Context) {
+	for {
+		now := time.Now()
+		runs, err := s.scheduleRepo.ClaimStaleRuns(ctx, now.Add(-s.runLease), now, s.batchSize)
+		if err != nil {
+			s.logger.ErrorContext(ctx, "failed to claim interrupted scheduled transfers", "error", err)
+			return
+		}
+		for _, run := range runs {
+			if ctx.Err() != nil {
+				return
+			}
+			s.logger.WarnContext(ctx, "resuming interrupted scheduled transfer", "run_id", run.ID, "schedule_id", run.ScheduleID)
+			s.execute(ctx, run)
+		}
+		if len(runs) < s.batchSize {
+			return
+		}
+	}
+}
+
+func (s *TransferScheduler) execute(ctx context.Context, run *domain.ScheduledTransferRun) {
+	// every run gets its own id, which the transfer worker logs too.
+	ctx = domain.WithRequestMeta(ctx, domain.RequestMeta{RequestID: uuid.NewString()})
+	// the id given when the run was claimed, a resumed run can't make the transfer twice.
+	transactionID := uuid.Nil
+	if run.TransactionID != nil {
+		transactionID = *run.TransactionID
+	}
+	schedule := run.Schedule
+	trans, err := s.transService.ProcessTransfer(ctx, schedule.UserID, schedule.TargetPhoneNumber, schedule.Amount, schedule.Remark, transactionID)
+	if err != nil {
+		run.Status = domain.ScheduleRunStatusFailed
+		run.Error = err.Error()
+		run.TransactionID = nil
+		s.logger.WarnContext(ctx, "scheduled transfer failed", "schedule_id", schedule.ID, "error", err)
+	} else {
+		run.Status = domain.ScheduleRunStatusSuccess
+		run.TransactionID = &trans.ID
+	}
+
+	run.UpdatedAt = time.Now()
+	if err = s.scheduleRepo.UpdateRun(ctx, run); err != nil {
+		s.logger.ErrorContext(ctx, "failed to record scheduled transfer run", "run_id", run.ID, "error", err)
+	}
+}
@@ -0,0 +1,68 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const (
+	defaultArchiveInterval  = time.Hour
+	defaultArchiveBatchSize = 50
+)
+
+// StatementArchiver stores the statements of the month that just ended, so they are ready
+// when users ask for them. It catches up after downtime as long as the month is the last one.
+type StatementArchiver struct {
+	statementService domain.StatementService
+	logger           *slog.Logger
+	interval         time.Duration
+	batchSize        int
+}
+
+func NewStatementArchiver(statementService domain.StatementService, logger *slog.Logger) *StatementArchiver {
+	return &StatementArchiver{
+		statementService: statementService,
+		logger:           logger,
+		interval:         defaultArchiveInterval,
+		batchSize:        defaultArchiveBatchSize,
+	}
+}
+
+// StartArchiver runs the archiver until ctx is cancelled
+func (a *StatementArchiver) StartArchiver(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.archiveLastMonth(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *StatementArchiver) archiveLastMonth(ctx context.Context) {
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+
+	total := 0
+	for ctx.Err() == nil {
+		archived, err := a.statementService.ArchiveMonth(ctx, month, a.batchSize)
+		total += archived
+		if err != nil {
+			a.logger.ErrorContext(ctx, "failed to archive statements", "error", err)
+			break
+		}
+		if archived < a.batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		a.logger.InfoContext(ctx, "archived statements", "count", total, "month", month.Format(domain.StatementMonthLayout))
+	}
+}
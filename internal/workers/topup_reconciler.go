@@ -0,0 +1,57 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"tahap2/internal/domain"
+	"time"
+)
+
+const (
+	defaultReconcileInterval  = 5 * time.Minute
+	defaultReconcileBatchSize = 100
+	// defaultReconcileStaleAfter gives the gateway callback time to arrive before the
+	// gateway is asked about an intent.
+	defaultReconcileStaleAfter = 15 * time.Minute
+)
+
+// TopUpReconciler settles payment intents whose gateway callback never arrived by asking
+// the gateway for their status. Settling locks the intent, so replicas can run it too.
+type TopUpReconciler struct {
+	intentService domain.PaymentIntentService
+	logger        *slog.Logger
+	interval      time.Duration
+	staleAfter    time.Duration
+	batchSize     int
+}
+
+func NewTopUpReconciler(intentService domain.PaymentIntentService, logger *slog.Logger) *TopUpReconciler {
+	return &TopUpReconciler{
+		intentService: intentService,
+		logger:        logger,
+		interval:      defaultReconcileInterval,
+		staleAfter:    defaultReconcileStaleAfter,
+		batchSize:     defaultReconcileBatchSize,
+	}
+}
+
+// StartReconciler runs the reconciler until ctx is cancelled
+func (r *TopUpReconciler) StartReconciler(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		changed, err := r.intentService.Reconcile(ctx, r.staleAfter, r.batchSize)
+		if err != nil {
+			r.logger.ErrorContext(ctx, "failed to reconcile payment intents", "error", err)
+		} else if changed > 0 {
+			r.logger.InfoContext(ctx, "reconciled payment intents", "count", changed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
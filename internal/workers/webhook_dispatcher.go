@@ -0,0 +1,183 @@
+package workers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"tahap2/internal/domain"
+	"tahap2/internal/metrics"
+	"time"
+)
+
+const (
+	defaultWebhookInterval  = 5 * time.Second
+	defaultWebhookBatchSize = 50
+	webhookLease            = 2 * time.Minute
+	webhookTimeout          = 10 * time.Second
+	// webhookBodyLimit caps how much of a receiver's response is read, it is discarded anyway.
+	webhookBodyLimit  = 64 << 10
+	webhookWorkerName = "webhook"
+)
+
+// WebhookDispatcher sends queued webhook deliveries and retries failed ones with backoff.
+// Like the transfer scheduler, claiming happens with row locks so replicas don't collide.
+type WebhookDispatcher struct {
+	webhookRepo domain.WebhookRepository
+	client      *http.Client
+	logger      *slog.Logger
+	interval    time.Duration
+	batchSize   int
+}
+
+func NewWebhookDispatcher(webhookRepo domain.WebhookRepository, logger *slog.Logger) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookRepo: webhookRepo,
+		client:      newWebhookClient(domain.WebhookAddressAllowed),
+		logger:      logger,
+		interval:    defaultWebhookInterval,
+		batchSize:   defaultWebhookBatchSize,
+	}
+}
+
+// newWebhookClient returns the client deliveries are sent with. It only connects to
+// addresses allowed accepts, whatever the endpoint's name resolves to when it is sent and
+// wherever a redirect leads, and never through a proxy.
+func newWebhookClient(allowed func(netip.Addr) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowed(addrPort.Addr()) {
+				return fmt.Errorf("webhook address %s is not allowed", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
+
+// StartDispatcher runs the dispatcher until ctx is cancelled
+func (d *WebhookDispatcher) StartDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.sendDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) sendDue(ctx context.Context) {
+	for {
+		deliveries, err := d.webhookRepo.ClaimDueDeliveries(ctx, time.Now(), webhookLease, d.batchSize)
+		if err != nil {
+			d.logger.ErrorContext(ctx, "failed to claim webhook deliveries", "error", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+		if len(deliveries) < d.batchSize {
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *domain.WebhookDelivery) {
+	endpoint, err := d.webhookRepo.GetEndpointByID(ctx, delivery.EndpointID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		d.logger.ErrorContext(ctx, "failed to load webhook endpoint", "endpoint_id", delivery.EndpointID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	delivery.UpdatedAt = now
+	if endpoint == nil || !endpoint.Active {
+		delivery.Status = domain.WebhookDeliveryFailed
+		d.saveDelivery(ctx, delivery)
+		return
+	}
+
+	delivery.Attempts++
+	attempt := &domain.WebhookAttempt{DeliveryID: delivery.ID, Attempt: delivery.Attempts}
+	attempt.StatusCode, err = d.send(ctx, endpoint, delivery)
+	attempt.DurationMs = time.Since(now).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err = d.webhookRepo.CreateAttempt(ctx, attempt); err != nil {
+		d.logger.ErrorContext(ctx, "failed to record webhook attempt", "delivery_id", delivery.ID, "error", err)
+	}
+
+	switch {
+	case attempt.Error == "":
+		delivered := time.Now()
+		delivery.Status = domain.WebhookDeliverySucceeded
+		delivery.DeliveredAt = &delivered
+		metrics.ObserveWorkerJob(webhookWorkerName, metrics.OutcomeSuccess, now)
+	case delivery.Attempts >= domain.WebhookMaxAttempts:
+		delivery.Status = domain.WebhookDeliveryFailed
+		d.logger.WarnContext(ctx, "webhook delivery gave up", "delivery_id", delivery.ID, "url", endpoint.URL,
+			"attempts", delivery.Attempts, "error", attempt.Error)
+		metrics.ObserveWorkerJob(webhookWorkerName, metrics.OutcomeFailed, now)
+	default:
+		delivery.NextAttemptAt = time.Now().Add(domain.WebhookBackoff(delivery.Attempts))
+		metrics.ObserveWorkerJob(webhookWorkerName, metrics.OutcomeFailed, now)
+		metrics.WorkerRetries.WithLabelValues(webhookWorkerName).Inc()
+	}
+	d.saveDelivery(ctx, delivery)
+}
+
+// send posts the signed payload and treats any 2xx answer as delivered.
+func (d *WebhookDispatcher) send(ctx context.Context, endpoint *domain.WebhookEndpoint, delivery *domain.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tahap2-webhooks/1.0")
+	req.Header.Set(domain.WebhookHeaderID, delivery.EventID.String())
+	req.Header.Set(domain.WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(domain.WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(domain.WebhookHeaderSignature, domain.SignWebhook(endpoint.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookBodyLimit))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *WebhookDispatcher) saveDelivery(ctx context.Context, delivery *domain.WebhookDelivery) {
+	if err := d.webhookRepo.UpdateDelivery(ctx, delivery); err != nil {
+		d.logger.ErrorContext(ctx, "failed to update webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
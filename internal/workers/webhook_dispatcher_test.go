@@ -0,0 +1,190 @@
+package workers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"tahap2/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memWebhookRepo struct {
+	domain.WebhookRepository
+	mu        sync.Mutex
+	endpoints map[uuid.UUID]*domain.WebhookEndpoint
+	attempts  []domain.WebhookAttempt
+	saved     []domain.WebhookDelivery
+}
+
+func (r *memWebhookRepo) GetEndpointByID(ctx context.Context, id uuid.UUID) (*domain.WebhookEndpoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.endpoints[id], nil
+}
+
+func (r *memWebhookRepo) CreateAttempt(ctx context.Context, attempt *domain.WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, *attempt)
+	return nil
+}
+
+func (r *memWebhookRepo) UpdateDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved = append(r.saved, *delivery)
+	return nil
+}
+
+// receiver is a webhook receiver that answers the statuses in order, then 200.
+type receiver struct {
+	t        *testing.T
+	secret   string
+	mu       sync.Mutex
+	statuses []int
+	received int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !domain.VerifyWebhook(rc.secret, r.Header.Get(domain.WebhookHeaderSignature), r.Header.Get(domain.WebhookHeaderTimestamp), body, 5*time.Minute) {
+		rc.t.Errorf("bad signature %q", r.Header.Get(domain.WebhookHeaderSignature))
+	}
+	if r.Header.Get(domain.WebhookHeaderEvent) != domain.WebhookEventTransactionSucceeded || r.Header.Get(domain.WebhookHeaderID) == "" {
+		rc.t.Errorf("headers = %v", r.Header)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.received++
+	if len(rc.statuses) > 0 {
+		w.WriteHeader(rc.statuses[0])
+		rc.statuses = rc.statuses[1:]
+	}
+}
+
+// newTestDispatcher returns a dispatcher sending to an endpoint at url. allowed stands in for
+// the address check, which would refuse the loopback address httptest listens on.
+func newTestDispatcher(url string, allowed func(netip.Addr) bool) (*WebhookDispatcher, *memWebhookRepo, *domain.WebhookDelivery) {
+	endpoint := &domain.WebhookEndpoint{ID: uuid.New(), URL: url, Secret: "whsec_test", Active: true,
+		Events: []string{domain.WebhookEventTransactionSucceeded}}
+	repo := &memWebhookRepo{endpoints: map[uuid.UUID]*domain.WebhookEndpoint{endpoint.ID: endpoint}}
+	dispatcher := NewWebhookDispatcher(repo, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if allowed != nil {
+		dispatcher.client = newWebhookClient(allowed)
+	}
+
+	delivery := &domain.WebhookDelivery{
+		ID:            uuid.New(),
+		EndpointID:    endpoint.ID,
+		EventID:       uuid.New(),
+		EventType:     domain.WebhookEventTransactionSucceeded,
+		Payload:       `{"type":"transaction.succeeded"}`,
+		Status:        domain.WebhookDeliveryPending,
+		NextAttemptAt: time.Now(),
+	}
+	return dispatcher, repo, delivery
+}
+
+func allowAll(netip.Addr) bool { return true }
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	rc := &receiver{t: t, secret: "whsec_test"}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	dispatcher, repo, delivery := newTestDispatcher(server.URL, allowAll)
+
+	dispatcher.deliver(context.Background(), delivery)
+	if delivery.Status != domain.WebhookDeliverySucceeded || delivery.DeliveredAt == nil || delivery.Attempts != 1 {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+	if rc.received != 1 || len(repo.attempts) != 1 || repo.attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("received %d, attempts %+v", rc.received, repo.attempts)
+	}
+}
+
+func TestWebhookDeliveryRetriesWithBackoff(t *testing.T) {
+	rc := &receiver{t: t, secret: "whsec_test", statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	dispatcher, repo, delivery := newTestDispatcher(server.URL, allowAll)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now()
+		dispatcher.deliver(context.Background(), delivery)
+		if delivery.Status != domain.WebhookDeliveryPending || delivery.Attempts != attempt {
+			t.Fatalf("attempt %d: status %q, attempts %d", attempt, delivery.Status, delivery.Attempts)
+		}
+		backoff := delivery.NextAttemptAt.Sub(before)
+		if want := domain.WebhookBackoff(attempt); backoff < want || backoff > want+time.Second {
+			t.Fatalf("attempt %d: next attempt in %s, want %s", attempt, backoff, want)
+		}
+		if got := repo.attempts[attempt-1]; got.Error == "" || got.StatusCode == http.StatusOK {
+			t.Fatalf("attempt %d recorded as %+v", attempt, got)
+		}
+	}
+
+	dispatcher.deliver(context.Background(), delivery)
+	if delivery.Status != domain.WebhookDeliverySucceeded || delivery.Attempts != 3 {
+		t.Fatalf("third attempt: status %q, attempts %d", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	statuses := make([]int, domain.WebhookMaxAttempts)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	rc := &receiver{t: t, secret: "whsec_test", statuses: statuses}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	dispatcher, _, delivery := newTestDispatcher(server.URL, allowAll)
+
+	for range domain.WebhookMaxAttempts {
+		dispatcher.deliver(context.Background(), delivery)
+	}
+	if delivery.Status != domain.WebhookDeliveryFailed || delivery.Attempts != domain.WebhookMaxAttempts {
+		t.Fatalf("status %q, attempts %d", delivery.Status, delivery.Attempts)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for n, want := range map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		10: 256 * time.Minute,
+		11: 6 * time.Hour,
+		50: 6 * time.Hour,
+	} {
+		if got := domain.WebhookBackoff(n); got != want {
+			t.Errorf("WebhookBackoff(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
+
+// The endpoint's name may resolve to an internal address only after it was registered, or
+// redirect there, so the dispatcher refuses such addresses when it connects.
+func TestWebhookDispatcherRefusesInternalAddresses(t *testing.T) {
+	rc := &receiver{t: t, secret: "whsec_test"}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	dispatcher, repo, delivery := newTestDispatcher(server.URL, nil)
+
+	dispatcher.deliver(context.Background(), delivery)
+	if delivery.Status != domain.WebhookDeliveryPending || len(repo.attempts) != 1 ||
+		!strings.Contains(repo.attempts[0].Error, "not allowed") {
+		t.Fatalf("status %q, attempts %+v", delivery.Status, repo.attempts)
+	}
+	if rc.received != 0 {
+		t.Fatalf("the receiver got %d requests", rc.received)
+	}
+}
@@ -0,0 +1,80 @@
+package workers
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log/slog"
+	"tahap2/internal/domain"
+	"tahap2/internal/metrics"
+	"time"
+)
+
+// defaultWithdrawalRetryInterval is how often withdrawals still waiting to be submitted are
+// picked up again.
+const defaultWithdrawalRetryInterval = time.Minute
+
+const withdrawalWorkerName = "withdrawal"
+
+// WithdrawalWorker submits requested withdrawals to the disbursement provider. New ones
+// arrive through the event bus, the ones whose event got lost or whose submission failed
+// are retried on a ticker.
+type WithdrawalWorker struct {
+	eventBus          *EventBus
+	withdrawalService domain.WithdrawalService
+	logger            *slog.Logger
+	retryInterval     time.Duration
+}
+
+func NewWithdrawalWorker(eventBus *EventBus, withdrawalService domain.WithdrawalService, logger *slog.Logger) *WithdrawalWorker {
+	return &WithdrawalWorker{
+		eventBus:          eventBus,
+		withdrawalService: withdrawalService,
+		logger:            logger,
+		retryInterval:     defaultWithdrawalRetryInterval,
+	}
+}
+
+// StartWorker runs the worker until ctx is cancelled
+func (w *WithdrawalWorker) StartWorker(ctx context.Context) {
+	eventChan := w.eventBus.Subscribe(EventTypeWithdrawal)
+	ticker := time.NewTicker(w.retryInterval)
+	defer ticker.Stop()
+
+	w.submitPending(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-eventChan:
+			if !ok {
+				return
+			}
+			param, ok := event.(WithdrawalParam)
+			if !ok {
+				w.logger.ErrorContext(ctx, "invalid event received", "event_type", EventTypeWithdrawal)
+				continue
+			}
+			w.submit(param.Meta.Context(ctx), param.WithdrawalID)
+		case <-ticker.C:
+			w.submitPending(ctx)
+		}
+	}
+}
+
+func (w *WithdrawalWorker) submit(ctx context.Context, withdrawalID uuid.UUID) {
+	start := time.Now()
+	if err := w.withdrawalService.Submit(ctx, withdrawalID); err != nil {
+		w.logger.ErrorContext(ctx, "failed to submit withdrawal", "withdrawal_id", withdrawalID, "error", err)
+		// the withdrawal is put back to pending and picked up again on the ticker.
+		metrics.ObserveWorkerJob(withdrawalWorkerName, metrics.OutcomeFailed, start)
+		metrics.WorkerRetries.WithLabelValues(withdrawalWorkerName).Inc()
+		return
+	}
+	metrics.ObserveWorkerJob(withdrawalWorkerName, metrics.OutcomeSuccess, start)
+}
+
+func (w *WithdrawalWorker) submitPending(ctx context.Context) {
+	if err := w.withdrawalService.SubmitPending(ctx); err != nil {
+		w.logger.ErrorContext(ctx, "failed to submit pending withdrawals", "error", err)
+	}
+}
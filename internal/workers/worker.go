@@ -1,75 +1,249 @@
 package workers
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"log/slog"
 	"tahap2/internal/domain"
+	"tahap2/internal/health"
+	"tahap2/internal/metrics"
+	"tahap2/internal/tracing"
+	"time"
+)
+
+const (
+	transferWorkerName = "transfer"
+	// heartbeatInterval is how often idle workers show they are still running.
+	heartbeatInterval = 10 * time.Second
 )
 
 type TransactionWorker struct {
-	eventBus        *EventBus
-	userRepository  domain.UserRepository
-	transactionRepo domain.TransactionRepository
+	eventBus          *EventBus
+	transactor        domain.Transactor
+	userRepository    domain.UserRepository
+	transactionRepo   domain.TransactionRepository
+	systemAccountRepo domain.SystemAccountRepository
+	limitService      domain.LimitService
+	auditService      domain.AuditService
+	webhookService    domain.WebhookService
+	logger            *slog.Logger
+	heartbeat         health.Heartbeat
+}
+
+func NewTransactionWorker(eventBus *EventBus, transactor domain.Transactor, userRepo domain.UserRepository,
+	transactionRepo domain.TransactionRepository, systemAccountRepo domain.SystemAccountRepository, limitService domain.LimitService,
+	auditService domain.AuditService, webhookService domain.WebhookService, logger *slog.Logger) *TransactionWorker {
+	return &TransactionWorker{
+		eventBus:          eventBus,
+		transactor:        transactor,
+		userRepository:    userRepo,
+		transactionRepo:   transactionRepo,
+		systemAccountRepo: systemAccountRepo,
+		limitService:      limitService,
+		auditService:      auditService,
+		webhookService:    webhookService,
+		logger:            logger,
+	}
 }
 
-func NewTransactionWorker(eventBus *EventBus, userRepo domain.UserRepository, transactionRepo domain.TransactionRepository) *TransactionWorker {
-	return &TransactionWorker{eventBus, userRepo, transactionRepo}
+// Check fails when the worker has stopped or has been stuck on a single transfer for several
+// heartbeats.
+func (w *TransactionWorker) Check() health.Check {
+	return w.heartbeat.Check(3 * heartbeatInterval)
 }
 
-// StartWorker Starts listening for transaction events
-func (w *TransactionWorker) StartWorker() {
+// StartWorker listens for transaction events until ctx is cancelled
+func (w *TransactionWorker) StartWorker(ctx context.Context) {
 	eventChan := w.eventBus.Subscribe(EventTypeTransfer)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
 
-	go func() {
-		for event := range eventChan {
+	for {
+		w.heartbeat.Beat()
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-eventChan:
+			if !ok {
+				return
+			}
 			trans, ok := event.(TransferParam)
 			if !ok {
-				log.Println("Invalid event received")
+				w.logger.Error("invalid event received", "event_type", EventTypeTransfer)
 				continue
 			}
 			w.processTransfer(trans)
+		case <-ticker.C:
 		}
-	}()
+	}
 }
 
 func (w *TransactionWorker) processTransfer(trans TransferParam) {
-	sender, err := w.userRepository.GetUserByID(trans.TransferInfo.UserID)
+	// carries the id of the request that created the transfer into logs and audit entries.
+	ctx := trans.Meta.Context(context.Background())
+	ctx, span := tracing.Start(ctx, "TransactionWorker.processTransfer",
+		attribute.String("transaction.id", trans.TransferInfo.ID.String()))
+	defer span.End()
+	start := time.Now()
+	var (
+		settled   *domain.Transaction
+		eventType string
+	)
+	err := w.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// the lock keeps a transfer published twice from being settled twice.
+		transInfo, err := w.transactionRepo.GetTransactionByIDForUpdate(ctx, trans.TransferInfo.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get transaction info: %w", err)
+		}
+		if transInfo.Status != domain.TransactionStatusPending {
+			return nil
+		}
+
+		sender, target, err := LockTransferParties(ctx, w.userRepository, transInfo.UserID, trans.TargetID)
+		if err != nil {
+			return err
+		}
+
+		total, err := domain.AddAmounts(transInfo.Amount, transInfo.Fee)
+		if err != nil {
+			return err
+		}
+		// things may have changed between the request and this worker picking it up.
+		// Fail before anything is debited.
+		if err = w.checkSettleable(ctx, sender, target, total, transInfo.Amount); err != nil {
+			w.logger.WarnContext(ctx, "transfer rejected", "transaction_id", transInfo.ID, "error", err)
+			transInfo.Status = domain.TransactionStatusFailed
+			settled, eventType = transInfo, domain.AuditTransferRejected
+			return w.transactionRepo.UpdateTransaction(ctx, transInfo)
+		}
+
+		// the balances given when the transfer was requested are stale if the sender's wallet
+		// moved since, the line records the wallet it was actually taken from.
+		transInfo.BalanceBefore = sender.Balance
+		sender.Balance -= total
+		transInfo.BalanceAfter = sender.Balance
+		err = w.userRepository.UpdateUser(ctx, sender)
+		if err != nil {
+			return fmt.Errorf("user update error: %w", err)
+		}
+
+		targetBefore := target.Balance
+		target.Balance += transInfo.Amount
+		err = w.userRepository.UpdateUser(ctx, target)
+		if err != nil {
+			return fmt.Errorf("target user update error: %w", err)
+		}
+		// the recipient's own line, so its wallet can be rebuilt from the ledger.
+		targetLine := domain.Transaction{
+			Status:          domain.TransactionStatusSuccess,
+			UserID:          target.ID,
+			TransactionType: domain.TransactionTypeDebit,
+			Kind:            domain.TransactionKindTransfer,
+			Amount:          transInfo.Amount,
+			Remark:          transInfo.Remark,
+			BalanceBefore:   targetBefore,
+			BalanceAfter:    target.Balance,
+			ReferenceID:     &transInfo.ID,
+		}
+		if err = w.transactionRepo.CreateTransaction(ctx, &targetLine); err != nil {
+			return fmt.Errorf("failed to record target line: %w", err)
+		}
+
+		if transInfo.Fee > 0 {
+			revenue, err := w.systemAccountRepo.CreditSystemAccount(ctx, domain.SystemAccountFeeRevenue, transInfo.Fee)
+			if err != nil {
+				return fmt.Errorf("failed to post fee: %w", err)
+			}
+			feeLine := domain.NewFeeLine(revenue, *transInfo)
+			if err = w.transactionRepo.CreateTransaction(ctx, &feeLine); err != nil {
+				return fmt.Errorf("failed to post fee: %w", err)
+			}
+		}
+
+		transInfo.Status = domain.TransactionStatusSuccess
+		err = w.transactionRepo.UpdateTransaction(ctx, transInfo)
+		if err != nil {
+			return fmt.Errorf("failed to update transaction info: %w", err)
+		}
+		settled, eventType = transInfo, domain.AuditTransferSettled
+		return nil
+	})
 	if err != nil {
-		log.Printf("User not found: %v", err)
+		w.logger.ErrorContext(ctx, "transfer failed", "transaction_id", trans.TransferInfo.ID, "error", err)
+		tracing.RecordError(span, err)
+		metrics.ObserveWorkerJob(transferWorkerName, metrics.OutcomeFailed, start)
 		return
 	}
-
-	target, err := w.userRepository.GetUserByID(trans.TargetID)
-	if err != nil {
-		log.Printf("Target user not found: %v", err)
+	if settled == nil {
+		w.logger.DebugContext(ctx, "transfer already settled", "transaction_id", trans.TransferInfo.ID)
 		return
 	}
+	w.logger.InfoContext(ctx, "transfer processed", "transaction_id", settled.ID, "status", settled.Status)
+	span.SetAttributes(attribute.String("transaction.status", settled.Status))
+	if eventType == domain.AuditTransferRejected {
+		metrics.ObserveWorkerJob(transferWorkerName, metrics.OutcomeRejected, start)
+	} else {
+		metrics.ObserveWorkerJob(transferWorkerName, metrics.OutcomeSuccess, start)
+		metrics.RecordMoneyMovement(domain.TransactionKindTransfer, settled.Amount)
+	}
 
-	sender.Balance -= trans.TransferInfo.Amount
-	err = w.userRepository.UpdateUser(context.TODO(), sender)
-	if err != nil {
-		log.Printf("User update error: %v", err)
-		return
+	after := settled.AuditState()
+	after["target_id"] = trans.TargetID.String()
+	w.auditService.Record(ctx, domain.AuditEntry{
+		EventType:   eventType,
+		ActorID:     settled.UserID,
+		SubjectType: "transaction",
+		SubjectID:   settled.ID.String(),
+		After:       after,
+	})
+
+	webhookEvent := domain.WebhookEventTransactionSucceeded
+	if eventType == domain.AuditTransferRejected {
+		webhookEvent = domain.WebhookEventTransactionFailed
 	}
+	w.webhookService.Publish(ctx, domain.WebhookEvent{
+		Type: webhookEvent,
+		Data: domain.NewWebhookTransaction(*settled),
+	})
+}
 
-	target.Balance += trans.TransferInfo.Amount
-	err = w.userRepository.UpdateUser(context.TODO(), target)
-	if err != nil {
-		log.Printf("Target user update error: %v", err)
-		return
+// checkSettleable returns why the transfer can't be settled anymore, if anything.
+func (w *TransactionWorker) checkSettleable(ctx context.Context, sender, target *domain.User, total, amount int64) error {
+	if err := sender.CanSendMoney(); err != nil {
+		return err
 	}
+	if target.IsClosed() {
+		return domain.ErrRecipientClosed
+	}
+	if sender.Balance < total {
+		return domain.ErrInsufficientBalance
+	}
+	return w.limitService.CheckIncoming(ctx, target, amount)
+}
 
-	transInfo, err := w.transactionRepo.GetTransactionByID(context.Background(), trans.TransferInfo.ID)
+// LockTransferParties locks both accounts of a transfer, always in the same order so two
+// opposite transfers between the same users can't deadlock.
+func LockTransferParties(ctx context.Context, userRepo domain.UserRepository, senderID, targetID uuid.UUID) (*domain.User, *domain.User, error) {
+	firstID, secondID := senderID, targetID
+	swapped := bytes.Compare(firstID[:], secondID[:]) > 0
+	if swapped {
+		firstID, secondID = secondID, firstID
+	}
+
+	first, err := userRepo.GetUserByIDForUpdate(ctx, firstID)
 	if err != nil {
-		log.Printf("Failed to get transaction info: %v", err)
-		return
+		return nil, nil, fmt.Errorf("failed to lock user %s: %w", firstID, err)
 	}
-	transInfo.Status = "success"
-	err = w.transactionRepo.UpdateTransaction(context.TODO(), transInfo)
+	second, err := userRepo.GetUserByIDForUpdate(ctx, secondID)
 	if err != nil {
-		log.Printf("Failed to update transaction info: %v", err)
-		return
+		return nil, nil, fmt.Errorf("failed to lock user %s: %w", secondID, err)
 	}
 
-	log.Printf("Transaction info %s updated!", transInfo.ID)
+	if swapped {
+		return second, first, nil
+	}
+	return first, second, nil
 }